@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/LinPr/s6cmd/strutil"
 	"github.com/go-playground/validator/v10"
@@ -121,7 +122,7 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 			return err
 		}
 		msg := bucketVersionMessage{Bucket: src.Bucket, Status: status, IsSet: true}
-		fmt.Fprintln(out, msg.String())
+		log.Fprint(out, o.common.Output == "json", msg)
 		return nil
 	}
 
@@ -130,7 +131,7 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 		return err
 	}
 	msg := bucketVersionMessage{Bucket: src.Bucket, Status: status, IsSet: false}
-	fmt.Fprintln(out, msg.String())
+	log.Fprint(out, o.common.Output == "json", msg)
 	return nil
 }
 
@@ -155,5 +156,5 @@ func (v bucketVersionMessage) String() string {
 }
 
 func (v bucketVersionMessage) JSON() string {
-	return strutil.JSON(v)
+	return strutil.SchemaJSON("bucket-version/v1", v)
 }
@@ -11,6 +11,12 @@
 //	src local,  dst remote   -> TransferSpec.Upload   (manager.Uploader)
 //	src local,  dst local    -> local copy via the filesystem store
 //
+// With several destinations (`cp src dst1 dst2 ...` or --to) every object
+// is fanned out instead: a local file is read once and uploaded to all
+// destinations concurrently (TransferSpec.UploadFanOut), and a remote
+// object is copied to the first destination and server-side from there to
+// the rest (TransferSpec.CopyFanOut).
+//
 // The transfer primitives live in internal/cliutil so mv shares the exact
 // same metadata/exclude/concurrency plumbing.
 //
@@ -21,13 +27,17 @@ package cp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
 	"github.com/LinPr/s6cmd/internal/parallel"
 	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/internal/report"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -40,10 +50,10 @@ import (
 func NewCpCmd() *cobra.Command {
 	o := newOptions()
 	cmd := cobra.Command{
-		Use:     "cp [flags] <source> <destination>",
+		Use:     "cp [flags] <source> <destination>...",
 		Short:   "copy file or files from source to destination",
 		Example: cp_examples,
-		Args:    cobra.ExactArgs(2),
+		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := o.complete(cmd, args); err != nil {
 				return err
@@ -65,8 +75,22 @@ func NewCpCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&o.IfSizeDiffer, "if-size-differ", "s", false, "only overwrite destination if size differs")
 	cmd.Flags().BoolVarP(&o.IfSourceNewer, "if-source-newer", "u", false, "only overwrite destination if source modtime is newer")
 	cmd.Flags().StringVar(&o.VersionID, "version-id", "", "use the specified version of an object")
-	cmd.Flags().BoolVar(&o.ShowProgress, "show-progress", false, "show a progress bar on stderr (only when stderr is a terminal; applies to uploads/downloads)")
 	cmd.Flags().BoolVar(&o.Recursive, "recursive", false, "copy prefix/bucket/directory sources recursively (required for such sources)")
+	cmd.Flags().StringArrayVar(&o.To, "to", nil, "additional s3:// destination; may be repeated to copy to several destinations at once")
+
+	// --task-retries / --task-retry-backoff / --failed-out, shared with
+	// mv, rm and sync.
+	o.Failures.AddToCmd(&cmd)
+	// --report / --report-format, shared with mv, rm, sync, put and get.
+	o.Report.AddToCmd(&cmd)
+	// --show-progress / --show-transfers, shared with mv, sync, put and
+	// get.
+	o.Progress.AddToCmd(&cmd)
+	// --inventory, shared with ls, du, find, rm and sync.
+	o.Inventory.AddToCmd(&cmd)
+	// --from-listing / --check-listing, shared with du, find, rm and
+	// sync.
+	o.Listing.AddToCmd(&cmd)
 
 	// Shared flags: --concurrency, --part-size, --acl, --metadata, ...
 	o.Shared.AddToCmd(&cmd)
@@ -91,8 +115,24 @@ type Flags struct {
 	IfSizeDiffer  bool
 	IfSourceNewer bool
 	VersionID     string
-	ShowProgress  bool
 	Recursive     bool
+	// To holds the destinations after the first, from extra positional
+	// arguments and --to.
+	To []string
+	// Failures retries transient task failures and records the rest
+	// (see cliutil.FailureFlags).
+	Failures cliutil.FailureFlags
+	// Report writes the outcome of every object to --report (see
+	// cliutil.ReportFlags).
+	Report cliutil.ReportFlags
+	// Progress shows the progress on stderr (see cliutil.ProgressFlags).
+	Progress cliutil.ProgressFlags
+	// Inventory lists a remote source from an S3 Inventory report (see
+	// cliutil.InventoryFlags).
+	Inventory cliutil.InventoryFlags
+	// Listing takes a remote source from a listing saved by ls (see
+	// cliutil.ListingFlags).
+	Listing cliutil.ListingFlags
 
 	// CommonFlags holds the global flags inherited from the parent
 	// command (endpoint, region, profile, ...). It is populated in
@@ -113,9 +153,12 @@ func newOptions() *Options {
 }
 
 func (o *Options) complete(cmd *cobra.Command, args []string) error {
-	if len(args) >= 2 {
+	if len(args) >= 1 {
 		o.SrcUri = args[0]
-		o.DestUri = args[1]
+	}
+	dsts := append(append([]string(nil), args[min(len(args), 1):]...), o.To...)
+	if len(dsts) > 0 {
+		o.DestUri, o.To = dsts[0], dsts[1:]
 	}
 	o.CommonFlags = cliutil.LoadParentFlags(cmd)
 	// Propagate --dry-run into the store constructors so every mutating
@@ -126,13 +169,19 @@ func (o *Options) complete(cmd *cobra.Command, args []string) error {
 }
 
 func (o *Options) validate() error {
+	if o.SrcUri != "" && o.DestUri == "" {
+		return fmt.Errorf("cp requires a destination argument or --to")
+	}
 	if err := validator.New().Struct(o.Args); err != nil {
 		return err
 	}
 	if err := o.Shared.ValidateMetadataDirective(); err != nil {
 		return err
 	}
-	return nil
+	if err := o.Failures.Validate(); err != nil {
+		return err
+	}
+	return o.Report.Validate()
 }
 
 // spec bundles the per-invocation transfer knobs for cliutil's shared
@@ -150,24 +199,38 @@ func (o *Options) spec() *cliutil.TransferSpec {
 }
 
 func (o *Options) run(ctx context.Context) error {
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+	err := o.copy(ctx)
+	// copy returns once every task finished, so the report is complete.
+	if cerr := o.Report.Close(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+func (o *Options) copy(ctx context.Context) error {
 	store, err := cliutil.NewStorage(ctx, o.CommonFlags)
 	if err != nil {
 		return err
 	}
 
-	srcURL, err := storage.NewStorageURL(o.SrcUri, storage.WithVersion(o.VersionID), storage.WithRaw(o.Shared.Raw))
+	srcURL, err := storage.NewStorageURL(o.SrcUri, storage.WithVersion(o.VersionID), storage.WithRaw(o.Shared.Raw), storage.WithIgnoreFiles(o.Shared.Ignore.Files()), o.Inventory.Option(), o.Listing.Option())
 	if err != nil {
 		return err
 	}
-	dstURL, err := storage.NewStorageURL(o.DestUri, storage.WithRaw(o.Shared.Raw))
-	if err != nil {
+	if err := o.Inventory.Validate(srcURL); err != nil {
 		return err
 	}
-
-	// dst must not be a wildcard.
-	if dstURL.IsWildcard() {
-		return fmt.Errorf("target %q can not contain glob characters", o.DestUri)
+	if err := o.Listing.Validate(srcURL); err != nil {
+		return err
 	}
+	dstURLs, err := o.destinations()
+	if err != nil {
+		return err
+	}
+	dstURL := dstURLs[0]
 
 	// Multi-object sources require an explicit --recursive (mirroring rm):
 	// a prefix/bucket/directory source expands to every object under it,
@@ -188,18 +251,29 @@ func (o *Options) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	objFilter, err := filter.Parse(o.Shared.Filter)
+	if err != nil {
+		return err
+	}
+	rules, err := filter.LoadRules(o.Shared.FilterFrom)
+	if err != nil {
+		return err
+	}
 
 	// Local->local copy does not need the parallel.Manager; the filesystem
 	// store's Copy is synchronous and cheap. Keep it on a tiny worker pool
 	// for parity with the other paths.
-	if !srcURL.IsRemote() && !dstURL.IsRemote() {
-		return copyLocalToLocal(ctx, store, srcURL, dstURL, o)
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
 	}
-
-	pb := progressbar.New(o.ShowProgress && srcURL.IsRemote() != dstURL.IsRemote())
 	pb.Start()
 	defer pb.Finish()
 
+	if len(dstURLs) == 1 && !srcURL.IsRemote() && !dstURL.IsRemote() {
+		return copyLocalToLocal(ctx, store, srcURL, dstURL, o, pb)
+	}
+
 	// Build a list of source objects first so the waiter goroutine can
 	// start draining before the first parallel.Run. The list is bounded by
 	// the size of the source, which is acceptable for the same reason a
@@ -227,6 +301,7 @@ func (o *Options) run(ctx context.Context) error {
 	// was a data race.
 	waiter := parallel.NewWaiter()
 	ec := cliutil.NewErrorCollector("cp")
+	o.Failures.Track(ec)
 	drainDone := ec.Drain(waiter)
 
 	spec := o.spec()
@@ -248,35 +323,74 @@ func (o *Options) run(ctx context.Context) error {
 		if name == "" {
 			name = object.StorageURL.Absolute()
 		}
-		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) {
+		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) || rules.Excluded(name) || !objFilter.Match(object) {
 			continue
 		}
 
 		pb.AddTotalBytes(object.Size)
 		pb.IncrementTotalObjects()
 
+		rops := o.Report.StartEach("cp", object, dstURLs, spec.Flatten, isBatch)
 		var task parallel.Task
 		switch {
+		case len(dstURLs) > 1:
+			task = prepareFanOutTask(ctx, store, ec, &o.Failures, spec, object, dstURLs, isBatch, pb, rops)
 		case srcURL.IsRemote() && dstURL.IsRemote():
-			task = prepareCopyTask(ctx, store, spec, object.StorageURL, dstURL, isBatch)
+			task = prepareCopyTask(ctx, store, spec, object, dstURL, isBatch, pb, rops[0])
 		case srcURL.IsRemote() && !dstURL.IsRemote():
-			task = prepareDownloadTask(ctx, store, spec, object.StorageURL, dstURL, isBatch, pb)
+			task = prepareDownloadTask(ctx, store, spec, object, dstURL, isBatch, pb, rops[0])
 		case !srcURL.IsRemote() && dstURL.IsRemote():
-			task = prepareUploadTask(ctx, store, spec, object.StorageURL, dstURL, isBatch, pb)
+			task = prepareUploadTask(ctx, store, spec, object, dstURL, isBatch, pb, rops[0])
 		default:
 			// Local->local should have been handled above; guard against
 			// future src/dst type combinations surfacing as silent no-ops.
 			ec.Collect(fmt.Errorf("unsupported cp pair: src=%v dst=%v", srcURL, dstURL))
 			continue
 		}
-		parallel.Run(task, waiter)
+		// A fan-out reports each destination itself; otherwise the
+		// report counts every retry of the task as an attempt.
+		if len(dstURLs) == 1 {
+			task = rops[0].Finishes(o.Failures.Wrap(ctx, rops[0].Attempt(task)))
+		} else {
+			task = o.Failures.Wrap(ctx, task)
+		}
+		// After the first Ctrl-C nothing new starts: the rest of the
+		// objects go to the resume journal.
+		if !parallel.Run(task, waiter) {
+			for _, err := range cliutil.NotStarted("cp", object.StorageURL, dstURLs, spec.Flatten, isBatch) {
+				ec.Collect(err)
+			}
+		}
 	}
 	waiter.Wait()
 	drainDone()
 
+	ec.Collect(o.Failures.WriteFailed())
 	return ec.Aggregate()
 }
 
+// destinations parses the destination arguments. Copying to more than one
+// destination requires every one of them to be remote.
+func (o *Options) destinations() ([]*storage.StorageURL, error) {
+	uris := append([]string{o.DestUri}, o.To...)
+	dsts := make([]*storage.StorageURL, 0, len(uris))
+	for _, uri := range uris {
+		dst, err := storage.NewStorageURL(uri, storage.WithRaw(o.Shared.Raw))
+		if err != nil {
+			return nil, err
+		}
+		// dst must not be a wildcard.
+		if dst.IsWildcard() {
+			return nil, fmt.Errorf("target %q can not contain glob characters", uri)
+		}
+		if len(uris) > 1 && !dst.IsRemote() {
+			return nil, fmt.Errorf("target %q: copying to several destinations requires s3:// destinations", uri)
+		}
+		dsts = append(dsts, dst)
+	}
+	return dsts, nil
+}
+
 // checkRecursive rejects prefix/bucket/directory sources unless
 // --recursive was passed. Wildcard (and --raw) sources are exempt.
 func (o *Options) checkRecursive(srcURL *storage.StorageURL) error {
@@ -300,38 +414,110 @@ func (o *Options) checkRecursive(srcURL *storage.StorageURL) error {
 }
 
 // prepareCopyTask builds a server-side copy task (S3 -> S3). It is the
-// only path that honours --metadata-directive.
-func prepareCopyTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, srcURL, dstURL *storage.StorageURL, isBatch bool) parallel.Task {
+// only path that honours --metadata-directive. The copy counts toward the
+// progress once it completes.
+func prepareCopyTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, src *storage.Object, dstURL *storage.StorageURL, isBatch bool, pb progressbar.ProgressBar, rop *report.Operation) parallel.Task {
 	return func() error {
+		srcURL := src.StorageURL
+		pt := pb.Transfer(srcURL.Relative(), src.Size)
+		defer pt.Finish()
 		dst := cliutil.PrepareRemoteDestination(srcURL, dstURL, spec.Flatten, isBatch)
-		if err := spec.Copy(ctx, store, srcURL, dst); err != nil {
+		obj, err := spec.Copy(ctx, store, srcURL, dst)
+		if err != nil {
 			return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dst.String(), Err: err}
 		}
+		rop.Wrote(obj)
+		pt.AddCompletedBytes(src.Size)
+		pt.IncrementCompletedObjects()
 		return nil
 	}
 }
 
 // prepareDownloadTask builds a remote -> local download task.
-func prepareDownloadTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, srcURL, dstURL *storage.StorageURL, isBatch bool, pb progressbar.ProgressBar) parallel.Task {
+func prepareDownloadTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, src *storage.Object, dstURL *storage.StorageURL, isBatch bool, pb progressbar.ProgressBar, rop *report.Operation) parallel.Task {
 	return func() error {
+		srcURL := src.StorageURL
+		pt := pb.Transfer(srcURL.Relative(), src.Size)
+		defer pt.Finish()
 		dst, err := cliutil.PrepareLocalDestination(ctx, store, srcURL, dstURL, spec.Flatten, isBatch)
 		if err != nil {
 			return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dstURL.String(), Err: err}
 		}
-		if err := spec.Download(ctx, store, srcURL, dst, pb); err != nil {
+		rop.Resolved(dst.Absolute())
+		obj, err := spec.Download(ctx, store, srcURL, dst, pt)
+		if err != nil {
 			return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dst.String(), Err: err}
 		}
+		rop.Wrote(obj)
 		return nil
 	}
 }
 
 // prepareUploadTask builds a local -> remote upload task.
-func prepareUploadTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, srcURL, dstURL *storage.StorageURL, isBatch bool, pb progressbar.ProgressBar) parallel.Task {
+func prepareUploadTask(ctx context.Context, store *storage.Storage, spec *cliutil.TransferSpec, src *storage.Object, dstURL *storage.StorageURL, isBatch bool, pb progressbar.ProgressBar, rop *report.Operation) parallel.Task {
 	return func() error {
+		srcURL := src.StorageURL
+		pt := pb.Transfer(srcURL.Relative(), src.Size)
+		defer pt.Finish()
 		dst := cliutil.PrepareRemoteDestination(srcURL, dstURL, spec.Flatten, isBatch)
-		if err := spec.Upload(ctx, store, srcURL, dst, pb); err != nil {
+		obj, err := spec.Upload(ctx, store, srcURL, dst, pt)
+		if err != nil {
 			return &errorpkg.Error{Op: "cp", Src: srcURL.Absolute(), Dst: dst.String(), Err: err}
 		}
+		rop.Wrote(obj)
+		return nil
+	}
+}
+
+// prepareFanOutTask builds a task that copies one source object to every
+// destination. Each destination's result is collected on its own, so a
+// failing destination shows up in the errors and stats without failing
+// the others, and only the destinations that failed transiently are
+// retried. rops holds the report entry of each destination. The progress
+// counts the object once, however many destinations it goes to.
+func prepareFanOutTask(ctx context.Context, store *storage.Storage, ec *cliutil.ErrorCollector, failures *cliutil.FailureFlags, spec *cliutil.TransferSpec, srcObj *storage.Object, dstURLs []*storage.StorageURL, isBatch bool, pb progressbar.ProgressBar, rops []*report.Operation) parallel.Task {
+	return func() error {
+		srcURL := srcObj.StorageURL
+		pt := pb.Transfer(srcURL.Relative(), srcObj.Size)
+		defer pt.Finish()
+		dsts := make([]*storage.StorageURL, 0, len(dstURLs))
+		for _, dstURL := range dstURLs {
+			dsts = append(dsts, cliutil.PrepareRemoteDestination(srcURL, dstURL, spec.Flatten, isBatch))
+		}
+		src := srcURL.String()
+		if !srcURL.IsRemote() {
+			src = srcURL.Absolute()
+		}
+		objs := make([]*storage.Object, len(dsts))
+		errs := failures.RetryEach(ctx, len(dsts), func(idx []int) []error {
+			targets := make([]*storage.StorageURL, 0, len(idx))
+			for _, i := range idx {
+				targets = append(targets, dsts[i])
+				rops[i].Attempted()
+			}
+			var written []*storage.Object
+			var errs []error
+			if srcURL.IsRemote() {
+				written, errs = spec.CopyFanOut(ctx, store, srcURL, targets)
+			} else {
+				written, errs = spec.UploadFanOut(ctx, store, srcURL, targets, pt)
+			}
+			for k, i := range idx {
+				objs[i] = written[k]
+			}
+			return errs
+		})
+		if srcURL.IsRemote() {
+			pt.AddCompletedBytes(srcObj.Size)
+		}
+		pt.IncrementCompletedObjects()
+		for i, err := range errs {
+			rops[i].Wrote(objs[i])
+			rops[i].Finish(err)
+			if err != nil {
+				ec.Collect(&errorpkg.Error{Op: "cp", Src: src, Dst: dsts[i].String(), Err: err})
+			}
+		}
 		return nil
 	}
 }
@@ -339,13 +525,13 @@ func prepareUploadTask(ctx context.Context, store *storage.Storage, spec *cliuti
 // copyLocalToLocal handles the local->local case. It walks the source
 // (directory or wildcard) and copies each file to the destination using
 // the local store's Copy, which is a plain io.Copy with MkdirAll.
-func copyLocalToLocal(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, o *Options) error {
+func copyLocalToLocal(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, o *Options, pb progressbar.ProgressBar) error {
 	srcBase := srcURL.Absolute()
 	if srcURL.IsWildcard() {
 		srcBase = cliutil.WildcardBasePath(srcBase)
 	}
 
-	files, err := cliutil.ListLocalFiles(srcURL.Absolute(), true)
+	files, err := cliutil.ListLocalFilesIgnoring(srcURL.Absolute(), true, srcURL.IgnoreFiles())
 	if err != nil {
 		return err
 	}
@@ -361,6 +547,7 @@ func copyLocalToLocal(ctx context.Context, store *storage.Storage, srcURL, dstUR
 
 	waiter := parallel.NewWaiter()
 	ec := cliutil.NewErrorCollector("cp")
+	o.Failures.Track(ec)
 	drainDone := ec.Drain(waiter)
 
 	// Per-file errors are collected instead of returned: an early return
@@ -395,19 +582,36 @@ func copyLocalToLocal(ctx context.Context, store *storage.Storage, srcURL, dstUR
 		}
 		src := fileURL
 		dstCopy := dst
-		parallel.Run(func() error {
+		var size int64
+		if fi, err := os.Stat(file); err == nil {
+			size = fi.Size()
+		}
+		pb.AddTotalBytes(size)
+		pb.IncrementTotalObjects()
+		rop := o.Report.Start("cp", src.Absolute(), dstCopy, size)
+		task := rop.Finishes(o.Failures.Wrap(ctx, rop.Attempt(func() error {
+			pt := pb.Transfer(src.Absolute(), size)
+			defer pt.Finish()
 			dstURLCopy, err := storage.NewStorageURL(dstCopy)
 			if err != nil {
 				return err
 			}
-			if err := store.Copy(ctx, src, dstURLCopy, storage.Metadata{}); err != nil {
+			obj, err := store.Copy(ctx, src, dstURLCopy, storage.Metadata{})
+			if err != nil {
 				return &errorpkg.Error{Op: "cp", Src: src.Absolute(), Dst: dstCopy, Err: err}
 			}
+			rop.Wrote(obj)
+			pt.AddCompletedBytes(size)
+			pt.IncrementCompletedObjects()
 			log.Info(log.InfoMessage{Operation: "cp", Source: src.Absolute(), Destination: dstCopy})
 			return nil
-		}, waiter)
+		})))
+		if !parallel.Run(task, waiter) {
+			ec.Collect(&errorpkg.Error{Op: "cp", Src: src.Absolute(), Dst: dstCopy, Err: errorpkg.ErrNotStarted})
+		}
 	}
 	waiter.Wait()
 	drainDone()
+	ec.Collect(o.Failures.WriteFailed())
 	return ec.Aggregate()
 }
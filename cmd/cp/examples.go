@@ -224,4 +224,94 @@ const cp_examples = `Example 1: Copying a local file to S3
        Output:
 
           download: s3://arn:aws:s3:us-west-2:123456789012:accesspoint/myaccesspoint/mykey to mydoc.txt
+
+       Example 16: Copying to several destinations at once
+
+       The following cp command reads each local file once and  uploads  it
+       to  both  buckets  concurrently.  Extra destinations can be given as
+       arguments or with --to; all of them must be S3 URLs:
+
+          s6cmd cp --recursive ./build/ s3://us-bucket/app/ --to s3://eu-bucket/app/
+
+       An S3 source is copied server-side to the first destination and from
+       there to the others:
+
+          s6cmd cp s3://bucket/release.tgz s3://us-bucket/ s3://eu-bucket/
+
+       Example 17: Retrying only the objects that failed
+
+       The following cp command retries an object up to 5 times when it fails
+       with a transient error, and writes every copy that still failed to
+       failed.txt, one cp command per line:
+
+          s6cmd cp --recursive --task-retries 5 --failed-out failed.txt ./data/ s3://bucket/data/
+
+       The failed copies can then be run again on their own:
+
+          s6cmd run failed.txt
+
+       Example 18: Resuming an interrupted copy
+
+       The first Ctrl-C stops starting new copies and waits up to 2 minutes for
+       the running ones; the copies it did not finish are written to
+       s6cmd-resume.txt:
+
+          s6cmd --drain-timeout 2m cp --recursive ./data/ s3://bucket/data/
+
+       They are resumed with:
+
+          s6cmd run s6cmd-resume.txt
+
+       Example 19: Record the outcome of every object in a CSV report
+
+       Each line has the source, destination, size, the ETag and version ID
+       of the object written, duration, attempts and status (ok, dry-run,
+       skipped, failed, canceled or not-started):
+
+          s6cmd cp --recursive --report report.csv ./data/ s3://bucket/data/
+
+       Example 20: Show the progress and the transfers in flight
+
+       On a terminal the summary line (objects, bytes, throughput, ETA) is
+       repainted in place with the active transfers under it; when stderr
+       is redirected a plain summary line is printed every 5 seconds:
+
+          s6cmd cp --recursive --show-transfers ./data/ s3://bucket/data/
+          s6cmd cp --recursive --show-progress ./data/ s3://bucket/data/ 2>progress.log
+
+       Example 21: Stream the progress as JSON events to another program
+
+       Each line is one event (start, object_started, object_progress,
+       object_finished, object_failed, progress, done); here file
+       descriptor 3 is the pipe to jq:
+
+          s6cmd cp --recursive --progress-json 3 ./data/ s3://bucket/data/ 3>&1 >/dev/null | jq -c .
+
+       Example 22: Copy only the objects an expression selects
+
+       The --filter expression is evaluated against every listed object;
+       fields are name, path, key, size, mtime, class, etag, version and
+       delete_marker:
+
+          s6cmd cp --recursive --filter 'size > 100MiB && class == "STANDARD"' s3://bucket/data/ s3://other-bucket/data/
+
+       Example 23: Copy only the Go sources, following ordered rules from a file
+
+       rules.txt holds "+ */", "+ *.go" and "- *"; the first matching rule
+       wins, and "+ */" keeps every directory open so the files inside are
+       decided one by one:
+
+          s6cmd cp --recursive --filter-from rules.txt ./src/ s3://bucket/src/
+
+       Example 24: Copy a directory including the files its .s6cmdignore files list
+
+          s6cmd cp --recursive --no-s6cmdignore ./data/ s3://bucket/data/
+
+       Example 25: Copy the objects an S3 Inventory report lists instead of listing the source
+
+          s6cmd cp --recursive --inventory s3://inventory-bucket/bucket/daily/2026-10-18T01-00Z/manifest.json "s3://bucket/logs/*" ./logs/
+
+       Example 26: Copy the objects of a listing saved by ls, checking 100 of them against the bucket first
+
+          s6cmd cp --recursive --from-listing listing.jsonl --check-listing 100 "s3://bucket/logs/*" s3://archive-bucket/logs/
 `
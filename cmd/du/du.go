@@ -2,15 +2,18 @@ package du
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/internal/outfmt"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/LinPr/s6cmd/strutil"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
 )
@@ -41,6 +44,11 @@ func NewDuCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&o.Humanize, "humanize", "H", false, "human-readable sizes")
 	cmd.Flags().BoolVarP(&o.GroupByClass, "group", "g", false, "group sizes by storage class")
 	cmd.Flags().StringSliceVarP(&o.Exclude, "exclude", "", nil, "exclude objects matching the given wildcard pattern (repeatable)")
+	cmd.Flags().StringVar(&o.FilterFrom, "filter-from", "", "read ordered rsync-style '+ PATTERN' / '- PATTERN' rules from a file; the first matching rule wins")
+	cmd.Flags().StringVar(&o.Filter, "filter", "", `only count objects matching the expression, e.g. 'size > 100MiB && mtime < now-30d'`)
+	cmd.Flags().StringVar(&o.Format, "format", "", `print each total with a Go template, e.g. '{{.Count}}\t{{humanize .Size}}\t{{.StorageClass}}'`)
+	o.Inventory.AddToCmd(&cmd)
+	o.Listing.AddToCmd(&cmd)
 
 	return &cmd
 }
@@ -53,6 +61,13 @@ type Flags struct {
 	Humanize     bool
 	GroupByClass bool
 	Exclude      []string
+	FilterFrom   string
+	Filter       string
+	Format       string
+	// Inventory holds --inventory.
+	Inventory cliutil.InventoryFlags
+	// Listing holds --from-listing and --check-listing.
+	Listing cliutil.ListingFlags
 }
 
 type Options struct {
@@ -83,7 +98,7 @@ type sizeAndCount struct {
 }
 
 func (o *Options) run(ctx context.Context, out io.Writer) error {
-	url, err := storage.NewStorageURL(o.S3Uri)
+	url, err := storage.NewStorageURL(o.S3Uri, storage.WithUnordered(true), o.Inventory.Option(), o.Listing.Option())
 	if err != nil {
 		return err
 	}
@@ -93,6 +108,9 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if url.Bucket == "" {
 		return fmt.Errorf("bucket is required")
 	}
+	if err := o.Listing.Validate(url); err != nil {
+		return err
+	}
 
 	cli, err := cliutil.NewS3Client(ctx, o.common)
 	if err != nil {
@@ -103,50 +121,63 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if err != nil {
 		return err
 	}
+	rules, err := filter.LoadRules(o.FilterFrom)
+	if err != nil {
+		return err
+	}
+	objFilter, err := filter.Parse(o.Filter)
+	if err != nil {
+		return err
+	}
+	printer, err := outfmt.New(out, o.common.Output, o.Format, "url", "count", "size", "storage_class")
+	if err != nil {
+		return err
+	}
 
 	storageTotal := map[string]sizeAndCount{}
 	total := sizeAndCount{}
 
-	prefix := url.Path
-	paginator := s3.NewListObjectsV2Paginator(cli.Client(), &s3.ListObjectsV2Input{
-		Bucket: aws.String(url.Bucket),
-		Prefix: aws.String(prefix),
-	})
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return err
-		}
-		for _, obj := range page.Contents {
-			if obj.Key == nil {
-				continue
-			}
-			key := aws.ToString(obj.Key)
-			if key == prefix {
-				continue
-			}
-			if cliutil.MatchAnyPattern(excludePatterns, key) {
+	// List every key below the path, in any order, and relate each one to
+	// the directory of the path for the filters.
+	listURL := url.Clone()
+	listURL.Delimiter = ""
+	dir := url.Path[:strings.LastIndex(url.Path, "/")+1]
+	for obj := range cli.List(ctx, listURL, false) {
+		if obj.Err != nil {
+			if errors.Is(obj.Err, errorpkg.ErrNoObjectFound) {
 				continue
 			}
-			size := aws.ToInt64(obj.Size)
-			cls := string(obj.StorageClass)
-			if cls == "" {
-				cls = "STANDARD"
-			}
+			return obj.Err
+		}
+		key := obj.StorageURL.Path
+		if key == url.Path {
+			continue
+		}
+		obj.StorageURL.SetRelativePath(strings.TrimPrefix(key, dir))
+		if cliutil.MatchAnyPattern(excludePatterns, key) || rules.Excluded(obj.StorageURL.Relative()) || !objFilter.Match(obj) {
+			continue
+		}
+		cls := string(obj.StorageClass)
+		if cls == "" {
+			cls = "STANDARD"
+		}
 
-			s := storageTotal[cls]
-			s.size += size
-			s.count++
-			storageTotal[cls] = s
+		s := storageTotal[cls]
+		s.size += obj.Size
+		s.count++
+		storageTotal[cls] = s
 
-			total.size += size
-			total.count++
-		}
+		total.size += obj.Size
+		total.count++
 	}
 
 	jsonOutput := o.common.Output == "json"
 
 	if !o.GroupByClass {
+		if printer != nil {
+			printer.Print(outfmt.Row{URL: o.S3Uri, Bucket: url.Bucket, Key: url.Path, Count: total.count, Size: total.size})
+			return printer.Flush()
+		}
 		if jsonOutput {
 			fmt.Fprintln(out, duMessage{Source: o.S3Uri, Count: total.count, Size: total.size}.JSON())
 			return nil
@@ -161,6 +192,17 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	}
 	sort.Strings(classes)
 	for _, c := range classes {
+		if printer != nil {
+			printer.Print(outfmt.Row{
+				URL:          o.S3Uri,
+				Bucket:       url.Bucket,
+				Key:          url.Path,
+				Count:        storageTotal[c].count,
+				Size:         storageTotal[c].size,
+				StorageClass: c,
+			})
+			continue
+		}
 		if jsonOutput {
 			fmt.Fprintln(out, duMessage{
 				Source:       o.S3Uri,
@@ -172,6 +214,9 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 		}
 		fmt.Fprintln(out, formatSizeLine(o.S3Uri, c, storageTotal[c], o.Humanize))
 	}
+	if printer != nil {
+		return printer.Flush()
+	}
 	return nil
 }
 
@@ -184,7 +229,7 @@ type duMessage struct {
 }
 
 func (m duMessage) String() string { return m.JSON() }
-func (m duMessage) JSON() string   { return strutil.JSON(m) }
+func (m duMessage) JSON() string   { return strutil.SchemaJSON("du/v1", m) }
 
 func formatSizeLine(source, class string, sc sizeAndCount, humanize bool) string {
 	var sizeStr string
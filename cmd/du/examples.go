@@ -15,4 +15,20 @@ Example 3: Show disk usage of all objects in a bucket but exclude py files
 Example 4: Human-readable sizes
 
          s6cmd du --humanize s3://bucket/
+
+Example 5: Show how much STANDARD data has not been modified for 90 days
+
+         s6cmd du --humanize --filter 'class == "STANDARD" && mtime < now-90d' s3://bucket/
+
+Example 6: Write the usage per storage class as CSV
+
+         s6cmd --output csv du --group s3://bucket/ > usage.csv
+
+Example 7: Show the usage per storage class from an S3 Inventory report instead of listing the bucket
+
+         s6cmd du --group --inventory s3://inventory-bucket/bucket/daily/2026-10-18T01-00Z/manifest.json s3://bucket/
+
+Example 8: Show the disk usage of a listing saved by ls --recursive --save-listing listing.jsonl
+
+         s6cmd du --from-listing listing.jsonl s3://bucket/
 `
@@ -0,0 +1,48 @@
+package find
+
+const find_examples = `Example 1: Print every .parquet object over 1 GiB, older than 90 days, in GLACIER
+
+         s6cmd find s3://bucket/warehouse/ -- -name '*.parquet' -size +1G -mtime +90 -storage-class GLACIER
+
+Example 2: Combine predicates with -or, -not and parentheses (quoted for the shell)
+
+         s6cmd find s3://bucket/logs/ -- '(' -name '*.gz' -or -name '*.zst' ')' -not -path 'keep/*'
+
+Example 3: Print the matches as JSON lines
+
+         s6cmd find --json s3://bucket/ -- -age -12h
+
+Example 4: Feed matches to xargs, NUL-separated
+
+         s6cmd find --print0 ./data/ -- -iname '*.TMP' | xargs -0 rm
+
+Example 5: Find objects by user metadata and by tag (looked up per candidate)
+
+         s6cmd find s3://bucket/ -- -name '*.csv' -metadata owner=etl -tag retention=short
+
+Example 6: Remove every delete marker, restoring the objects they hide
+
+         s6cmd find --all-versions --delete s3://bucket/ -- -delete-marker
+
+Example 7: Archive matches to another bucket, then delete them; an object whose copy failed is kept
+
+         s6cmd find --copy-to s3://archive/2024/ --delete s3://bucket/ -- -newer 2024-01-01 -not -newer 2025-01-01
+
+Example 8: Preview the deletions without deleting anything
+
+         s6cmd find --dry-run --delete s3://bucket/tmp/ -- -age +7d
+
+Example 9: Run a command per match. The placeholders are {} (the URL as printed),
+           {path} (relative to the source), {name}, {bucket}, {key}, {size},
+           {etag} and {version}
+
+         s6cmd find --exec 'echo {size} {key}' s3://bucket/ -- -size +100M
+
+Example 10: Find objects whose ETag shows a multipart upload
+
+         s6cmd find s3://bucket/ -- -etag '*-*'
+
+Example 11: Delete at most 1000 matches, each copied aside first
+
+         s6cmd find --delete --max-delete 1000 --backup-dir s3://bucket-backup/ s3://bucket/tmp/ -- -age +30d
+`
@@ -0,0 +1,532 @@
+package find
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
+)
+
+// expr is a node of a parsed find expression.
+type expr interface {
+	match(ctx context.Context, c *candidate) (bool, error)
+}
+
+type andExpr struct{ x, y expr }
+
+func (e andExpr) match(ctx context.Context, c *candidate) (bool, error) {
+	ok, err := e.x.match(ctx, c)
+	if err != nil || !ok {
+		return false, err
+	}
+	return e.y.match(ctx, c)
+}
+
+type orExpr struct{ x, y expr }
+
+func (e orExpr) match(ctx context.Context, c *candidate) (bool, error) {
+	ok, err := e.x.match(ctx, c)
+	if err != nil || ok {
+		return ok, err
+	}
+	return e.y.match(ctx, c)
+}
+
+type notExpr struct{ x expr }
+
+func (e notExpr) match(ctx context.Context, c *candidate) (bool, error) {
+	ok, err := e.x.match(ctx, c)
+	return !ok && err == nil, err
+}
+
+// predicate is a leaf of the expression: one test of an object.
+type predicate func(ctx context.Context, c *candidate) (bool, error)
+
+func (p predicate) match(ctx context.Context, c *candidate) (bool, error) {
+	return p(ctx, c)
+}
+
+// lookup fetches what a listing does not carry. *storage.Storage
+// implements it.
+type lookup interface {
+	HeadObject(ctx context.Context, url *storage.StorageURL) (*storage.Object, *storage.Metadata, error)
+	ObjectTags(ctx context.Context, url *storage.StorageURL) (map[string]string, error)
+}
+
+// candidate is an object an expression is evaluated against. Its user
+// metadata and tags are looked up the first time a predicate asks for
+// them, so an expression that rules an object out on its listing fields
+// costs no request. A candidate is evaluated by one goroutine.
+type candidate struct {
+	obj *storage.Object
+	// path is the object's path relative to the source it was found under.
+	path   string
+	now    time.Time
+	lookup lookup
+
+	metadata map[string]string
+	tags     map[string]string
+}
+
+// userMetadata returns the user-defined metadata of the object, with the
+// keys lower-cased.
+func (c *candidate) userMetadata(ctx context.Context) (map[string]string, error) {
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+	_, md, err := c.lookup.HeadObject(ctx, c.obj.StorageURL)
+	if err != nil {
+		return nil, err
+	}
+	c.metadata = make(map[string]string, len(md.UserDefined))
+	for k, v := range md.UserDefined {
+		c.metadata[strings.ToLower(k)] = v
+	}
+	return c.metadata, nil
+}
+
+// objectTags returns the tag set of the object.
+func (c *candidate) objectTags(ctx context.Context) (map[string]string, error) {
+	if c.tags != nil {
+		return c.tags, nil
+	}
+	tags, err := c.lookup.ObjectTags(ctx, c.obj.StorageURL)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	c.tags = tags
+	return c.tags, nil
+}
+
+// expression is a parsed find expression. remoteOnly and versioned name
+// the first predicate, if any, that only applies to s3:// sources and
+// that needs --all-versions, so the command can reject a combination
+// that could never match.
+type expression struct {
+	root       expr
+	remoteOnly string
+	versioned  string
+}
+
+// match reports whether c matches the expression. An empty expression
+// matches every object.
+func (e *expression) match(ctx context.Context, c *candidate) (bool, error) {
+	if e.root == nil {
+		return true, nil
+	}
+	return e.root.match(ctx, c)
+}
+
+// parseExpression parses the tokens of a find expression:
+//
+//	expr    = or
+//	or      = and { ("-o" | "-or") and }
+//	and     = unary { ["-a" | "-and"] unary }
+//	unary   = ("!" | "-not") unary | "(" expr ")" | primary
+//
+// Two terms without an operator between them are joined with and.
+func parseExpression(tokens []string) (*expression, error) {
+	p := &parser{tokens: tokens, e: &expression{}}
+	if len(tokens) == 0 {
+		return p.e, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q in expression", tok)
+	}
+	p.e.root = root
+	return p.e, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	e      *expression
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (string, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+func (p *parser) parseOr() (expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || (tok != "-o" && tok != "-or") {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = orExpr{x, y}
+	}
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok == "-o" || tok == "-or" || tok == ")" {
+			return x, nil
+		}
+		if tok == "-a" || tok == "-and" {
+			p.pos++
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = andExpr{x, y}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("expression ends where a term was expected")
+	}
+	switch tok {
+	case "!", "-not":
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.next(); !ok || tok != ")" {
+			return nil, fmt.Errorf("missing \")\" in expression")
+		}
+		return x, nil
+	}
+	return p.parsePrimary(tok)
+}
+
+// parsePrimary parses the predicate named tok and its argument.
+func (p *parser) parsePrimary(tok string) (expr, error) {
+	arg := func() (string, error) {
+		v, ok := p.next()
+		if !ok {
+			return "", fmt.Errorf("%s needs an argument", tok)
+		}
+		return v, nil
+	}
+	remoteOnly := func() {
+		if p.e.remoteOnly == "" {
+			p.e.remoteOnly = tok
+		}
+	}
+	versioned := func() {
+		if p.e.versioned == "" {
+			p.e.versioned = tok
+		}
+	}
+
+	switch tok {
+	case "-name", "-iname":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		re, err := compileGlob(v, tok == "-iname")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tok, err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return re.MatchString(path.Base(c.path)), nil
+		}), nil
+	case "-path", "-ipath":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		re, err := compileGlob(v, tok == "-ipath")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tok, err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return re.MatchString(c.path), nil
+		}), nil
+	case "-regex", "-iregex":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		flags := "(?s)"
+		if tok == "-iregex" {
+			flags = "(?is)"
+		}
+		re, err := regexp.Compile(flags + "^(?:" + v + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tok, err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return re.MatchString(c.path), nil
+		}), nil
+	case "-size":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		cmp, n, err := parseSize(v)
+		if err != nil {
+			return nil, fmt.Errorf("-size: %w", err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return compare(cmp, c.obj.Size, n), nil
+		}), nil
+	case "-mtime":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		cmp, days, err := parseDays(v)
+		if err != nil {
+			return nil, fmt.Errorf("-mtime: %w", err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			if c.obj.ModTime == nil {
+				return false, nil
+			}
+			// Like find(1), the age is counted in whole days, dropping
+			// the fraction.
+			age := int64(c.now.Sub(*c.obj.ModTime) / (24 * time.Hour))
+			return compare(cmp, age, days), nil
+		}), nil
+	case "-age":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		cmp, d, err := parseAge(v)
+		if err != nil {
+			return nil, fmt.Errorf("-age: %w", err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			if c.obj.ModTime == nil {
+				return false, nil
+			}
+			return compare(cmp, int64(c.now.Sub(*c.obj.ModTime)), int64(d)), nil
+		}), nil
+	case "-newer":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		t, err := filter.ParseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("-newer: %w", err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return c.obj.ModTime != nil && c.obj.ModTime.After(t), nil
+		}), nil
+	case "-storage-class":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		remoteOnly()
+		classes := strings.Split(v, ",")
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			class := string(c.obj.StorageClass)
+			if class == "" {
+				// S3 leaves the class out of a listing for STANDARD.
+				class = "STANDARD"
+			}
+			for _, want := range classes {
+				if strings.EqualFold(strings.TrimSpace(want), class) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}), nil
+	case "-etag":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		remoteOnly()
+		re, err := compileGlob(strutil.TrimQuotes(v), true)
+		if err != nil {
+			return nil, fmt.Errorf("-etag: %w", err)
+		}
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return re.MatchString(c.obj.Etag), nil
+		}), nil
+	case "-version-id":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		remoteOnly()
+		versioned()
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			id := c.obj.VersionID
+			if id == "" {
+				id = "null"
+			}
+			return id == v, nil
+		}), nil
+	case "-delete-marker":
+		remoteOnly()
+		versioned()
+		return predicate(func(_ context.Context, c *candidate) (bool, error) {
+			return c.obj.IsDeleteMarker, nil
+		}), nil
+	case "-metadata", "-tag":
+		v, err := arg()
+		if err != nil {
+			return nil, err
+		}
+		remoteOnly()
+		key, pattern, hasValue := strings.Cut(v, "=")
+		if key == "" {
+			return nil, fmt.Errorf("%s: want KEY or KEY=PATTERN, got %q", tok, v)
+		}
+		var re *regexp.Regexp
+		if hasValue {
+			if re, err = compileGlob(pattern, false); err != nil {
+				return nil, fmt.Errorf("%s: %w", tok, err)
+			}
+		}
+		get := (*candidate).objectTags
+		if tok == "-metadata" {
+			// Metadata keys are case-insensitive HTTP headers.
+			key = strings.ToLower(key)
+			get = (*candidate).userMetadata
+		}
+		return predicate(func(ctx context.Context, c *candidate) (bool, error) {
+			if c.obj.IsDeleteMarker {
+				// A delete marker has neither.
+				return false, nil
+			}
+			values, err := get(c, ctx)
+			if err != nil {
+				return false, err
+			}
+			value, ok := values[key]
+			if !ok || re == nil {
+				return ok, nil
+			}
+			return re.MatchString(value), nil
+		}), nil
+	}
+	if strings.HasPrefix(tok, "-") {
+		return nil, fmt.Errorf("unknown predicate %q", tok)
+	}
+	return nil, fmt.Errorf("unexpected %q in expression: predicates start with \"-\"", tok)
+}
+
+// compileGlob compiles a wildcard pattern with the semantics of
+// --exclude: "*" matches any run of characters, "/" included, and "?" one
+// character. The pattern must match the whole string.
+func compileGlob(pattern string, foldCase bool) (*regexp.Regexp, error) {
+	re := strutil.AddNewLineFlag(strutil.MatchFromStartToEnd(strutil.WildCardToRegexp(pattern)))
+	if foldCase {
+		re = "(?i)" + re
+	}
+	return regexp.Compile(re)
+}
+
+// comparison is how a numeric predicate compares a value with its
+// argument: "+N" is more than N, "-N" less than N and "N" exactly N.
+type comparison int
+
+const (
+	equal comparison = iota
+	greater
+	less
+)
+
+func compare(cmp comparison, value, n int64) bool {
+	switch cmp {
+	case greater:
+		return value > n
+	case less:
+		return value < n
+	}
+	return value == n
+}
+
+// splitSign splits the leading "+" or "-" off a numeric argument.
+func splitSign(v string) (comparison, string) {
+	switch {
+	case strings.HasPrefix(v, "+"):
+		return greater, v[1:]
+	case strings.HasPrefix(v, "-"):
+		return less, v[1:]
+	}
+	return equal, v
+}
+
+// parseSize parses a -size argument: an optional sign and a size, as in
+// +1.5G (see filter.ParseSize).
+func parseSize(v string) (comparison, int64, error) {
+	cmp, num := splitSign(v)
+	n, err := filter.ParseSize(num)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cmp, n, nil
+}
+
+// parseDays parses a -mtime argument: an optional sign and a whole number
+// of days.
+func parseDays(v string) (comparison, int64, error) {
+	cmp, num := splitSign(v)
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, 0, fmt.Errorf("invalid number of days %q", v)
+	}
+	return cmp, n, nil
+}
+
+// parseAge parses an -age argument: "+" (older than) or "-" (newer than)
+// followed by a duration such as 90d, 12h or 1w2d (see
+// filter.ParseDuration).
+func parseAge(v string) (comparison, time.Duration, error) {
+	cmp, num := splitSign(v)
+	if cmp == equal {
+		return 0, 0, fmt.Errorf("%q must start with + (older than) or - (newer than)", v)
+	}
+	d, err := filter.ParseDuration(num)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cmp, d, nil
+}
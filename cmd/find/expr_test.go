@@ -0,0 +1,239 @@
+package find
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// fakeLookup serves the metadata and tags of every object and counts the
+// requests.
+type fakeLookup struct {
+	metadata map[string]string
+	tags     map[string]string
+	err      error
+	heads    int
+	tagCalls int
+}
+
+func (f *fakeLookup) HeadObject(_ context.Context, url *storage.StorageURL) (*storage.Object, *storage.Metadata, error) {
+	f.heads++
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return &storage.Object{StorageURL: url}, &storage.Metadata{UserDefined: f.metadata}, nil
+}
+
+func (f *fakeLookup) ObjectTags(_ context.Context, _ *storage.StorageURL) (map[string]string, error) {
+	f.tagCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.tags, nil
+}
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newCandidate returns a candidate for s3://bucket/<key>, found under
+// s3://bucket/ so its path is the key.
+func newCandidate(t *testing.T, key string, size int64, age time.Duration, class string, lk *fakeLookup) *candidate {
+	t.Helper()
+	u, err := storage.NewStorageURL("s3://bucket/" + key)
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	mod := testNow.Add(-age)
+	return &candidate{
+		obj: &storage.Object{
+			StorageURL:   u,
+			Etag:         "d41d8cd98f00b204e9800998ecf8427e-3",
+			ModTime:      &mod,
+			Size:         size,
+			StorageClass: storage.StorageClass(class),
+		},
+		path:   key,
+		now:    testNow,
+		lookup: lk,
+	}
+}
+
+func mustMatch(t *testing.T, tokens string, c *candidate) bool {
+	t.Helper()
+	e, err := parseExpression(strings.Fields(tokens))
+	if err != nil {
+		t.Fatalf("parseExpression(%q): %v", tokens, err)
+	}
+	ok, err := e.match(context.Background(), c)
+	if err != nil {
+		t.Fatalf("match(%q): %v", tokens, err)
+	}
+	return ok
+}
+
+func TestExpressionPredicates(t *testing.T) {
+	const day = 24 * time.Hour
+	c := newCandidate(t, "warehouse/2024/part-0001.parquet", 3<<30, 120*day, "GLACIER", &fakeLookup{})
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"-name *.parquet", true},
+		{"-name *.PARQUET", false},
+		{"-iname *.PARQUET", true},
+		{"-name warehouse*", false},
+		{"-path warehouse/*/part-*", true},
+		{"-ipath WAREHOUSE/*", true},
+		{"-regex warehouse/[0-9]+/part-[0-9]{4}\\.parquet", true},
+		{"-regex part-.*", false},
+		{"-size +1G", true},
+		{"-size -1G", false},
+		{"-size 3G", true},
+		{"-size +3GiB", false},
+		{"-size +2.5GB", true},
+		{"-size -3221225473", true},
+		{"-mtime +90", true},
+		{"-mtime -90", false},
+		{"-mtime 120", true},
+		{"-age +12w", true},
+		{"-age -1w2d", false},
+		{"-newer 2025-03-01", false},
+		{"-newer 2024-12-01T00:00:00Z", true},
+		{"-storage-class glacier", true},
+		{"-storage-class STANDARD,DEEP_ARCHIVE", false},
+		{"-etag *-*", true},
+		{"-etag \"D41D8CD98F00B204E9800998ECF8427E-3\"", true},
+		{"-name *.parquet -size +1G -mtime +90 -storage-class GLACIER", true},
+		{"-name *.parquet -a -size -1G", false},
+		{"-name *.csv -o -size +1G", true},
+		{"-name *.csv -or -size -1G", false},
+		{"! -name *.csv", true},
+		{"-not -name *.parquet", false},
+		{"-name *.csv -o -name *.parquet -size -1G", false},
+		{"( -name *.csv -o -name *.parquet ) -size +1G", true},
+		{"-not ( -name *.csv -o -size -1G )", true},
+	}
+	for _, tc := range cases {
+		if got := mustMatch(t, tc.expr, c); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestExpressionStandardClass(t *testing.T) {
+	c := newCandidate(t, "a.txt", 1, time.Hour, "", &fakeLookup{})
+	if !mustMatch(t, "-storage-class STANDARD", c) {
+		t.Errorf("an object listed without a class should match STANDARD")
+	}
+}
+
+func TestExpressionVersions(t *testing.T) {
+	c := newCandidate(t, "a.txt", 0, time.Hour, "", &fakeLookup{})
+	c.obj.IsDeleteMarker = true
+	c.obj.VersionID = "v2"
+	if !mustMatch(t, "-delete-marker -version-id v2", c) {
+		t.Errorf("delete marker v2 should match")
+	}
+	c.obj.IsDeleteMarker = false
+	c.obj.VersionID = ""
+	if mustMatch(t, "-delete-marker", c) || !mustMatch(t, "-version-id null", c) {
+		t.Errorf("an unversioned object is not a delete marker and has version ID null")
+	}
+}
+
+// TestExpressionLookups verifies metadata and tags are fetched lazily: not
+// at all when the listing fields already decide, and once per candidate
+// however many predicates use them.
+func TestExpressionLookups(t *testing.T) {
+	lk := &fakeLookup{
+		metadata: map[string]string{"Owner": "etl"},
+		tags:     map[string]string{"retention": "short-30d"},
+	}
+	c := newCandidate(t, "a.csv", 10, time.Hour, "", lk)
+
+	if mustMatch(t, "-name *.parquet -metadata owner=etl", c) {
+		t.Errorf("-name should rule the object out")
+	}
+	if lk.heads != 0 {
+		t.Errorf("HeadObject called %d times for an object the listing ruled out", lk.heads)
+	}
+
+	if !mustMatch(t, "-metadata OWNER=e* -metadata owner -tag retention=short-*", c) {
+		t.Errorf("metadata and tag predicates should match")
+	}
+	if mustMatch(t, "-tag retention=long -o -metadata missing -o -tag team", c) {
+		t.Errorf("absent tag values and keys should not match")
+	}
+	if lk.heads != 1 || lk.tagCalls != 1 {
+		t.Errorf("lookups: %d heads, %d tag calls; want 1 each", lk.heads, lk.tagCalls)
+	}
+
+	marker := newCandidate(t, "gone.csv", 0, time.Hour, "", lk)
+	marker.obj.IsDeleteMarker = true
+	if mustMatch(t, "-tag retention", marker) {
+		t.Errorf("a delete marker has no tags")
+	}
+
+	failing := newCandidate(t, "a.csv", 10, time.Hour, "", &fakeLookup{err: errors.New("access denied")})
+	e, _ := parseExpression([]string{"-metadata", "owner"})
+	if _, err := e.match(context.Background(), failing); err == nil {
+		t.Errorf("a failed lookup should fail the evaluation")
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, tokens := range []string{
+		"-name",
+		"-bogus x",
+		"name *.txt",
+		"( -name a",
+		"-name a )",
+		"-name a -o",
+		"!",
+		"-size 1X",
+		"-size 1.5",
+		"-size +-1",
+		"-mtime 1.5",
+		"-age 90d",
+		"-age +soon",
+		"-newer yesterday",
+		"-regex (",
+		"-tag =x",
+	} {
+		if _, err := parseExpression(strings.Fields(tokens)); err == nil {
+			t.Errorf("parseExpression(%q) = nil error", tokens)
+		}
+	}
+}
+
+func TestParseExpressionRequirements(t *testing.T) {
+	e, err := parseExpression(strings.Fields("-name a -o -delete-marker -tag x"))
+	if err != nil {
+		t.Fatalf("parseExpression: %v", err)
+	}
+	if e.remoteOnly != "-delete-marker" || e.versioned != "-delete-marker" {
+		t.Errorf("remoteOnly=%q versioned=%q, want -delete-marker for both", e.remoteOnly, e.versioned)
+	}
+	e, err = parseExpression(strings.Fields("-name a -size +1K -newer 2024-01-01"))
+	if err != nil {
+		t.Fatalf("parseExpression: %v", err)
+	}
+	if e.remoteOnly != "" || e.versioned != "" {
+		t.Errorf("remoteOnly=%q versioned=%q, want none", e.remoteOnly, e.versioned)
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	u, _ := storage.NewStorageURL("s3://bucket/logs/a b.gz")
+	u.SetRelativePath("a b.gz")
+	obj := &storage.Object{StorageURL: u, Size: 42, Etag: "abc", VersionID: "v1"}
+	got := expandCommand([]string{"echo", "{}", "{bucket}:{key}", "{path}|{name}|{size}|{etag}|{version}"}, obj)
+	want := []string{"echo", "s3://bucket/logs/a b.gz?versionId=v1", "bucket:logs/a b.gz", "a b.gz|a b.gz|42|abc|v1"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expandCommand = %q, want %q", got, want)
+	}
+}
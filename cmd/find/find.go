@@ -0,0 +1,573 @@
+// Package find implements the `s6cmd find` command. find walks S3
+// prefixes and local paths, evaluates a find(1)-style expression against
+// every object and runs the requested actions on the objects it matches.
+//
+// The expression follows the sources after a "--" and is built from
+// predicates joined with -and (or nothing), -or, -not/! and parentheses:
+//
+//   - -name/-iname, -path/-ipath: wildcard match on the base name or on
+//     the path relative to the source
+//   - -regex/-iregex: regular expression match on the relative path
+//   - -size [+-]N[BKMGTP]: larger than, smaller than or exactly N bytes
+//   - -mtime [+-]N, -age [+-]DURATION, -newer TIME: modification time
+//   - -storage-class, -etag: listing fields of S3 objects
+//   - -version-id, -delete-marker: versions, with --all-versions
+//   - -metadata KEY[=PATTERN], -tag KEY[=PATTERN]: user metadata and
+//     object tags, looked up per object only when the rest of the
+//     expression did not already rule it out
+//
+// Each source is listed as it is walked and every object is evaluated on
+// the parallel.Manager, so matches print in completion order rather than
+// listing order. A matched object is printed (--print, --print0, --json;
+// --print is the default when no other action is given), then copied
+// (--copy-to), passed to a command (--exec) and deleted (--delete), in
+// that order; an action that fails skips the ones after it, so an object
+// whose copy failed is never deleted.
+//
+// The deletions run once every source was walked, as rm's do: the matches
+// are checked against --max-delete, copied to --backup-dir and deleted in
+// batches, with --task-retries, --failed-out and --report.
+package find
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/internal/report"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+// NewFindCmd creates the `find` command.
+func NewFindCmd() *cobra.Command {
+	o := newOptions()
+	cmd := cobra.Command{
+		Use:     "find [flags] <source>... [-- <expression>]",
+		Short:   "find objects matching an expression and act on them",
+		Example: find_examples,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd, args); err != nil {
+				return err
+			}
+			if err := o.validate(); err != nil {
+				return err
+			}
+			return o.run(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "n", false, "print what --delete, --copy-to and --exec would do without doing it")
+	cmd.Flags().BoolVar(&o.AllVersions, "all-versions", false, "evaluate every version and delete marker of the S3 objects")
+	cmd.Flags().BoolVar(&o.NoFollowSymlinks, "no-follow-symlinks", false, "do not follow symbolic links")
+	cmd.Flags().BoolVar(&o.Print, "print", false, "print the URL of each match, one per line (the default without other actions)")
+	cmd.Flags().BoolVar(&o.Print0, "print0", false, "print the URL of each match followed by a NUL byte, for xargs -0")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print each match as a JSON object, one per line")
+	cmd.Flags().BoolVar(&o.Delete, "delete", false, "delete each match (a version with --all-versions)")
+	cmd.Flags().StringVar(&o.CopyTo, "copy-to", "", "copy each match under this prefix or directory, keeping its path relative to the source")
+	cmd.Flags().StringVar(&o.Exec, "exec", "", "run this command for each match; {} is its URL, see the examples for the other placeholders")
+	o.Inventory.AddToCmd(&cmd)
+	o.Listing.AddToCmd(&cmd)
+	o.Guard.AddToCmd(&cmd)
+	o.Failures.AddToCmd(&cmd)
+	o.Report.AddToCmd(&cmd)
+
+	return &cmd
+}
+
+// Args holds the positional arguments: the sources, and the expression
+// after "--".
+type Args struct {
+	Sources    []string `validate:"required"`
+	Expression []string
+}
+
+// Flags holds the find-specific flags plus the CommonFlags inherited from
+// the parent command.
+type Flags struct {
+	DryRun           bool
+	AllVersions      bool
+	NoFollowSymlinks bool
+	Print            bool
+	Print0           bool
+	JSON             bool
+	Delete           bool
+	CopyTo           string
+	Exec             string
+	// Inventory holds --inventory.
+	Inventory cliutil.InventoryFlags
+	// Listing holds --from-listing and --check-listing.
+	Listing cliutil.ListingFlags
+	// Guard holds --max-delete and --backup-dir.
+	Guard cliutil.DeleteGuard
+	// Failures holds --task-retries, --task-retry-backoff and --failed-out.
+	Failures cliutil.FailureFlags
+	// Report holds --report and --report-format.
+	Report cliutil.ReportFlags
+	cliutil.CommonFlags
+}
+
+// Options is the closure of Args + Flags and the state derived from them
+// by validate.
+type Options struct {
+	Args
+	Flags
+
+	expr    *expression
+	command []string
+	copyDst *storage.StorageURL
+
+	// out is the command's standard output, shared by the tasks.
+	out *lockedWriter
+
+	// deletes collects the matches --delete deletes once the walk is
+	// done.
+	mu      sync.Mutex
+	deletes []*storage.Object
+}
+
+func newOptions() *Options {
+	return &Options{}
+}
+
+func (o *Options) complete(cmd *cobra.Command, args []string) error {
+	o.Sources = args
+	o.Expression = nil
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		o.Sources, o.Expression = args[:dash], args[dash:]
+	}
+	o.CommonFlags = cliutil.LoadParentFlags(cmd)
+	// The stores no-op --delete and --copy-to under --dry-run and log what
+	// they would have done.
+	o.CommonFlags.DryRun = o.DryRun
+	return nil
+}
+
+func (o *Options) validate() error {
+	if err := validator.New().Struct(o.Args); err != nil {
+		return fmt.Errorf("find needs at least one source before the expression: %w", err)
+	}
+	if o.Print0 && o.JSON {
+		return fmt.Errorf("--print0 and --json are mutually exclusive")
+	}
+	if err := o.Guard.Validate(); err != nil {
+		return err
+	}
+	if err := o.Failures.Validate(); err != nil {
+		return err
+	}
+	if err := o.Report.Validate(); err != nil {
+		return err
+	}
+	expr, err := parseExpression(o.Expression)
+	if err != nil {
+		return err
+	}
+	o.expr = expr
+	if expr.versioned != "" && !o.AllVersions {
+		return fmt.Errorf("%s needs --all-versions", expr.versioned)
+	}
+	for _, src := range o.Sources {
+		if strings.HasPrefix(src, "s3://") {
+			continue
+		}
+		if expr.remoteOnly != "" {
+			return fmt.Errorf("%s only applies to s3:// sources, not %q", expr.remoteOnly, src)
+		}
+		if o.AllVersions {
+			return fmt.Errorf("--all-versions only applies to s3:// sources, not %q", src)
+		}
+	}
+	if o.Exec != "" {
+		command, err := cliutil.SplitCommandLine(o.Exec)
+		if err != nil {
+			return fmt.Errorf("--exec: %w", err)
+		}
+		if len(command) == 0 {
+			return fmt.Errorf("--exec: empty command")
+		}
+		o.command = command
+	}
+	if o.CopyTo != "" {
+		dst, err := storage.NewStorageURL(o.CopyTo)
+		if err != nil {
+			return err
+		}
+		if dst.IsWildcard() {
+			return fmt.Errorf("--copy-to %q can not contain glob characters", o.CopyTo)
+		}
+		if dst.IsRemote() && !(dst.IsBucket() || dst.IsPrefix()) {
+			return fmt.Errorf("--copy-to %q must be a bucket or a prefix ending in /", o.CopyTo)
+		}
+		o.copyDst = dst
+	}
+	return nil
+}
+
+// jsonOutput reports whether matches are printed as JSON, by --json or
+// --output json.
+func (o *Options) jsonOutput() bool {
+	return o.JSON || o.CommonFlags.Output == "json"
+}
+
+// printing reports whether matches are printed: by --print, --print0 or
+// --json, or by default when no other action was asked for.
+func (o *Options) printing() bool {
+	return o.Print || o.Print0 || o.JSON || (!o.Delete && o.copyDst == nil && o.command == nil)
+}
+
+func (o *Options) run(ctx context.Context, out io.Writer) error {
+	sources := make([]*storage.StorageURL, 0, len(o.Sources))
+	for _, s := range o.Sources {
+		src, err := storage.NewStorageURL(s, storage.WithAllVersions(o.AllVersions), o.Inventory.Option(), o.Listing.Option())
+		if err != nil {
+			return err
+		}
+		if err := o.Inventory.Validate(src); err != nil {
+			return err
+		}
+		if err := o.Listing.Validate(src); err != nil {
+			return err
+		}
+		if src.IsRemote() && src.VersionID != "" {
+			return fmt.Errorf("source %q: use --all-versions and -version-id to select versions", s)
+		}
+		sources = append(sources, src)
+	}
+
+	store, err := cliutil.NewStorage(ctx, o.CommonFlags)
+	if err != nil {
+		return err
+	}
+	o.out = &lockedWriter{w: out}
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+
+	waiter := parallel.NewWaiter()
+	ec := cliutil.NewErrorCollector("find")
+	o.Failures.Track(ec)
+	drainDone := ec.Drain(waiter)
+	now := time.Now()
+	listed := 0
+	for _, src := range sources {
+		stopped := o.walk(ctx, store, src, func(obj *storage.Object) bool {
+			if obj.Err != nil {
+				ec.Collect(obj.Err)
+				return true
+			}
+			if obj.Type.IsDir() {
+				return true
+			}
+			listed++
+			c := &candidate{obj: obj, path: obj.StorageURL.Relative(), now: now, lookup: store}
+			return parallel.Run(func() error { return o.visit(ctx, store, c) }, waiter)
+		})
+		if stopped {
+			break
+		}
+	}
+	waiter.Wait()
+	drainDone()
+
+	var backupErr error
+	if o.Delete {
+		var err error
+		if backupErr, err = o.deleteMatches(ctx, store, ec, listed); err != nil {
+			return err
+		}
+	}
+	return cliutil.AggregateErrors([]error{ec.Aggregate(), backupErr, o.Report.Close(), o.Failures.WriteFailed()})
+}
+
+// deleteMatches deletes the matches collected by visit, out of the listed
+// objects the sources held: nothing when they exceed --max-delete, and
+// only the matches whose backup to --backup-dir succeeded. Keys that fail
+// with a transient error are deleted again in one batch per retry, and
+// the keys that still fail are collected in ec. It returns the failed
+// backups, and apart the --max-delete refusal.
+func (o *Options) deleteMatches(ctx context.Context, store *storage.Storage, ec *cliutil.ErrorCollector, listed int) (backupErr, err error) {
+	matches := o.deletes
+	if err := o.Guard.Check(len(matches), listed); err != nil {
+		return nil, err
+	}
+	if o.Guard.BackupEnabled() {
+		matches, backupErr = o.Guard.BackupEach(ctx, store, "find", matches)
+	}
+	rops := make([]*report.Operation, len(matches))
+	for i, obj := range matches {
+		rops[i] = o.Report.Start("rm", objectURL(obj), "", obj.Size)
+	}
+	results := o.Failures.RetryEach(ctx, len(matches), func(idx []int) []error {
+		for _, i := range idx {
+			rops[i].Attempted()
+		}
+		return cliutil.DeleteObjects(ctx, store, matches, idx)
+	})
+	for i, err := range results {
+		rops[i].Wrote(matches[i])
+		rops[i].Finish(err)
+		if err != nil {
+			ec.Collect(cliutil.DeleteFailure(matches[i].StorageURL, err))
+		}
+	}
+	return backupErr, nil
+}
+
+// walk lists src and calls fn with every object under it, its relative
+// path set to the path under src. It stops when fn returns false and
+// reports whether it did.
+//
+// A bucket or a prefix ending in "/" is listed recursively, as is a
+// wildcard. Any other S3 URL names one object (all of its versions with
+// --all-versions). A local directory is walked.
+func (o *Options) walk(ctx context.Context, store *storage.Storage, src *storage.StorageURL, fn func(*storage.Object) bool) bool {
+	if !src.IsRemote() {
+		var root string
+		if !src.IsWildcard() {
+			if isDir, err := cliutil.IsLocalDir(src.Absolute()); err == nil && isDir {
+				root = src.Absolute()
+			}
+		}
+		for obj := range store.List(ctx, src, !o.NoFollowSymlinks) {
+			if obj.Err == nil {
+				switch {
+				case root != "":
+					if rel, err := filepath.Rel(root, obj.StorageURL.Absolute()); err == nil {
+						obj.StorageURL.SetRelativePath(filepath.ToSlash(rel))
+					}
+				case !src.IsWildcard():
+					obj.StorageURL.SetRelativePath(obj.StorageURL.Base())
+				}
+			}
+			if !fn(obj) {
+				return true
+			}
+		}
+		return false
+	}
+
+	single := !src.IsWildcard() && !src.IsBucket() && !src.IsPrefix()
+	listURL := src.Clone()
+	listURL.Delimiter = ""
+	for obj := range store.List(ctx, listURL, false) {
+		if obj.Err == nil && obj.StorageURL != nil {
+			// The listing is prefix-based, so a single key also returns the
+			// keys it is a prefix of.
+			if single && obj.StorageURL.Path != src.Path {
+				continue
+			}
+			rel := strings.TrimPrefix(obj.StorageURL.Path, src.Prefix)
+			if single {
+				rel = obj.StorageURL.Base()
+			}
+			obj.StorageURL.SetRelativePath(rel)
+		}
+		if !fn(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// visit evaluates the expression against c and runs the actions on a
+// match.
+func (o *Options) visit(ctx context.Context, store *storage.Storage, c *candidate) error {
+	obj := c.obj
+	ok, err := o.expr.match(ctx, c)
+	if err != nil {
+		return &errorpkg.Error{Op: "find", Src: objectURL(obj), Err: err}
+	}
+	if !ok {
+		return nil
+	}
+	if o.printing() {
+		o.print(c)
+	}
+	if o.copyDst != nil {
+		if err := o.copyTo(ctx, store, obj); err != nil {
+			return err
+		}
+	}
+	if o.command != nil {
+		if err := o.execute(ctx, obj); err != nil {
+			return &errorpkg.Error{Op: "exec", Src: objectURL(obj), Err: err}
+		}
+	}
+	if o.Delete {
+		o.mu.Lock()
+		o.deletes = append(o.deletes, obj)
+		o.mu.Unlock()
+	}
+	return nil
+}
+
+// print writes the match in the output format.
+func (o *Options) print(c *candidate) {
+	obj := c.obj
+	switch {
+	case o.jsonOutput():
+		o.out.Write([]byte(findMessage{
+			Key:            objectURL(obj),
+			Path:           c.path,
+			Etag:           obj.Etag,
+			LastModified:   obj.ModTime,
+			Size:           obj.Size,
+			StorageClass:   string(obj.StorageClass),
+			VersionID:      obj.VersionID,
+			IsDeleteMarker: obj.IsDeleteMarker,
+			Metadata:       c.metadata,
+			Tags:           c.tags,
+		}.JSON() + "\n"))
+	case o.Print0:
+		o.out.Write([]byte(objectURL(obj) + "\x00"))
+	default:
+		o.out.Write([]byte(objectURL(obj) + "\n"))
+	}
+}
+
+// copyTo copies obj under --copy-to, at its path relative to the source.
+func (o *Options) copyTo(ctx context.Context, store *storage.Storage, obj *storage.Object) error {
+	src := obj.StorageURL
+	if obj.IsDeleteMarker {
+		return &errorpkg.Error{Op: "cp", Src: objectURL(obj), Dst: o.copyDst.String(), Err: errors.New("a delete marker can not be copied")}
+	}
+	spec := &cliutil.TransferSpec{Op: "cp", DryRun: o.DryRun, Shared: cliutil.NewSharedFlags()}
+	var (
+		dst *storage.StorageURL
+		err error
+	)
+	switch {
+	case src.IsRemote() && o.copyDst.IsRemote():
+		dst = cliutil.PrepareRemoteDestination(src, o.copyDst, false, true)
+		_, err = spec.Copy(ctx, store, src, dst)
+	case src.IsRemote():
+		if dst, err = cliutil.PrepareLocalDestination(ctx, store, src, o.copyDst, false, true); err == nil {
+			_, err = spec.Download(ctx, store, src, dst, &progressbar.NoOp{})
+		}
+	case o.copyDst.IsRemote():
+		dst = cliutil.PrepareRemoteDestination(src, o.copyDst, false, true)
+		_, err = spec.Upload(ctx, store, src, dst, &progressbar.NoOp{})
+	default:
+		dst = o.copyDst.Join(src.Relative())
+		if _, err = store.Copy(ctx, src, dst, storage.Metadata{}); err == nil {
+			log.Info(log.InfoMessage{Operation: "cp", Source: src.Absolute(), Destination: dst.Absolute()})
+		}
+	}
+	if err != nil {
+		target := o.copyDst.String()
+		if dst != nil {
+			target = dst.String()
+		}
+		return &errorpkg.Error{Op: "cp", Src: objectURL(obj), Dst: target, Err: err}
+	}
+	return nil
+}
+
+// execute runs the --exec command for obj, with its placeholders replaced,
+// and fails when the command exits non-zero. Its output goes to the
+// command's standard output and error.
+func (o *Options) execute(ctx context.Context, obj *storage.Object) error {
+	argv := expandCommand(o.command, obj)
+	if o.DryRun {
+		log.Info(log.InfoMessage{Operation: "exec", Source: strings.Join(argv, " ")})
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = o.out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("`%s` exited with code %d", strings.Join(argv, " "), exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+// expandCommand replaces the placeholders of the --exec command with the
+// values of obj:
+//
+//	{}        the object's URL, as printed
+//	{path}    its path relative to the source
+//	{name}    its base name
+//	{bucket}  its bucket (empty for a local file)
+//	{key}     its key, or the file's path
+//	{size}    its size in bytes
+//	{etag}    its ETag
+//	{version} its version ID
+func expandCommand(command []string, obj *storage.Object) []string {
+	u := obj.StorageURL
+	r := strings.NewReplacer(
+		"{}", objectURL(obj),
+		"{path}", u.Relative(),
+		"{name}", u.Base(),
+		"{bucket}", u.Bucket,
+		"{key}", u.Path,
+		"{size}", strconv.FormatInt(obj.Size, 10),
+		"{etag}", obj.Etag,
+		"{version}", obj.VersionID,
+	)
+	argv := make([]string, len(command))
+	for i, field := range command {
+		argv[i] = r.Replace(field)
+	}
+	return argv
+}
+
+// objectURL is how a match is printed: its s3:// URL with the version ID
+// of a version, which s6cmd commands accept back, or the path of a file.
+func objectURL(obj *storage.Object) string {
+	if obj.VersionID != "" {
+		return obj.StorageURL.Absolute() + "?versionId=" + obj.VersionID
+	}
+	return obj.StorageURL.Absolute()
+}
+
+// lockedWriter serializes the writes of the tasks, so lines printed
+// concurrently do not interleave.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// findMessage is the per-line JSON payload of a match with --json or
+// --output json. Metadata and tags are included when the expression
+// looked them up.
+type findMessage struct {
+	Key            string            `json:"key"`
+	Path           string            `json:"path"`
+	Etag           string            `json:"etag,omitempty"`
+	LastModified   *time.Time        `json:"last_modified,omitempty"`
+	Size           int64             `json:"size"`
+	StorageClass   string            `json:"storage_class,omitempty"`
+	VersionID      string            `json:"version_id,omitempty"`
+	IsDeleteMarker bool              `json:"is_delete_marker,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+func (m findMessage) String() string { return m.JSON() }
+func (m findMessage) JSON() string   { return strutil.SchemaJSON("find.match/v1", m) }
@@ -11,4 +11,12 @@ Example 2: Download all objects under a prefix recursively
 Example 3: Download with 8 concurrent workers
 
          s6cmd get --recursive --jobs 8 s3://bucket/prefix/ ./local-dir/
+
+Example 4: Download a prefix and record the outcome of every object in a CSV report
+
+         s6cmd get --recursive --report report.csv s3://bucket/prefix/ ./local-dir/
+
+Example 5: Download a prefix showing the objects and bytes downloaded and the throughput
+
+         s6cmd get --recursive --jobs 8 --show-progress s3://bucket/prefix/ ./local-dir/
 `
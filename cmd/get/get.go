@@ -2,12 +2,15 @@ package get
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/progressbar"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -41,6 +44,8 @@ func NewGetCmd() *cobra.Command {
 	// (as opposed to --jobs, which bounds how many objects transfer at once).
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", cliutil.DefaultCopyConcurrency, "number of concurrent parts transferred per object")
 	cmd.Flags().IntVar(&o.PartSizeMiB, "part-size", cliutil.DefaultPartSizeMiB, "size of each part transferred per object, in MiB")
+	o.Report.AddToCmd(&cmd)
+	o.Progress.AddToCmd(&cmd)
 
 	return &cmd
 }
@@ -57,6 +62,12 @@ type Flags struct {
 	// tuning, converted to bytes via cliutil.PartSizeBytesFromMiB.
 	Concurrency int
 	PartSizeMiB int
+	// Report holds --report and --report-format.
+	Report cliutil.ReportFlags
+	// Progress holds --show-progress and --show-transfers. The keys are
+	// listed without their sizes, so the progress has the bytes
+	// downloaded but no percentage or ETA.
+	Progress cliutil.ProgressFlags
 }
 
 type Options struct {
@@ -82,10 +93,23 @@ func (o *Options) validate() error {
 		return err
 	}
 
-	return nil
+	return o.Report.Validate()
 }
 
 func (o *Options) run(ctx context.Context) error {
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+	err := o.get(ctx)
+	// get returns once every download finished, so the report is
+	// complete.
+	if cerr := o.Report.Close(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+func (o *Options) get(ctx context.Context) error {
 	srcURL, err := storage.NewStorageURL(o.S3Uri)
 	if err != nil {
 		return err
@@ -104,7 +128,13 @@ func (o *Options) run(ctx context.Context) error {
 		return err
 	}
 
-	return downloadS3ToLocal(ctx, store, srcURL, destURL, o.Recursive, o.Jobs, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB))
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
+	}
+	pb.Start()
+	defer pb.Finish()
+	return downloadS3ToLocal(ctx, store, srcURL, destURL, o.Recursive, o.Jobs, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB), &o.Report, pb)
 }
 
 func listS3KeysForGet(ctx context.Context, store *storage.Storage, src *storage.StorageURL, recursive bool) ([]string, string, error) {
@@ -137,7 +167,7 @@ func listS3KeysForGet(ctx context.Context, store *storage.Storage, src *storage.
 	return []string{src.Path}, src.Path, nil
 }
 
-func downloadS3ToLocal(ctx context.Context, store *storage.Storage, src, dest *storage.StorageURL, recursive bool, jobs, concurrency int, partSize int64) error {
+func downloadS3ToLocal(ctx context.Context, store *storage.Storage, src, dest *storage.StorageURL, recursive bool, jobs, concurrency int, partSize int64, rep *cliutil.ReportFlags, pb progressbar.ProgressBar) error {
 	keys, srcPrefix, err := listS3KeysForGet(ctx, store, src, recursive)
 	if err != nil {
 		return err
@@ -192,13 +222,22 @@ func downloadS3ToLocal(ctx context.Context, store *storage.Storage, src, dest *s
 		}
 		dlKey := key
 		dlPath := destPath
-		tasks = append(tasks, func() error {
-			if err := store.DownloadFile(ctx, src.Bucket, dlKey, dlPath, concurrency, partSize); err != nil {
+		dlURL := "s3://" + src.Bucket + "/" + dlKey
+		pb.IncrementTotalObjects()
+		rop := rep.Start("get", dlURL, dlPath, 0)
+		tasks = append(tasks, rop.Finishes(rop.Attempt(func() error {
+			pt := pb.Transfer(dlKey, 0)
+			defer pt.Finish()
+			if err := store.DownloadFile(ctx, src.Bucket, dlKey, dlPath, concurrency, partSize, cliutil.CountProgress(pt)); err != nil {
 				return err
 			}
-			log.Info(log.InfoMessage{Operation: "get", Source: "s3://" + src.Bucket + "/" + dlKey, Destination: dlPath})
+			pt.IncrementCompletedObjects()
+			if fi, err := os.Stat(dlPath); err == nil {
+				rop.Wrote(&storage.Object{Size: fi.Size()})
+			}
+			log.Info(log.InfoMessage{Operation: "get", Source: dlURL, Destination: dlPath})
 			return nil
-		})
+		})))
 	}
 
 	return cliutil.RunTasks(jobs, tasks)
@@ -15,4 +15,8 @@ Example 3: Print a remote object's metadata with a specific version
 Example 4: Print metadata for an object whose key contains glob characters
 
          s6cmd head --raw "s3://bucket/prefix/file*.txt"
+
+Example 5: Print only the ETag and size of a remote object
+
+         s6cmd head --format '{{.ETag}} {{.Size}}' s3://bucket/prefix/object
 `
@@ -1,7 +1,8 @@
 // Package head implements the `s6cmd head` command. It is the JSON-leaning
 // counterpart to `stat`: where stat prints multi-line human-readable output,
 // head emits a single JSON object per result so it is easy to consume from
-// scripts.
+// scripts. --format and --output csv|tsv print the result through package
+// outfmt instead.
 //
 // For a bucket target it HeadBuckets and prints the bucket URL; for an
 // object target it HeadObjects and prints the object metadata as JSON.
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/outfmt"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/LinPr/s6cmd/strutil"
 	"github.com/go-playground/validator/v10"
@@ -42,6 +44,7 @@ func NewHeadCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&o.VersionID, "version-id", "", "use the specified version of an object")
+	cmd.Flags().StringVar(&o.Format, "format", "", `print the metadata with a Go template instead of JSON, e.g. '{{.ETag}} {{.Size}}'`)
 	cmd.Flags().BoolVar(&o.Raw, "raw", false, "disable wildcard operations, useful with filenames that contain glob characters")
 
 	return &cmd
@@ -56,6 +59,7 @@ type Args struct {
 type Flags struct {
 	VersionID string
 	Raw       bool
+	Format    string
 }
 
 // Options is the closure of Args + Flags + CommonFlags.
@@ -105,13 +109,32 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 		return err
 	}
 
+	columns := objectColumns
 	if src.IsBucket() {
-		return o.runBucket(ctx, store, src, out)
+		columns = bucketColumns
 	}
-	return o.runObject(ctx, store, src, out)
+	printer, err := outfmt.New(out, o.common.Output, o.Format, columns...)
+	if err != nil {
+		return err
+	}
+	if src.IsBucket() {
+		err = o.runBucket(ctx, store, src, printer, out)
+	} else {
+		err = o.runObject(ctx, store, src, printer, out)
+	}
+	if err != nil || printer == nil {
+		return err
+	}
+	return printer.Flush()
 }
 
-func (o *Options) runBucket(ctx context.Context, store *storage.Storage, src *storage.StorageURL, out io.Writer) error {
+// The csv and tsv columns of bucket and object results.
+var (
+	bucketColumns = []string{"url", "bucket", "region"}
+	objectColumns = []string{"url", "key", "size", "last_modified", "etag", "content_type", "storage_class", "version_id", "server_side_encryption"}
+)
+
+func (o *Options) runBucket(ctx context.Context, store *storage.Storage, src *storage.StorageURL, printer *outfmt.Printer, out io.Writer) error {
 	bucket, err := store.HeadBucket(ctx, src.Bucket)
 	if err != nil {
 		return err
@@ -120,11 +143,15 @@ func (o *Options) runBucket(ctx context.Context, store *storage.Storage, src *st
 	if bucket != nil {
 		msg.Region = bucket.Region
 	}
+	if printer != nil {
+		printer.Print(outfmt.Row{Type: outfmt.TypeBucket, URL: msg.Bucket, Bucket: src.Bucket, Region: msg.Region})
+		return nil
+	}
 	fmt.Fprintln(out, msg.JSON())
 	return nil
 }
 
-func (o *Options) runObject(ctx context.Context, store *storage.Storage, src *storage.StorageURL, out io.Writer) error {
+func (o *Options) runObject(ctx context.Context, store *storage.Storage, src *storage.StorageURL, printer *outfmt.Printer, out io.Writer) error {
 	obj, md, err := store.HeadObject(ctx, src)
 	if err != nil {
 		return err
@@ -136,6 +163,26 @@ func (o *Options) runObject(ctx context.Context, store *storage.Storage, src *st
 	// carries it when set by the user, otherwise we leave it empty so
 	// the JSON omits the field.
 	versionID := src.VersionID
+	if printer != nil {
+		row := outfmt.Row{
+			Type:                 outfmt.TypeFile,
+			URL:                  obj.String(),
+			Bucket:               src.Bucket,
+			Key:                  src.Path,
+			Size:                 obj.Size,
+			ETag:                 obj.Etag,
+			StorageClass:         string(obj.StorageClass),
+			VersionID:            versionID,
+			ContentType:          mdContentType(md),
+			ServerSideEncryption: mdEncryption(md),
+			Metadata:             mdUserDefined(md),
+		}
+		if obj.ModTime != nil {
+			row.ModTime = *obj.ModTime
+		}
+		printer.Print(row)
+		return nil
+	}
 	msg := headObjectMessage{
 		Key:                  obj.String(),
 		ContentType:          mdContentType(md),
@@ -186,7 +233,7 @@ type headObjectMessage struct {
 }
 
 func (m headObjectMessage) String() string { return m.JSON() }
-func (m headObjectMessage) JSON() string   { return strutil.JSON(m) }
+func (m headObjectMessage) JSON() string   { return strutil.SchemaJSON("head.object/v1", m) }
 
 // headBucketMessage is the JSON payload for a HeadBucket result.
 type headBucketMessage struct {
@@ -195,4 +242,4 @@ type headBucketMessage struct {
 }
 
 func (m headBucketMessage) String() string { return m.JSON() }
-func (m headBucketMessage) JSON() string   { return strutil.JSON(m) }
+func (m headBucketMessage) JSON() string   { return strutil.SchemaJSON("head.bucket/v1", m) }
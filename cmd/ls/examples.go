@@ -107,4 +107,59 @@ const ls_examples = `Example 1: Listing all user owned buckets
 
                                      PRE somePrefix/
           2013-07-25 17:06:27         88 test.txt
+
+       Example 7: Listing the objects matching an expression
+
+       The following ls command lists the objects bigger than 100 MiB that
+       were not modified for 30 days. The filter applies to objects only;
+       the prefixes of a non-recursive listing are still shown:
+
+          s6cmd ls --recursive --filter 'size > 100MiB && mtime < now-30d' s3://mybucket/
+
+       Example 8: Listing the ten biggest objects
+
+       The following ls command lists every object under the prefix, sorts
+       them by size and prints the ten biggest:
+
+          s6cmd ls --recursive --sort size --reverse --max-items 10 s3://mybucket/logs/
+
+       Example 9: Listing objects by size and age
+
+       The following ls command lists the objects of at least 1 GiB that
+       were not modified for 90 days. --newer-than and --older-than take a
+       duration (90d, 12h) or a time (2024-01-01, 2024-01-01T00:00:00Z):
+
+          s6cmd ls --recursive --min-size 1GiB --older-than 90d s3://mybucket/
+
+       Example 10: Resuming a listing
+
+       The following ls command lists the keys after the last one an
+       interrupted listing printed, 1000 at a time:
+
+          s6cmd ls --recursive --start-after logs/2024/06/17.gz --max-items 1000 s3://mybucket/
+
+       Example 11: Choosing the columns
+
+       The following ls commands print the key, size and Unix modification
+       time of every object separated by tabs, then the same listing as CSV
+       with a header line:
+
+          s6cmd ls --recursive --format '{{.Key}}\t{{.Size}}\t{{.ModTime.Unix}}' s3://mybucket/
+          s6cmd --output csv ls --recursive s3://mybucket/
+
+       Example 12: Listing from an S3 Inventory report
+
+       The following ls command lists the objects of the bucket as the
+       daily inventory report recorded them, without listing the bucket:
+
+          s6cmd ls --recursive --inventory s3://inventory-bucket/mybucket/daily/2026-10-18T01-00Z/manifest.json s3://mybucket/
+
+       Example 13: Saving a listing for later commands
+
+       The following ls command lists a prefix once and saves the listing,
+       which cp, rm, du, sync and find then read with --from-listing
+       instead of listing the prefix again:
+
+          s6cmd ls --recursive --save-listing listing.jsonl s3://mybucket/logs/ > /dev/null
+          s6cmd du --from-listing listing.jsonl s3://mybucket/logs/
 `
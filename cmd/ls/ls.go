@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/internal/outfmt"
+	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	s3store "github.com/LinPr/s6cmd/storage/s3"
 	"github.com/LinPr/s6cmd/strutil"
@@ -48,6 +52,18 @@ func NewLsCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&o.StorageClass, "storage-class", "s", false, "show storage class in output")
 	cmd.Flags().BoolVarP(&o.ShowFullPath, "show-fullpath", "", false, "show absolute s3:// URLs instead of relative keys")
 	cmd.Flags().BoolVarP(&o.AllVersions, "all-versions", "", false, "list all object versions and delete markers with their version IDs")
+	cmd.Flags().StringVar(&o.Filter, "filter", "", `only list objects matching the expression, e.g. 'size > 100MiB && mtime < now-30d'`)
+	cmd.Flags().StringVar(&o.MinSize, "min-size", "", "only list objects of at least this size, e.g. 10MiB")
+	cmd.Flags().StringVar(&o.MaxSize, "max-size", "", "only list objects of at most this size, e.g. 1GiB")
+	cmd.Flags().StringVar(&o.NewerThan, "newer-than", "", "only list objects modified after this duration ago or time, e.g. 7d or 2024-01-01")
+	cmd.Flags().StringVar(&o.OlderThan, "older-than", "", "only list objects modified before this duration ago or time, e.g. 30d or 2024-01-01T00:00:00Z")
+	cmd.Flags().StringVar(&o.Sort, "sort", "", "sort objects by name, size or time (the whole listing is read first)")
+	cmd.Flags().BoolVar(&o.Reverse, "reverse", false, "reverse the sort order (by name unless --sort is given)")
+	cmd.Flags().IntVar(&o.MaxItems, "max-items", 0, "stop after listing this many objects (0 = no limit)")
+	cmd.Flags().StringVar(&o.Format, "format", "", `print each row with a Go template, e.g. '{{.Key}}\t{{.Size}}\t{{.ModTime.Unix}}'`)
+	cmd.Flags().StringVar(&o.StartAfter, "start-after", "", "only list keys after this one, e.g. the last key of an interrupted listing")
+	o.Inventory.AddToCmd(&cmd)
+	cmd.Flags().StringVar(&o.SaveListing, "save-listing", "", "also save the listed objects to this file for cp, rm, du, sync and find --from-listing (needs --recursive)")
 
 	return &cmd
 }
@@ -64,12 +80,47 @@ type Flags struct {
 	StorageClass bool
 	ShowFullPath bool
 	AllVersions  bool
+	Filter       string
+	MinSize      string
+	MaxSize      string
+	NewerThan    string
+	OlderThan    string
+	Sort         string
+	Reverse      bool
+	MaxItems     int
+	StartAfter   string
+	Format       string
+	// Inventory holds --inventory.
+	Inventory   cliutil.InventoryFlags
+	SaveListing string
 }
 
 type Options struct {
 	Args
 	Flags
 	common cliutil.CommonFlags
+	// filter is the parsed --filter; nil matches every object.
+	filter *filter.Filter
+	// now is the time --newer-than and --older-than durations count back
+	// from.
+	now time.Time
+	// Parsed --min-size, --max-size (-1 when unset), --newer-than and
+	// --older-than (zero when unset).
+	minSize   int64
+	maxSize   int64
+	newerThan time.Time
+	olderThan time.Time
+	// held are the objects kept back for sorting; taken counts the objects
+	// printed so far.
+	held  []*storage.Object
+	taken int
+	// printer renders the rows for --format and --output csv|tsv; nil
+	// prints text or JSON.
+	printer *outfmt.Printer
+	// listing saves the printed objects for --save-listing; listingErr is
+	// the first error writing it.
+	listing    *storage.ListingWriter
+	listingErr error
 }
 
 func newOptions() *Options {
@@ -81,6 +132,7 @@ func (o *Options) complete(cmd *cobra.Command, args []string) error {
 		o.S3Uri = args[0]
 	}
 	o.common = cliutil.LoadParentFlags(cmd)
+	o.now = time.Now()
 	return nil
 }
 
@@ -88,6 +140,46 @@ func (o *Options) validate() error {
 	if err := validator.New().Struct(o); err != nil {
 		return err
 	}
+	if o.SaveListing != "" && !o.Recursive {
+		return fmt.Errorf("--save-listing needs --recursive")
+	}
+	if o.SaveListing != "" && o.AllVersions {
+		return fmt.Errorf("--save-listing does not save versions, drop --all-versions")
+	}
+	if err := o.validateSaveListing(); err != nil {
+		return err
+	}
+	return o.parseSelection()
+}
+
+// validateSaveListing rejects --save-listing with anything that would
+// leave out objects ls lists: a saved listing stands in for the full
+// listing of its URL, and sync --delete deletes what it does not hold.
+func (o *Options) validateSaveListing() error {
+	if o.SaveListing == "" {
+		return nil
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"--filter", o.Filter != ""},
+		{"--min-size", o.MinSize != ""},
+		{"--max-size", o.MaxSize != ""},
+		{"--newer-than", o.NewerThan != ""},
+		{"--older-than", o.OlderThan != ""},
+		{"--start-after", o.StartAfter != ""},
+		{"--max-items", o.MaxItems != 0},
+		{"--no-paginate", o.common.NoPaginate},
+		{"--inventory", o.Inventory.Manifest != ""},
+	} {
+		if f.set {
+			return fmt.Errorf("--save-listing saves complete listings only, drop %s", f.name)
+		}
+	}
+	if u, err := storage.NewStorageURL(o.S3Uri); err == nil && u.IsWildcard() {
+		return fmt.Errorf("--save-listing saves complete listings only, list a bucket or prefix instead of %q", o.S3Uri)
+	}
 	return nil
 }
 
@@ -102,24 +194,64 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 		return err
 	}
 
-	if o.S3Uri == "" {
-		return o.listBuckets(ctx, cli, out)
+	var parsedUri *storage.StorageURL
+	if o.S3Uri != "" {
+		if parsedUri, err = storage.NewStorageURL(o.S3Uri); err != nil {
+			return err
+		}
 	}
-
-	parsedUri, err := storage.NewStorageURL(o.S3Uri)
-	if err != nil {
-		return err
+	if o.Inventory.Manifest != "" && (parsedUri == nil || parsedUri.Bucket == "") {
+		return fmt.Errorf("--inventory needs an s3:// bucket or prefix")
 	}
-	if parsedUri.Bucket == "" {
-		return o.listBuckets(ctx, cli, out)
+	if o.SaveListing != "" {
+		if parsedUri == nil || parsedUri.Bucket == "" {
+			return fmt.Errorf("--save-listing needs an s3:// bucket or prefix")
+		}
+		if o.listing, err = storage.CreateListing(o.SaveListing, parsedUri, o.now); err != nil {
+			return err
+		}
+		defer o.listing.Close()
 	}
-
-	if o.AllVersions {
-		return o.listAllVersions(ctx, cli, out)
+	if parsedUri == nil || parsedUri.Bucket == "" {
+		if o.printer, err = outfmt.New(out, o.common.Output, o.Format, bucketColumns...); err != nil {
+			return err
+		}
+		err = o.listBuckets(ctx, cli, out)
+	} else {
+		columns := objectColumns
+		if o.AllVersions {
+			columns = append(columns, "version_id", "is_delete_marker")
+		}
+		if o.printer, err = outfmt.New(out, o.common.Output, o.Format, columns...); err != nil {
+			return err
+		}
+		if o.filter, err = filter.Parse(o.Filter); err != nil {
+			return err
+		}
+		if o.AllVersions || o.Inventory.Manifest != "" {
+			err = o.listStream(ctx, cli, out)
+		} else {
+			err = o.listObjects(ctx, cli, parsedUri, out)
+		}
+	}
+	if err == nil && o.listing != nil {
+		if err = o.listingErr; err == nil {
+			err = o.listing.Commit()
+		}
 	}
-	return o.listObjects(ctx, cli, parsedUri.Bucket, parsedUri.Path, out)
+	if err != nil || o.printer == nil {
+		return err
+	}
+	return o.printer.Flush()
 }
 
+// The csv and tsv columns of bucket and object rows. --all-versions adds
+// version_id and is_delete_marker to the object columns.
+var (
+	bucketColumns = []string{"bucket", "created_at"}
+	objectColumns = []string{"type", "url", "key", "size", "last_modified", "etag", "storage_class"}
+)
+
 const lsDateFormat = "2006-01-02 15:04:05"
 
 func (o *Options) listBuckets(ctx context.Context, cli *s3store.S3Store, out io.Writer) error {
@@ -129,6 +261,15 @@ func (o *Options) listBuckets(ctx context.Context, cli *s3store.S3Store, out io.
 	}
 	for _, bucket := range buckets {
 		name := aws.ToString(bucket.Name)
+		if o.printer != nil {
+			o.printer.Print(outfmt.Row{
+				Type:         outfmt.TypeBucket,
+				URL:          "s3://" + name,
+				Bucket:       name,
+				CreationDate: aws.ToTime(bucket.CreationDate),
+			})
+			continue
+		}
 		if o.jsonOutput() {
 			fmt.Fprintln(out, lsBucketMessage{
 				CreationDate: bucket.CreationDate,
@@ -141,80 +282,102 @@ func (o *Options) listBuckets(ctx context.Context, cli *s3store.S3Store, out io.
 	return nil
 }
 
-func (o *Options) listObjects(ctx context.Context, cli *s3store.S3Store, bucket, key string, out io.Writer) error {
+// listObjects lists the objects under listURL. --filter and the other
+// selections apply to the objects only: the common prefixes of a
+// non-recursive listing are printed regardless, ahead of the objects.
+func (o *Options) listObjects(ctx context.Context, cli *s3store.S3Store, listURL *storage.StorageURL, out io.Writer) error {
+	bucket, key := listURL.Bucket, listURL.Path
 	delimiter := "/"
 	if o.Recursive {
 		delimiter = ""
 	}
-	objects, prefixes, err := cli.ListObjectsWithPagination(ctx, bucket, key, delimiter, o.PageSize, o.common.NoPaginate)
-	if err != nil {
-		return err
-	}
 
-	// Non-recursive listing prints CommonPrefixes first (aws s3 ls style).
+	var (
+		totalSize int64
+		totalCnt  int64
+		prefixes  []string
+		pending   []*storage.Object
+	)
+	print := func(obj *storage.Object) {
+		totalSize += obj.Size
+		totalCnt++
+		o.printObject(out, obj)
+	}
+	// Non-recursive listing prints CommonPrefixes first (aws s3 ls style),
+	// so objects are printed as pages arrive only in a recursive listing.
+	emit := print
 	if !o.Recursive {
-		for _, p := range prefixes {
-			prefix := aws.ToString(p.Prefix)
-			if o.jsonOutput() {
-				fmt.Fprintln(out, lsObjectMessage{
-					Key:  "s3://" + bucket + "/" + prefix,
-					Type: "directory",
-				}.JSON())
+		emit = func(obj *storage.Object) { pending = append(pending, obj) }
+	}
+	err := cli.ListObjectPages(ctx, bucket, key, delimiter, o.StartAfter, o.PageSize, func(objects []types.Object, commonPrefixes []types.CommonPrefix) bool {
+		for _, p := range commonPrefixes {
+			prefixes = append(prefixes, aws.ToString(p.Prefix))
+		}
+		for _, obj := range objects {
+			if obj.Key == nil {
+				continue
+			}
+			// S3 sometimes returns the prefix itself as a "directory" object; skip
+			// zero-size keys that end with "/".
+			keyName := aws.ToString(obj.Key)
+			if keyName == key || (obj.Size != nil && *obj.Size == 0 && keyName == key+"/") {
+				continue
+			}
+			listed := s3store.ListedObject(listURL, obj)
+			if !o.selected(listed) {
 				continue
 			}
-			fmt.Fprintf(out, "%s %s\n", formatDirColumn(), prefix)
+			if !o.take(listed, emit) {
+				return false
+			}
 		}
+		return !o.common.NoPaginate
+	})
+	if err != nil {
+		return err
 	}
 
-	var (
-		totalSize int64
-		totalCnt  int64
-	)
-	for _, obj := range objects {
-		if obj.Key == nil {
+	for _, prefix := range prefixes {
+		if o.printer != nil {
+			o.printer.Print(outfmt.Row{
+				Type:   outfmt.TypeDirectory,
+				URL:    "s3://" + bucket + "/" + prefix,
+				Bucket: bucket,
+				Key:    prefix,
+			})
 			continue
 		}
-		// S3 sometimes returns the prefix itself as a "directory" object; skip
-		// zero-size keys that end with "/".
-		keyName := aws.ToString(obj.Key)
-		if keyName == key || (obj.Size != nil && *obj.Size == 0 && keyName == key+"/") {
-			continue
-		}
-		size := aws.ToInt64(obj.Size)
-		totalSize += size
-		totalCnt++
 		if o.jsonOutput() {
 			fmt.Fprintln(out, lsObjectMessage{
-				Key:          "s3://" + bucket + "/" + keyName,
-				Type:         "file",
-				Etag:         strutil.TrimQuotes(aws.ToString(obj.ETag)),
-				LastModified: obj.LastModified,
-				Size:         size,
-				StorageClass: string(obj.StorageClass),
+				Key:  "s3://" + bucket + "/" + prefix,
+				Type: "directory",
 			}.JSON())
 			continue
 		}
-		fmt.Fprintln(out, formatObjectLine(obj, o.Flags, bucket))
+		fmt.Fprintf(out, "%s %s\n", formatDirColumn(), prefix)
+	}
+	for _, obj := range pending {
+		print(obj)
 	}
+	o.flush(print)
 
-	if o.Summarize && !o.jsonOutput() {
-		sizeStr := fmt.Sprintf("%d", totalSize)
-		if o.Humanize {
-			sizeStr = strutil.HumanizeBytes(totalSize)
-		}
-		fmt.Fprintf(out, "\nTotal Objects: %d\n   Total Size: %s\n", totalCnt, sizeStr)
+	if o.Summarize && o.printer == nil {
+		log.Fprint(out, o.jsonOutput(), lsSummaryMessage{Objects: totalCnt, Size: totalSize, humanize: o.Humanize})
 	}
 	return nil
 }
 
-// listAllVersions lists every object version and delete marker under the
-// target via the store's ListObjectVersions path (the same one rm
-// --all-versions uses), printing the version ID as an extra column.
+// listStream lists the target through the store's List channel, the same
+// one rm uses: every object version and delete marker with --all-versions,
+// printing the version ID as an extra column, and the rows of an S3
+// Inventory report with --inventory.
 //
-// --page-size and --no-paginate are not honoured here: the version listing
-// streams through the shared storage.List channel which paginates fully.
-func (o *Options) listAllVersions(ctx context.Context, cli *s3store.S3Store, out io.Writer) error {
-	listURL, err := storage.NewStorageURL(o.S3Uri, storage.WithAllVersions(true))
+// --page-size and --no-paginate are not honoured here: the channel
+// paginates fully. --start-after is applied to the listed keys instead of
+// on the server, and directories are printed as they are listed rather
+// than ahead of the objects.
+func (o *Options) listStream(ctx context.Context, cli *s3store.S3Store, out io.Writer) error {
+	listURL, err := storage.NewStorageURL(o.S3Uri, storage.WithAllVersions(o.AllVersions), o.Inventory.Option())
 	if err != nil {
 		return err
 	}
@@ -223,16 +386,38 @@ func (o *Options) listAllVersions(ctx context.Context, cli *s3store.S3Store, out
 		// under the prefix rather than collapsing sub-prefixes.
 		listURL.Delimiter = ""
 	}
-
+	dir := listURL.Path[:strings.LastIndex(listURL.Path, "/")+1]
+
+	// Stop the listing once --max-items objects were printed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var totalSize, totalCnt int64
+	print := func(obj *storage.Object) {
+		totalSize += obj.Size
+		totalCnt++
+		o.printObject(out, obj)
+	}
 	for obj := range cli.List(ctx, listURL, false) {
 		if obj.Err != nil {
 			// An empty prefix is not an error for ls; print nothing.
 			if errors.Is(obj.Err, errorpkg.ErrNoObjectFound) {
-				return nil
+				break
 			}
 			return obj.Err
 		}
+		if o.StartAfter != "" && obj.StorageURL.Path <= o.StartAfter {
+			continue
+		}
 		if obj.Type.IsDir() {
+			if o.printer != nil {
+				o.printer.Print(outfmt.Row{
+					Type:   outfmt.TypeDirectory,
+					URL:    obj.StorageURL.Absolute(),
+					Bucket: obj.StorageURL.Bucket,
+					Key:    obj.StorageURL.Path,
+				})
+				continue
+			}
 			if o.jsonOutput() {
 				fmt.Fprintln(out, lsObjectMessage{
 					Key:  obj.StorageURL.Absolute(),
@@ -243,24 +428,69 @@ func (o *Options) listAllVersions(ctx context.Context, cli *s3store.S3Store, out
 			fmt.Fprintf(out, "%s %s\n", formatDirColumn(), obj.StorageURL.Path)
 			continue
 		}
-		if o.jsonOutput() {
-			fmt.Fprintln(out, lsObjectMessage{
-				Key:            obj.StorageURL.Absolute(),
-				Type:           "file",
-				Etag:           obj.Etag,
-				LastModified:   obj.ModTime,
-				Size:           obj.Size,
-				StorageClass:   string(obj.StorageClass),
-				VersionID:      obj.VersionID,
-				IsDeleteMarker: obj.IsDeleteMarker,
-			}.JSON())
+		obj.StorageURL.SetRelativePath(strings.TrimPrefix(obj.StorageURL.Path, dir))
+		if !o.selected(obj) {
 			continue
 		}
-		fmt.Fprintln(out, o.formatVersionLine(obj))
+		if !o.take(obj, print) {
+			cancel()
+			break
+		}
+	}
+	o.flush(print)
+
+	if o.Summarize && o.printer == nil {
+		log.Fprint(out, o.jsonOutput(), lsSummaryMessage{Objects: totalCnt, Size: totalSize, humanize: o.Humanize})
 	}
 	return nil
 }
 
+// printObject prints one object row, as JSON with --output json. In
+// --all-versions output the row also carries the version ID. With
+// --save-listing the object is saved as well.
+func (o *Options) printObject(out io.Writer, obj *storage.Object) {
+	if o.listing != nil && o.listingErr == nil {
+		o.listingErr = o.listing.Write(obj)
+	}
+	if o.printer != nil {
+		row := outfmt.Row{
+			Type:         outfmt.TypeFile,
+			URL:          obj.StorageURL.Absolute(),
+			Bucket:       obj.StorageURL.Bucket,
+			Key:          obj.StorageURL.Path,
+			Size:         obj.Size,
+			ETag:         obj.Etag,
+			StorageClass: string(obj.StorageClass),
+		}
+		if obj.ModTime != nil {
+			row.ModTime = *obj.ModTime
+		}
+		if o.AllVersions {
+			row.VersionID = obj.VersionID
+			row.IsDeleteMarker = obj.IsDeleteMarker
+		}
+		o.printer.Print(row)
+		return
+	}
+	if o.jsonOutput() {
+		msg := lsObjectMessage{
+			Key:          obj.StorageURL.Absolute(),
+			Type:         "file",
+			Etag:         obj.Etag,
+			LastModified: obj.ModTime,
+			Size:         obj.Size,
+			StorageClass: string(obj.StorageClass),
+		}
+		if o.AllVersions {
+			msg.VersionID = obj.VersionID
+			msg.IsDeleteMarker = obj.IsDeleteMarker
+		}
+		fmt.Fprintln(out, msg.JSON())
+		return
+	}
+	fmt.Fprintln(out, o.formatObjectLine(obj))
+}
+
 // formatDirColumn renders the leading columns for a directory (CommonPrefix)
 // row in non-recursive aws s3 ls output: a fixed-width date placeholder and a
 // "PRE" marker.
@@ -269,41 +499,10 @@ func formatDirColumn() string {
 	return fmt.Sprintf("%-20s %10s", "", "PRE")
 }
 
-// formatObjectLine builds a single aws s3 ls style line for one object.
-func formatObjectLine(obj types.Object, f Flags, bucket string) string {
-	date := ""
-	if obj.LastModified != nil {
-		date = obj.LastModified.Format(lsDateFormat)
-	}
-
-	var sizeStr string
-	if f.Humanize {
-		sizeStr = strutil.HumanizeBytes(aws.ToInt64(obj.Size))
-	} else {
-		sizeStr = fmt.Sprintf("%d", aws.ToInt64(obj.Size))
-	}
-
-	key := aws.ToString(obj.Key)
-	if f.ShowFullPath {
-		key = "s3://" + bucket + "/" + key
-	}
-
-	// Optional extra columns between size and key: etag, storage class.
-	var extra string
-	if f.Etag {
-		extra += " " + strutil.TrimQuotes(aws.ToString(obj.ETag))
-	}
-	if f.StorageClass {
-		extra += " " + string(obj.StorageClass)
-	}
-
-	return fmt.Sprintf("%s %10s%s %s", date, sizeStr, extra, key)
-}
-
-// formatVersionLine builds a single line for one object version (or delete
-// marker) in --all-versions output. The version ID is always printed as an
-// extra column between size and key; delete markers are flagged.
-func (o *Options) formatVersionLine(obj *storage.Object) string {
+// formatObjectLine builds a single aws s3 ls style line for one object. In
+// --all-versions output the version ID is printed as an extra column
+// between size and key, and delete markers are flagged.
+func (o *Options) formatObjectLine(obj *storage.Object) string {
 	date := ""
 	if obj.ModTime != nil {
 		date = obj.ModTime.Format(lsDateFormat)
@@ -321,6 +520,7 @@ func (o *Options) formatVersionLine(obj *storage.Object) string {
 		key = obj.StorageURL.Absolute()
 	}
 
+	// Optional extra columns between size and key: etag, storage class.
 	var extra string
 	if o.Etag {
 		extra += " " + obj.Etag
@@ -328,6 +528,10 @@ func (o *Options) formatVersionLine(obj *storage.Object) string {
 	if o.StorageClass {
 		extra += " " + string(obj.StorageClass)
 	}
+	if !o.AllVersions {
+		return fmt.Sprintf("%s %10s%s %s", date, sizeStr, extra, key)
+	}
+
 	versionID := obj.VersionID
 	if versionID == "" {
 		versionID = "null"
@@ -336,7 +540,6 @@ func (o *Options) formatVersionLine(obj *storage.Object) string {
 	if obj.IsDeleteMarker {
 		marker = " (delete-marker)"
 	}
-
 	return fmt.Sprintf("%s %10s%s %s %s%s", date, sizeStr, extra, versionID, key, marker)
 }
 
@@ -354,7 +557,7 @@ type lsObjectMessage struct {
 }
 
 func (m lsObjectMessage) String() string { return m.JSON() }
-func (m lsObjectMessage) JSON() string   { return strutil.JSON(m) }
+func (m lsObjectMessage) JSON() string   { return strutil.SchemaJSON("ls.object/v1", m) }
 
 // lsBucketMessage is the per-line JSON payload for bucket rows when
 // --output json is set.
@@ -364,4 +567,22 @@ type lsBucketMessage struct {
 }
 
 func (m lsBucketMessage) String() string { return m.JSON() }
-func (m lsBucketMessage) JSON() string   { return strutil.JSON(m) }
+func (m lsBucketMessage) JSON() string   { return strutil.SchemaJSON("ls.bucket/v1", m) }
+
+// lsSummaryMessage is the --summarize total of the objects listed.
+type lsSummaryMessage struct {
+	Objects int64 `json:"objects"`
+	Size    int64 `json:"size"`
+
+	humanize bool
+}
+
+func (m lsSummaryMessage) String() string {
+	sizeStr := fmt.Sprintf("%d", m.Size)
+	if m.humanize {
+		sizeStr = strutil.HumanizeBytes(m.Size)
+	}
+	return fmt.Sprintf("\nTotal Objects: %d\n   Total Size: %s", m.Objects, sizeStr)
+}
+
+func (m lsSummaryMessage) JSON() string { return strutil.SchemaJSON("ls.summary/v1", m) }
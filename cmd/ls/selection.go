@@ -0,0 +1,145 @@
+package ls
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// Orders accepted by --sort.
+const (
+	sortName = "name"
+	sortSize = "size"
+	sortTime = "time"
+)
+
+// parseSelection parses the size, age and sort flags into the private
+// fields of o. It runs after complete so that durations count back from
+// o.now.
+func (o *Options) parseSelection() error {
+	var err error
+	o.maxSize = -1
+	if o.MinSize != "" {
+		if o.minSize, err = filter.ParseSize(o.MinSize); err != nil {
+			return fmt.Errorf("invalid --min-size: %w", err)
+		}
+	}
+	if o.MaxSize != "" {
+		if o.maxSize, err = filter.ParseSize(o.MaxSize); err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+		if o.maxSize < o.minSize {
+			return fmt.Errorf("--max-size %s is smaller than --min-size %s", o.MaxSize, o.MinSize)
+		}
+	}
+	if o.newerThan, err = o.parseAge("--newer-than", o.NewerThan); err != nil {
+		return err
+	}
+	if o.olderThan, err = o.parseAge("--older-than", o.OlderThan); err != nil {
+		return err
+	}
+	switch o.Sort {
+	case "", sortName, sortSize, sortTime:
+	default:
+		return fmt.Errorf("invalid --sort %q: want %s, %s or %s", o.Sort, sortName, sortSize, sortTime)
+	}
+	if o.MaxItems < 0 {
+		return fmt.Errorf("--max-items must not be negative")
+	}
+	return nil
+}
+
+// parseAge parses the value of --newer-than or --older-than: a duration
+// such as 7d counts back from now, anything else must be a timestamp. The
+// zero time means the flag is unset.
+func (o *Options) parseAge(flag, v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := filter.ParseDuration(v); err == nil {
+		return o.now.Add(-d), nil
+	}
+	t, err := filter.ParseTime(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: want a duration such as 7d or a time such as 2024-01-01", flag, v)
+	}
+	return t, nil
+}
+
+// selected reports whether obj passes the size, age and --filter
+// selections.
+func (o *Options) selected(obj *storage.Object) bool {
+	if obj.Size < o.minSize || (o.maxSize >= 0 && obj.Size > o.maxSize) {
+		return false
+	}
+	if !o.newerThan.IsZero() || !o.olderThan.IsZero() {
+		var mod time.Time
+		if obj.ModTime != nil {
+			mod = *obj.ModTime
+		}
+		if !o.newerThan.IsZero() && !mod.After(o.newerThan) {
+			return false
+		}
+		if !o.olderThan.IsZero() && !mod.Before(o.olderThan) {
+			return false
+		}
+	}
+	return o.filter.Match(obj)
+}
+
+// sorted reports whether the objects must be held back and ordered before
+// they are printed.
+func (o *Options) sorted() bool {
+	return o.Sort != "" || o.Reverse
+}
+
+// take prints a selected object, or holds it back for flush when the
+// listing is sorted. It returns false once --max-items objects were
+// printed, so the caller can stop listing.
+func (o *Options) take(obj *storage.Object, print func(*storage.Object)) bool {
+	if o.sorted() {
+		o.held = append(o.held, obj)
+		return true
+	}
+	print(obj)
+	o.taken++
+	return o.MaxItems == 0 || o.taken < o.MaxItems
+}
+
+// flush orders the objects held back by take and prints the first
+// --max-items of them. Objects of equal sort key keep the listing order.
+func (o *Options) flush(print func(*storage.Object)) {
+	objs := o.held
+	o.held = nil
+	var less func(a, b *storage.Object) bool
+	switch o.Sort {
+	case sortSize:
+		less = func(a, b *storage.Object) bool { return a.Size < b.Size }
+	case sortTime:
+		less = func(a, b *storage.Object) bool { return modTime(a).Before(modTime(b)) }
+	default:
+		less = func(a, b *storage.Object) bool { return a.StorageURL.Path < b.StorageURL.Path }
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		if o.Reverse {
+			return less(objs[j], objs[i])
+		}
+		return less(objs[i], objs[j])
+	})
+	if o.MaxItems > 0 && len(objs) > o.MaxItems {
+		objs = objs[:o.MaxItems]
+	}
+	for _, obj := range objs {
+		print(obj)
+	}
+}
+
+func modTime(obj *storage.Object) time.Time {
+	if obj.ModTime == nil {
+		return time.Time{}
+	}
+	return *obj.ModTime
+}
@@ -6,7 +6,9 @@ import (
 	"io"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
 )
@@ -90,8 +92,9 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if err != nil {
 		return err
 	}
+	jsonOutput := o.common.Output == "json"
 	if exist {
-		fmt.Fprintf(out, "Bucket %s already exists.\n", parsedUri.Bucket)
+		log.Fprint(out, jsonOutput, mbMessage{Bucket: parsedUri.Bucket})
 		return nil
 	}
 
@@ -102,6 +105,24 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if err := cli.CreateBucket(ctx, parsedUri.Bucket, o.common.Region); err != nil {
 		return err
 	}
-	fmt.Fprintf(out, "make_bucket: %s\n", o.S3Uri)
+	log.Fprint(out, jsonOutput, mbMessage{Bucket: parsedUri.Bucket, Created: true, uri: o.S3Uri})
 	return nil
 }
+
+// mbMessage reports whether the bucket was created or already existed.
+type mbMessage struct {
+	Bucket  string `json:"bucket"`
+	Created bool   `json:"created"`
+
+	// uri is the target as given, for the text output.
+	uri string
+}
+
+func (m mbMessage) String() string {
+	if !m.Created {
+		return fmt.Sprintf("Bucket %s already exists.", m.Bucket)
+	}
+	return fmt.Sprintf("make_bucket: %s", m.uri)
+}
+
+func (m mbMessage) JSON() string { return strutil.SchemaJSON("mb/v1", m) }
@@ -11,4 +11,21 @@ Example 2: Move all objects under a prefix recursively
 Example 3: Move local files to S3
 
          s6cmd mv --recursive ./local-dir/ s3://bucket/prefix/
+
+Example 4: Move a prefix and rerun only the moves that failed
+
+         s6cmd mv --recursive --failed-out failed.txt s3://bucket/prefix/ s3://other-bucket/prefix/
+         s6cmd run failed.txt
+
+Example 5: Move a prefix and write the outcome of every object as JSON lines
+
+         s6cmd mv --recursive --report report.jsonl s3://bucket/prefix/ s3://other-bucket/prefix/
+
+Example 6: Move a directory to S3 showing the progress
+
+         s6cmd mv --recursive --show-progress ./local-dir/ s3://bucket/prefix/
+
+Example 7: Move the objects older than 30 days to an archive bucket
+
+         s6cmd mv --recursive --filter 'mtime < now-30d' s3://bucket/prefix/ s3://archive-bucket/prefix/
 `
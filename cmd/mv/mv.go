@@ -28,8 +28,8 @@ import (
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
 	"github.com/LinPr/s6cmd/internal/parallel"
-	"github.com/LinPr/s6cmd/internal/progressbar"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -64,6 +64,15 @@ func NewMvCmd() *cobra.Command {
 	// both are passed, --concurrency wins (it is the more specific knob).
 	cmd.Flags().IntVarP(&o.Jobs, "jobs", "j", 0, "number of concurrent operations (alias for --concurrency; --concurrency wins when both are set)")
 
+	// --task-retries / --task-retry-backoff / --failed-out, shared with
+	// cp, rm and sync.
+	o.Failures.AddToCmd(&cmd)
+	// --report / --report-format, shared with cp, rm, sync, put and get.
+	o.Report.AddToCmd(&cmd)
+	// --show-progress / --show-transfers, shared with cp, sync, put and
+	// get.
+	o.Progress.AddToCmd(&cmd)
+
 	// Shared flags: --concurrency, --part-size, --acl, --metadata, ...
 	o.Shared.AddToCmd(&cmd)
 
@@ -84,6 +93,15 @@ type Flags struct {
 	// --concurrency explicitly. It does not drive the transfer path
 	// directly.
 	Jobs int
+	// Failures retries transient task failures and records the rest
+	// (see cliutil.FailureFlags).
+	Failures cliutil.FailureFlags
+	// Report writes the outcome of every object to --report (see
+	// cliutil.ReportFlags). An entry reports the transfer; a failure to
+	// delete the source afterwards is an error of the command.
+	Report cliutil.ReportFlags
+	// Progress shows the progress on stderr (see cliutil.ProgressFlags).
+	Progress cliutil.ProgressFlags
 
 	// CommonFlags holds the global flags inherited from the parent
 	// command (endpoint, region, profile, retry-count, ...). It is
@@ -131,7 +149,10 @@ func (o *Options) validate() error {
 	if err := o.Shared.ValidateMetadataDirective(); err != nil {
 		return err
 	}
-	return nil
+	if err := o.Failures.Validate(); err != nil {
+		return err
+	}
+	return o.Report.Validate()
 }
 
 // spec bundles the per-invocation transfer knobs for cliutil's shared
@@ -146,7 +167,19 @@ func (o *Options) spec() *cliutil.TransferSpec {
 }
 
 func (o *Options) run(ctx context.Context) error {
-	srcURL, err := storage.NewStorageURL(o.Source, storage.WithRaw(o.Shared.Raw))
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+	err := o.move(ctx)
+	// move returns once every task finished, so the report is complete.
+	if cerr := o.Report.Close(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+func (o *Options) move(ctx context.Context) error {
+	srcURL, err := storage.NewStorageURL(o.Source, storage.WithRaw(o.Shared.Raw), storage.WithIgnoreFiles(o.Shared.Ignore.Files()))
 	if err != nil {
 		return err
 	}
@@ -166,7 +199,10 @@ func (o *Options) run(ctx context.Context) error {
 	// Local->local move is a plain rename (with a copy fallback across
 	// filesystems); it never touches S3.
 	if !srcURL.IsRemote() && !destURL.IsRemote() {
-		return o.moveLocalToLocal(srcURL.Path, destURL.Path)
+		rop := o.Report.Start("mv", srcURL.Absolute(), destURL.Absolute(), 0)
+		return rop.Finishes(rop.Attempt(func() error {
+			return o.moveLocalToLocal(srcURL.Path, destURL.Path)
+		}))()
 	}
 
 	// Multi-object sources require an explicit --recursive (mirroring cp
@@ -186,6 +222,14 @@ func (o *Options) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	objFilter, err := filter.Parse(o.Shared.Filter)
+	if err != nil {
+		return err
+	}
+	rules, err := filter.LoadRules(o.Shared.FilterFrom)
+	if err != nil {
+		return err
+	}
 
 	srcIsLocalDir := false
 	if !srcURL.IsRemote() && !srcURL.IsWildcard() {
@@ -210,12 +254,16 @@ func (o *Options) run(ctx context.Context) error {
 		return err
 	}
 
-	pb := progressbar.New(false)
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
+	}
 	pb.Start()
 	defer pb.Finish()
 
 	waiter := parallel.NewWaiter()
 	ec := cliutil.NewErrorCollector("mv")
+	o.Failures.Track(ec)
 	drainDone := ec.Drain(waiter)
 
 	// moved collects the source URLs whose transfer succeeded; only those
@@ -251,13 +299,22 @@ func (o *Options) run(ctx context.Context) error {
 		if name == "" {
 			name = object.StorageURL.Absolute()
 		}
-		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) {
+		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) || rules.Excluded(name) || !objFilter.Match(object) {
 			continue
 		}
 
 		srcObj := object.StorageURL
+		size := object.Size
+		pb.AddTotalBytes(size)
+		pb.IncrementTotalObjects()
+		rop := o.Report.StartEach("mv", object, []*storage.StorageURL{destURL}, false, isBatch)[0]
 		task := func() error {
-			var terr error
+			pt := pb.Transfer(srcObj.Relative(), size)
+			defer pt.Finish()
+			var (
+				obj  *storage.Object
+				terr error
+			)
 			switch {
 			case srcURL.IsRemote() && destURL.IsRemote():
 				dst := cliutil.PrepareRemoteDestination(srcObj, destURL, false, isBatch)
@@ -266,20 +323,24 @@ func (o *Options) run(ctx context.Context) error {
 					// it; skip it instead.
 					return nil
 				}
-				if terr = spec.Copy(ctx, store, srcObj, dst); terr != nil {
+				if obj, terr = spec.Copy(ctx, store, srcObj, dst); terr != nil {
 					terr = &errorpkg.Error{Op: "mv", Src: srcObj.String(), Dst: dst.String(), Err: terr}
+				} else {
+					pt.AddCompletedBytes(size)
+					pt.IncrementCompletedObjects()
 				}
 			case srcURL.IsRemote() && !destURL.IsRemote():
 				dst, derr := cliutil.PrepareLocalDestination(ctx, store, srcObj, destURL, false, isBatch)
 				if derr != nil {
 					return &errorpkg.Error{Op: "mv", Src: srcObj.String(), Dst: destURL.String(), Err: derr}
 				}
-				if terr = spec.Download(ctx, store, srcObj, dst, pb); terr != nil {
+				rop.Resolved(dst.Absolute())
+				if obj, terr = spec.Download(ctx, store, srcObj, dst, pt); terr != nil {
 					terr = &errorpkg.Error{Op: "mv", Src: srcObj.String(), Dst: dst.Absolute(), Err: terr}
 				}
 			case !srcURL.IsRemote() && destURL.IsRemote():
 				dst := cliutil.PrepareRemoteDestination(srcObj, destURL, false, isBatch)
-				if terr = spec.Upload(ctx, store, srcObj, dst, pb); terr != nil {
+				if obj, terr = spec.Upload(ctx, store, srcObj, dst, pt); terr != nil {
 					terr = &errorpkg.Error{Op: "mv", Src: srcObj.Absolute(), Dst: dst.String(), Err: terr}
 				}
 			default:
@@ -291,12 +352,20 @@ func (o *Options) run(ctx context.Context) error {
 				// recorded as moved and stays in place.
 				return terr
 			}
+			rop.Wrote(obj)
 			movedMu.Lock()
 			moved = append(moved, srcObj)
 			movedMu.Unlock()
 			return nil
 		}
-		parallel.Run(task, waiter)
+		// After the first Ctrl-C nothing new starts: the rest of the
+		// objects go to the resume journal. The sources moved so far
+		// are still deleted below.
+		if !parallel.Run(rop.Finishes(o.Failures.Wrap(ctx, rop.Attempt(task))), waiter) {
+			for _, err := range cliutil.NotStarted("mv", srcObj, []*storage.StorageURL{destURL}, false, isBatch) {
+				ec.Collect(err)
+			}
+		}
 	}
 	waiter.Wait()
 	drainDone()
@@ -306,6 +375,7 @@ func (o *Options) run(ctx context.Context) error {
 	// source so a re-run can pick them up.
 	ec.Collect(o.deleteMovedSources(ctx, store, srcURL, srcIsLocalDir, moved))
 
+	ec.Collect(o.Failures.WriteFailed())
 	return ec.Aggregate()
 }
 
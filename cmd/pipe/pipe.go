@@ -178,7 +178,7 @@ func (o *Options) run(ctx context.Context) error {
 	// stdinReader wraps os.Stdin so only Read is exposed. See the stdin
 	// type comment below for why.
 	reader := &stdin{file: os.Stdin}
-	if err := store.Put(ctx, reader, dst, metadata, concurrency, partSize); err != nil {
+	if _, err := store.Put(ctx, reader, dst, metadata, concurrency, partSize); err != nil {
 		return err
 	}
 
@@ -14,7 +14,9 @@ import (
 	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
 )
@@ -114,6 +116,20 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(out, url)
+	log.Fprint(out, o.common.Output == "json", presignMessage{
+		Source:    src.String(),
+		URL:       url,
+		ExpiresAt: time.Now().Add(expire).UTC().Truncate(time.Second),
+	})
 	return nil
 }
+
+// presignMessage is a presigned URL; the text output is the URL alone.
+type presignMessage struct {
+	Source    string    `json:"source"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (m presignMessage) String() string { return m.URL }
+func (m presignMessage) JSON() string   { return strutil.SchemaJSON("presign/v1", m) }
@@ -11,4 +11,16 @@ Example 2: Upload a directory recursively
 Example 3: Upload from stdin
 
          s6cmd put - s3://bucket/object.txt
+
+Example 4: Upload a directory and record the ETag of every object uploaded
+
+         s6cmd put --recursive --jobs 8 --report report.jsonl ./local-dir/ s3://bucket/prefix/
+
+Example 5: Upload a directory showing the progress and the files being uploaded
+
+         s6cmd put --recursive --jobs 8 --show-transfers ./local-dir/ s3://bucket/prefix/
+
+Example 6: Upload a source tree, skipping what its .gitignore and .s6cmdignore files list
+
+         s6cmd put --recursive --respect-gitignore ./project/ s3://bucket/project/
 `
@@ -2,11 +2,14 @@ package put
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/progressbar"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -41,6 +44,9 @@ func NewPutCmd() *cobra.Command {
 	// (as opposed to --jobs, which bounds how many files transfer at once).
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", cliutil.DefaultCopyConcurrency, "number of concurrent parts transferred per file")
 	cmd.Flags().IntVar(&o.PartSizeMiB, "part-size", cliutil.DefaultPartSizeMiB, "size of each part transferred per file, in MiB")
+	o.Ignore.AddToCmd(&cmd)
+	o.Report.AddToCmd(&cmd)
+	o.Progress.AddToCmd(&cmd)
 
 	return &cmd
 }
@@ -57,6 +63,13 @@ type Flags struct {
 	// tuning, converted to bytes via cliutil.PartSizeBytesFromMiB.
 	Concurrency int
 	PartSizeMiB int
+	// Ignore holds --no-s6cmdignore and --respect-gitignore.
+	Ignore cliutil.IgnoreFlags
+	// Report holds --report and --report-format.
+	Report cliutil.ReportFlags
+	// Progress holds --show-progress and --show-transfers; the upload
+	// from stdin shows no progress.
+	Progress cliutil.ProgressFlags
 }
 
 type Options struct {
@@ -82,10 +95,22 @@ func (o *Options) validate() error {
 		return err
 	}
 
-	return nil
+	return o.Report.Validate()
 }
 
 func (o *Options) run(ctx context.Context) error {
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+	err := o.put(ctx)
+	// put returns once every upload finished, so the report is complete.
+	if cerr := o.Report.Close(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+func (o *Options) put(ctx context.Context) error {
 	if o.localFile == "-" {
 		if o.Recursive {
 			return fmt.Errorf("cannot use --recursive with stdin")
@@ -102,14 +127,19 @@ func (o *Options) run(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		if _, err := store.UploadFromStdin(ctx, parsedDest.Bucket, parsedDest.Path, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB)); err != nil {
-			return err
-		}
-		log.Info(log.InfoMessage{Operation: "put", Source: "-", Destination: parsedDest.String()})
-		return nil
+		rop := o.Report.Start("put", "-", parsedDest.String(), 0)
+		return rop.Finishes(rop.Attempt(func() error {
+			out, err := store.UploadFromStdin(ctx, parsedDest.Bucket, parsedDest.Path, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB))
+			if err != nil {
+				return err
+			}
+			rop.Wrote(cliutil.UploadedObject(parsedDest, out))
+			log.Info(log.InfoMessage{Operation: "put", Source: "-", Destination: parsedDest.String()})
+			return nil
+		}))()
 	}
 
-	srcURL, err := storage.NewStorageURL(o.localFile)
+	srcURL, err := storage.NewStorageURL(o.localFile, storage.WithIgnoreFiles(o.Ignore.Files()))
 	if err != nil {
 		return err
 	}
@@ -126,19 +156,25 @@ func (o *Options) run(ctx context.Context) error {
 		return err
 	}
 
-	return uploadLocalToS3(ctx, store, srcURL, destURL, o.Recursive, o.Jobs, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB))
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
+	}
+	pb.Start()
+	defer pb.Finish()
+	return uploadLocalToS3(ctx, store, srcURL, destURL, o.Recursive, o.Jobs, o.Concurrency, cliutil.PartSizeBytesFromMiB(o.PartSizeMiB), &o.Report, pb)
 }
 
 func isLocalDir(path string) (bool, error) {
 	return cliutil.IsLocalDir(path)
 }
 
-func listLocalFiles(src string, recursive bool) ([]string, error) {
-	return cliutil.ListLocalFiles(src, recursive)
+func listLocalFiles(src *storage.StorageURL, recursive bool) ([]string, error) {
+	return cliutil.ListLocalFilesIgnoring(src.Path, recursive, src.IgnoreFiles())
 }
 
-func uploadLocalToS3(ctx context.Context, store *storage.Storage, src, dest *storage.StorageURL, recursive bool, jobs, concurrency int, partSize int64) error {
-	files, err := listLocalFiles(src.Path, recursive)
+func uploadLocalToS3(ctx context.Context, store *storage.Storage, src, dest *storage.StorageURL, recursive bool, jobs, concurrency int, partSize int64, rep *cliutil.ReportFlags, pb progressbar.ProgressBar) error {
+	files, err := listLocalFiles(src, recursive)
 	if err != nil {
 		return err
 	}
@@ -172,13 +208,28 @@ func uploadLocalToS3(ctx context.Context, store *storage.Storage, src, dest *sto
 		}
 		uploadPath := filePath
 		uploadKey := destKey
-		tasks = append(tasks, func() error {
-			if _, err := store.UploadFile(ctx, uploadPath, dest.Bucket, uploadKey, concurrency, partSize); err != nil {
+		uploadURL := "s3://" + dest.Bucket + "/" + uploadKey
+		var size int64
+		if fi, err := os.Stat(uploadPath); err == nil {
+			size = fi.Size()
+		}
+		pb.AddTotalBytes(size)
+		pb.IncrementTotalObjects()
+		rop := rep.Start("put", uploadPath, uploadURL, size)
+		tasks = append(tasks, rop.Finishes(rop.Attempt(func() error {
+			pt := pb.Transfer(uploadPath, size)
+			defer pt.Finish()
+			out, err := store.UploadFile(ctx, uploadPath, dest.Bucket, uploadKey, concurrency, partSize, cliutil.CountProgress(pt))
+			if err != nil {
 				return err
 			}
-			log.Info(log.InfoMessage{Operation: "put", Source: uploadPath, Destination: "s3://" + dest.Bucket + "/" + uploadKey})
+			obj := cliutil.UploadedObject(dest, out)
+			obj.Size = size
+			rop.Wrote(obj)
+			pt.IncrementCompletedObjects()
+			log.Info(log.InfoMessage{Operation: "put", Source: uploadPath, Destination: uploadURL})
 			return nil
-		})
+		})))
 	}
 
 	return cliutil.RunTasks(jobs, tasks)
@@ -15,4 +15,24 @@ Example 3: Remove all objects matching a wildcard
 Example 4: Dry-run — show what would be removed
 
          s6cmd rm --dry-run --recursive s3://bucket/prefix/
+
+Example 5: Refuse to remove more than 100 objects, moving the removed ones to a trash prefix
+
+         s6cmd rm --recursive --max-delete 100 --backup-dir s3://bucket/trash/2026-10-17/ s3://bucket/prefix/
+
+Example 6: Retry throttled deletes longer and list the keys that could not be removed
+
+         s6cmd rm --recursive --task-retries 5 --task-retry-backoff 5s --failed-out failed.txt s3://bucket/prefix/
+
+Example 7: Record every key removed, with its ETag and version ID
+
+         s6cmd rm --recursive --all-versions --report removed.csv s3://bucket/prefix/
+
+Example 8: Remove the empty and the temporary objects older than a week
+
+         s6cmd rm --recursive --filter 'size == 0 || (name =~ "\\.tmp$" && mtime < now-7d)' s3://bucket/prefix/
+
+Example 9: Remove everything under a prefix except what the rules in keep.txt exclude, e.g. "- /archive/"
+
+         s6cmd rm --recursive --filter-from keep.txt s3://bucket/prefix/
 `
@@ -7,6 +7,10 @@
 //   - --version-id for deleting a specific object version
 //   - --all-versions for deleting every version of an object
 //   - --raw to disable wildcard expansion (useful for keys with glob chars)
+//   - --max-delete to abort when the expansion matches too many objects
+//   - --backup-dir to copy every object aside before it is deleted
+//   - --task-retries / --failed-out to retry keys that failed transiently
+//     and list the ones that still failed for `s6cmd run`
 //
 // Deletion runs via storage.MultiDelete, which batches keys 1000 at a time
 // (the S3 DeleteObjects limit) and returns a per-URL result channel. The
@@ -21,6 +25,8 @@ import (
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/internal/report"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -57,6 +63,13 @@ func NewRmCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&o.Raw, "raw", false, "disable wildcard operations, useful with filenames that contains glob characters")
 	cmd.Flags().StringSliceVar(&o.Exclude, "exclude", nil, "exclude objects with given pattern (repeatable)")
 	cmd.Flags().StringSliceVar(&o.Include, "include", nil, "include objects with given pattern (repeatable)")
+	cmd.Flags().StringVar(&o.FilterFrom, "filter-from", "", "read ordered rsync-style '+ PATTERN' / '- PATTERN' rules from a file; the first matching rule wins")
+	cmd.Flags().StringVar(&o.Filter, "filter", "", `only remove objects matching the expression, e.g. 'size > 100MiB && mtime < now-30d'`)
+	o.Guard.AddToCmd(&cmd)
+	o.Failures.AddToCmd(&cmd)
+	o.Report.AddToCmd(&cmd)
+	o.Inventory.AddToCmd(&cmd)
+	o.Listing.AddToCmd(&cmd)
 
 	return &cmd
 }
@@ -76,6 +89,18 @@ type Flags struct {
 	Raw         bool
 	Exclude     []string
 	Include     []string
+	FilterFrom  string
+	Filter      string
+	// Guard holds --max-delete and --backup-dir.
+	Guard cliutil.DeleteGuard
+	// Failures holds --task-retries, --task-retry-backoff and --failed-out.
+	Failures cliutil.FailureFlags
+	// Report holds --report and --report-format.
+	Report cliutil.ReportFlags
+	// Inventory holds --inventory.
+	Inventory cliutil.InventoryFlags
+	// Listing holds --from-listing and --check-listing.
+	Listing cliutil.ListingFlags
 	cliutil.CommonFlags
 }
 
@@ -102,7 +127,13 @@ func (o *Options) validate() error {
 	if err := validator.New().Struct(o.Args); err != nil {
 		return err
 	}
-	return nil
+	if err := o.Guard.Validate(); err != nil {
+		return err
+	}
+	if err := o.Failures.Validate(); err != nil {
+		return err
+	}
+	return o.Report.Validate()
 }
 
 func (o *Options) run(ctx context.Context) error {
@@ -110,6 +141,11 @@ func (o *Options) run(ctx context.Context) error {
 		storage.WithVersion(o.VersionID),
 		storage.WithAllVersions(o.AllVersions),
 		storage.WithRaw(o.Raw),
+		// rm collects every match before deleting, so a sharded
+		// listing need not keep them in key order.
+		storage.WithUnordered(true),
+		o.Inventory.Option(),
+		o.Listing.Option(),
 	)
 	if err != nil {
 		return err
@@ -120,6 +156,9 @@ func (o *Options) run(ctx context.Context) error {
 	if url.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
 	}
+	if err := o.Listing.Validate(url); err != nil {
+		return err
+	}
 	// rm operates on objects, not buckets. A bare bucket or prefix URL is
 	// only valid with --recursive (which expands to every object under
 	// the prefix) so we reject it here to surface a clear error.
@@ -147,6 +186,14 @@ func (o *Options) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	rules, err := filter.LoadRules(o.FilterFrom)
+	if err != nil {
+		return err
+	}
+	objFilter, err := filter.Parse(o.Filter)
+	if err != nil {
+		return err
+	}
 
 	// Collect source objects into a slice first so we can drive the
 	// MultiDelete channel from a single producer goroutine. The slice is
@@ -164,7 +211,8 @@ func (o *Options) run(ctx context.Context) error {
 	// listing exit 0. AggregateErrors still drops warning sentinels, so a
 	// wildcard that matches nothing keeps exiting 0.
 	errs := make([]error, 0)
-	deletable := make([]*storage.StorageURL, 0, len(objects))
+	deletable := make([]*storage.Object, 0, len(objects))
+	listed := 0
 	for _, obj := range objects {
 		if obj.Err != nil {
 			if errorpkg.IsCancelation(obj.Err) {
@@ -177,43 +225,67 @@ func (o *Options) run(ctx context.Context) error {
 		if obj.Type.IsDir() {
 			continue
 		}
+		listed++
 		name := obj.StorageURL.Relative()
 		if name == "" {
 			name = obj.StorageURL.Absolute()
 		}
-		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) {
+		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) || rules.Excluded(name) || !objFilter.Match(obj) {
 			continue
 		}
-		deletable = append(deletable, obj.StorageURL)
+		deletable = append(deletable, obj)
 	}
 
-	// Build the URL channel consumed by MultiDelete. We feed it from a
-	// goroutine so MultiDelete's batching goroutine can start draining
-	// immediately.
-	urlCh := make(chan *storage.StorageURL)
-	go func() {
-		defer close(urlCh)
-		for _, url := range deletable {
-			urlCh <- url
-		}
-	}()
+	// --max-delete sees the whole deletion set before anything is
+	// deleted. Percentages are of the objects the URL matched before
+	// --exclude/--include, --filter-from and --filter.
+	if err := o.Guard.Check(len(deletable), listed); err != nil {
+		return err
+	}
+	if o.Guard.BackupEnabled() {
+		var err error
+		deletable, err = o.Guard.BackupEach(ctx, store, "rm", deletable)
+		errs = append(errs, err)
+	}
 
-	// MultiDelete returns a per-URL result channel. Drain it on the main
-	// goroutine so the log output is ordered and so we can aggregate
-	// errors into the final return.
-	resultCh := store.MultiDelete(ctx, urlCh)
-	for obj := range resultCh {
-		if obj.Err != nil {
-			if errorpkg.IsCancelation(obj.Err) {
-				continue
-			}
-			log.Error(log.ErrorMessage{Operation: "rm", Err: obj.Err.Error()})
-			errs = append(errs, obj.Err)
+	// The report entry of a deletion records the ETag and version ID of
+	// the object deleted.
+	if err := o.Report.Open(o.DryRun); err != nil {
+		return err
+	}
+	rops := make([]*report.Operation, len(deletable))
+	for i, obj := range deletable {
+		rops[i] = o.Report.Start("rm", obj.StorageURL.String(), "", obj.Size)
+	}
+
+	// Keys that fail with a transient error (SlowDown, InternalError, a
+	// DeleteObjects call that timed out) are deleted again, in one batch,
+	// after the --task-retry-backoff wait.
+	results := o.Failures.RetryEach(ctx, len(deletable), func(idx []int) []error {
+		for _, i := range idx {
+			rops[i].Attempted()
+		}
+		return cliutil.DeleteObjects(ctx, store, deletable, idx)
+	})
+	for i, err := range results {
+		rops[i].Wrote(deletable[i])
+		rops[i].Finish(err)
+		if err == nil {
+			continue
+		}
+		if errorpkg.IsCancelation(err) {
+			// Canceled or kept from starting by an interrupt: a line of
+			// the resume journal, not a failure.
+			o.Failures.Record(cliutil.DeleteFailure(deletable[i].StorageURL, err))
 			continue
 		}
-		log.Info(log.InfoMessage{Operation: "rm", Source: obj.String()})
+		log.Error(log.ErrorMessage{Operation: "rm", Err: err.Error()})
+		errs = append(errs, err)
+		o.Failures.Record(cliutil.DeleteFailure(deletable[i].StorageURL, err))
 	}
 
+	errs = append(errs, o.Report.Close())
+	errs = append(errs, o.Failures.WriteFailed())
 	return cliutil.AggregateErrors(errs)
 }
 
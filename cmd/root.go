@@ -10,11 +10,13 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/LinPr/s6cmd/cmd/bucketversion"
 	"github.com/LinPr/s6cmd/cmd/cat"
 	"github.com/LinPr/s6cmd/cmd/cp"
 	"github.com/LinPr/s6cmd/cmd/du"
+	"github.com/LinPr/s6cmd/cmd/find"
 	"github.com/LinPr/s6cmd/cmd/get"
 	"github.com/LinPr/s6cmd/cmd/head"
 	"github.com/LinPr/s6cmd/cmd/ls"
@@ -30,8 +32,11 @@ import (
 	"github.com/LinPr/s6cmd/cmd/stat"
 	syncCmd "github.com/LinPr/s6cmd/cmd/sync"
 	"github.com/LinPr/s6cmd/cmd/tree"
+	"github.com/LinPr/s6cmd/cmd/verify"
 	"github.com/LinPr/s6cmd/cmd/version"
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/interrupt"
+	"github.com/LinPr/s6cmd/internal/outfmt"
 	"github.com/LinPr/s6cmd/log"
 	logstat "github.com/LinPr/s6cmd/log/stat"
 	"github.com/go-playground/validator/v10"
@@ -64,12 +69,21 @@ type Options struct {
 	// legacy ListObjects API is used instead of ListObjectsV2, for
 	// S3-compatible services that do not implement V2.
 	UseListObjectsV1 bool
+	// ListShards mirrors --list-shards: the number of concurrent shards of
+	// a recursive object listing. <=1 lists sequentially.
+	ListShards int
 	// LogLevel mirrors --log. One of trace, debug, info, error.
 	LogLevel string
 	// Stat mirrors --stat. When true, per-operation success/error counters
 	// are collected and a summary table is printed after the command
 	// completes.
 	Stat bool
+	// DrainTimeout mirrors --drain-timeout: how long the first Ctrl-C
+	// lets in-flight operations finish before canceling them.
+	DrainTimeout time.Duration
+	// ResumeFile mirrors --resume-file: where an interrupted run writes
+	// the operations it did not finish. Empty disables the journal.
+	ResumeFile string
 
 	// profileFlagChanged / credentialsFileFlagChanged record whether the
 	// user passed --profile / --credentials-file on the command line (as
@@ -112,8 +126,11 @@ func (o *Options) complete(root *cobra.Command) error {
 		{Name: "no-sign-request", Bool: &o.NoSignRequest},
 		{Name: "use-list-objects-v1", Bool: &o.UseListObjectsV1},
 		{Name: "stat", Bool: &o.Stat},
+		{Name: "drain-timeout", Duration: &o.DrainTimeout},
+		{Name: "resume-file", String: &o.ResumeFile},
 		{Name: "retry-count", Int: &o.RetryCount},
 		{Name: "no-such-upload-retry-count", Int: &o.NoSuchUploadRetryCount},
+		{Name: "list-shards", Int: &o.ListShards},
 	})
 	o.profileFlagChanged = root.PersistentFlags().Changed("profile")
 	o.credentialsFileFlagChanged = root.PersistentFlags().Changed("credentials-file")
@@ -125,11 +142,12 @@ func (o *Options) validate() error {
 		return err
 	}
 	// "table" was advertised in older help text but nothing implements
-	// it, so only json/text are accepted.
+	// it, so only json/text and the csv/tsv modes of ls, stat, head and du
+	// are accepted. The other commands print text for csv and tsv.
 	switch o.Output {
-	case "json", "text":
+	case "json", "text", outfmt.OutputCSV, outfmt.OutputTSV:
 	default:
-		return fmt.Errorf(`--output must be "json" or "text", got %q`, o.Output)
+		return fmt.Errorf(`--output must be "json", "text", "csv" or "tsv", got %q`, o.Output)
 	}
 	if _, ok := log.LevelFromString(o.LogLevel); !ok {
 		return fmt.Errorf(`--log must be one of "trace", "debug", "info", "error", got %q`, o.LogLevel)
@@ -140,6 +158,12 @@ func (o *Options) validate() error {
 	if o.NoSuchUploadRetryCount < 0 {
 		return fmt.Errorf("no-such-upload-retry-count cannot be a negative value")
 	}
+	if o.ListShards < 0 {
+		return fmt.Errorf("list-shards cannot be a negative value")
+	}
+	if o.DrainTimeout < 0 {
+		return fmt.Errorf("drain-timeout cannot be a negative value")
+	}
 	// --no-sign-request is mutually exclusive with --profile and
 	// --credentials-file because it disables credential loading entirely.
 	// The mutex only fires when the conflicting flag was passed EXPLICITLY
@@ -200,6 +224,8 @@ func NewRootCmd() *cobra.Command {
 			if o.Stat {
 				logstat.InitStat()
 			}
+			interrupt.SetDrainTimeout(o.DrainTimeout)
+			interrupt.SetJournal(o.ResumeFile)
 			if used := viper.ConfigFileUsed(); used != "" {
 				log.Debug(log.DebugMessage{Err: fmt.Sprintf("using config file: %v", used)})
 			}
@@ -220,7 +246,7 @@ func NewRootCmd() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&o.EndpointUrl, "endpoint-url", "", "Override the default endpoint URL (or use AWS_ENDPOINT_URL_S3 environment variable)")
 	cmd.PersistentFlags().BoolVarP(&o.NoVerifySSL, "no-verify-ssl", "", false, "Disable SSL certificate verification (or use AWS_NO_VERIFY_SSL environment variable)")
 	cmd.PersistentFlags().BoolVarP(&o.NoPaginate, "no-paginate", "", false, "Disable automatic pagination and return only the first page of results; currently honoured by ls object listings, other commands always paginate (or use AWS_NO_PAGINATE environment variable)")
-	cmd.PersistentFlags().StringVarP(&o.Output, "output", "o", "text", "Set output format. One of: json, text (or use AWS_OUTPUT environment variable)")
+	cmd.PersistentFlags().StringVarP(&o.Output, "output", "o", "text", "Set output format. One of: json, text, csv, tsv (csv and tsv apply to ls, stat, head and du) (or use AWS_OUTPUT environment variable)")
 	cmd.PersistentFlags().StringVar(&o.LogLevel, "log", "info", "Set log level. One of: trace, debug, info, error (or use S6CMD_LOG environment variable)")
 	cmd.PersistentFlags().StringVarP(&o.Profile, "profile", "p", "", "Use a specific profile from your credential file (or use AWS_PROFILE environment variable)")
 	cmd.PersistentFlags().StringVar(&o.Region, "region", "", "The region to use. Overrides config/env settings (or use AWS_REGION environment variable)")
@@ -236,7 +262,10 @@ func NewRootCmd() *cobra.Command {
 	if f := cmd.PersistentFlags().Lookup("use-list-objects-v1"); f != nil {
 		f.Hidden = true
 	}
+	cmd.PersistentFlags().IntVar(&o.ListShards, "list-shards", 0, "list recursive object listings of very large prefixes as this many concurrent shards, split at sub-prefixes or key ranges; 0 or 1 lists sequentially (or use S6CMD_LIST_SHARDS environment variable)")
 	cmd.PersistentFlags().BoolVar(&o.Stat, "stat", false, "collect statistics of program execution and print a summary at the end (or use S6CMD_STAT environment variable)")
+	cmd.PersistentFlags().DurationVar(&o.DrainTimeout, "drain-timeout", interrupt.DefaultDrainTimeout, "on the first Ctrl-C, stop starting new operations and wait this long for the running ones before aborting them; a second Ctrl-C aborts at once (or use S6CMD_DRAIN_TIMEOUT environment variable)")
+	cmd.PersistentFlags().StringVar(&o.ResumeFile, "resume-file", interrupt.DefaultJournal, "file an interrupted run writes its unfinished operations to, as 's6cmd run' input; --failed-out takes precedence, empty disables it (or use S6CMD_RESUME_FILE environment variable)")
 
 	// Bind persistent flags to viper so that config file / env values flow
 	// through viper.Get(key). BindPFlag keeps the flag pointer; when the flag
@@ -248,7 +277,7 @@ func NewRootCmd() *cobra.Command {
 		"config", "endpoint-url", "no-verify-ssl", "no-paginate", "output",
 		"log", "profile", "region", "path-style", "retry-count",
 		"no-such-upload-retry-count", "credentials-file", "no-sign-request",
-		"use-list-objects-v1", "stat",
+		"use-list-objects-v1", "list-shards", "stat", "drain-timeout", "resume-file",
 	} {
 		if err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name)); err != nil {
 			panic(err)
@@ -276,6 +305,7 @@ func NewRootCmd() *cobra.Command {
 		{"credentials-file", "AWS_SHARED_CREDENTIALS_FILE"},
 		{"no-sign-request", "AWS_ANON_BOOL"},
 		{"use-list-objects-v1", "S6CMD_USE_LIST_OBJECTS_V1"},
+		{"list-shards", "S6CMD_LIST_SHARDS"},
 		{"stat", "S6CMD_STAT"},
 	} {
 		if err := viper.BindEnv(kv[0], kv[1]); err != nil {
@@ -361,7 +391,7 @@ func execute(ctx context.Context, args []string) int {
 		return preRun(cmd, args)
 	}
 
-	err := rootCmd.ExecuteContext(ctx)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
 
 	// With --stat, print the end-of-run summary table before the exit-code
 	// classification message. Statistics() returns an empty slice when
@@ -370,7 +400,31 @@ func execute(ctx context.Context, args []string) int {
 		log.Stat(stats)
 	}
 
-	code, msg := classify(err, ctx.Err(), parsed)
+	// After the first Ctrl-C the command drains and returns on its own,
+	// usually before the root context is canceled; it was still
+	// interrupted.
+	ctxErr := ctx.Err()
+	if ctxErr == nil && interrupt.Requested() {
+		ctxErr = context.Canceled
+	}
+	code, msg := classify(err, ctxErr, parsed)
+	if msg != "" && (log.JSON() || rootCmd.PersistentFlags().Lookup("output").Value.String() == "json") {
+		// With --output json the final error is a JSON line as well,
+		// queued after the per-object errors already on the logger. A
+		// usage error can stop cobra before the root hook switched the
+		// logger to JSON, so the parsed flag value is checked too.
+		command := rootCmd.CommandPath()
+		if cmd != nil {
+			command = cmd.CommandPath()
+		}
+		errMsg := log.ErrorMessage{Command: command, Err: msg, ExitCode: code}
+		if log.JSON() {
+			log.Error(errMsg)
+		} else {
+			log.Fprint(os.Stderr, true, errMsg)
+		}
+		return code
+	}
 	if msg != "" {
 		fmt.Fprintf(os.Stderr, "err: %v\n", msg)
 	}
@@ -496,6 +550,11 @@ func registerSubCommands(cmd *cobra.Command) {
 	cmd.AddCommand(du.NewDuCmd())
 	cmd.AddCommand(cp.NewCpCmd())
 	cmd.AddCommand(syncCmd.NewSyncCmd())
+	// bisync shares the sync listing and transfer tasks and propagates
+	// changes in both directions.
+	cmd.AddCommand(syncCmd.NewBisyncCmd())
+	// apply executes a plan written by sync --plan-out.
+	cmd.AddCommand(syncCmd.NewApplyCmd())
 	cmd.AddCommand(mv.NewMvCmd())
 	cmd.AddCommand(rb.NewRbCmd())
 	cmd.AddCommand(tree.NewTreeCmd())
@@ -517,4 +576,12 @@ func registerSubCommands(cmd *cobra.Command) {
 	// run reads commands from a file (or stdin) and dispatches each line
 	// as a forked s6cmd child process, bounded by --numworkers.
 	cmd.AddCommand(runCmd.NewRunCmd())
+
+	// verify compares a destination with its source by ETag, stored
+	// checksum or streamed content digest.
+	cmd.AddCommand(verify.NewVerifyCmd())
+
+	// find evaluates predicate expressions over S3 prefixes and local
+	// paths and acts on the matches.
+	cmd.AddCommand(find.NewFindCmd())
 }
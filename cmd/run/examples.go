@@ -15,4 +15,8 @@ Example 3: A commands file with comments and blank lines
 
          # copy a file
          cp local.txt s3://bucket/remote.txt
+
+Example 4: Resume an interrupted command from the journal its first Ctrl-C wrote
+
+         s6cmd run s6cmd-resume.txt
 `
@@ -17,6 +17,12 @@
 // cliutil.LoadParentFlags, plus the root-only --log, --config and --stat)
 // are resolved and prepended to each line's arguments so the user does not
 // have to repeat them on every line of the commands file.
+//
+// On the first Ctrl-C run starts no more lines and waits for the running
+// children, which drain their own work; the lines it did not start and
+// those whose child was interrupted go to the resume journal as they
+// were written. Children are given --resume-file "" so they do not write
+// journals of their own over it.
 package run
 
 import (
@@ -24,13 +30,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/interrupt"
 	"github.com/LinPr/s6cmd/internal/parallel"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/go-playground/validator/v10"
@@ -43,6 +53,14 @@ import (
 // exhaust file descriptors and memory.
 const defaultNumWorkers = 16
 
+const (
+	// childCancelDelay is how long a child interrupted by the cancelation
+	// of run may take to abort its operations before it is killed.
+	childCancelDelay = 10 * time.Second
+	// childExitCanceled is the exit code of an interrupted child.
+	childExitCanceled = 130
+)
+
 // NewRunCmd creates the `run` command. It accepts zero or one positional
 // argument: a file containing one command per line. With no argument it
 // reads commands from stdin.
@@ -96,11 +114,13 @@ type Flags struct {
 }
 
 // rootForward holds the effective values of the root flags forwarded to
-// children in addition to CommonFlags: --log, --config and --stat.
+// children in addition to CommonFlags: --log, --config, --stat and
+// --drain-timeout.
 type rootForward struct {
-	LogLevel string
-	Config   string
-	Stat     bool
+	LogLevel     string
+	Config       string
+	Stat         bool
+	DrainTimeout time.Duration
 }
 
 // Options is the closure of Args + Flags + the reader the commands are
@@ -138,6 +158,7 @@ func (o *Options) complete(cmd *cobra.Command, args []string) error {
 		{Name: "log", String: &o.rootForward.LogLevel},
 		{Name: "config", String: &o.rootForward.Config},
 		{Name: "stat", Bool: &o.rootForward.Stat},
+		{Name: "drain-timeout", Duration: &o.rootForward.DrainTimeout},
 	})
 
 	// Resolve the s6cmd binary path. os.Executable returns the path of
@@ -195,7 +216,8 @@ func (o *Options) validate() error {
 //     rejects nested `run` commands, and submits each line as a task.
 //   - Each task forks the s6cmd binary with the parent's global flags
 //     prepended to the line's args.
-//   - waiter.Wait(); <-errDoneCh; aggregate errors.
+//   - waiter.Wait(); <-errDoneCh; write the resume journal if
+//     interrupted; aggregate errors.
 func (o *Options) run(ctx context.Context) error {
 	// Close the commands file if we opened one. stdin is not closed.
 	if rc, ok := o.reader.(io.Closer); ok && o.File != "" {
@@ -215,6 +237,15 @@ func (o *Options) run(ctx context.Context) error {
 	waiter := parallel.NewWaiter()
 	errs := make([]error, 0)
 	var errsMu sync.Mutex
+	// unfinished collects the lines an interrupt kept from completing,
+	// by line number, for the resume journal.
+	unfinished := make(map[int]string)
+	var unfinishedMu sync.Mutex
+	addUnfinished := func(lineno int, line string) {
+		unfinishedMu.Lock()
+		unfinished[lineno] = line
+		unfinishedMu.Unlock()
+	}
 	errDoneCh := make(chan struct{})
 	go func() {
 		defer close(errDoneCh)
@@ -229,7 +260,22 @@ func (o *Options) run(ctx context.Context) error {
 		}
 	}()
 
-	reader := newLineReader(ctx, o.reader)
+	// After the first Ctrl-C the rest of a commands file is read into the
+	// journal, but stdin may never end: stop reading it.
+	readCtx := ctx
+	if o.File == "" {
+		var cancel context.CancelFunc
+		readCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-interrupt.Done():
+				cancel()
+			case <-readCtx.Done():
+			}
+		}()
+	}
+	reader := newLineReader(readCtx, o.reader)
 	// Line numbers are 1-based so error messages match what an editor
 	// shows for the commands file.
 	lineno := 0
@@ -240,7 +286,7 @@ func (o *Options) run(ctx context.Context) error {
 			continue
 		}
 
-		fields, err := shellquoteSplit(line)
+		fields, err := cliutil.SplitCommandLine(line)
 		if err != nil {
 			// shellquote returns an error for unterminated quotes; surface
 			// it as a per-line warning, not a fatal command failure.
@@ -269,15 +315,31 @@ func (o *Options) run(ctx context.Context) error {
 		// shadowed local copies here).
 		lineArgs := fields
 		lineNo := lineno
+		lineText := line
 		task := func() error {
-			return o.execChild(ctx, lineArgs, globalArgs, lineNo)
+			err := o.execChild(ctx, lineArgs, globalArgs, lineNo)
+			if errorpkg.IsCancelation(err) {
+				addUnfinished(lineNo, lineText)
+			}
+			return err
+		}
+		if !pm.Run(task, waiter) {
+			addUnfinished(lineNo, lineText)
 		}
-		pm.Run(task, waiter)
 	}
 
 	waiter.Wait()
 	<-errDoneCh
 
+	linenos := slices.Sorted(maps.Keys(unfinished))
+	journal := make([]string, 0, len(linenos))
+	for _, n := range linenos {
+		journal = append(journal, unfinished[n])
+	}
+	if err := cliutil.WriteResumeJournal(journal); err != nil {
+		errs = append(errs, err)
+	}
+
 	if rerr := reader.Err(); rerr != nil {
 		errsMu.Lock()
 		errs = append(errs, rerr)
@@ -291,13 +353,19 @@ func (o *Options) run(ctx context.Context) error {
 // (globalArgs) prepended to the per-line args (lineArgs). The child
 // inherits the parent's stdin/stdout/stderr so output flows naturally to
 // the terminal; its exit code is converted to an error so the waiter
-// aggregates failures.
+// aggregates failures. A child interrupted along with run returns a
+// cancelation.
 func (o *Options) execChild(ctx context.Context, lineArgs, globalArgs []string, lineno int) error {
-	fullArgs := make([]string, 0, len(globalArgs)+len(lineArgs))
+	fullArgs := make([]string, 0, len(globalArgs)+len(lineArgs)+1)
+	fullArgs = append(fullArgs, "--resume-file=")
 	fullArgs = append(fullArgs, globalArgs...)
 	fullArgs = append(fullArgs, lineArgs...)
 
 	cmd := exec.CommandContext(ctx, o.binaryPath, fullArgs...)
+	// Interrupt rather than kill a child when ctx is canceled, so it
+	// aborts its multipart uploads and removes its temporary files.
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = childCancelDelay
 	cmd.Stdin = nil // child commands read from /dev/null, not the parent's stdin
 	cmd.Stdout = o.stdout
 	cmd.Stderr = o.stderr
@@ -311,7 +379,15 @@ func (o *Options) execChild(ctx context.Context, lineArgs, globalArgs []string,
 		// Convert a non-zero exit code to a descriptive error. exec.ExitError
 		// carries the stderr captured by the OS, but we already streamed
 		// stderr to the parent's stderr, so we only need the exit code.
+		if ctx.Err() != nil {
+			return fmt.Errorf("run: line %d `%s`: %w", lineno, strings.Join(lineArgs, " "), ctx.Err())
+		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
+			// -1: the signal killed a child that had not installed its
+			// handler yet.
+			if code := exitErr.ExitCode(); (code == childExitCanceled || code == -1) && interrupt.Requested() {
+				return fmt.Errorf("run: line %d `%s` interrupted: %w", lineno, strings.Join(lineArgs, " "), context.Canceled)
+			}
 			return fmt.Errorf("run: line %d `%s` exited with code %d", lineno, strings.Join(lineArgs, " "), exitErr.ExitCode())
 		}
 		return fmt.Errorf("run: line %d `%s`: %v", lineno, strings.Join(lineArgs, " "), err)
@@ -320,10 +396,10 @@ func (o *Options) execChild(ctx context.Context, lineArgs, globalArgs []string,
 }
 
 // globalFlagArgs converts the resolved CommonFlags (plus the root-only
-// forwarded flags: --log, --config, --stat) back into CLI args so they can
-// be prepended to each child command line. Only non-default values are
-// forwarded; this avoids overriding the child's own flag defaults with
-// empty strings.
+// forwarded flags: --log, --config, --stat, --drain-timeout) back into
+// CLI args so they can be prepended to each child command line. Only
+// non-default values are forwarded; this avoids overriding the child's
+// own flag defaults with empty strings.
 func globalFlagArgs(cf cliutil.CommonFlags, rf rootForward) []string {
 	var args []string
 	if cf.EndpointURL != "" {
@@ -367,6 +443,9 @@ func globalFlagArgs(cf cliutil.CommonFlags, rf rootForward) []string {
 	if cf.UseListObjectsV1 {
 		args = append(args, "--use-list-objects-v1")
 	}
+	if cf.ListShards > 1 {
+		args = append(args, "--list-shards", fmt.Sprintf("%d", cf.ListShards))
+	}
 	if rf.LogLevel != "" && rf.LogLevel != "info" {
 		args = append(args, "--log", rf.LogLevel)
 	}
@@ -376,6 +455,9 @@ func globalFlagArgs(cf cliutil.CommonFlags, rf rootForward) []string {
 	if rf.Stat {
 		args = append(args, "--stat")
 	}
+	if rf.DrainTimeout > 0 && rf.DrainTimeout != interrupt.DefaultDrainTimeout {
+		args = append(args, "--drain-timeout", rf.DrainTimeout.String())
+	}
 	return args
 }
 
@@ -476,89 +558,3 @@ func (r *lineReader) lines() <-chan string {
 func (r *lineReader) Err() error {
 	return r.err
 }
-
-// shellquoteSplit splits a command line into fields with POSIX-like
-// quoting rules, so `rm "s3://bucket/file with spaces"` targets one key
-// instead of three:
-//
-//   - unquoted whitespace separates fields;
-//   - single quotes preserve everything literally up to the closing quote;
-//   - double quotes preserve everything except `\"` and `\\`, which escape
-//     the quote and the backslash respectively;
-//   - an unquoted backslash escapes the next character.
-//
-// An unterminated quote (or a trailing bare backslash) returns an error so
-// a malformed line is rejected instead of silently targeting the wrong
-// key. Empty quoted strings (” / "") produce empty fields.
-func shellquoteSplit(s string) ([]string, error) {
-	const (
-		stateUnquoted = iota
-		stateSingle
-		stateDouble
-	)
-	var (
-		fields  []string
-		cur     []byte
-		inField bool // distinguishes "" (empty field) from no field at all
-	)
-	state := stateUnquoted
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		switch state {
-		case stateSingle:
-			if c == '\'' {
-				state = stateUnquoted
-			} else {
-				cur = append(cur, c)
-			}
-		case stateDouble:
-			switch c {
-			case '"':
-				state = stateUnquoted
-			case '\\':
-				// Inside double quotes a backslash only escapes the
-				// closing quote and itself; otherwise it is literal.
-				if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
-					i++
-					cur = append(cur, s[i])
-				} else {
-					cur = append(cur, c)
-				}
-			default:
-				cur = append(cur, c)
-			}
-		default: // stateUnquoted
-			switch c {
-			case '\'':
-				state = stateSingle
-				inField = true
-			case '"':
-				state = stateDouble
-				inField = true
-			case '\\':
-				if i+1 >= len(s) {
-					return nil, fmt.Errorf("trailing backslash")
-				}
-				i++
-				cur = append(cur, s[i])
-				inField = true
-			case ' ', '\t', '\n', '\r':
-				if inField {
-					fields = append(fields, string(cur))
-					cur = cur[:0]
-					inField = false
-				}
-			default:
-				cur = append(cur, c)
-				inField = true
-			}
-		}
-	}
-	if state == stateSingle || state == stateDouble {
-		return nil, fmt.Errorf("unterminated quote")
-	}
-	if inField {
-		fields = append(fields, string(cur))
-	}
-	return fields, nil
-}
@@ -276,6 +276,7 @@ func TestGlobalFlagArgsForwarding(t *testing.T) {
 	args := globalFlagArgs(cliutil.CommonFlags{
 		EndpointURL:      "http://127.0.0.1:9000",
 		UseListObjectsV1: true,
+		ListShards:       8,
 	}, rootForward{
 		LogLevel: "debug",
 		Config:   "/path/to/s6cmd.yaml",
@@ -289,6 +290,7 @@ func TestGlobalFlagArgsForwarding(t *testing.T) {
 	}
 	for _, pair := range [][2]string{
 		{"--endpoint-url", "http://127.0.0.1:9000"},
+		{"--list-shards", "8"},
 		{"--log", "debug"},
 		{"--config", "/path/to/s6cmd.yaml"},
 	} {
@@ -22,6 +22,7 @@ import (
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
 	"github.com/LinPr/s6cmd/internal/parallel"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
@@ -154,6 +155,7 @@ func addSharedSelectFlags(cmd *cobra.Command, o *Options) {
 	cmd.Flags().StringVar(&o.OutputFormat, "output-format", "", "output format of the result (json, csv)")
 	cmd.Flags().StringSliceVar(&o.Exclude, "exclude", nil, "exclude objects with given pattern (repeatable)")
 	cmd.Flags().StringSliceVar(&o.Include, "include", nil, "include objects with given pattern (repeatable)")
+	cmd.Flags().StringVar(&o.FilterFrom, "filter-from", "", "read ordered rsync-style '+ PATTERN' / '- PATTERN' rules from a file; the first matching rule wins")
 	cmd.Flags().BoolVar(&o.Raw, "raw", false, "disable wildcard operations, useful with filenames that contain glob characters")
 	cmd.Flags().BoolVar(&o.AllVersions, "all-versions", false, "list all versions of object(s)")
 	cmd.Flags().StringVar(&o.VersionID, "version-id", "", "use the specified version of the object")
@@ -178,6 +180,9 @@ type Flags struct {
 	// Exclude / Include are repeatable wildcard patterns.
 	Exclude []string
 	Include []string
+	// FilterFrom is a file of ordered rsync-style rules (see
+	// filter.Rules).
+	FilterFrom string
 	// Raw disables wildcard expansion on the source URL.
 	Raw bool
 	// AllVersions / VersionID select a specific version of the object.
@@ -314,6 +319,10 @@ func (o *Options) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	rules, err := filter.LoadRules(o.FilterFrom)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -388,7 +397,7 @@ func (o *Options) run(ctx context.Context) error {
 		if name == "" {
 			name = object.StorageURL.Absolute()
 		}
-		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) {
+		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) || rules.Excluded(name) {
 			continue
 		}
 
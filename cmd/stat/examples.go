@@ -7,4 +7,12 @@ const stat_examples = `Example 1: Print a remote object's metadata
 Example 2: Check if a remote bucket exists
 
          s6cmd stat s3://bucket
+
+Example 3: Check whether a local file matches an object, even one uploaded in parts
+
+         s6cmd stat --compare ./object s3://bucket/prefix/object
+
+Example 4: Print the size, content type and one metadata value of an object
+
+         s6cmd stat --format '{{.Size}} {{.ContentType}} {{index .Metadata "owner"}}' s3://bucket/prefix/object
 `
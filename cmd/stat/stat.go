@@ -2,11 +2,15 @@ package stat
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/etag"
+	"github.com/LinPr/s6cmd/internal/outfmt"
 	"github.com/LinPr/s6cmd/storage"
 	s3store "github.com/LinPr/s6cmd/storage/s3"
 	"github.com/LinPr/s6cmd/strutil"
@@ -37,6 +41,9 @@ func NewStatCmd() *cobra.Command {
 	// stat is read-only; --dry-run is accepted for interface consistency
 	// with the mutating commands but has no effect.
 	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "n", false, "no effect: stat is read-only (accepted for consistency)")
+	cmd.Flags().StringVar(&o.Compare, "compare", "", "check whether the given local file matches the object by reproducing its (multipart) ETag")
+	cmd.Flags().StringVar(&o.Format, "format", "", `print the metadata with a Go template, e.g. '{{.Key}}\t{{.Size}}\t{{index .Metadata "owner"}}'`)
+	cmd.Flags().IntVar(&o.PartSizeMiB, "part-size", cliutil.DefaultPartSizeMiB, "part size tried first when reproducing a multipart ETag with --compare, in MiB")
 
 	return &cmd
 }
@@ -45,7 +52,10 @@ type Args struct {
 	S3Uri string `validate:"omitempty"`
 }
 type Flags struct {
-	DryRun bool
+	DryRun      bool
+	Compare     string
+	PartSizeMiB int
+	Format      string
 }
 
 type Options struct {
@@ -87,13 +97,39 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 
 	jsonOutput := o.common.Output == "json"
 	if parsedUri.Path == "" {
-		return getBucketMetadata(ctx, cli, parsedUri.Bucket, jsonOutput, out)
+		if o.Compare != "" {
+			return fmt.Errorf("--compare requires an object, got bucket %q", o.S3Uri)
+		}
+		printer, err := outfmt.New(out, o.common.Output, o.Format, "url", "bucket", "region")
+		if err != nil {
+			return err
+		}
+		return getBucketMetadata(ctx, cli, parsedUri.Bucket, jsonOutput, printer, out)
+	}
+
+	columns := objectColumns
+	if o.Compare != "" {
+		columns = append(columns[:len(columns):len(columns)], compareColumns...)
+	}
+	printer, err := outfmt.New(out, o.common.Output, o.Format, columns...)
+	if err != nil {
+		return err
 	}
+	return o.getObjectMetadata(ctx, cli, parsedUri.Bucket, parsedUri.Path, jsonOutput, printer, out)
+}
 
-	return getObjectMetadata(ctx, cli, parsedUri.Bucket, parsedUri.Path, jsonOutput, out)
+// objectColumns are the csv and tsv columns of object metadata.
+var objectColumns = []string{
+	"url", "key", "size", "last_modified", "etag", "content_type", "storage_class", "version_id",
+	"cache_control", "content_encoding", "content_disposition", "server_side_encryption",
 }
 
-func getObjectMetadata(ctx context.Context, cli *s3store.S3Store, bucket, key string, jsonOutput bool, out io.Writer) error {
+// compareColumns are the csv and tsv columns added by --compare.
+var compareColumns = []string{"compare_file", "compare_match", "compare_part_size", "compare_reason"}
+
+// getObjectMetadata prints the metadata of the object, through printer
+// unless it is nil.
+func (o *Options) getObjectMetadata(ctx context.Context, cli *s3store.S3Store, bucket, key string, jsonOutput bool, printer *outfmt.Printer, out io.Writer) error {
 	output, err := cli.HeadObjectOutput(ctx, bucket, key)
 	if err != nil {
 		return err
@@ -102,6 +138,45 @@ func getObjectMetadata(ctx context.Context, cli *s3store.S3Store, bucket, key st
 		return fmt.Errorf("object not found: s3://%s/%s", bucket, key)
 	}
 
+	var cmp *statCompareMessage
+	if o.Compare != "" {
+		cmp, err = compareLocal(ctx, o.Compare, aws.ToInt64(output.ContentLength),
+			strutil.TrimQuotes(aws.ToString(output.ETag)), cliutil.PartSizeBytesFromMiB(o.PartSizeMiB))
+		if err != nil {
+			return err
+		}
+	}
+
+	if printer != nil {
+		row := outfmt.Row{
+			Type:                 outfmt.TypeFile,
+			URL:                  fmt.Sprintf("s3://%s/%s", bucket, key),
+			Bucket:               bucket,
+			Key:                  key,
+			Size:                 aws.ToInt64(output.ContentLength),
+			ModTime:              aws.ToTime(output.LastModified),
+			ETag:                 strutil.TrimQuotes(aws.ToString(output.ETag)),
+			ContentType:          aws.ToString(output.ContentType),
+			StorageClass:         nonEmpty(string(output.StorageClass), "STANDARD"),
+			VersionID:            aws.ToString(output.VersionId),
+			CacheControl:         aws.ToString(output.CacheControl),
+			ContentEncoding:      aws.ToString(output.ContentEncoding),
+			ContentDisposition:   aws.ToString(output.ContentDisposition),
+			ServerSideEncryption: string(output.ServerSideEncryption),
+			Metadata:             output.Metadata,
+		}
+		if cmp != nil {
+			row.CompareFile = cmp.File
+			row.CompareMatch = cmp.Match
+			row.ComparePartSize = cmp.PartSize
+			row.CompareReason = cmp.Reason
+		}
+		printer.Print(row)
+		if err := printer.Flush(); err != nil {
+			return err
+		}
+		return cmp.err(bucket, key)
+	}
 	if jsonOutput {
 		msg := statObjectMessage{
 			Key:                  fmt.Sprintf("s3://%s/%s", bucket, key),
@@ -118,9 +193,10 @@ func getObjectMetadata(ctx context.Context, cli *s3store.S3Store, bucket, key st
 			SSEKMSKeyID:          aws.ToString(output.SSEKMSKeyId),
 			Restore:              aws.ToString(output.Restore),
 			Metadata:             output.Metadata,
+			Compare:              cmp,
 		}
 		fmt.Fprintln(out, msg.JSON())
-		return nil
+		return cmp.err(bucket, key)
 	}
 
 	// Canonical key for display.
@@ -149,10 +225,54 @@ func getObjectMetadata(ctx context.Context, cli *s3store.S3Store, bucket, key st
 			fmt.Fprintf(out, "  %s: %s\n", k, v)
 		}
 	}
-	return nil
+	if cmp != nil {
+		fmt.Fprintf(out, "Compare: %s\n", cmp.File)
+		fmt.Fprintf(out, "Match: %t\n", cmp.Match)
+		if cmp.PartSize > 0 {
+			fmt.Fprintf(out, "PartSize: %d\n", cmp.PartSize)
+		}
+		if cmp.Reason != "" {
+			fmt.Fprintf(out, "Reason: %s\n", cmp.Reason)
+		}
+	}
+	return cmp.err(bucket, key)
+}
+
+// compareLocal checks whether the local file at path has the object's
+// content, reproducing its ETag with etag.Match. The part size of a
+// multipart ETag is auto-detected, trying preferred first.
+func compareLocal(ctx context.Context, path string, size int64, objectETag string, preferred int64) (*statCompareMessage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("--compare %q is a directory", path)
+	}
+	cmp := &statCompareMessage{File: path}
+	if info.Size() != size {
+		cmp.Reason = fmt.Sprintf("size %d != %d", info.Size(), size)
+		return cmp, nil
+	}
+	matched, partSize, err := etag.Match(ctx, path, size, objectETag, preferred)
+	if errors.Is(err, etag.ErrIncomparable) {
+		return nil, fmt.Errorf("cannot compare %q: ETag %q is not an MD5 of the content (SSE-KMS/SSE-C, or an unusual part size)", path, objectETag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cmp.Match = matched
+	if matched {
+		if parts, _ := etag.Parse(objectETag); parts > 0 {
+			cmp.PartSize = partSize
+		}
+	} else {
+		cmp.Reason = "content differs"
+	}
+	return cmp, nil
 }
 
-func getBucketMetadata(ctx context.Context, cli *s3store.S3Store, bucket string, jsonOutput bool, out io.Writer) error {
+func getBucketMetadata(ctx context.Context, cli *s3store.S3Store, bucket string, jsonOutput bool, printer *outfmt.Printer, out io.Writer) error {
 	output, err := cli.HeadBucketOutput(ctx, bucket)
 	if err != nil {
 		return err
@@ -164,6 +284,10 @@ func getBucketMetadata(ctx context.Context, cli *s3store.S3Store, bucket string,
 		// BucketRegion in newer versions). We print whatever the SDK exposes.
 		region = aws.ToString(output.BucketRegion)
 	}
+	if printer != nil {
+		printer.Print(outfmt.Row{Type: outfmt.TypeBucket, URL: "s3://" + bucket, Bucket: bucket, Region: region})
+		return printer.Flush()
+	}
 	if jsonOutput {
 		fmt.Fprintln(out, statBucketMessage{Bucket: "s3://" + bucket, Region: region}.JSON())
 		return nil
@@ -178,24 +302,25 @@ func getBucketMetadata(ctx context.Context, cli *s3store.S3Store, bucket string,
 // statObjectMessage is the JSON payload for object metadata when
 // --output json is set.
 type statObjectMessage struct {
-	Key                  string            `json:"key"`
-	ContentLength        int64             `json:"size"`
-	LastModified         *time.Time        `json:"last_modified,omitempty"`
-	ETag                 string            `json:"etag,omitempty"`
-	ContentType          string            `json:"content_type,omitempty"`
-	StorageClass         string            `json:"storage_class,omitempty"`
-	VersionID            string            `json:"version_id,omitempty"`
-	CacheControl         string            `json:"cache_control,omitempty"`
-	ContentEncoding      string            `json:"content_encoding,omitempty"`
-	ContentDisposition   string            `json:"content_disposition,omitempty"`
-	ServerSideEncryption string            `json:"server_side_encryption,omitempty"`
-	SSEKMSKeyID          string            `json:"sse_kms_key_id,omitempty"`
-	Restore              string            `json:"restore,omitempty"`
-	Metadata             map[string]string `json:"metadata,omitempty"`
+	Key                  string              `json:"key"`
+	ContentLength        int64               `json:"size"`
+	LastModified         *time.Time          `json:"last_modified,omitempty"`
+	ETag                 string              `json:"etag,omitempty"`
+	ContentType          string              `json:"content_type,omitempty"`
+	StorageClass         string              `json:"storage_class,omitempty"`
+	VersionID            string              `json:"version_id,omitempty"`
+	CacheControl         string              `json:"cache_control,omitempty"`
+	ContentEncoding      string              `json:"content_encoding,omitempty"`
+	ContentDisposition   string              `json:"content_disposition,omitempty"`
+	ServerSideEncryption string              `json:"server_side_encryption,omitempty"`
+	SSEKMSKeyID          string              `json:"sse_kms_key_id,omitempty"`
+	Restore              string              `json:"restore,omitempty"`
+	Metadata             map[string]string   `json:"metadata,omitempty"`
+	Compare              *statCompareMessage `json:"compare,omitempty"`
 }
 
 func (m statObjectMessage) String() string { return m.JSON() }
-func (m statObjectMessage) JSON() string   { return strutil.JSON(m) }
+func (m statObjectMessage) JSON() string   { return strutil.SchemaJSON("stat.object/v1", m) }
 
 // statBucketMessage is the JSON payload for bucket metadata when
 // --output json is set.
@@ -205,7 +330,25 @@ type statBucketMessage struct {
 }
 
 func (m statBucketMessage) String() string { return m.JSON() }
-func (m statBucketMessage) JSON() string   { return strutil.JSON(m) }
+func (m statBucketMessage) JSON() string   { return strutil.SchemaJSON("stat.bucket/v1", m) }
+
+// statCompareMessage is the result of --compare. PartSize is the detected
+// part size of a multipart ETag.
+type statCompareMessage struct {
+	File     string `json:"file"`
+	Match    bool   `json:"match"`
+	PartSize int64  `json:"part_size,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// err turns a failed comparison into the command's error so scripts can
+// rely on the exit code. It is nil when --compare was not given.
+func (m *statCompareMessage) err(bucket, key string) error {
+	if m == nil || m.Match {
+		return nil
+	}
+	return fmt.Errorf("%s does not match s3://%s/%s: %s", m.File, bucket, key, m.Reason)
+}
 
 func nonEmpty(v, fallback string) string {
 	if v == "" {
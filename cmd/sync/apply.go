@@ -0,0 +1,228 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+// errChangedSincePlan is reported for every entry apply refuses.
+var errChangedSincePlan = errors.New("changed since the plan was made, refusing to apply")
+
+// NewApplyCmd creates the `apply` command. It executes a plan written by
+// sync --plan-out with the sync transfer and delete tasks, checking each
+// entry's objects against the plan right before acting on it.
+func NewApplyCmd() *cobra.Command {
+	o := newApplyOptions()
+	cmd := cobra.Command{
+		Use:     "apply [flags] <plan.json>",
+		Short:   "execute a plan written by sync --plan-out",
+		Example: apply_examples,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd, args); err != nil {
+				return err
+			}
+			if err := o.validate(); err != nil {
+				return err
+			}
+			return o.run(cmd.Context(), cmd.InOrStdin(), cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "n", false, "check the plan and print one line per operation without transferring or deleting anything")
+	cmd.Flags().BoolVarP(&o.Yes, "yes", "y", false, "skip risk prompt when the plan deletes objects")
+	cmd.Flags().BoolVar(&o.ExitOnError, "exit-on-error", false, "stop applying the plan on the first error")
+
+	// --max-delete / --backup-dir, shared with sync and rm.
+	o.Guard.AddToCmd(&cmd)
+
+	// Shared flags: --concurrency, --part-size, --acl, --metadata, ...
+	o.Shared.AddToCmd(&cmd)
+
+	return &cmd
+}
+
+type ApplyArgs struct {
+	Plan string `validate:"required"`
+}
+
+type ApplyFlags struct {
+	DryRun      bool
+	Yes         bool
+	ExitOnError bool
+	// Guard caps the planned deletes and backs up deleted and
+	// overwritten objects (see cliutil.DeleteGuard).
+	Guard cliutil.DeleteGuard
+	cliutil.CommonFlags
+}
+
+type ApplyOptions struct {
+	ApplyArgs
+	ApplyFlags
+	Shared *cliutil.SharedFlags
+}
+
+func newApplyOptions() *ApplyOptions {
+	return &ApplyOptions{Shared: cliutil.NewSharedFlags()}
+}
+
+func (o *ApplyOptions) complete(cmd *cobra.Command, args []string) error {
+	if len(args) >= 1 {
+		o.Plan = args[0]
+	}
+	o.CommonFlags = cliutil.LoadParentFlags(cmd)
+	o.CommonFlags.DryRun = o.DryRun
+	return nil
+}
+
+func (o *ApplyOptions) validate() error {
+	if err := validator.New().Struct(o.ApplyArgs); err != nil {
+		return err
+	}
+	if err := o.Shared.ValidateMetadataDirective(); err != nil {
+		return err
+	}
+	return o.Guard.Validate()
+}
+
+// syncer returns the sync Options whose transfer and delete tasks apply
+// runs, with the delete order recorded in the plan.
+func (o *ApplyOptions) syncer(plan *syncPlan) *Options {
+	return &Options{
+		Flags: Flags{
+			ExitOnError:  o.ExitOnError,
+			DeleteBefore: plan.DeleteOrder == planDeleteBefore,
+			DeleteAfter:  plan.DeleteOrder == planDeleteAfter,
+			Guard:        o.Guard,
+			CommonFlags:  o.CommonFlags,
+		},
+		Shared: o.Shared,
+	}
+}
+
+// applyItem is a copy or delete entry of the plan with its URLs parsed.
+// src is nil for deletes.
+type applyItem struct {
+	entry    planEntry
+	src, dst *storage.StorageURL
+}
+
+func (o *ApplyOptions) run(ctx context.Context, stdin io.Reader, stderr io.Writer) error {
+	plan, err := loadSyncPlan(o.Plan)
+	if err != nil {
+		return err
+	}
+	var copies, deletes []applyItem
+	for _, e := range plan.Entries {
+		if e.Action == planSkip {
+			continue
+		}
+		item := applyItem{entry: e}
+		if item.dst, err = e.Destination.url(); err != nil {
+			return err
+		}
+		if o.Guard.BackupEnabled() && !item.dst.IsRemote() {
+			return fmt.Errorf("--backup-dir requires an s3:// destination")
+		}
+		if o.Guard.Contains(item.dst) {
+			return fmt.Errorf("--backup-dir %q must not be inside the destination %q", o.Guard.BackupDir, item.dst)
+		}
+		if e.Action == planDelete {
+			deletes = append(deletes, item)
+			continue
+		}
+		if item.src, err = e.Source.url(); err != nil {
+			return err
+		}
+		copies = append(copies, item)
+	}
+
+	if len(deletes) > 0 && !o.Yes && !o.DryRun {
+		fmt.Fprintf(stderr, "WARNING: this plan deletes %d objects in destination.\n", len(deletes))
+		fmt.Fprintf(stderr, "  plan: %s\n  destination: %s\n", o.Plan, plan.Destination)
+		if err := cliutil.Confirm(ctx, stdin, stderr, "Continue?"); err != nil {
+			return fmt.Errorf("apply: %w", err)
+		}
+	}
+	if err := o.Guard.Check(len(deletes), plan.DestinationObjects); err != nil {
+		return err
+	}
+
+	store, err := cliutil.NewStorage(ctx, o.CommonFlags)
+	if err != nil {
+		return err
+	}
+	syncer := o.syncer(plan)
+	transfer := syncer.transferTask(ctx, store)
+
+	transfers := make([]parallel.Task, 0, len(copies))
+	for _, item := range copies {
+		transfers = append(transfers, func() error {
+			if err := o.verify(ctx, store, item); err != nil {
+				return err
+			}
+			if item.entry.Destination.Exists {
+				if err := syncer.Guard.Backup(ctx, store, "sync", &storage.Object{StorageURL: item.dst}); err != nil {
+					return err
+				}
+			}
+			return transfer(&storage.Object{StorageURL: item.src, Size: item.entry.Source.Size}, item.dst, nil)()
+		})
+	}
+	deleteTasks := make([]parallel.Task, 0, len(deletes))
+	for _, item := range deletes {
+		remove := syncer.deleteTask(ctx, store, &storage.Object{StorageURL: item.dst})
+		deleteTasks = append(deleteTasks, func() error {
+			if err := o.verify(ctx, store, item); err != nil {
+				return err
+			}
+			return remove()
+		})
+	}
+
+	waiter := parallel.NewWaiter()
+	ec := cliutil.NewErrorCollector("apply")
+	drainDone := ec.Drain(waiter)
+	syncer.execute(ec, waiter, drainDone, transfers, deleteTasks)
+	return ec.Aggregate()
+}
+
+// verify stats the objects of an entry and fails with
+// errChangedSincePlan unless both still match the plan: the source of a
+// copy, and the destination, which must still be missing when the plan
+// created it.
+func (o *ApplyOptions) verify(ctx context.Context, store *storage.Storage, item applyItem) error {
+	check := func(side string, planned *planObject, u *storage.StorageURL) error {
+		obj, err := store.Stat(ctx, u)
+		if err != nil {
+			if !errorpkg.IsWarning(err) {
+				return err
+			}
+			obj = nil
+		}
+		if !planned.matches(obj) {
+			return fmt.Errorf("%s %s %w", side, u, errChangedSincePlan)
+		}
+		return nil
+	}
+	e := &errorpkg.Error{Op: "apply", Dst: item.dst.String()}
+	if item.src != nil {
+		e.Src = item.src.String()
+		if e.Err = check("source", item.entry.Source, item.src); e.Err != nil {
+			return e
+		}
+	}
+	if e.Err = check("destination", item.entry.Destination, item.dst); e.Err != nil {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,652 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/etag"
+	"github.com/LinPr/s6cmd/internal/ignore"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+// Conflict policies for bisync --conflict: what to do with a key that
+// changed on both sides since the last run.
+const (
+	conflictNewer    = "newer"
+	conflictKeepBoth = "keep-both"
+	conflictFail     = "fail"
+)
+
+// errBisyncConflict is reported for every conflict under --conflict fail.
+var errBisyncConflict = errors.New("changed on both sides since the last run")
+
+// NewBisyncCmd creates the `bisync` command. It lists both sides with the
+// sync listing, diffs each side against the state saved by the previous
+// run, and propagates creates, updates and deletes in both directions
+// with the sync transfer tasks.
+func NewBisyncCmd() *cobra.Command {
+	o := newBisyncOptions()
+	cmd := cobra.Command{
+		Use:     "bisync [flags] <path1> <path2>",
+		Short:   "synchronize two directories or prefixes in both directions",
+		Example: bisync_examples,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd, args); err != nil {
+				return err
+			}
+			if err := o.validate(); err != nil {
+				return err
+			}
+			return o.run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "n", false, "plan the bisync and print one line per operation without changing either side or the state")
+	cmd.Flags().StringVar(&o.Conflict, "conflict", conflictNewer, "resolve keys changed on both sides: newer (the newer version wins), keep-both (the older version is also kept as <name>.conflict-<time><ext>), fail (leave both untouched and exit 1)")
+	cmd.Flags().StringVar(&o.StateFile, "state-file", "", "file storing the listing of the last run (default: a per-pair file under the user cache directory)")
+	cmd.Flags().BoolVar(&o.Resync, "resync", false, "ignore the saved state: copy files missing on either side, resolve differing files by --conflict and delete nothing")
+
+	// --max-delete / --backup-dir, shared with sync and rm.
+	o.Guard.AddToCmd(&cmd)
+
+	// Shared flags: --concurrency, --part-size, --exclude, --include, ...
+	o.Shared.AddToCmd(&cmd)
+
+	return &cmd
+}
+
+type BisyncArgs struct {
+	Path1 string `validate:"required"`
+	Path2 string `validate:"required"`
+}
+
+type BisyncFlags struct {
+	DryRun    bool
+	Conflict  string
+	StateFile string
+	Resync    bool
+	// Guard caps the deletes propagated in one run and backs up deleted
+	// and overwritten objects (see cliutil.DeleteGuard).
+	Guard cliutil.DeleteGuard
+	cliutil.CommonFlags
+}
+
+type BisyncOptions struct {
+	BisyncArgs
+	BisyncFlags
+	Shared *cliutil.SharedFlags
+
+	// now stamps the names of conflict copies.
+	now time.Time
+}
+
+func newBisyncOptions() *BisyncOptions {
+	return &BisyncOptions{Shared: cliutil.NewSharedFlags()}
+}
+
+func (o *BisyncOptions) complete(cmd *cobra.Command, args []string) error {
+	if len(args) >= 2 {
+		o.Path1 = args[0]
+		o.Path2 = args[1]
+	}
+	o.CommonFlags = cliutil.LoadParentFlags(cmd)
+	o.CommonFlags.DryRun = o.DryRun
+	o.now = time.Now().UTC()
+	return nil
+}
+
+func (o *BisyncOptions) validate() error {
+	if err := validator.New().Struct(o.BisyncArgs); err != nil {
+		return err
+	}
+	switch o.Conflict {
+	case conflictNewer, conflictKeepBoth, conflictFail:
+	default:
+		return fmt.Errorf("--conflict must be %s, %s or %s, got %q", conflictNewer, conflictKeepBoth, conflictFail, o.Conflict)
+	}
+	if err := o.Shared.ValidateMetadataDirective(); err != nil {
+		return err
+	}
+	// A filter on size or mtime would drop an object from the listing the
+	// moment it changes across the threshold, which reads as a deletion
+	// and would be propagated to the other side.
+	if o.Shared.Filter != "" {
+		return errors.New("--filter is not supported by bisync: objects changing across it would read as deleted")
+	}
+	return o.Guard.Validate()
+}
+
+// syncer returns the sync Options whose listing and transfer tasks bisync
+// reuses. A listing error aborts the run: a partial listing would read as
+// deletions and be propagated to the other side.
+func (o *BisyncOptions) syncer() *Options {
+	return &Options{
+		Flags:  Flags{ExitOnError: true, CommonFlags: o.CommonFlags},
+		Shared: o.Shared,
+	}
+}
+
+func (o *BisyncOptions) run(ctx context.Context) error {
+	roots, err := o.roots()
+	if err != nil {
+		return err
+	}
+	if o.Guard.BackupEnabled() && (!roots[0].IsRemote() || !roots[1].IsRemote()) {
+		return fmt.Errorf("--backup-dir requires s3:// on both sides")
+	}
+	ids := [2]string{bisyncRootID(roots[0]), bisyncRootID(roots[1])}
+
+	stateFile := o.StateFile
+	if stateFile == "" {
+		if stateFile, err = defaultStateFile(ids[0], ids[1]); err != nil {
+			return err
+		}
+	}
+	var prev *bisyncState
+	if !o.Resync {
+		if prev, err = loadBisyncState(stateFile, ids[0], ids[1]); err != nil {
+			return err
+		}
+	}
+
+	store, err := cliutil.NewStorage(ctx, o.CommonFlags)
+	if err != nil {
+		return err
+	}
+	before, err := o.listSides(ctx, store, roots)
+	if err != nil {
+		return err
+	}
+
+	if prev != nil {
+		// A mistyped or unmounted root lists nothing, which the diff
+		// would read as "every file was deleted" and propagate.
+		for i, objs := range before {
+			if len(objs) == 0 && len(prev.Entries) > 0 {
+				return fmt.Errorf("path%d %s is empty but the last run synced %d objects; refusing to delete them on the other side (rerun with --resync to copy them back)", i+1, ids[i], len(prev.Entries))
+			}
+		}
+	}
+
+	actions := planBisync(prev, before, o.Conflict, func(a, b *storage.Object) bool {
+		return o.identical(ctx, a, b)
+	})
+	deletes := 0
+	for _, a := range actions {
+		if a.kind == bisyncDelete {
+			deletes++
+		}
+	}
+	if prev != nil {
+		if err := o.Guard.Check(deletes, len(prev.Entries)); err != nil {
+			return err
+		}
+	}
+
+	failed, ec := o.apply(ctx, store, roots, before, actions)
+	if o.DryRun {
+		return ec.Aggregate()
+	}
+
+	// The state is rebuilt from a fresh listing: uploads get a new
+	// LastModified and downloads a new mtime, which the next run must
+	// not mistake for changes.
+	after, err := o.listSides(ctx, store, roots)
+	if err != nil {
+		return errors.Join(ec.Aggregate(), fmt.Errorf("state not saved: %w", err))
+	}
+	next := nextBisyncState(prev, ids, before, after, actions, failed)
+	next.UpdatedAt = time.Now().UTC()
+	if err := next.save(stateFile); err != nil {
+		return errors.Join(ec.Aggregate(), fmt.Errorf("save state: %w", err))
+	}
+	return ec.Aggregate()
+}
+
+// roots parses both paths. Remote roots are forced to a prefix; local
+// roots are made absolute and must be directories, which are created
+// when missing.
+func (o *BisyncOptions) roots() ([2]*storage.StorageURL, error) {
+	var roots [2]*storage.StorageURL
+	for i, p := range []string{o.Path1, o.Path2} {
+		u, err := storage.NewStorageURL(p)
+		if err != nil {
+			return roots, err
+		}
+		if u.IsWildcard() {
+			return roots, fmt.Errorf("path%d %q can not contain glob characters", i+1, p)
+		}
+		if u.IsRemote() {
+			if u.Bucket == "" {
+				return roots, fmt.Errorf("path%d %q: bucket name is required", i+1, p)
+			}
+			if !u.IsBucket() && !u.IsPrefix() {
+				if u, err = storage.NewStorageURL(p + "/"); err != nil {
+					return roots, err
+				}
+			}
+			roots[i] = u
+			continue
+		}
+
+		abs, err := filepath.Abs(u.Absolute())
+		if err != nil {
+			return roots, err
+		}
+		info, err := os.Stat(abs)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if !o.DryRun {
+				if err := os.MkdirAll(abs, 0o755); err != nil {
+					return roots, err
+				}
+			}
+		case err != nil:
+			return roots, err
+		case !info.IsDir():
+			return roots, fmt.Errorf("path%d %q must be a directory", i+1, p)
+		}
+		if roots[i], err = storage.NewStorageURL(abs, storage.WithIgnoreFiles(o.Shared.Ignore.Files())); err != nil {
+			return roots, err
+		}
+	}
+	return roots, nil
+}
+
+// bisyncRootID identifies a root in the state file.
+func bisyncRootID(u *storage.StorageURL) string {
+	if u.IsRemote() {
+		return u.String()
+	}
+	return u.Absolute()
+}
+
+// listSides lists both roots keyed by path relative to the root, dropping
+// keys excluded by --exclude/--include, --filter-from or the ignore files
+// so they are neither propagated nor recorded.
+func (o *BisyncOptions) listSides(ctx context.Context, store *storage.Storage, roots [2]*storage.StorageURL) ([2]map[string]*storage.Object, error) {
+	var sides [2]map[string]*storage.Object
+	filters, err := newSyncFilters(o.Shared, nil)
+	if err != nil {
+		return sides, err
+	}
+	// A key a local root's ignore files leave out is dropped from both
+	// sides: listed on one side only, it would be copied back or deleted.
+	var trees []*ignore.Tree
+	for _, root := range roots {
+		if !root.IsRemote() {
+			trees = append(trees, ignore.NewTree(root.Absolute(), root.IgnoreFiles()))
+		}
+	}
+	syncer := o.syncer()
+	for i, root := range roots {
+		followSymlinks := !root.IsRemote() && !o.Shared.NoFollowSymlinks
+		objs, err := syncer.listObjects(ctx, store, root, followSymlinks, true)
+		if err != nil {
+			return sides, err
+		}
+		sides[i] = make(map[string]*storage.Object, len(objs))
+		for _, obj := range objs {
+			key := bisyncKey(root, obj.StorageURL)
+			if key == "" || filters.excluded(key, obj) {
+				continue
+			}
+			ignored, err := bisyncIgnored(trees, key)
+			if err != nil {
+				return sides, err
+			}
+			if ignored {
+				continue
+			}
+			sides[i][key] = obj
+		}
+	}
+	return sides, nil
+}
+
+// bisyncIgnored reports whether any of the ignore trees leaves out key.
+func bisyncIgnored(trees []*ignore.Tree, key string) (bool, error) {
+	for _, tree := range trees {
+		if ignored, err := tree.Ignored(key, false); err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
+
+// bisyncKey returns the slash-separated path of u under root, the form
+// both sides are matched on. listObjects already sets the relative path of
+// remote objects to the key minus the root prefix.
+func bisyncKey(root, u *storage.StorageURL) string {
+	if u.IsRemote() {
+		return u.Relative()
+	}
+	rel, err := filepath.Rel(root.Absolute(), u.Absolute())
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// identical reports whether two objects changed on both sides ended up
+// with the same content, which needs no conflict resolution. Remote pairs
+// compare ETags, a local file is hashed against the remote ETag, and two
+// local files compare modification times.
+func (o *BisyncOptions) identical(ctx context.Context, a, b *storage.Object) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	aRemote, bRemote := a.StorageURL.IsRemote(), b.StorageURL.IsRemote()
+	switch {
+	case aRemote && bRemote:
+		return a.Etag != "" && a.Etag == b.Etag
+	case aRemote != bRemote:
+		local, remote := a, b
+		if aRemote {
+			local, remote = b, a
+		}
+		matched, _, err := etag.Match(ctx, local.StorageURL.Absolute(), local.Size, remote.Etag, o.Shared.PartSizeBytes())
+		return err == nil && matched
+	default:
+		return a.ModTime != nil && b.ModTime != nil && a.ModTime.Equal(*b.ModTime)
+	}
+}
+
+// bisyncChange is how one side changed a key since the last run.
+type bisyncChange int
+
+const (
+	changeNone bisyncChange = iota
+	changeCreated
+	changeModified
+	changeDeleted
+)
+
+func sideChange(prev *bisyncSnapshot, cur *storage.Object) bisyncChange {
+	switch {
+	case prev == nil && cur == nil:
+		return changeNone
+	case prev == nil:
+		return changeCreated
+	case cur == nil:
+		return changeDeleted
+	case prev.equal(cur):
+		return changeNone
+	default:
+		return changeModified
+	}
+}
+
+type bisyncActionKind int
+
+const (
+	// bisyncCopy copies the key from side to the other side.
+	bisyncCopy bisyncActionKind = iota
+	// bisyncDelete deletes the key on side.
+	bisyncDelete
+	// bisyncKeepBoth copies the key from side (the newer version) to
+	// the other side after keeping the other side's version under a
+	// conflict name on both sides.
+	bisyncKeepBoth
+	// bisyncConflict reports the key and leaves both sides untouched.
+	bisyncConflict
+)
+
+type bisyncAction struct {
+	key  string
+	kind bisyncActionKind
+	side int
+}
+
+// planBisync diffs each side against the previous state and returns the
+// actions that bring both sides in step. A change on one side is
+// propagated to the other; a modification beats a deletion on the other
+// side; a key created or modified on both sides is a conflict unless same
+// reports identical content. Without a previous state nothing was ever
+// synced, so every file is "created" and nothing is deleted.
+func planBisync(prev *bisyncState, cur [2]map[string]*storage.Object, policy string, same func(a, b *storage.Object) bool) []bisyncAction {
+	keys := make(map[string]struct{})
+	if prev != nil {
+		for key := range prev.Entries {
+			keys[key] = struct{}{}
+		}
+	}
+	for _, side := range cur {
+		for key := range side {
+			keys[key] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var actions []bisyncAction
+	for _, key := range sorted {
+		entry := prev.entry(key)
+		c := [2]bisyncChange{
+			sideChange(entry.side(0), cur[0][key]),
+			sideChange(entry.side(1), cur[1][key]),
+		}
+		switch {
+		case c[0] == changeNone && c[1] == changeNone,
+			c[0] == changeDeleted && c[1] == changeDeleted:
+		case c[0] == changeDeleted && c[1] == changeNone:
+			if cur[1][key] != nil {
+				actions = append(actions, bisyncAction{key: key, kind: bisyncDelete, side: 1})
+			}
+		case c[0] == changeNone && c[1] == changeDeleted:
+			if cur[0][key] != nil {
+				actions = append(actions, bisyncAction{key: key, kind: bisyncDelete, side: 0})
+			}
+		case c[1] == changeNone || c[1] == changeDeleted:
+			actions = append(actions, bisyncAction{key: key, kind: bisyncCopy, side: 0})
+		case c[0] == changeNone || c[0] == changeDeleted:
+			actions = append(actions, bisyncAction{key: key, kind: bisyncCopy, side: 1})
+		default:
+			if same(cur[0][key], cur[1][key]) {
+				continue
+			}
+			winner := newerSide(cur[0][key], cur[1][key])
+			switch policy {
+			case conflictKeepBoth:
+				actions = append(actions, bisyncAction{key: key, kind: bisyncKeepBoth, side: winner})
+			case conflictFail:
+				actions = append(actions, bisyncAction{key: key, kind: bisyncConflict})
+			default:
+				actions = append(actions, bisyncAction{key: key, kind: bisyncCopy, side: winner})
+			}
+		}
+	}
+	return actions
+}
+
+// entry returns the state entry for key; it is nil-safe so a first run
+// needs no special casing.
+func (st *bisyncState) entry(key string) *bisyncEntry {
+	if st == nil {
+		return nil
+	}
+	return st.Entries[key]
+}
+
+// newerSide returns the side holding the newer version; path1 wins ties.
+func newerSide(a, b *storage.Object) int {
+	if a.ModTime != nil && b.ModTime != nil && b.ModTime.After(*a.ModTime) {
+		return 1
+	}
+	return 0
+}
+
+// conflictName returns the name the losing version of key is kept under
+// by --conflict keep-both: "dir/report.conflict-20261017-093000.txt".
+func conflictName(key string, t time.Time) string {
+	dir, base := path.Split(key)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		stem, ext = base, ""
+	}
+	return dir + stem + ".conflict-" + t.Format("20060102-150405") + ext
+}
+
+// apply runs every action on the parallel manager and returns the keys
+// whose action failed, which keep their previous state entry.
+func (o *BisyncOptions) apply(ctx context.Context, store *storage.Storage, roots [2]*storage.StorageURL, cur [2]map[string]*storage.Object, actions []bisyncAction) (map[string]bool, *cliutil.ErrorCollector) {
+	// bisync has no --report.
+	build := o.syncer().transferTask(ctx, store)
+	transfer := func(srcURL, dstURL *storage.StorageURL) parallel.Task {
+		return build(&storage.Object{StorageURL: srcURL}, dstURL, nil)
+	}
+
+	// Every task writes only its own slot; the slice is read after
+	// waiter.Wait().
+	done := make([]bool, len(actions))
+	waiter := parallel.NewWaiter()
+	ec := cliutil.NewErrorCollector("bisync")
+	drainDone := ec.Drain(waiter)
+	for i, a := range actions {
+		task := o.actionTask(ctx, store, transfer, roots, cur, a)
+		parallel.Run(func() error {
+			if err := task(); err != nil {
+				return err
+			}
+			done[i] = true
+			return nil
+		}, waiter)
+	}
+	waiter.Wait()
+	drainDone()
+
+	failed := make(map[string]bool)
+	for i, a := range actions {
+		if !done[i] {
+			failed[a.key] = true
+		}
+	}
+	return failed, ec
+}
+
+func (o *BisyncOptions) actionTask(ctx context.Context, store *storage.Storage, transfer func(srcURL, dstURL *storage.StorageURL) parallel.Task, roots [2]*storage.StorageURL, cur [2]map[string]*storage.Object, a bisyncAction) parallel.Task {
+	from, to := a.side, 1-a.side
+	src, dst := roots[from].Join(a.key), roots[to].Join(a.key)
+	switch a.kind {
+	case bisyncDelete:
+		obj := cur[a.side][a.key]
+		return func() error {
+			if err := o.Guard.Backup(ctx, store, "bisync", obj); err != nil {
+				return err
+			}
+			if err := store.Delete(ctx, obj.StorageURL); err != nil {
+				return &errorpkg.Error{Op: "bisync", Dst: obj.StorageURL.String(), Err: err}
+			}
+			log.Info(log.InfoMessage{Operation: "rm", Source: obj.StorageURL.String()})
+			return nil
+		}
+	case bisyncKeepBoth:
+		// The older version is copied to the conflict name on its own
+		// side and on the winner's side before the newer version
+		// overwrites it.
+		name := conflictName(a.key, o.now)
+		keptHere, keptThere := roots[to].Join(name), roots[from].Join(name)
+		return func() error {
+			if err := transfer(dst, keptHere)(); err != nil {
+				return err
+			}
+			if err := transfer(dst, keptThere)(); err != nil {
+				return err
+			}
+			return transfer(src, dst)()
+		}
+	case bisyncConflict:
+		return func() error {
+			return &errorpkg.Error{Op: "bisync", Src: roots[0].Join(a.key).String(), Dst: roots[1].Join(a.key).String(), Err: errBisyncConflict}
+		}
+	default:
+		copyTask := transfer(src, dst)
+		existing := cur[to][a.key]
+		if existing == nil || !o.Guard.BackupEnabled() {
+			return copyTask
+		}
+		return func() error {
+			if err := o.Guard.Backup(ctx, store, "bisync", existing); err != nil {
+				return err
+			}
+			return copyTask()
+		}
+	}
+}
+
+// nextBisyncState builds the state to save from the listing taken after
+// the run. Keys present on only one side are left out so the next run
+// propagates them. A key whose action failed, or that changed on a side
+// no action wrote while the run was in progress, keeps its previous entry
+// so the next run sees the change again.
+func nextBisyncState(prev *bisyncState, ids [2]string, before, after [2]map[string]*storage.Object, actions []bisyncAction, failed map[string]bool) *bisyncState {
+	next := &bisyncState{
+		Version: bisyncStateVersion,
+		Path1:   ids[0],
+		Path2:   ids[1],
+		Entries: make(map[string]*bisyncEntry),
+	}
+
+	written := make(map[string][2]bool)
+	for _, a := range actions {
+		w := written[a.key]
+		switch a.kind {
+		case bisyncCopy, bisyncKeepBoth:
+			w[1-a.side] = true
+		case bisyncDelete:
+			w[a.side] = true
+		}
+		written[a.key] = w
+	}
+
+	keep := func(key string) {
+		if e := prev.entry(key); e != nil {
+			next.Entries[key] = e
+		}
+	}
+	for key := range failed {
+		keep(key)
+	}
+	for key, a := range after[0] {
+		b := after[1][key]
+		if b == nil || failed[key] {
+			continue
+		}
+		stable := true
+		for i, obj := range [2]*storage.Object{a, b} {
+			prior, seen := before[i][key]
+			if written[key][i] || !seen {
+				// Written by this run, or a conflict copy it created.
+				continue
+			}
+			if !newSnapshot(prior).equal(obj) {
+				stable = false
+			}
+		}
+		if !stable {
+			keep(key)
+			continue
+		}
+		next.Entries[key] = &bisyncEntry{Path1: newSnapshot(a), Path2: newSnapshot(b)}
+	}
+	return next
+}
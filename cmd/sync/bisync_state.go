@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// bisyncStateVersion is bumped when the state file layout changes. A state
+// file of another version is rejected rather than misread.
+const bisyncStateVersion = 1
+
+// bisyncState is the listing both sides agreed on after the last run. It
+// is what bisync diffs each side against to tell a created, modified or
+// deleted file from one that was merely never synced.
+type bisyncState struct {
+	Version   int                     `json:"version"`
+	Path1     string                  `json:"path1"`
+	Path2     string                  `json:"path2"`
+	UpdatedAt time.Time               `json:"updated_at"`
+	Entries   map[string]*bisyncEntry `json:"entries"`
+}
+
+// bisyncEntry records one key as each side held it after the last run.
+type bisyncEntry struct {
+	Path1 *bisyncSnapshot `json:"path1"`
+	Path2 *bisyncSnapshot `json:"path2"`
+}
+
+// side returns the snapshot of side i (0 for path1, 1 for path2).
+func (e *bisyncEntry) side(i int) *bisyncSnapshot {
+	if e == nil {
+		return nil
+	}
+	if i == 0 {
+		return e.Path1
+	}
+	return e.Path2
+}
+
+// bisyncSnapshot is the part of an object's listing that changes when the
+// object is rewritten.
+type bisyncSnapshot struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag,omitempty"`
+}
+
+func newSnapshot(obj *storage.Object) *bisyncSnapshot {
+	if obj == nil {
+		return nil
+	}
+	s := &bisyncSnapshot{Size: obj.Size, ETag: obj.Etag}
+	if obj.ModTime != nil {
+		s.ModTime = obj.ModTime.UTC()
+	}
+	return s
+}
+
+// equal reports whether obj still matches the snapshot.
+func (s *bisyncSnapshot) equal(obj *storage.Object) bool {
+	cur := newSnapshot(obj)
+	if s == nil || cur == nil {
+		return s == nil && cur == nil
+	}
+	if s.ETag != "" && cur.ETag != "" && s.ETag != cur.ETag {
+		return false
+	}
+	return s.Size == cur.Size && s.ModTime.Equal(cur.ModTime)
+}
+
+// defaultStateFile returns the state file for a pair of roots under the
+// user cache directory, named after a hash of the pair so every pair gets
+// its own file.
+func defaultStateFile(path1, path2 string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate a state directory, pass --state-file: %w", err)
+	}
+	sum := sha256.Sum256([]byte(path1 + "\x00" + path2))
+	return filepath.Join(dir, "s6cmd", "bisync", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// loadBisyncState reads the state file. A missing file yields nil: the
+// pair has never been synced.
+func loadBisyncState(file, path1, path2 string) (*bisyncState, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st bisyncState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("state file %s is corrupt (rerun with --resync): %w", file, err)
+	}
+	if st.Version != bisyncStateVersion {
+		return nil, fmt.Errorf("state file %s has version %d, want %d (rerun with --resync)", file, st.Version, bisyncStateVersion)
+	}
+	if st.Path1 != path1 || st.Path2 != path2 {
+		return nil, fmt.Errorf("state file %s belongs to %s <-> %s", file, st.Path1, st.Path2)
+	}
+	if st.Entries == nil {
+		st.Entries = make(map[string]*bisyncEntry)
+	}
+	return &st, nil
+}
+
+// save writes the state through a temporary file and a rename, so an
+// interrupted run leaves the previous state intact.
+func (st *bisyncState) save(file string) error {
+	return writeJSONFile(file, st)
+}
+
+// writeJSONFile writes v as indented JSON through a temporary file in the
+// same directory and a rename, creating the directory when missing.
+func writeJSONFile(file string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
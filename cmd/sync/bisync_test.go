@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+var bisyncT0 = time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+// bisyncObj returns a listed object of the given size modified `age`
+// minutes after bisyncT0.
+func bisyncObj(size int64, age int) *storage.Object {
+	mod := bisyncT0.Add(time.Duration(age) * time.Minute)
+	return &storage.Object{Size: size, ModTime: &mod}
+}
+
+func bisyncEntryOf(a, b *storage.Object) *bisyncEntry {
+	return &bisyncEntry{Path1: newSnapshot(a), Path2: newSnapshot(b)}
+}
+
+func describe(actions []bisyncAction) []string {
+	out := make([]string, 0, len(actions))
+	kinds := map[bisyncActionKind]string{bisyncCopy: "copy", bisyncDelete: "delete", bisyncKeepBoth: "keep-both", bisyncConflict: "conflict"}
+	for _, a := range actions {
+		out = append(out, fmt.Sprintf("%s %s from path%d", kinds[a.kind], a.key, a.side+1))
+	}
+	return out
+}
+
+// TestPlanBisync covers the change matrix: one-sided changes propagate,
+// deletions propagate only onto unchanged keys, and keys changed on both
+// sides are resolved by the policy.
+func TestPlanBisync(t *testing.T) {
+	synced := bisyncObj(10, 0)
+	prev := &bisyncState{Entries: map[string]*bisyncEntry{
+		"same.txt":       bisyncEntryOf(synced, synced),
+		"mod1.txt":       bisyncEntryOf(synced, synced),
+		"mod2.txt":       bisyncEntryOf(synced, synced),
+		"del1.txt":       bisyncEntryOf(synced, synced),
+		"del2.txt":       bisyncEntryOf(synced, synced),
+		"delboth.txt":    bisyncEntryOf(synced, synced),
+		"del1mod2.txt":   bisyncEntryOf(synced, synced),
+		"conflict.txt":   bisyncEntryOf(synced, synced),
+		"samechange.txt": bisyncEntryOf(synced, synced),
+	}}
+	cur := [2]map[string]*storage.Object{
+		{
+			"same.txt":       synced,
+			"mod1.txt":       bisyncObj(11, 5),
+			"mod2.txt":       synced,
+			"del2.txt":       synced,
+			"conflict.txt":   bisyncObj(12, 5),
+			"samechange.txt": bisyncObj(13, 5),
+			"new1.txt":       bisyncObj(1, 5),
+		},
+		{
+			"same.txt":       synced,
+			"mod1.txt":       synced,
+			"mod2.txt":       bisyncObj(11, 5),
+			"del1.txt":       synced,
+			"del1mod2.txt":   bisyncObj(11, 5),
+			"conflict.txt":   bisyncObj(14, 9),
+			"samechange.txt": bisyncObj(13, 6),
+			"new2.txt":       bisyncObj(1, 5),
+		},
+	}
+	same := func(a, b *storage.Object) bool { return a.Size == 13 && b.Size == 13 }
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{conflictNewer, []string{
+			"copy conflict.txt from path2",
+			"delete del1.txt from path2",
+			"copy del1mod2.txt from path2",
+			"delete del2.txt from path1",
+			"copy mod1.txt from path1",
+			"copy mod2.txt from path2",
+			"copy new1.txt from path1",
+			"copy new2.txt from path2",
+		}},
+		{conflictKeepBoth, []string{
+			"keep-both conflict.txt from path2",
+			"delete del1.txt from path2",
+			"copy del1mod2.txt from path2",
+			"delete del2.txt from path1",
+			"copy mod1.txt from path1",
+			"copy mod2.txt from path2",
+			"copy new1.txt from path1",
+			"copy new2.txt from path2",
+		}},
+		{conflictFail, []string{
+			"conflict conflict.txt from path1",
+			"delete del1.txt from path2",
+			"copy del1mod2.txt from path2",
+			"delete del2.txt from path1",
+			"copy mod1.txt from path1",
+			"copy mod2.txt from path2",
+			"copy new1.txt from path1",
+			"copy new2.txt from path2",
+		}},
+	}
+	for _, tt := range tests {
+		got := describe(planBisync(prev, cur, tt.policy, same))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--conflict %s:\n got %q\nwant %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+// TestPlanBisync_NoState verifies a first run (or --resync) copies every
+// one-sided key and never deletes anything.
+func TestPlanBisync_NoState(t *testing.T) {
+	cur := [2]map[string]*storage.Object{
+		{"a.txt": bisyncObj(1, 0), "both.txt": bisyncObj(2, 0)},
+		{"b.txt": bisyncObj(1, 0), "both.txt": bisyncObj(2, 3)},
+	}
+	got := describe(planBisync(nil, cur, conflictNewer, func(a, b *storage.Object) bool { return false }))
+	want := []string{"copy a.txt from path1", "copy b.txt from path2", "copy both.txt from path2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestNextBisyncState verifies failed keys and keys changed during the run
+// keep their previous entry, and one-sided keys are not recorded.
+func TestNextBisyncState(t *testing.T) {
+	old := bisyncObj(10, 0)
+	prev := &bisyncState{Entries: map[string]*bisyncEntry{
+		"failed.txt": bisyncEntryOf(old, old),
+		"racy.txt":   bisyncEntryOf(old, old),
+	}}
+	before := [2]map[string]*storage.Object{
+		{"ok.txt": bisyncObj(1, 1), "failed.txt": bisyncObj(11, 1), "racy.txt": old},
+		{"failed.txt": old, "racy.txt": old},
+	}
+	after := [2]map[string]*storage.Object{
+		{"ok.txt": bisyncObj(1, 1), "failed.txt": bisyncObj(11, 1), "racy.txt": bisyncObj(12, 2), "lonely.txt": bisyncObj(1, 2)},
+		{"ok.txt": bisyncObj(1, 3), "failed.txt": old, "racy.txt": old},
+	}
+	actions := []bisyncAction{
+		{key: "ok.txt", kind: bisyncCopy, side: 0},
+		{key: "failed.txt", kind: bisyncCopy, side: 0},
+	}
+	next := nextBisyncState(prev, [2]string{"/a", "s3://b/"}, before, after, actions, map[string]bool{"failed.txt": true})
+
+	if got := next.Entries["ok.txt"]; got == nil || got.Path2.Size != 1 || !got.Path2.ModTime.Equal(bisyncT0.Add(3*time.Minute)) {
+		t.Errorf("ok.txt should be recorded from the fresh listing, got %+v", got)
+	}
+	if next.Entries["failed.txt"] != prev.Entries["failed.txt"] {
+		t.Errorf("failed.txt should keep its previous entry")
+	}
+	if next.Entries["racy.txt"] != prev.Entries["racy.txt"] {
+		t.Errorf("racy.txt changed during the run and should keep its previous entry")
+	}
+	if _, ok := next.Entries["lonely.txt"]; ok {
+		t.Errorf("a key present on one side only must not be recorded")
+	}
+}
+
+func TestBisyncStateRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "pair.json")
+	st := &bisyncState{
+		Version: bisyncStateVersion,
+		Path1:   "/a",
+		Path2:   "s3://b/",
+		Entries: map[string]*bisyncEntry{"x/y.txt": bisyncEntryOf(bisyncObj(1, 1), bisyncObj(1, 2))},
+	}
+	if err := st.save(file); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := loadBisyncState(file, "/a", "s3://b/")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !got.Entries["x/y.txt"].Path2.equal(bisyncObj(1, 2)) {
+		t.Errorf("round-tripped entry = %+v", got.Entries["x/y.txt"].Path2)
+	}
+	if _, err := loadBisyncState(file, "/a", "s3://other/"); err == nil {
+		t.Errorf("loading the state of another pair should fail")
+	}
+	if got, err := loadBisyncState(filepath.Join(t.TempDir(), "missing.json"), "/a", "s3://b/"); got != nil || err != nil {
+		t.Errorf("missing state = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestConflictName(t *testing.T) {
+	tests := map[string]string{
+		"report.txt":         "report.conflict-20261017-093000.txt",
+		"dir/archive.tar.gz": "dir/archive.tar.conflict-20261017-093000.gz",
+		"dir/.bashrc":        "dir/.bashrc.conflict-20261017-093000",
+		"Makefile":           "Makefile.conflict-20261017-093000",
+	}
+	for key, want := range tests {
+		if got := conflictName(key, bisyncT0); got != want {
+			t.Errorf("conflictName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
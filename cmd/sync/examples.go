@@ -11,4 +11,106 @@ Example 2: Sync S3 to local, deleting extra destination files
 Example 3: Sync S3 to S3 with 8 concurrent workers
 
          s6cmd sync --jobs 8 s3://bucket/prefix/ s3://other-bucket/prefix/
+
+Example 4: Mirror a reorganized local tree, copying moved files server-side
+
+         s6cmd sync --delete --yes --detect-renames ./local-dir/ s3://bucket/prefix/
+
+Example 5: Only refresh keys already present, and never overwrite newer ones
+
+         s6cmd sync --existing --update ./local-dir/ s3://bucket/prefix/
+
+Example 6: Remove excluded logs from the destination once every transfer succeeded
+
+         s6cmd sync --delete-excluded --delete-after --yes --exclude "*.log" ./local-dir/ s3://bucket/prefix/
+
+Example 7: Abort if more than 10% of the destination would be deleted, keeping removed and overwritten objects
+
+         s6cmd sync --delete --yes --max-delete 10% --backup-dir s3://bucket/trash/2026-10-17/ ./local-dir/ s3://bucket/prefix/
+
+Example 8: Keep a prefix mirrored while files change, uploading each batch after 2s of quiet (Ctrl-C to stop)
+
+         s6cmd sync --watch --debounce 2s --delete --yes ./local-dir/ s3://bucket/prefix/
+
+Example 9: Write the plan (copies with reasons, deletes, skips) to a file for review and a later apply
+
+         s6cmd sync --delete --plan-out plan.json s3://bucket/prefix/ s3://other-bucket/prefix/
+
+Example 10: Sync into a huge prefix using the listing saved by the previous run, listing it again once a week
+
+         s6cmd sync --manifest s3://bucket/state/prefix.manifest.gz --rescan-interval 168h ./local-dir/ s3://bucket/prefix/
+
+Example 11: Rebuild the manifest after the destination was changed by other tools
+
+         s6cmd sync --manifest ./prefix.manifest.gz --full-rescan ./local-dir/ s3://bucket/prefix/
+
+Example 12: Sync a directory to two buckets, reading every changed file once
+
+         s6cmd sync --delete --yes ./local-dir/ s3://us-bucket/prefix/ --to s3://eu-bucket/prefix/
+
+Example 13: Sync, then retry only the copies and deletes that failed
+
+         s6cmd sync --delete --yes --failed-out failed.txt ./local-dir/ s3://bucket/prefix/
+         s6cmd run failed.txt
+
+Example 14: Sync and record every copy and delete with its outcome
+
+         s6cmd sync --delete --yes --report report.jsonl ./local-dir/ s3://bucket/prefix/
+         jq -c 'select(.status != "ok")' report.jsonl
+
+Example 15: Show the throughput, ETA and the files being uploaded
+
+         s6cmd sync --show-transfers ./local-dir/ s3://bucket/prefix/
+
+Example 16: Write the progress as JSON events to a file another tool follows
+
+         s6cmd sync --progress-json progress.jsonl ./local-dir/ s3://bucket/prefix/
+
+Example 17: Only sync the files modified in the last day and smaller than 1 GiB
+
+         s6cmd sync --filter 'mtime > now-1d && size < 1GiB' ./local-dir/ s3://bucket/prefix/
+
+Example 18: Sync with ordered rsync-style rules, e.g. "- /build/" and "- *.tmp"
+
+         s6cmd sync --filter-from rules.txt ./local-dir/ s3://bucket/prefix/
+
+Example 19: Mirror a git checkout without what its .gitignore lists, keeping those keys in the destination
+
+         s6cmd sync --delete --respect-gitignore ./repo/ s3://bucket/repo/
+
+Example 20: Sync a huge bucket to another one, taking the source listing from yesterday's S3 Inventory report
+
+         s6cmd sync --inventory s3://inventory-bucket/bucket/daily/2026-10-18T01-00Z/manifest.json s3://bucket/ s3://backup-bucket/
+
+Example 21: Sync a prefix using the source listing saved by ls --recursive --save-listing listing.jsonl
+
+         s6cmd sync --from-listing listing.jsonl s3://bucket/prefix/ s3://backup-bucket/prefix/
+`
+
+const bisync_examples = `Example 1: Keep a local directory and an S3 prefix in step in both directions
+
+         s6cmd bisync ./local-dir/ s3://bucket/prefix/
+
+Example 2: Keep both versions of a file changed on both sides
+
+         s6cmd bisync --conflict keep-both ./local-dir/ s3://bucket/prefix/
+
+Example 3: Preview a run, refusing to propagate more than 10 deletions
+
+         s6cmd bisync --dry-run --max-delete 10 ./local-dir/ s3://bucket/prefix/
+
+Example 4: Rebuild the state after it was lost, without deleting anything
+
+         s6cmd bisync --resync ./local-dir/ s3://bucket/prefix/
+`
+
+const apply_examples = `Example 1: Review a sync before running it, then run exactly the reviewed plan
+
+         s6cmd sync --delete --plan-out plan.json ./local-dir/ s3://bucket/prefix/
+         jq '.entries[] | select(.action != "skip")' plan.json
+         s6cmd apply --yes plan.json
+
+Example 2: Check which entries of a plan still apply, without changing anything
+
+         s6cmd apply --dry-run plan.json
 `
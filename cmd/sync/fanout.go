@@ -0,0 +1,211 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/internal/report"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// syncFanOut syncs src to several s3:// destinations in one run. Every
+// destination is listed and planned on its own, with its own --max-delete
+// check and delete set, but a source object that one or more destinations
+// need is transferred by a single task: a local file is read once and
+// streamed to all of them, and a remote object is copied to the first and
+// server-side from there to the rest.
+func (o *Options) syncFanOut(ctx context.Context, store *storage.Storage, src *storage.StorageURL, dsts []*storage.StorageURL) error {
+	isBatch := src.IsRemote() && (src.IsBucket() || src.IsPrefix())
+	followSymlinks := false
+	if !src.IsRemote() {
+		isDir, err := cliutil.IsLocalDir(src.Absolute())
+		if err != nil {
+			return err
+		}
+		isBatch = isDir
+		followSymlinks = !o.Shared.NoFollowSymlinks
+	}
+	if isBatch {
+		for _, dst := range dsts {
+			if !(dst.IsBucket() || dst.IsPrefix()) {
+				return fmt.Errorf("destination %q must be a prefix when source is a prefix or directory", dst)
+			}
+		}
+	}
+
+	filters, err := newSyncFilters(o.Shared, src)
+	if err != nil {
+		return err
+	}
+
+	srcObjects, err := o.listObjects(ctx, store, src, followSymlinks, false)
+	if err != nil {
+		return err
+	}
+
+	ec := cliutil.NewErrorCollector("sync")
+	o.Failures.Track(ec)
+	// targets holds, per source object, the plan items of every
+	// destination that needs it.
+	targets := make(map[*storage.Object][]syncPlanItem, len(srcObjects))
+	var deletes []*storage.Object
+	for _, dst := range dsts {
+		dstObjects, err := o.listDestObjects(ctx, store, dst, false)
+		if err != nil {
+			return err
+		}
+		items, extras, planErrs := buildSyncPlan(srcObjects, dstObjects, dst, isBatch, false)
+		for _, err := range planErrs {
+			ec.Collect(err)
+		}
+		decisions, dstDeletes := o.decide(items, extras, filters)
+		// Every destination is checked before anything is written, so an
+		// exceeded limit on one leaves all of them untouched.
+		if o.Delete {
+			if err := o.Guard.Check(len(dstDeletes), len(dstObjects)); err != nil {
+				return fmt.Errorf("destination %s: %w", dst, err)
+			}
+		}
+		for _, d := range decisions {
+			switch {
+			case d.copy:
+				targets[d.item.srcObj] = append(targets[d.item.srcObj], d.item)
+			case d.err != nil:
+				ec.Collect(d.err)
+			}
+		}
+		deletes = append(deletes, dstDeletes...)
+	}
+
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
+	}
+	pb.Start()
+	defer pb.Finish()
+	o.progress = pb
+	defer func() { o.progress = nil }()
+
+	transfers := make([]parallel.Task, 0, len(targets))
+	for _, srcObj := range srcObjects {
+		if items := targets[srcObj]; len(items) > 0 {
+			pb.AddTotalBytes(srcObj.Size)
+			pb.IncrementTotalObjects()
+			transfers = append(transfers, o.fanOutTask(ctx, store, ec, srcObj, items))
+		}
+	}
+	deleteTasks := make([]parallel.Task, 0, len(deletes))
+	for _, obj := range deletes {
+		deleteTasks = append(deleteTasks, o.deleteTask(ctx, store, obj))
+	}
+
+	waiter := parallel.NewWaiter()
+	drainDone := ec.Drain(waiter)
+	o.execute(ec, waiter, drainDone, transfers, deleteTasks)
+	if parallel.Stopped() {
+		o.Failures.RecordCommand(os.Args[1:])
+	}
+	ec.Collect(o.Failures.WriteFailed())
+	return ec.Aggregate()
+}
+
+// fanOutTask transfers srcURL to the destinations of items. With
+// --backup-dir the object about to be overwritten at each destination is
+// backed up first, and a destination whose backup fails is left alone.
+// Every destination's result is collected on its own, so one failing
+// destination does not fail the task for the others, and only the
+// destinations that failed transiently are retried. Each destination has
+// its own report entry, and the progress counts the object once.
+func (o *Options) fanOutTask(ctx context.Context, store *storage.Storage, ec *cliutil.ErrorCollector, srcObj *storage.Object, items []syncPlanItem) parallel.Task {
+	srcURL := srcObj.StorageURL
+	src := displayURL(srcURL)
+	rops := make([]*report.Operation, len(items))
+	for i, item := range items {
+		rops[i] = o.Report.Start("cp", src, displayURL(item.dstURL), srcObj.Size)
+	}
+	return func() error {
+		pt := o.transferProgress(srcObj)
+		defer pt.Finish()
+		dsts := make([]*storage.StorageURL, 0, len(items))
+		dstOps := make([]*report.Operation, 0, len(items))
+		for i, item := range items {
+			if item.dstObj != nil && o.Guard.BackupEnabled() {
+				if err := o.Guard.Backup(ctx, store, "sync", item.dstObj); err != nil {
+					rops[i].Finish(err)
+					ec.Collect(err)
+					continue
+				}
+			}
+			dsts = append(dsts, item.dstURL)
+			dstOps = append(dstOps, rops[i])
+		}
+
+		objs := make([]*storage.Object, len(dsts))
+		errs := o.Failures.RetryEach(ctx, len(dsts), func(idx []int) []error {
+			targets := make([]*storage.StorageURL, len(idx))
+			for n, i := range idx {
+				targets[n] = dsts[i]
+				dstOps[i].Attempted()
+			}
+			var (
+				written []*storage.Object
+				errs    []error
+			)
+			if !srcURL.IsRemote() {
+				written, errs = o.fanOutUpload(ctx, store, src, srcObj.Size, targets, pt)
+			} else {
+				md := o.sharedMetadata()
+				md.Directive = cliutil.MetadataDirectiveReplace
+				written, errs = cliutil.FanOutCopy(ctx, store, srcURL, targets, md)
+			}
+			for n, i := range idx {
+				objs[i] = written[n]
+			}
+			return errs
+		})
+		if srcURL.IsRemote() {
+			pt.AddCompletedBytes(srcObj.Size)
+		}
+		pt.IncrementCompletedObjects()
+		for i, dst := range dsts {
+			dstOps[i].Wrote(objs[i])
+			dstOps[i].Finish(errs[i])
+			if errs[i] != nil {
+				ec.Collect(&errorpkg.Error{Op: "cp", Src: src, Dst: dst.String(), Err: errs[i]})
+				continue
+			}
+			log.Info(log.InfoMessage{Operation: "cp", Source: src, Destination: dst.String()})
+		}
+		return nil
+	}
+}
+
+// fanOutUpload reads the local file of size bytes once and uploads it to
+// every destination, with the shared metadata and the Content-Type
+// guessed from the file, as cp does. The bytes read are counted toward
+// pb.
+func (o *Options) fanOutUpload(ctx context.Context, store *storage.Storage, file string, size int64, dsts []*storage.StorageURL, pb progressbar.ProgressBar) ([]*storage.Object, []error) {
+	fail := func(err error) ([]*storage.Object, []error) {
+		errs := make([]error, len(dsts))
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([]*storage.Object, len(dsts)), errs
+	}
+	md, err := o.uploadMetadata(file)
+	if err != nil {
+		return fail(err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fail(err)
+	}
+	defer f.Close()
+	return cliutil.FanOutPut(ctx, store, cliutil.NewCountingReaderWriter(f, pb), size, dsts, md, o.Shared.Concurrency, o.Shared.PartSizeBytes())
+}
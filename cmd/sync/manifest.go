@@ -0,0 +1,325 @@
+package sync
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// manifestVersion is bumped when the manifest layout changes. A manifest
+// of another version is rejected rather than misread.
+const manifestVersion = 1
+
+// defaultRescanInterval is how old the last full listing of the
+// destination may get before sync --manifest lists it again.
+const defaultRescanInterval = 24 * time.Hour
+
+// manifestHeader is the first line of a manifest. ListedAt is the time of
+// the last full listing of Destination; a zero ListedAt forces the next
+// run to list again.
+type manifestHeader struct {
+	Version     int       `json:"version"`
+	Destination string    `json:"destination"`
+	ListedAt    time.Time `json:"listed_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// manifestEntry is one destination object, keyed by its path under the
+// destination prefix.
+type manifestEntry struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag,omitempty"`
+}
+
+// destManifest implements sync --manifest: a cached listing of an S3
+// destination prefix that stands in for listing it. It is read and written
+// with its own store so a --dry-run still reads it.
+type destManifest struct {
+	url     *storage.StorageURL
+	store   *storage.Storage
+	header  manifestHeader
+	entries map[string]manifestEntry
+	// usable is set when entries may replace the destination listing.
+	usable bool
+}
+
+// openManifest loads the manifest for dst. A missing manifest, one whose
+// last full listing is older than --rescan-interval, and --full-rescan all
+// leave it unusable, so listDestObjects lists the destination instead.
+func (o *Options) openManifest(ctx context.Context, dst *storage.StorageURL) (*destManifest, error) {
+	if !dst.IsRemote() || !(dst.IsBucket() || dst.IsPrefix()) {
+		return nil, fmt.Errorf("--manifest requires an s3:// prefix destination")
+	}
+	u, err := storage.NewStorageURL(o.Manifest, storage.WithRaw(true))
+	if err != nil {
+		return nil, err
+	}
+	if u.IsRemote() {
+		if u.IsBucket() || u.IsPrefix() {
+			return nil, fmt.Errorf("--manifest %q must be an object key or a local file", o.Manifest)
+		}
+		// A manifest inside the destination would be listed as one of
+		// its objects and deleted by --delete.
+		if u.Bucket == dst.Bucket && strings.HasPrefix(u.Path, dst.Prefix) {
+			return nil, fmt.Errorf("--manifest %q must not be inside the destination %q", o.Manifest, o.Destination)
+		}
+	}
+	flags := o.CommonFlags
+	flags.DryRun = false
+	store, err := cliutil.NewStorage(ctx, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &destManifest{
+		url:     u,
+		store:   store,
+		header:  manifestHeader{Version: manifestVersion, Destination: dst.String()},
+		entries: make(map[string]manifestEntry),
+	}
+	// --full-rescan rebuilds the manifest without reading it, which also
+	// replaces a corrupt one.
+	if o.FullRescan {
+		return m, nil
+	}
+	found, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !found:
+		log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("manifest %s not found, listing %s", u, dst)})
+	case m.header.Destination != dst.String():
+		return nil, fmt.Errorf("manifest %s belongs to %s, not %s", u, m.header.Destination, dst)
+	case m.header.ListedAt.IsZero():
+		log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("manifest %s is incomplete, listing %s", u, dst)})
+	case o.RescanInterval > 0 && time.Since(m.header.ListedAt) > o.RescanInterval:
+		log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("manifest %s was listed at %s, listing %s", u, m.header.ListedAt.Format(time.RFC3339), dst)})
+	default:
+		m.usable = true
+	}
+	return m, nil
+}
+
+// destObjects returns the destination objects under dst: the manifest
+// entries when it is usable, otherwise a full listing, which then
+// replaces the entries.
+func (m *destManifest) destObjects(ctx context.Context, o *Options, store *storage.Storage, dst *storage.StorageURL) ([]*storage.Object, error) {
+	if !m.usable {
+		listedAt := time.Now().UTC()
+		objs, err := o.listObjects(ctx, store, dst, false, true)
+		if err != nil {
+			return nil, err
+		}
+		m.header.ListedAt = listedAt
+		m.entries = make(map[string]manifestEntry, len(objs))
+		for _, obj := range objs {
+			m.set(obj.StorageURL.Relative(), obj)
+		}
+		return objs, nil
+	}
+
+	objs := make([]*storage.Object, 0, len(m.entries))
+	for key, e := range m.entries {
+		u := dst.Join(key)
+		u.SetRelativePath(key)
+		mod := e.ModTime
+		objs = append(objs, &storage.Object{StorageURL: u, Size: e.Size, Etag: e.ETag, ModTime: &mod})
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].StorageURL.Relative() < objs[j].StorageURL.Relative()
+	})
+	return objs, nil
+}
+
+func (m *destManifest) set(key string, obj *storage.Object) {
+	e := manifestEntry{Key: key, Size: obj.Size, ETag: obj.Etag}
+	if obj.ModTime != nil {
+		e.ModTime = obj.ModTime.UTC()
+	}
+	m.entries[key] = e
+}
+
+// update re-stats the destination keys the run wrote or deleted and saves
+// the manifest. A key that cannot be stat'ed leaves the manifest
+// incomplete, and the next run lists the destination again.
+func (m *destManifest) update(ctx context.Context, dst *storage.StorageURL, touched []*storage.StorageURL) error {
+	// The run is over: an interrupt must not leave the manifest behind
+	// the destination.
+	ctx = context.WithoutCancel(ctx)
+	type result struct {
+		obj *storage.Object
+		err error
+	}
+	// Every task writes only its own slot; the slice is read after
+	// waiter.Wait().
+	results := make([]result, len(touched))
+	waiter := parallel.NewWaiter()
+	for i, u := range touched {
+		parallel.Run(func() error {
+			obj, err := m.store.Stat(ctx, u)
+			if errorpkg.IsWarning(err) {
+				obj, err = nil, nil
+			}
+			results[i] = result{obj, err}
+			return nil
+		}, waiter)
+	}
+	waiter.Wait()
+
+	for i, u := range touched {
+		key := strings.TrimPrefix(strings.TrimPrefix(u.Path, dst.Prefix), "/")
+		switch r := results[i]; {
+		case r.err != nil:
+			log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("manifest: stat %s: %v", u, r.err)})
+			m.header.ListedAt = time.Time{}
+			delete(m.entries, key)
+		case r.obj == nil:
+			delete(m.entries, key)
+		default:
+			m.set(key, r.obj)
+		}
+	}
+	m.header.UpdatedAt = time.Now().UTC()
+	if err := m.save(ctx); err != nil {
+		return fmt.Errorf("save manifest %s: %w", m.url, err)
+	}
+	return nil
+}
+
+// load reads the manifest. It reports false when there is none.
+func (m *destManifest) load(ctx context.Context) (bool, error) {
+	file := m.url.Absolute()
+	if m.url.IsRemote() {
+		if _, err := m.store.Stat(ctx, m.url); err != nil {
+			if errorpkg.IsWarning(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		tmp, err := os.CreateTemp("", "s6cmd-manifest-")
+		if err != nil {
+			return false, err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err := m.store.DownloadFile(ctx, m.url.Bucket, m.url.Path, tmp.Name(), 0, 0); err != nil {
+			return false, err
+		}
+		file = tmp.Name()
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := m.decode(f); err != nil {
+		return false, fmt.Errorf("manifest %s is corrupt (rerun with --full-rescan to rebuild it): %w", m.url, err)
+	}
+	return true, nil
+}
+
+// save writes the manifest to a temporary file, then renames it into
+// place or uploads it.
+func (m *destManifest) save(ctx context.Context) error {
+	dir := os.TempDir()
+	if !m.url.IsRemote() {
+		dir = filepath.Dir(m.url.Absolute())
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(dir, "s6cmd-manifest-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := m.encode(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !m.url.IsRemote() {
+		return os.Rename(tmp.Name(), m.url.Absolute())
+	}
+	_, err = m.store.UploadFile(ctx, tmp.Name(), m.url.Bucket, m.url.Path, 0, 0)
+	return err
+}
+
+// encode writes the manifest as gzip-compressed JSON Lines: the header,
+// then one entry per object sorted by key.
+func (m *destManifest) encode(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(m.header); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := enc.Encode(m.entries[key]); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// decode reads a manifest written by encode.
+func (m *destManifest) decode(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	var header manifestHeader
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version != manifestVersion {
+		return fmt.Errorf("version %d, want %d", header.Version, manifestVersion)
+	}
+	entries := make(map[string]manifestEntry)
+	for {
+		var e manifestEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		entries[e.Key] = e
+	}
+	m.header, m.entries = header, entries
+	return nil
+}
@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	t.Parallel()
+	t0 := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	file := filepath.Join(t.TempDir(), "state", "manifest.gz")
+	m := &destManifest{
+		url:    mustURL(t, file, ""),
+		header: manifestHeader{Version: manifestVersion, Destination: "s3://bucket/prefix/", ListedAt: t0, UpdatedAt: t0},
+		entries: map[string]manifestEntry{
+			"a.txt":     {Key: "a.txt", Size: 1, ModTime: t0, ETag: "e1"},
+			"dir/b.txt": {Key: "dir/b.txt", Size: 2, ModTime: t0.Add(time.Minute)},
+		},
+	}
+	if err := m.save(context.Background()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got := &destManifest{url: m.url}
+	found, err := got.load(context.Background())
+	if err != nil || !found {
+		t.Fatalf("load = %v, %v", found, err)
+	}
+	if !reflect.DeepEqual(got.header, m.header) || !reflect.DeepEqual(got.entries, m.entries) {
+		t.Errorf("round trip = %+v %+v, want %+v %+v", got.header, got.entries, m.header, m.entries)
+	}
+
+	missing := &destManifest{url: mustURL(t, filepath.Join(t.TempDir(), "none.gz"), "")}
+	if found, err := missing.load(context.Background()); found || err != nil {
+		t.Errorf("load of a missing manifest = %v, %v; want false, nil", found, err)
+	}
+}
+
+// TestManifestDestObjects verifies that a usable manifest yields the
+// objects a listing of the destination would: keyed under the prefix with
+// the relative path the plan matches on, sorted.
+func TestManifestDestObjects(t *testing.T) {
+	t.Parallel()
+	t0 := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	m := &destManifest{
+		usable: true,
+		entries: map[string]manifestEntry{
+			"z.txt":     {Key: "z.txt", Size: 1, ModTime: t0, ETag: "e1"},
+			"dir/b.txt": {Key: "dir/b.txt", Size: 2, ModTime: t0},
+		},
+	}
+	dst := mustURL(t, "s3://bucket/prefix/", "")
+	objs, err := m.destObjects(context.Background(), newOptions(), nil, dst)
+	if err != nil {
+		t.Fatalf("destObjects: %v", err)
+	}
+	var got []string
+	for _, obj := range objs {
+		got = append(got, obj.StorageURL.String()+" "+obj.StorageURL.Relative())
+	}
+	want := []string{"s3://bucket/prefix/dir/b.txt dir/b.txt", "s3://bucket/prefix/z.txt z.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("objects = %q, want %q", got, want)
+	}
+	if objs[1].Etag != "e1" || objs[1].Size != 1 || !objs[1].ModTime.Equal(t0) {
+		t.Errorf("object = %+v, want the manifest entry's stat info", objs[1])
+	}
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/LinPr/s6cmd/log"
+)
+
+// inotifyMask is every event that can change what a rescan sees.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_DELETE | syscall.IN_DELETE_SELF |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_MOVE_SELF
+
+// inotifyNotifier watches every directory of the tree with one inotify
+// instance. inotify is not recursive, so Watch adds the directories each
+// rescan finds; anything it misses in between (a file created in a new
+// directory before its watch exists, a queue overflow) is still caught by
+// the periodic rescan.
+type inotifyNotifier struct {
+	// fd is kept next to file because file.Fd() would switch the
+	// descriptor back to blocking mode.
+	fd     int
+	file   *os.File
+	events chan struct{}
+
+	mu      sync.Mutex
+	watched map[string]bool
+}
+
+func newNotifier(root string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+	// A non-blocking descriptor is registered with the runtime poller,
+	// so Close unblocks the pending Read in loop.
+	n := &inotifyNotifier{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan struct{}, 1),
+		watched: make(map[string]bool),
+	}
+	go n.loop()
+	return n, nil
+}
+
+func (n *inotifyNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+// loop turns every read of the event queue into a single notification.
+// The events themselves are not decoded: the rescan works out what
+// changed.
+func (n *inotifyNotifier) loop() {
+	buf := make([]byte, 64*1024)
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotifyNotifier) Watch(dirs []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	current := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		current[dir] = true
+		if n.watched[dir] {
+			continue
+		}
+		if _, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask); err != nil {
+			log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("inotify watch %s: %v", dir, err)})
+			continue
+		}
+		n.watched[dir] = true
+	}
+	// The kernel drops the watch of a removed directory by itself;
+	// forgetting it lets a directory recreated under the same name be
+	// watched again.
+	for dir := range n.watched {
+		if !current[dir] {
+			delete(n.watched, dir)
+		}
+	}
+}
+
+func (n *inotifyNotifier) Close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package sync
+
+import "errors"
+
+// newNotifier is only implemented on Linux; elsewhere sync --watch relies
+// on periodic rescans alone.
+func newNotifier(root string) (notifier, error) {
+	return nil, errors.New("not supported on this platform")
+}
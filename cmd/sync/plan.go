@@ -0,0 +1,204 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// syncPlanVersion is bumped when the plan file layout changes. A plan of
+// another version is rejected rather than misread.
+const syncPlanVersion = 1
+
+// Plan entry actions.
+const (
+	planCopy   = "copy"
+	planDelete = "delete"
+	planSkip   = "skip"
+)
+
+// Delete orders recorded in a plan, from --delete-before and
+// --delete-after. The empty order runs the deletes alongside the copies.
+const (
+	planDeleteBefore = "before"
+	planDeleteAfter  = "after"
+)
+
+// syncPlan is a sync run written by sync --plan-out and executed by apply.
+// Every entry carries the listing of both sides as sync saw it, so apply
+// can refuse entries whose objects changed after planning.
+type syncPlan struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	// DestinationObjects is the size of the destination listing, the
+	// total a --max-delete percentage is taken of.
+	DestinationObjects int         `json:"destination_objects"`
+	DeleteOrder        string      `json:"delete_order,omitempty"`
+	Entries            []planEntry `json:"entries"`
+}
+
+// planEntry is one planned operation: a copy of Source onto Destination,
+// a delete of Destination, or a skipped Source. Reason says why.
+type planEntry struct {
+	Action      string      `json:"action"`
+	Reason      string      `json:"reason"`
+	Source      *planObject `json:"source,omitempty"`
+	Destination *planObject `json:"destination,omitempty"`
+}
+
+// planObject is an object as listed at planning time. A copy to a new key
+// has a destination with Exists unset.
+type planObject struct {
+	URL     string     `json:"url"`
+	Exists  bool       `json:"exists"`
+	Size    int64      `json:"size"`
+	ModTime *time.Time `json:"mod_time,omitempty"`
+	ETag    string     `json:"etag,omitempty"`
+}
+
+// newPlanObject records obj, the object listed at u, or a missing object
+// when obj is nil. Local paths are made absolute so the plan can be
+// applied from any directory.
+func newPlanObject(u *storage.StorageURL, obj *storage.Object) (*planObject, error) {
+	p := &planObject{URL: u.Absolute()}
+	if !u.IsRemote() {
+		abs, err := filepath.Abs(p.URL)
+		if err != nil {
+			return nil, err
+		}
+		p.URL = abs
+	}
+	if obj == nil {
+		return p, nil
+	}
+	p.Exists = true
+	p.Size = obj.Size
+	p.ETag = obj.Etag
+	if obj.ModTime != nil {
+		mod := obj.ModTime.UTC()
+		p.ModTime = &mod
+	}
+	return p, nil
+}
+
+// url parses the recorded URL. Keys are taken literally: they were listed,
+// not typed, so glob characters in them are not patterns.
+func (p *planObject) url() (*storage.StorageURL, error) {
+	return storage.NewStorageURL(p.URL, storage.WithRaw(true))
+}
+
+// matches reports whether obj, the object found at the URL now (nil when
+// there is none), is still the one recorded. ETags are compared when both
+// sides have one. Remote modification times are compared to the second:
+// HeadObject reports them with less precision than a listing.
+func (p *planObject) matches(obj *storage.Object) bool {
+	if obj == nil || !p.Exists {
+		return obj == nil && !p.Exists
+	}
+	if p.Size != obj.Size {
+		return false
+	}
+	if p.ETag != "" && obj.Etag != "" && p.ETag != obj.Etag {
+		return false
+	}
+	if p.ModTime == nil || obj.ModTime == nil {
+		return p.ModTime == nil && obj.ModTime == nil
+	}
+	if obj.StorageURL != nil && obj.StorageURL.IsRemote() {
+		return p.ModTime.Truncate(time.Second).Equal(obj.ModTime.Truncate(time.Second))
+	}
+	return p.ModTime.Equal(*obj.ModTime)
+}
+
+// newSyncPlan turns the decisions and the delete set of planAndRun into a
+// plan: one entry per source object in plan order, then the deletes.
+// destinationObjects is the size of the destination listing.
+func (o *Options) newSyncPlan(decisions []syncDecision, deletes []*storage.Object, destinationObjects int) (*syncPlan, error) {
+	plan := &syncPlan{
+		Version:            syncPlanVersion,
+		CreatedAt:          time.Now().UTC(),
+		Source:             o.Source,
+		Destination:        o.Destination,
+		DestinationObjects: destinationObjects,
+		Entries:            make([]planEntry, 0, len(decisions)+len(deletes)),
+	}
+	switch {
+	case o.DeleteBefore:
+		plan.DeleteOrder = planDeleteBefore
+	case o.DeleteAfter:
+		plan.DeleteOrder = planDeleteAfter
+	}
+
+	excluded := make(map[*storage.Object]bool)
+	for _, d := range decisions {
+		src, err := newPlanObject(d.item.srcObj.StorageURL, d.item.srcObj)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := newPlanObject(d.item.dstURL, d.item.dstObj)
+		if err != nil {
+			return nil, err
+		}
+		action := planSkip
+		if d.copy {
+			action = planCopy
+		} else if d.reason == "excluded" && d.item.dstObj != nil {
+			excluded[d.item.dstObj] = true
+		}
+		plan.Entries = append(plan.Entries, planEntry{Action: action, Reason: d.reason, Source: src, Destination: dst})
+	}
+	for _, obj := range deletes {
+		dst, err := newPlanObject(obj.StorageURL, obj)
+		if err != nil {
+			return nil, err
+		}
+		reason := "not in source"
+		if excluded[obj] {
+			reason = "excluded (--delete-excluded)"
+		}
+		plan.Entries = append(plan.Entries, planEntry{Action: planDelete, Reason: reason, Destination: dst})
+	}
+	return plan, nil
+}
+
+// save writes the plan through a temporary file and a rename, so a failed
+// run never leaves a truncated plan behind.
+func (p *syncPlan) save(file string) error {
+	return writeJSONFile(file, p)
+}
+
+// loadSyncPlan reads and checks a plan file.
+func loadSyncPlan(file string) (*syncPlan, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var plan syncPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("plan %s is corrupt: %w", file, err)
+	}
+	if plan.Version != syncPlanVersion {
+		return nil, fmt.Errorf("plan %s has version %d, want %d", file, plan.Version, syncPlanVersion)
+	}
+	switch plan.DeleteOrder {
+	case "", planDeleteBefore, planDeleteAfter:
+	default:
+		return nil, fmt.Errorf("plan %s has an unknown delete order %q", file, plan.DeleteOrder)
+	}
+	for i, e := range plan.Entries {
+		switch {
+		case e.Action == planCopy && e.Source != nil && e.Destination != nil:
+		case e.Action == planDelete && e.Destination != nil:
+		case e.Action == planSkip:
+		default:
+			return nil, fmt.Errorf("plan %s: entry %d is not a valid copy, delete or skip", file, i+1)
+		}
+	}
+	return &plan, nil
+}
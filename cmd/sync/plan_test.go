@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+func planObj(t *testing.T, s string, size int64, etag string, mod time.Time) *storage.Object {
+	t.Helper()
+	return &storage.Object{StorageURL: mustURL(t, s, ""), Size: size, Etag: etag, ModTime: &mod}
+}
+
+// TestNewSyncPlan verifies that every source object gets a copy or skip
+// entry with its reason and that deletes follow, in plan order.
+func TestNewSyncPlan(t *testing.T) {
+	t.Parallel()
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	dst := mustURL(t, "s3://bucket/prefix/", "")
+	src := []*storage.Object{
+		planObj(t, "s3://src/a.txt", 1, "e1", t0),
+		planObj(t, "s3://src/b.txt", 2, "e2", t0),
+		planObj(t, "s3://src/c.txt", 1, "e3", t0),
+		planObj(t, "s3://src/d.log", 1, "e4", t0),
+	}
+	for _, o := range src {
+		o.StorageURL.SetRelativePath(path.Base(o.StorageURL.Path))
+	}
+	dstObjects := []*storage.Object{
+		planObj(t, "s3://bucket/prefix/b.txt", 1, "x2", t0),
+		planObj(t, "s3://bucket/prefix/c.txt", 1, "e3", t0),
+		planObj(t, "s3://bucket/prefix/d.log", 1, "x4", t0),
+		planObj(t, "s3://bucket/prefix/old.txt", 1, "x5", t0),
+	}
+
+	o := newOptions()
+	o.Source, o.Destination = "s3://src/", "s3://bucket/prefix/"
+	o.Delete, o.DeleteExcluded, o.DeleteAfter = true, true, true
+	items, extras, errs := buildSyncPlan(src, dstObjects, dst, true, false)
+	if len(errs) != 0 {
+		t.Fatalf("buildSyncPlan errs = %v", errs)
+	}
+	o.Shared.Exclude = []string{"*.log"}
+	filters, err := newSyncFilters(o.Shared, nil)
+	if err != nil {
+		t.Fatalf("newSyncFilters: %v", err)
+	}
+	decisions, deletes := o.decide(items, extras, filters)
+	plan, err := o.newSyncPlan(decisions, deletes, len(dstObjects))
+	if err != nil {
+		t.Fatalf("newSyncPlan: %v", err)
+	}
+
+	var got []string
+	for _, e := range plan.Entries {
+		got = append(got, e.Action+" "+e.Destination.URL+" ("+e.Reason+")")
+	}
+	want := []string{
+		"copy s3://bucket/prefix/a.txt (not in destination)",
+		"copy s3://bucket/prefix/b.txt (size differs)",
+		"skip s3://bucket/prefix/c.txt (object is newer or same age and object size matches)",
+		"skip s3://bucket/prefix/d.log (excluded)",
+		"delete s3://bucket/prefix/old.txt (not in source)",
+		"delete s3://bucket/prefix/d.log (excluded (--delete-excluded))",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if plan.DeleteOrder != planDeleteAfter || plan.DestinationObjects != 4 {
+		t.Errorf("delete order %q, destination objects %d", plan.DeleteOrder, plan.DestinationObjects)
+	}
+	if a := plan.Entries[0]; a.Destination.Exists || !a.Source.Exists || a.Source.ETag != "e1" {
+		t.Errorf("copy to a new key = %+v -> %+v", a.Source, a.Destination)
+	}
+}
+
+func TestPlanObjectMatches(t *testing.T) {
+	t.Parallel()
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 123e6, time.UTC)
+	remote := planObj(t, "s3://bucket/a.txt", 3, "e1", t0)
+	planned, err := newPlanObject(remote.StorageURL, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := newPlanObject(mustURL(t, "s3://bucket/b.txt", ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		planned *planObject
+		now     *storage.Object
+		want    bool
+	}{
+		{"unchanged", planned, remote, true},
+		{"head has second precision", planned, planObj(t, "s3://bucket/a.txt", 3, "e1", t0.Truncate(time.Second)), true},
+		{"size", planned, planObj(t, "s3://bucket/a.txt", 4, "e1", t0), false},
+		{"etag", planned, planObj(t, "s3://bucket/a.txt", 3, "e2", t0), false},
+		{"mtime", planned, planObj(t, "s3://bucket/a.txt", 3, "e1", t0.Add(time.Second)), false},
+		{"deleted", planned, nil, false},
+		{"still missing", missing, nil, true},
+		{"created", missing, planObj(t, "s3://bucket/b.txt", 3, "e1", t0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.planned.matches(tt.now); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Local files compare modification times exactly.
+	local := planObj(t, "a.txt", 3, "", t0)
+	plannedLocal, err := newPlanObject(local.StorageURL, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(plannedLocal.URL) {
+		t.Errorf("local URL %q is not absolute", plannedLocal.URL)
+	}
+	if plannedLocal.matches(planObj(t, "a.txt", 3, "", t0.Truncate(time.Second))) {
+		t.Errorf("local mtime change within the second was not detected")
+	}
+}
+
+func TestSyncPlanRoundTrip(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "plan.json")
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	plan := &syncPlan{
+		Version:     syncPlanVersion,
+		CreatedAt:   t0,
+		Source:      "./dir/",
+		Destination: "s3://bucket/prefix/",
+		Entries: []planEntry{
+			{Action: planCopy, Reason: "not in destination", Source: &planObject{URL: "/dir/a.txt", Exists: true, Size: 1, ModTime: &t0}, Destination: &planObject{URL: "s3://bucket/prefix/a.txt"}},
+			{Action: planDelete, Reason: "not in source", Destination: &planObject{URL: "s3://bucket/prefix/b.txt", Exists: true, Size: 2, ETag: "e"}},
+		},
+	}
+	if err := plan.save(file); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := loadSyncPlan(file)
+	if err != nil {
+		t.Fatalf("loadSyncPlan: %v", err)
+	}
+	if !reflect.DeepEqual(got, plan) {
+		t.Errorf("round trip = %+v, want %+v", got, plan)
+	}
+
+	for name, content := range map[string]string{
+		"version": `{"version": 99, "entries": []}`,
+		"entry":   `{"version": 1, "entries": [{"action": "copy", "destination": {"url": "s3://b/k"}}]}`,
+		"order":   `{"version": 1, "delete_order": "sometime", "entries": []}`,
+	} {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadSyncPlan(file); err == nil {
+			t.Errorf("%s: loadSyncPlan accepted %s", name, content)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/etag"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// maxRenameCopySize is the largest object a single CopyObject call can
+// copy. Larger renamed files are uploaded again instead.
+const maxRenameCopySize = 5 * 1024 * 1024 * 1024
+
+// copyRenames implements --detect-renames for local -> S3 syncs. A local
+// file that was moved shows up in the plan twice: as a pending upload to
+// its new key and as an extra object under its old key. For every pending
+// upload whose size matches an extra object, the local file is hashed and
+// compared with the extra's ETag (etag.Match, which also reproduces
+// multipart ETags); on a match the new key is written by a server-side
+// Copy from the old key instead of an upload, with the metadata the upload
+// would set. A copy that still fails after --task-retries is left to the
+// upload.
+//
+// The copies run on their own Waiter and copyRenames waits for them, so
+// every copy has finished reading its old key before planAndRun submits
+// the --delete phase. It returns the items that still need a transfer.
+func (o *Options) copyRenames(ctx context.Context, store *storage.Storage, ec *cliutil.ErrorCollector, items []syncPlanItem, extras []*storage.Object) []syncPlanItem {
+	bySize := make(map[int64][]*storage.Object)
+	for _, extra := range extras {
+		if extra.Size == 0 || extra.Size > maxRenameCopySize || extra.Etag == "" {
+			continue
+		}
+		bySize[extra.Size] = append(bySize[extra.Size], extra)
+	}
+	if len(bySize) == 0 {
+		return items
+	}
+
+	// Every task writes only its own slot; the slice is read after
+	// waiter.Wait().
+	renamed := make([]bool, len(items))
+	waiter := parallel.NewWaiter()
+	drainDone := ec.Drain(waiter)
+	for i, item := range items {
+		candidates := bySize[item.srcObj.Size]
+		if len(candidates) == 0 || item.srcObj.StorageURL.IsRemote() || !item.dstURL.IsRemote() {
+			continue
+		}
+		if o.ExitOnError && ec.HasError() {
+			break
+		}
+		parallel.Run(func() error {
+			from, err := o.findRenameSource(ctx, item.srcObj, candidates)
+			if err != nil || from == nil {
+				// A file that cannot be hashed is left to the upload,
+				// which reports the read error itself.
+				return nil
+			}
+			rop := o.Report.Start("cp", from.StorageURL.String(), item.dstURL.String(), from.Size)
+			err = o.Failures.Wrap(ctx, rop.Attempt(func() error {
+				if item.dstObj != nil {
+					if err := o.Guard.Backup(ctx, store, "sync", item.dstObj); err != nil {
+						return err
+					}
+				}
+				md, err := o.uploadMetadata(item.srcObj.StorageURL.Absolute())
+				if err != nil {
+					return err
+				}
+				md.Directive = cliutil.MetadataDirectiveReplace
+				obj, err := store.Copy(ctx, from.StorageURL, item.dstURL, md)
+				if err != nil {
+					return &errorpkg.Error{Op: "cp", Src: from.StorageURL.String(), Dst: item.dstURL.String(), Err: err}
+				}
+				rop.Wrote(obj)
+				return nil
+			}))()
+			if err != nil {
+				// The upload of the item takes over and reports its own
+				// outcome.
+				rop.Discard()
+				log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("rename copy failed, uploading %s instead: %v", item.dstURL, err)})
+				return nil
+			}
+			rop.Finish(nil)
+			renamed[i] = true
+			log.Info(log.InfoMessage{Operation: "cp", Source: from.StorageURL.String(), Destination: item.dstURL.String()})
+			return nil
+		}, waiter)
+	}
+	waiter.Wait()
+	drainDone()
+
+	pending := items[:0:0]
+	for i, item := range items {
+		if !renamed[i] {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// findRenameSource returns the first candidate whose ETag the local file
+// reproduces, or nil. Each distinct ETag is hashed against once, and
+// candidates whose ETag is not MD5 based (SSE-KMS, SSE-C) are skipped.
+func (o *Options) findRenameSource(ctx context.Context, src *storage.Object, candidates []*storage.Object) (*storage.Object, error) {
+	tried := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		if tried[candidate.Etag] {
+			continue
+		}
+		tried[candidate.Etag] = true
+		matched, _, err := etag.Match(ctx, src.StorageURL.Absolute(), src.Size, candidate.Etag, o.Shared.PartSizeBytes())
+		if errors.Is(err, etag.ErrIncomparable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return candidate, nil
+		}
+	}
+	return nil, nil
+}
@@ -21,16 +21,22 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/filter"
+	"github.com/LinPr/s6cmd/internal/ignore"
 	"github.com/LinPr/s6cmd/internal/parallel"
 	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/internal/report"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 	"github.com/go-playground/validator/v10"
@@ -40,10 +46,10 @@ import (
 func NewSyncCmd() *cobra.Command {
 	o := newOptions()
 	cmd := cobra.Command{
-		Use:     "sync [flags] <source> <destination>",
+		Use:     "sync [flags] <source> <destination>...",
 		Short:   "sync objects between source and destination",
 		Example: sync_examples,
-		Args:    cobra.ExactArgs(2),
+		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := o.complete(cmd, args); err != nil {
 				return err
@@ -62,6 +68,41 @@ func NewSyncCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&o.SizeOnly, "size-only", false, "make size of object the only comparison criterion")
 	cmd.Flags().BoolVar(&o.ExitOnError, "exit-on-error", false, "stop the sync process on the first error")
 	cmd.Flags().BoolVar(&o.Recursive, "recursive", false, "sync objects recursively (kept for backwards compatibility)")
+	cmd.Flags().BoolVar(&o.IgnoreExisting, "ignore-existing", false, "skip objects that already exist in destination, never overwriting them")
+	cmd.Flags().BoolVar(&o.Existing, "existing", false, "only update objects that already exist in destination, never creating new ones")
+	cmd.Flags().BoolVarP(&o.Update, "update", "u", false, "skip objects whose destination is newer than the source")
+	cmd.Flags().BoolVar(&o.DeleteExcluded, "delete-excluded", false, "also delete destination objects matched by --exclude/--include or left out by the ignore files (implies --delete)")
+	cmd.Flags().BoolVar(&o.DeleteBefore, "delete-before", false, "delete extra destination objects before transferring (implies --delete)")
+	cmd.Flags().BoolVar(&o.DeleteAfter, "delete-after", false, "delete extra destination objects after every transfer succeeded (implies --delete)")
+	cmd.Flags().BoolVar(&o.DetectRenames, "detect-renames", false, "local to S3: copy moved files server-side from an extra destination object with the same content instead of uploading them")
+
+	cmd.Flags().StringArrayVar(&o.To, "to", nil, "additional s3:// destination; may be repeated to sync to several destinations at once, reading the source once")
+
+	cmd.Flags().StringVar(&o.PlanOut, "plan-out", "", "write the planned copies, deletes and skips to this JSON file for `s6cmd apply` instead of syncing")
+
+	cmd.Flags().StringVar(&o.Manifest, "manifest", "", "S3 destination: keep the destination listing in this local file or s3:// object and use it instead of listing the destination on the next run")
+	cmd.Flags().BoolVar(&o.FullRescan, "full-rescan", false, "with --manifest, list the destination and rebuild the manifest")
+	cmd.Flags().DurationVar(&o.RescanInterval, "rescan-interval", defaultRescanInterval, "with --manifest, list the destination again when its last full listing is older than this (0 never)")
+
+	cmd.Flags().BoolVar(&o.Watch, "watch", false, "local to S3: after the sync, keep watching the source directory and upload changed files until interrupted")
+	cmd.Flags().DurationVar(&o.WatchInterval, "watch-interval", defaultWatchInterval, "with --watch, how often the source directory is rescanned")
+	cmd.Flags().DurationVar(&o.Debounce, "debounce", defaultDebounce, "with --watch, how long the source must stay unchanged before a batch of changes is synced")
+	cmd.Flags().BoolVar(&o.Poll, "poll", false, "with --watch, rely on rescans only and do not use inotify")
+
+	// --max-delete / --backup-dir, shared with rm.
+	o.Guard.AddToCmd(&cmd)
+	// --task-retries / --task-retry-backoff / --failed-out, shared with
+	// cp, mv and rm.
+	o.Failures.AddToCmd(&cmd)
+	// --report / --report-format, shared with cp, mv, rm, put and get.
+	o.Report.AddToCmd(&cmd)
+	// --show-progress / --show-transfers, shared with cp, mv, put and
+	// get.
+	o.Progress.AddToCmd(&cmd)
+	// --inventory, shared with ls, du, find, rm and cp.
+	o.Inventory.AddToCmd(&cmd)
+	// --from-listing / --check-listing, shared with cp, du, find and rm.
+	o.Listing.AddToCmd(&cmd)
 
 	// Shared flags: --concurrency, --part-size, --acl, --metadata, ...
 	o.Shared.AddToCmd(&cmd)
@@ -81,6 +122,53 @@ type Flags struct {
 	SizeOnly    bool
 	ExitOnError bool
 	Recursive   bool
+	// IgnoreExisting, Existing and Update are the rsync update modes
+	// (see strategy).
+	IgnoreExisting bool
+	Existing       bool
+	Update         bool
+	// DeleteExcluded, DeleteBefore and DeleteAfter refine --delete and
+	// imply it.
+	DeleteExcluded bool
+	DeleteBefore   bool
+	DeleteAfter    bool
+	// DetectRenames pairs pending uploads with extra destination
+	// objects of the same content (see copyRenames).
+	DetectRenames bool
+	// To holds the destinations after the first, from extra positional
+	// arguments and --to (see syncFanOut).
+	To []string
+	// PlanOut writes the plan for apply instead of running it (see
+	// syncPlan).
+	PlanOut string
+	// Manifest caches the destination listing between runs (see
+	// destManifest).
+	Manifest       string
+	FullRescan     bool
+	RescanInterval time.Duration
+	// Watch keeps syncing changes after the first run (see watch).
+	Watch         bool
+	WatchInterval time.Duration
+	Debounce      time.Duration
+	Poll          bool
+	// Guard caps the delete set and backs up deleted and overwritten
+	// objects (see cliutil.DeleteGuard).
+	Guard cliutil.DeleteGuard
+	// Failures retries transient transfer and delete failures and
+	// records the rest (see cliutil.FailureFlags).
+	Failures cliutil.FailureFlags
+	// Report writes the outcome of every transfer and delete to
+	// --report (see cliutil.ReportFlags).
+	Report cliutil.ReportFlags
+	// Progress shows the progress of the transfers on stderr (see
+	// cliutil.ProgressFlags).
+	Progress cliutil.ProgressFlags
+	// Inventory lists a remote source from an S3 Inventory report (see
+	// cliutil.InventoryFlags).
+	Inventory cliutil.InventoryFlags
+	// Listing takes a remote source from a listing saved by ls (see
+	// cliutil.ListingFlags).
+	Listing cliutil.ListingFlags
 	cliutil.CommonFlags
 }
 
@@ -88,6 +176,15 @@ type Options struct {
 	Args
 	Flags
 	Shared *cliutil.SharedFlags
+
+	// manifest is opened by run for --manifest.
+	manifest *destManifest
+	// progress is the progress bar of the run's transfers, set while
+	// planAndRun or syncFanOut runs them; nil shows nothing.
+	progress progressbar.ProgressBar
+	// failed, when set, is called with the source of every transfer of
+	// planAndRun that failed, from the task that ran it.
+	failed func(src *storage.Object)
 }
 
 func newOptions() *Options {
@@ -95,60 +192,141 @@ func newOptions() *Options {
 }
 
 func (o *Options) complete(cmd *cobra.Command, args []string) error {
-	if len(args) >= 2 {
+	if len(args) >= 1 {
 		o.Source = args[0]
-		o.Destination = args[1]
+	}
+	dsts := append(append([]string(nil), args[min(len(args), 1):]...), o.To...)
+	if len(dsts) > 0 {
+		o.Destination, o.To = dsts[0], dsts[1:]
 	}
 	o.CommonFlags = cliutil.LoadParentFlags(cmd)
 	// Propagate --dry-run into the store constructors so every mutating
 	// storage call becomes a no-op while the plan/compare phases run for
 	// real.
 	o.CommonFlags.DryRun = o.DryRun
+	if o.DeleteExcluded || o.DeleteBefore || o.DeleteAfter {
+		o.Delete = true
+	}
 	return nil
 }
 
 func (o *Options) validate() error {
+	if o.Source != "" && o.Destination == "" {
+		return fmt.Errorf("sync requires a destination argument or --to")
+	}
 	if err := validator.New().Struct(o.Args); err != nil {
 		return err
 	}
 	if err := o.Shared.ValidateMetadataDirective(); err != nil {
 		return err
 	}
-	return nil
+	if o.DeleteBefore && o.DeleteAfter {
+		return fmt.Errorf("--delete-before and --delete-after are mutually exclusive")
+	}
+	if o.PlanOut != "" && (o.Watch || o.DetectRenames) {
+		return fmt.Errorf("--plan-out can not be combined with --watch or --detect-renames")
+	}
+	if len(o.To) > 0 && (o.PlanOut != "" || o.Manifest != "" || o.Watch || o.DetectRenames) {
+		return fmt.Errorf("several destinations can not be combined with --plan-out, --manifest, --watch or --detect-renames")
+	}
+	if o.Manifest == "" && o.FullRescan {
+		return fmt.Errorf("--full-rescan requires --manifest")
+	}
+	if o.RescanInterval < 0 {
+		return fmt.Errorf("--rescan-interval must not be negative")
+	}
+	if o.Manifest != "" && o.Watch {
+		return fmt.Errorf("--manifest can not be combined with --watch")
+	}
+	if o.Failures.FailedOut != "" && (o.Watch || o.PlanOut != "") {
+		return fmt.Errorf("--failed-out can not be combined with --watch or --plan-out")
+	}
+	if o.Report.File != "" && (o.Watch || o.PlanOut != "") {
+		return fmt.Errorf("--report can not be combined with --watch or --plan-out")
+	}
+	if o.Watch {
+		if o.WatchInterval <= 0 || o.Debounce < 0 {
+			return fmt.Errorf("--watch-interval must be positive and --debounce must not be negative")
+		}
+		// Watch batches upload every changed file; the update modes
+		// and rename detection only apply to the initial sync's plan.
+		if o.IgnoreExisting || o.Existing || o.Update || o.DetectRenames {
+			return fmt.Errorf("--watch can not be combined with --ignore-existing, --existing, --update or --detect-renames")
+		}
+		// A file changing across the filter leaves the next scan, which
+		// reads as a removal and would delete its object.
+		if o.Delete && o.Shared.Filter != "" {
+			return fmt.Errorf("--watch --delete can not be combined with --filter")
+		}
+	}
+	if err := o.Guard.Validate(); err != nil {
+		return err
+	}
+	if err := o.Failures.Validate(); err != nil {
+		return err
+	}
+	return o.Report.Validate()
 }
 
 func (o *Options) run(ctx context.Context, stdin io.Reader, stderr io.Writer) error {
 	// --delete destroys destination objects, so it needs an explicit
 	// confirmation: --yes, or an interactive y at the prompt. A dry run
-	// deletes nothing and skips the prompt. Non-interactive runs without
+	// deletes nothing and skips the prompt, and so does --plan-out:
+	// apply asks before running the plan. Non-interactive runs without
 	// --yes fail loudly instead of silently skipping the deletes (the
 	// previous behaviour, which also ate a line of piped stdin).
-	if o.Delete && !o.Yes && !o.DryRun {
+	if o.Delete && !o.Yes && !o.DryRun && o.PlanOut == "" {
 		fmt.Fprintf(stderr, "WARNING: this will delete objects in destination that are not in source.\n")
-		fmt.Fprintf(stderr, "  source: %s\n  destination: %s\n", o.Source, o.Destination)
+		fmt.Fprintf(stderr, "  source: %s\n  destination: %s\n", o.Source, strings.Join(append([]string{o.Destination}, o.To...), ", "))
 		if err := cliutil.Confirm(ctx, stdin, stderr, "Continue?"); err != nil {
 			return fmt.Errorf("sync --delete: %w", err)
 		}
 	}
 
-	srcURL, err := storage.NewStorageURL(o.Source, storage.WithRaw(o.Shared.Raw))
-	if err != nil {
+	if err := o.Report.Open(o.DryRun); err != nil {
 		return err
 	}
-	dstURL, err := storage.NewStorageURL(o.Destination, storage.WithRaw(o.Shared.Raw))
+	err := o.sync(ctx)
+	// sync returns once every task finished, so the report is complete.
+	if cerr := o.Report.Close(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+func (o *Options) sync(ctx context.Context) error {
+	srcURL, err := storage.NewStorageURL(o.Source, storage.WithRaw(o.Shared.Raw), storage.WithIgnoreFiles(o.Shared.Ignore.Files()), o.Inventory.Option(), o.Listing.Option())
 	if err != nil {
 		return err
 	}
-	if dstURL.IsWildcard() {
-		return fmt.Errorf("destination %q can not contain glob characters", o.Destination)
+	if err := o.Inventory.Validate(srcURL); err != nil {
+		return err
 	}
+	if err := o.Listing.Validate(srcURL); err != nil {
+		return err
+	}
+	dstURLs, err := o.destinations()
+	if err != nil {
+		return err
+	}
+	dstURL := dstURLs[0]
 
 	store, err := cliutil.NewStorage(ctx, o.CommonFlags)
 	if err != nil {
 		return err
 	}
 
+	if o.Manifest != "" {
+		if o.manifest, err = o.openManifest(ctx, dstURL); err != nil {
+			return err
+		}
+	}
+
 	switch {
+	case len(dstURLs) > 1:
+		return o.syncFanOut(ctx, store, srcURL, dstURLs)
+	case o.Watch:
+		return o.syncAndWatch(ctx, store, srcURL, dstURL)
 	case srcURL.IsRemote() && dstURL.IsRemote():
 		return o.syncS3ToS3(ctx, store, srcURL, dstURL)
 	case srcURL.IsRemote() && !dstURL.IsRemote():
@@ -160,6 +338,35 @@ func (o *Options) run(ctx context.Context, stdin io.Reader, stderr io.Writer) er
 	}
 }
 
+// destinations parses the destination arguments. Syncing to more than one
+// destination requires every one of them to be remote.
+func (o *Options) destinations() ([]*storage.StorageURL, error) {
+	uris := append([]string{o.Destination}, o.To...)
+	dsts := make([]*storage.StorageURL, 0, len(uris))
+	for _, uri := range uris {
+		dst, err := storage.NewStorageURL(uri, storage.WithRaw(o.Shared.Raw))
+		if err != nil {
+			return nil, err
+		}
+		if dst.IsWildcard() {
+			return nil, fmt.Errorf("destination %q can not contain glob characters", uri)
+		}
+		if len(uris) > 1 && !dst.IsRemote() {
+			return nil, fmt.Errorf("destination %q: syncing to several destinations requires s3:// destinations", uri)
+		}
+		if o.Guard.BackupEnabled() && !dst.IsRemote() {
+			return nil, fmt.Errorf("--backup-dir requires an s3:// destination")
+		}
+		// Backups inside the destination would be listed as destination
+		// objects: overwritten by the next sync or deleted as extras.
+		if o.Guard.Contains(dst) {
+			return nil, fmt.Errorf("--backup-dir %q must not be inside the destination %q", o.Guard.BackupDir, uri)
+		}
+		dsts = append(dsts, dst)
+	}
+	return dsts, nil
+}
+
 // syncPair captures the per-direction dispatch for sync. Each variant
 // (S3ToS3/S3ToLocal/LocalToS3/LocalToLocal) populates the source and
 // destination object slices via listObjects, then calls planAndRun with
@@ -243,10 +450,12 @@ func (o *Options) listObjects(ctx context.Context, store *storage.Storage, src *
 //  2. Resolve every source object to its exact destination URL and pair
 //     it with the existing destination object under that key, if any
 //     (buildSyncPlan).
-//  3. Submit cp tasks for every pair the strategy says should be copied
-//     on the parallel.Manager.
-//  4. When --delete is set, submit rm tasks for the extra destination
-//     objects: (destination keys) minus (keys written this run).
+//  3. Decide for every pair whether to copy it (decide), and with
+//     --delete collect the extra destination objects: (destination keys)
+//     minus (keys written this run).
+//  4. With --plan-out, write the decisions to the plan file and stop;
+//     otherwise submit the cp and rm tasks on the parallel.Manager
+//     (execute).
 //
 // All task-submission happens on the main goroutine so the plan maps are
 // never written from a worker.
@@ -256,13 +465,9 @@ func (o *Options) planAndRun(
 	pair syncPair,
 	srcObjects, dstObjects []*storage.Object,
 	isBatch bool,
-	buildTask func(srcURL, dstURL *storage.StorageURL) parallel.Task,
+	buildTask func(src *storage.Object, dstURL *storage.StorageURL, rop *report.Operation) parallel.Task,
 ) error {
-	excludePatterns, err := cliutil.CompileExcludeIncludePatterns(o.Shared.Exclude)
-	if err != nil {
-		return err
-	}
-	includePatterns, err := cliutil.CompileExcludeIncludePatterns(o.Shared.Include)
+	filters, err := newSyncFilters(o.Shared, pair.src)
 	if err != nil {
 		return err
 	}
@@ -277,9 +482,14 @@ func (o *Options) planAndRun(
 		}
 	}
 
-	pb := progressbar.New(false)
+	pb, err := o.Progress.New()
+	if err != nil {
+		return err
+	}
 	pb.Start()
 	defer pb.Finish()
+	o.progress = pb
+	defer func() { o.progress = nil }()
 
 	// The collector serializes appends from the drain goroutine and the
 	// submission loop below. The drain never stops early: the Waiter's
@@ -290,64 +500,307 @@ func (o *Options) planAndRun(
 	// and their errors are still drained.
 	waiter := parallel.NewWaiter()
 	ec := cliutil.NewErrorCollector("sync")
+	o.Failures.Track(ec)
 	drainDone := ec.Drain(waiter)
 
-	strategy := newStrategy(o.SizeOnly)
-
 	items, extras, planErrs := buildSyncPlan(srcObjects, dstObjects, pair.dst, isBatch, dstIsDir)
 	for _, err := range planErrs {
 		ec.Collect(err)
 	}
+	decisions, deletes := o.decide(items, extras, filters)
 
-	for _, item := range items {
-		if o.ExitOnError && ec.HasError() {
-			// Stop scheduling new work after the first failure; the
-			// drain goroutine keeps consuming errors from in-flight
-			// tasks so waiter.Wait() below cannot deadlock.
-			break
+	// --max-delete is checked against the full delete set before any
+	// rename copy, transfer or delete is submitted, so an exceeded limit
+	// leaves the destination untouched.
+	if o.Delete {
+		if err := o.Guard.Check(len(deletes), len(dstObjects)); err != nil {
+			waiter.Wait()
+			drainDone()
+			return err
+		}
+	}
+
+	pending := make([]syncPlanItem, 0, len(decisions))
+	for _, d := range decisions {
+		switch {
+		case d.copy:
+			pending = append(pending, d.item)
+		case d.err != nil:
+			ec.Collect(d.err)
+		}
+	}
+
+	// --plan-out records the decisions for a later apply instead of
+	// acting on them.
+	if o.PlanOut != "" {
+		waiter.Wait()
+		drainDone()
+		plan, err := o.newSyncPlan(decisions, deletes, len(dstObjects))
+		if err != nil {
+			return err
+		}
+		if err := plan.save(o.PlanOut); err != nil {
+			return fmt.Errorf("write plan: %w", err)
+		}
+		return ec.Aggregate()
+	}
+
+	// Renamed files are copied server-side from their old key, and the
+	// copies finish before any delete below is submitted.
+	if o.DetectRenames {
+		pending = o.copyRenames(ctx, store, ec, pending, extras)
+	}
+
+	transfers := make([]parallel.Task, 0, len(pending))
+	for _, item := range pending {
+		pb.AddTotalBytes(item.srcObj.Size)
+		pb.IncrementTotalObjects()
+
+		rop := o.Report.Start("cp", displayURL(item.srcObj.StorageURL), displayURL(item.dstURL), item.srcObj.Size)
+		task := buildTask(item.srcObj, item.dstURL, rop)
+		if item.dstObj != nil && o.Guard.BackupEnabled() {
+			// Keep the version about to be overwritten.
+			dstObj, transfer := item.dstObj, task
+			task = func() error {
+				if err := o.Guard.Backup(ctx, store, "sync", dstObj); err != nil {
+					return err
+				}
+				return transfer()
+			}
+		}
+		if o.failed != nil {
+			srcObj, transfer := item.srcObj, task
+			task = func() error {
+				err := transfer()
+				if err != nil && !errorpkg.IsWarning(err) {
+					o.failed(srcObj)
+				}
+				return err
+			}
+		}
+		transfers = append(transfers, rop.Finishes(task))
+	}
+	deleteTasks := make([]parallel.Task, 0, len(deletes))
+	for _, obj := range deletes {
+		deleteTasks = append(deleteTasks, o.deleteTask(ctx, store, obj))
+	}
+
+	o.execute(ec, waiter, drainDone, transfers, deleteTasks)
+
+	// The manifest records the keys this run wrote or deleted as they
+	// are now; failed and skipped transfers left their keys as listed.
+	if o.manifest != nil && !o.DryRun {
+		touched := make([]*storage.StorageURL, 0, len(decisions)+len(deletes))
+		for _, d := range decisions {
+			if d.copy {
+				touched = append(touched, d.item.dstURL)
+			}
+		}
+		for _, obj := range deletes {
+			touched = append(touched, obj.StorageURL)
+		}
+		ec.Collect(o.manifest.update(ctx, pair.dst, touched))
+	}
+	// An interrupted sync is resumed by running it again: it only
+	// transfers what is still missing.
+	if parallel.Stopped() {
+		o.Failures.RecordCommand(os.Args[1:])
+	}
+	ec.Collect(o.Failures.WriteFailed())
+	return ec.Aggregate()
+}
+
+// syncDecision is what the plan does with one source object: copy it or
+// skip it, and why.
+type syncDecision struct {
+	item   syncPlanItem
+	copy   bool
+	reason string
+	// err is the strategy's verdict on a skipped existing destination,
+	// which the run collects as a warning.
+	err error
+}
+
+// syncFilters holds the filters a sync applies to its source objects:
+// the --exclude/--include patterns, the --filter-from rules and the
+// --filter expression. The ignore files of a local source already pruned
+// its listing; ignore keeps the destination counterparts of the ignored
+// files out of the --delete set.
+type syncFilters struct {
+	excludePatterns, includePatterns []string
+	rules                            *filter.Rules
+	expr                             *filter.Filter
+	ignore                           *ignore.Tree
+}
+
+// newSyncFilters compiles the filters of sf for the source src, which may
+// be nil when the ignore files are handled by the caller.
+func newSyncFilters(sf *cliutil.SharedFlags, src *storage.StorageURL) (syncFilters, error) {
+	var f syncFilters
+	var err error
+	if f.ignore, err = sourceIgnores(src); err != nil {
+		return f, err
+	}
+	if f.excludePatterns, err = cliutil.CompileExcludeIncludePatterns(sf.Exclude); err != nil {
+		return f, err
+	}
+	if f.includePatterns, err = cliutil.CompileExcludeIncludePatterns(sf.Include); err != nil {
+		return f, err
+	}
+	if f.rules, err = filter.LoadRules(sf.FilterFrom); err != nil {
+		return f, err
+	}
+	f.expr, err = filter.Parse(sf.Filter)
+	return f, err
+}
+
+// excluded reports whether obj, at the relative path name, is filtered
+// out.
+func (f syncFilters) excluded(name string, obj *storage.Object) bool {
+	return cliutil.IsObjectExcluded(name, f.excludePatterns, f.includePatterns) ||
+		f.rules.Excluded(name) || !f.expr.Match(obj)
+}
+
+// protected reports whether extra, an object only in the destination, is
+// the counterpart of a source file the ignore files leave out, which
+// --delete keeps like the counterpart of an excluded source.
+func (f syncFilters) protected(extra *storage.Object) bool {
+	ignored, err := f.ignore.Ignored(extra.StorageURL.Relative(), false)
+	return err != nil || ignored
+}
+
+// sourceIgnores returns the ignore files of a local directory or wildcard
+// source, rooted where its listing computes relative paths.
+func sourceIgnores(src *storage.StorageURL) (*ignore.Tree, error) {
+	if src == nil || src.IsRemote() || len(src.IgnoreFiles()) == 0 {
+		return nil, nil
+	}
+	root := src.Absolute()
+	if src.IsWildcard() {
+		root = cliutil.WildcardBasePath(root)
+	} else if isDir, err := cliutil.IsLocalDir(root); err != nil || !isDir {
+		return nil, err
+	}
+	return ignore.NewTree(root, src.IgnoreFiles()), nil
+}
+
+// decide applies the filters, the rsync update modes and the
+// comparison strategy to the plan items, and returns one decision per
+// item in plan order together with the delete set: the extra destination
+// objects and, with --delete-excluded, the destinations of excluded
+// sources. The delete set is empty without --delete.
+func (o *Options) decide(items []syncPlanItem, extras []*storage.Object, filters syncFilters) ([]syncDecision, []*storage.Object) {
+	strategy := o.strategy()
+	decisions := make([]syncDecision, 0, len(items))
+	deletes := extras
+	if filters.ignore != nil && !o.DeleteExcluded {
+		deletes = make([]*storage.Object, 0, len(extras))
+		for _, extra := range extras {
+			if !filters.protected(extra) {
+				deletes = append(deletes, extra)
+			}
 		}
+	}
+	for _, item := range items {
 		// Apply exclude/include on the source name. The destination name
 		// is derived from the source name so it does not need separate
 		// filtering. Excluded sources still keep their destination key in
 		// the plan's written set, so --delete never removes the untouched
-		// counterpart of an excluded source.
+		// counterpart of an excluded source; --delete-excluded opts in to
+		// removing it.
 		name := item.srcObj.StorageURL.Relative()
 		if name == "" {
 			name = item.srcObj.StorageURL.Absolute()
 		}
-		if cliutil.IsObjectExcluded(name, excludePatterns, includePatterns) {
+		if filters.excluded(name, item.srcObj) {
+			if o.DeleteExcluded && item.dstObj != nil {
+				deletes = append(deletes, item.dstObj)
+			}
+			decisions = append(decisions, syncDecision{item: item, reason: "excluded"})
 			continue
 		}
-		if item.dstObj != nil {
-			// The destination key already exists: ask the strategy
-			// whether to copy over it.
-			if err := strategy.ShouldSync(item.srcObj, item.dstObj); err != nil {
-				ec.Collect(err)
+		if item.dstObj == nil {
+			if o.Existing {
+				log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("%s does not exist in destination, skipped (--existing)", item.dstURL)})
+				decisions = append(decisions, syncDecision{item: item, reason: "not in destination (--existing)"})
 				continue
 			}
+			decisions = append(decisions, syncDecision{item: item, copy: true, reason: "not in destination"})
+			continue
 		}
+		// The destination key already exists: ask the strategy whether
+		// to copy over it.
+		if err := strategy.ShouldSync(item.srcObj, item.dstObj); err != nil {
+			decisions = append(decisions, syncDecision{item: item, reason: err.Error(), err: err})
+			continue
+		}
+		decisions = append(decisions, syncDecision{item: item, copy: true, reason: copyReason(item.srcObj, item.dstObj)})
+	}
+	if !o.Delete {
+		deletes = nil
+	}
+	return decisions, deletes
+}
 
-		pb.AddTotalBytes(item.srcObj.Size)
-		pb.IncrementTotalObjects()
+// copyReason explains why the strategy copies src over an existing dst.
+func copyReason(src, dst *storage.Object) string {
+	if src.Size != dst.Size {
+		return "size differs"
+	}
+	if src.ModTime != nil && dst.ModTime != nil && src.ModTime.After(*dst.ModTime) {
+		return "source is newer"
+	}
+	return "changed"
+}
 
-		parallel.Run(buildTask(item.srcObj.StorageURL, item.dstURL), waiter)
+// execute submits the transfers and deletes of a plan and waits for them.
+// The delete set is keyed by full destination path — never Base() names —
+// so it can never hold the destination key of a transfer. By default
+// deletes run alongside the transfers; --delete-before finishes them
+// first, --delete-after waits for every transfer.
+func (o *Options) execute(ec *cliutil.ErrorCollector, waiter *parallel.Waiter, drainDone func(), transfers, deletes []parallel.Task) {
+	if o.DeleteBefore {
+		o.runTasks(ec, deletes)
+	}
+	o.submit(ec, waiter, transfers)
+	if !o.DeleteBefore && !o.DeleteAfter {
+		o.submit(ec, waiter, deletes)
 	}
+	waiter.Wait()
+	drainDone()
 
-	// The delete set is keyed by full destination path — never Base()
-	// names — so --delete can never enqueue the destination key of an
-	// in-flight copy scheduled above.
-	if o.Delete {
-		for _, extra := range extras {
-			if o.ExitOnError && ec.HasError() {
-				break
-			}
-			o.queueDelete(ctx, store, waiter, extra.StorageURL)
+	if o.DeleteAfter {
+		// Like rsync, a failed transfer cancels the deferred deletes:
+		// the destination may be the only intact copy left.
+		if ec.HasError() {
+			log.Error(log.ErrorMessage{Operation: "sync", Err: "transfer errors occurred, skipping --delete-after"})
+		} else {
+			o.runTasks(ec, deletes)
+		}
+	}
+}
+
+// submit runs every task on waiter.
+func (o *Options) submit(ec *cliutil.ErrorCollector, waiter *parallel.Waiter, tasks []parallel.Task) {
+	for _, task := range tasks {
+		if o.ExitOnError && ec.HasError() {
+			// Stop scheduling new work after the first failure; the
+			// drain goroutine keeps consuming errors from in-flight
+			// tasks so waiter.Wait() cannot deadlock.
+			break
 		}
+		parallel.Run(task, waiter)
 	}
+}
 
+// runTasks runs every task on its own Waiter and waits for them, for
+// --delete-before and --delete-after.
+func (o *Options) runTasks(ec *cliutil.ErrorCollector, tasks []parallel.Task) {
+	waiter := parallel.NewWaiter()
+	drainDone := ec.Drain(waiter)
+	o.submit(ec, waiter, tasks)
 	waiter.Wait()
 	drainDone()
-	return ec.Aggregate()
 }
 
 // syncPlanItem pairs a source object with its resolved destination URL and
@@ -407,17 +860,29 @@ func buildSyncPlan(srcObjects, dstObjects []*storage.Object, dst *storage.Storag
 	return items, extras, errs
 }
 
-// queueDelete submits a MultiDelete-friendly delete for a single URL. It
-// does not call MultiDelete (which expects a channel); instead it uses
-// the per-URL Delete on the store. The waiter aggregates the error.
-func (o *Options) queueDelete(ctx context.Context, store *storage.Storage, waiter *parallel.Waiter, url *storage.StorageURL) {
-	parallel.Run(func() error {
+// deleteTask deletes a single destination object. With --backup-dir the
+// object is copied there first and a failed backup leaves it in place. A
+// transiently failed delete is retried per --task-retries, and a failed
+// one is reported as an rm so --failed-out can replay it.
+func (o *Options) deleteTask(ctx context.Context, store *storage.Storage, obj *storage.Object) parallel.Task {
+	url := obj.StorageURL
+	rop := o.Report.Start("rm", displayURL(url), "", obj.Size)
+	del := o.Failures.Wrap(ctx, rop.Attempt(func() error {
 		if err := store.Delete(ctx, url); err != nil {
-			return &errorpkg.Error{Op: "sync", Dst: url.String(), Err: err}
+			return &errorpkg.Error{Op: "rm", Dst: url.String(), Err: err}
+		}
+		return nil
+	}))
+	return rop.Finishes(func() error {
+		if err := o.Guard.Backup(ctx, store, "sync", obj); err != nil {
+			return err
+		}
+		if err := del(); err != nil {
+			return err
 		}
 		log.Info(log.InfoMessage{Operation: "rm", Source: url.String()})
 		return nil
-	}, waiter)
+	})
 }
 
 // generateDestinationURL resolves the destination URL: for batch sources
@@ -470,17 +935,7 @@ func (o *Options) syncS3ToS3(ctx context.Context, store *storage.Storage, src, d
 	if err != nil {
 		return err
 	}
-	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsPrefix, func(srcURL, dstURL *storage.StorageURL) parallel.Task {
-		return func() error {
-			md := o.sharedMetadata()
-			md.Directive = cliutil.MetadataDirectiveReplace
-			if err := store.Copy(ctx, srcURL, dstURL, md); err != nil {
-				return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dstURL.String(), Err: err}
-			}
-			log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.String(), Destination: dstURL.String()})
-			return nil
-		}
-	})
+	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsPrefix, o.transferTask(ctx, store))
 }
 
 // --- S3 -> local ---
@@ -506,15 +961,7 @@ func (o *Options) syncS3ToLocal(ctx context.Context, store *storage.Storage, src
 	if err != nil {
 		return err
 	}
-	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsPrefix, func(srcURL, dstURL *storage.StorageURL) parallel.Task {
-		return func() error {
-			if err := store.DownloadFile(ctx, srcURL.Bucket, srcURL.Path, dstURL.Absolute(), o.Shared.Concurrency, o.Shared.PartSizeBytes()); err != nil {
-				return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dstURL.Absolute(), Err: err}
-			}
-			log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.String(), Destination: dstURL.Absolute()})
-			return nil
-		}
-	})
+	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsPrefix, o.transferTask(ctx, store))
 }
 
 // --- local -> S3 ---
@@ -536,16 +983,7 @@ func (o *Options) syncLocalToS3(ctx context.Context, store *storage.Storage, src
 	if err != nil {
 		return err
 	}
-	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsDir, func(srcURL, dstURL *storage.StorageURL) parallel.Task {
-		return func() error {
-			_, err := store.UploadFile(ctx, srcURL.Absolute(), dstURL.Bucket, dstURL.Path, o.Shared.Concurrency, o.Shared.PartSizeBytes())
-			if err != nil {
-				return &errorpkg.Error{Op: "cp", Src: srcURL.Absolute(), Dst: dstURL.String(), Err: err}
-			}
-			log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.Absolute(), Destination: dstURL.String()})
-			return nil
-		}
-	})
+	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsDir, o.transferTask(ctx, store))
 }
 
 // --- local -> local ---
@@ -573,15 +1011,76 @@ func (o *Options) syncLocalToLocal(ctx context.Context, store *storage.Storage,
 	if err != nil {
 		return err
 	}
-	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsDir, func(srcURL, dstURL *storage.StorageURL) parallel.Task {
-		return func() error {
-			if err := store.Copy(ctx, srcURL, dstURL, storage.Metadata{}); err != nil {
-				return &errorpkg.Error{Op: "cp", Src: srcURL.Absolute(), Dst: dstURL.Absolute(), Err: err}
+	return o.planAndRun(ctx, store, syncPair{src: src, dst: dst}, srcObjects, dstObjects, srcIsDir, o.transferTask(ctx, store))
+}
+
+// transferTask returns the task builder planAndRun uses to write a source
+// object to its destination URL: a server-side copy between buckets, a
+// multipart download or upload across the local/remote boundary, and a
+// file copy between local paths. A transiently failed transfer is retried
+// per --task-retries, and each attempt and the object written are
+// recorded in rop, which the caller finishes. Downloads and uploads count
+// their bytes toward the progress as they go, copies once they completed.
+func (o *Options) transferTask(ctx context.Context, store *storage.Storage) func(src *storage.Object, dstURL *storage.StorageURL, rop *report.Operation) parallel.Task {
+	return func(src *storage.Object, dstURL *storage.StorageURL, rop *report.Operation) parallel.Task {
+		srcURL := src.StorageURL
+		return o.Failures.Wrap(ctx, rop.Attempt(func() error {
+			pt := o.transferProgress(src)
+			defer pt.Finish()
+			switch {
+			case srcURL.IsRemote() && dstURL.IsRemote():
+				md := o.sharedMetadata()
+				md.Directive = cliutil.MetadataDirectiveReplace
+				obj, err := store.Copy(ctx, srcURL, dstURL, md)
+				if err != nil {
+					return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dstURL.String(), Err: err}
+				}
+				rop.Wrote(obj)
+				pt.AddCompletedBytes(src.Size)
+				log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.String(), Destination: dstURL.String()})
+			case srcURL.IsRemote():
+				if err := store.DownloadFile(ctx, srcURL.Bucket, srcURL.Path, dstURL.Absolute(), o.Shared.Concurrency, o.Shared.PartSizeBytes(), cliutil.CountProgress(pt)); err != nil {
+					return &errorpkg.Error{Op: "cp", Src: srcURL.String(), Dst: dstURL.Absolute(), Err: err}
+				}
+				log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.String(), Destination: dstURL.Absolute()})
+			case dstURL.IsRemote():
+				out, err := store.UploadFile(ctx, srcURL.Absolute(), dstURL.Bucket, dstURL.Path, o.Shared.Concurrency, o.Shared.PartSizeBytes(), cliutil.CountProgress(pt))
+				if err != nil {
+					return &errorpkg.Error{Op: "cp", Src: srcURL.Absolute(), Dst: dstURL.String(), Err: err}
+				}
+				rop.Wrote(cliutil.UploadedObject(dstURL, out))
+				log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.Absolute(), Destination: dstURL.String()})
+			default:
+				obj, err := store.Copy(ctx, srcURL, dstURL, storage.Metadata{})
+				if err != nil {
+					return &errorpkg.Error{Op: "cp", Src: srcURL.Absolute(), Dst: dstURL.Absolute(), Err: err}
+				}
+				rop.Wrote(obj)
+				pt.AddCompletedBytes(src.Size)
+				log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.Absolute(), Destination: dstURL.Absolute()})
 			}
-			log.Info(log.InfoMessage{Operation: "cp", Source: srcURL.Absolute(), Destination: dstURL.Absolute()})
+			pt.IncrementCompletedObjects()
 			return nil
-		}
-	})
+		}))
+	}
+}
+
+// transferProgress starts the progress of transferring src; a NoOp
+// outside planAndRun and syncFanOut.
+func (o *Options) transferProgress(src *storage.Object) progressbar.ProgressBar {
+	if o.progress == nil {
+		return &progressbar.NoOp{}
+	}
+	return o.progress.Transfer(src.StorageURL.Relative(), src.Size)
+}
+
+// displayURL is how logs and reports name u: the s3:// URL of a remote
+// object, the absolute path of a local file.
+func displayURL(u *storage.StorageURL) string {
+	if u.IsRemote() {
+		return u.String()
+	}
+	return u.Absolute()
 }
 
 // sharedMetadata assembles a storage.Metadata from the SharedFlags. It is
@@ -602,6 +1101,22 @@ func (o *Options) sharedMetadata() storage.Metadata {
 	}
 }
 
+// uploadMetadata is sharedMetadata for an upload of the local file: the
+// Content-Type is guessed from the file unless --content-type is set.
+func (o *Options) uploadMetadata(file string) (storage.Metadata, error) {
+	md := o.sharedMetadata()
+	if md.ContentType != "" {
+		return md, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return md, err
+	}
+	defer f.Close()
+	md.ContentType = cliutil.GuessContentType(f)
+	return md, nil
+}
+
 // listDestObjects collects the destination objects for the plan. A single
 // remote destination key is Stat'ed directly instead of listing its parent
 // prefix: the parent listing inherited the destination URL's filter (so it
@@ -609,8 +1124,12 @@ func (o *Options) sharedMetadata() storage.Metadata {
 // then raced), and for keys under a sub-prefix the delimiter listing only
 // returned a CommonPrefix so the existing destination was never seen at
 // all. A missing destination is an empty listing, not an error: every
-// first-time sync writes to a destination that does not exist yet.
+// first-time sync writes to a destination that does not exist yet. With
+// --manifest the listing comes from the manifest when it is usable.
 func (o *Options) listDestObjects(ctx context.Context, store *storage.Storage, dst *storage.StorageURL, followSymlinks bool) ([]*storage.Object, error) {
+	if o.manifest != nil {
+		return o.manifest.destObjects(ctx, o, store, dst)
+	}
 	if dst.IsRemote() && !dst.IsBucket() && !dst.IsPrefix() {
 		obj, err := store.Stat(ctx, dst)
 		if err != nil {
@@ -632,6 +1151,21 @@ type syncStrategy interface {
 	ShouldSync(src, dst *storage.Object) error
 }
 
+// strategy returns the comparison strategy, wrapped by the rsync update
+// modes: --update skips destinations newer than their source before the
+// size/mtime comparison runs, and --ignore-existing skips every existing
+// destination outright.
+func (o *Options) strategy() syncStrategy {
+	if o.IgnoreExisting {
+		return &ignoreExistingStrategy{}
+	}
+	s := newStrategy(o.SizeOnly)
+	if o.Update {
+		s = &updateStrategy{next: s}
+	}
+	return s
+}
+
 // newStrategy returns the strategy selected by the --size-only flag.
 func newStrategy(sizeOnly bool) syncStrategy {
 	if sizeOnly {
@@ -665,8 +1199,31 @@ func (s *sizeAndModificationStrategy) ShouldSync(src, dst *storage.Object) error
 	return errorpkg.ErrObjectIsNewerAndSizesMatch
 }
 
+// ignoreExistingStrategy never overwrites an existing destination.
+type ignoreExistingStrategy struct{}
+
+func (s *ignoreExistingStrategy) ShouldSync(src, dst *storage.Object) error {
+	return errorpkg.ErrObjectExists
+}
+
+// updateStrategy skips destinations that are newer than their source and
+// defers every other pair to next.
+type updateStrategy struct {
+	next syncStrategy
+}
+
+func (s *updateStrategy) ShouldSync(src, dst *storage.Object) error {
+	srcMod, dstMod := src.ModTime, dst.ModTime
+	if srcMod != nil && dstMod != nil && dstMod.After(*srcMod) {
+		return errorpkg.ErrObjectIsNewer
+	}
+	return s.next.ShouldSync(src, dst)
+}
+
 // Compile-time assertion that the strategies satisfy the interface.
 var (
 	_ syncStrategy = (*sizeOnlyStrategy)(nil)
 	_ syncStrategy = (*sizeAndModificationStrategy)(nil)
+	_ syncStrategy = (*ignoreExistingStrategy)(nil)
+	_ syncStrategy = (*updateStrategy)(nil)
 )
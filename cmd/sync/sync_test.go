@@ -1,7 +1,11 @@
 package sync
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
 
 	"github.com/LinPr/s6cmd/storage"
 )
@@ -258,3 +262,37 @@ func TestGenerateDestinationURL_TraversalRejected(t *testing.T) {
 		t.Fatalf("generateDestinationURL accepted a traversal relative path")
 	}
 }
+
+// TestStrategy_UpdateModes covers how --ignore-existing and --update wrap
+// the size/mtime comparison.
+func TestStrategy_UpdateModes(t *testing.T) {
+	t.Parallel()
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	object := func(size int64, mod time.Time) *storage.Object {
+		return &storage.Object{Size: size, ModTime: &mod}
+	}
+	tests := []struct {
+		name     string
+		flags    Flags
+		src, dst *storage.Object
+		want     error
+	}{
+		{"default copies a newer source", Flags{}, object(1, newer), object(1, older), nil},
+		{"default copies over a newer destination of another size", Flags{}, object(1, older), object(2, newer), nil},
+		{"update skips a newer destination", Flags{Update: true}, object(1, older), object(2, newer), errorpkg.ErrObjectIsNewer},
+		{"update copies a newer source", Flags{Update: true}, object(1, newer), object(1, older), nil},
+		{"update defers equal times to the comparison", Flags{Update: true}, object(1, older), object(1, older), errorpkg.ErrObjectIsNewerAndSizesMatch},
+		{"update with size-only", Flags{Update: true, SizeOnly: true}, object(1, older), object(2, older), nil},
+		{"ignore-existing never overwrites", Flags{IgnoreExisting: true}, object(1, newer), object(2, older), errorpkg.ErrObjectExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			o := &Options{Flags: tt.flags}
+			if got := o.strategy().ShouldSync(tt.src, tt.dst); !errors.Is(got, tt.want) {
+				t.Errorf("ShouldSync = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
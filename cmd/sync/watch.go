@@ -0,0 +1,350 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/interrupt"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// Defaults for sync --watch.
+const (
+	defaultWatchInterval = 2 * time.Second
+	defaultDebounce      = 500 * time.Millisecond
+)
+
+// notifier reports file-system activity under a directory tree. It only
+// says "something changed": the rescan that follows works out what.
+type notifier interface {
+	// Events receives a value after one or more changes.
+	Events() <-chan struct{}
+	// Watch makes the notifier cover dirs, the directories of the
+	// latest scan.
+	Watch(dirs []string)
+	Close() error
+}
+
+// watchSnapshot is one scan of the source tree keyed by absolute path.
+type watchSnapshot map[string]*storage.Object
+
+// sameStat reports whether a file kept its size and modification time.
+func sameStat(a, b *storage.Object) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	if a.ModTime == nil || b.ModTime == nil {
+		return a.ModTime == nil && b.ModTime == nil
+	}
+	return a.ModTime.Equal(*b.ModTime)
+}
+
+// equal reports whether both scans saw the same files with the same stat
+// info.
+func (s watchSnapshot) equal(other watchSnapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for path, obj := range s {
+		o, ok := other[path]
+		if !ok || !sameStat(obj, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// diff returns the files of cur that are new or changed since s, and the
+// files of s that are gone from cur, both sorted by path.
+func (s watchSnapshot) diff(cur watchSnapshot) (changed, removed []*storage.Object) {
+	for path, obj := range cur {
+		if old, ok := s[path]; !ok || !sameStat(old, obj) {
+			changed = append(changed, obj)
+		}
+	}
+	for path, obj := range s {
+		if _, ok := cur[path]; !ok {
+			removed = append(removed, obj)
+		}
+	}
+	byPath := func(objs []*storage.Object) {
+		sort.Slice(objs, func(i, j int) bool {
+			return objs[i].StorageURL.Absolute() < objs[j].StorageURL.Absolute()
+		})
+	}
+	byPath(changed)
+	byPath(removed)
+	return changed, removed
+}
+
+// dirs returns root and every directory holding a scanned file.
+func (s watchSnapshot) dirs(root string) []string {
+	seen := map[string]bool{root: true}
+	out := []string{root}
+	for path := range s {
+		for dir := filepath.Dir(path); !seen[dir] && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			seen[dir] = true
+			out = append(out, dir)
+		}
+	}
+	return out
+}
+
+// scan walks the source tree with the sync listing. Any listing error
+// fails the whole scan: a partial scan would read as removed files. An
+// empty directory is an empty scan, but a missing one is an error rather
+// than "every file was removed".
+func (o *Options) scan(ctx context.Context, store *storage.Storage, src *storage.StorageURL) (watchSnapshot, error) {
+	if info, err := os.Stat(src.Absolute()); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", src.Absolute())
+	}
+	filters, err := newSyncFilters(o.Shared, nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := *o
+	scanner.ExitOnError = true
+	objs, err := scanner.listObjects(ctx, store, src, !o.Shared.NoFollowSymlinks, true)
+	if err != nil {
+		return nil, err
+	}
+	snap := make(watchSnapshot, len(objs))
+	for _, obj := range objs {
+		if filters.excluded(obj.StorageURL.Relative(), obj) {
+			continue
+		}
+		snap[obj.StorageURL.Absolute()] = obj
+	}
+	return snap, nil
+}
+
+// syncAndWatch implements sync --watch: it runs the regular local -> S3
+// sync, then keeps the prefix mirrored with watch until the first SIGINT.
+// Like a later batch, the initial sync tolerates files that fail: they
+// are left out of the baseline, so the first batch retries them. Any other
+// error, or any error with --exit-on-error, ends the watch.
+func (o *Options) syncAndWatch(ctx context.Context, store *storage.Storage, src, dst *storage.StorageURL) error {
+	if src.IsRemote() || !dst.IsRemote() {
+		return fmt.Errorf("--watch syncs a local directory to an s3:// prefix")
+	}
+	isDir, err := cliutil.IsLocalDir(src.Absolute())
+	if err != nil {
+		return err
+	}
+	if !isDir || !(dst.IsBucket() || dst.IsPrefix()) {
+		return fmt.Errorf("--watch needs a source directory and a destination prefix")
+	}
+
+	// The baseline is taken before the initial sync, so a file changed
+	// while it runs shows up in the first batch instead of being missed.
+	synced, err := o.scan(ctx, store, src)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	o.failed = func(src *storage.Object) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed[src.StorageURL.Absolute()] = true
+	}
+	err = o.syncLocalToS3(ctx, store, src, dst)
+	o.failed = nil
+	if err != nil && (o.ExitOnError || !objectErrors(err)) {
+		return err
+	}
+	if interrupt.Requested() {
+		return nil
+	}
+	for path := range failed {
+		delete(synced, path)
+	}
+	return o.watch(ctx, store, src, dst, synced)
+}
+
+// objectErrors reports whether err only holds failures of single objects,
+// such as a file that could not be read, and no error that failed the
+// sync as a whole.
+func objectErrors(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if !objectErrors(err) {
+				return false
+			}
+		}
+		return true
+	}
+	var objErr *errorpkg.Error
+	return errors.As(err, &objErr)
+}
+
+// watch rescans the source every --watch-interval, and on Linux as soon
+// as inotify reports activity. Once the tree has been quiet for
+// --debounce, the files whose size or mtime changed since the last batch
+// are uploaded and, with --delete, the objects of removed files deleted.
+//
+// The first SIGINT stops the parallel.Manager: watch stops submitting
+// work, lets the batch in flight finish and returns; the command then
+// exits 130 like any interrupted run. A second SIGINT cancels the batch
+// (see interrupt).
+func (o *Options) watch(ctx context.Context, store *storage.Storage, src, dst *storage.StorageURL, synced watchSnapshot) error {
+	root := src.Absolute()
+
+	var n notifier
+	var events <-chan struct{}
+	if !o.Poll {
+		var err error
+		if n, err = newNotifier(root); err != nil {
+			log.Debug(log.DebugMessage{Operation: "sync", Err: fmt.Sprintf("file-system notifications unavailable, rescanning every %s: %v", o.WatchInterval, err)})
+		}
+		if n != nil {
+			defer n.Close()
+			n.Watch(synced.dirs(root))
+			events = n.Events()
+		}
+	}
+
+	ticker := time.NewTicker(o.WatchInterval)
+	defer ticker.Stop()
+	settle := time.NewTimer(o.Debounce)
+	settle.Stop()
+	defer settle.Stop()
+
+	last := synced
+	var lastChange time.Time
+	notified := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-interrupt.Done():
+			return nil
+		case <-events:
+			lastChange = time.Now()
+			notified = true
+			settle.Reset(o.Debounce)
+			continue
+		case <-ticker.C:
+		case <-settle.C:
+		}
+
+		cur, err := o.scan(ctx, store, src)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Error(log.ErrorMessage{Operation: "sync", Err: fmt.Sprintf("rescan %s: %v", root, err)})
+			continue
+		}
+		if n != nil {
+			n.Watch(cur.dirs(root))
+		}
+		// A notification already dated the change; otherwise the scan
+		// that first sees it does.
+		if !notified && !cur.equal(last) {
+			lastChange = time.Now()
+		}
+		notified = false
+		last = cur
+		if cur.equal(synced) {
+			continue
+		}
+		if wait := o.Debounce - time.Since(lastChange); wait > 0 {
+			settle.Reset(wait)
+			continue
+		}
+		if synced, err = o.syncChanges(ctx, store, dst, synced, cur); err != nil {
+			return err
+		}
+	}
+}
+
+// syncChanges uploads the files changed between synced and cur and, with
+// --delete, deletes the objects of removed files. It returns the new
+// synced snapshot:
+// cur, except that a file whose transfer failed keeps its old entry so
+// the next batch retries it. An exceeded --max-delete aborts the watch.
+func (o *Options) syncChanges(ctx context.Context, store *storage.Storage, dst *storage.StorageURL, synced, cur watchSnapshot) (watchSnapshot, error) {
+	changed, removed := synced.diff(cur)
+	if !o.Delete {
+		removed = nil
+	}
+	if err := o.Guard.Check(len(removed), len(synced)); err != nil {
+		return synced, err
+	}
+
+	batch := append(changed[:len(changed):len(changed)], removed...)
+	transfer := o.transferTask(ctx, store)
+	// Every task writes only its own slot; the slice is read after
+	// waiter.Wait().
+	done := make([]bool, len(batch))
+	waiter := parallel.NewWaiter()
+	ec := cliutil.NewErrorCollector("sync")
+	drainDone := ec.Drain(waiter)
+	submit := func(i int, task parallel.Task) {
+		parallel.Run(func() error {
+			if err := task(); err != nil {
+				return err
+			}
+			done[i] = true
+			return nil
+		}, waiter)
+	}
+	for i, obj := range batch {
+		if ctx.Err() != nil || parallel.Stopped() {
+			break
+		}
+		dstURL, err := generateDestinationURL(obj.StorageURL, dst, true, true)
+		if err != nil {
+			ec.Collect(err)
+			continue
+		}
+		target := &storage.Object{StorageURL: dstURL}
+		if i >= len(changed) {
+			submit(i, o.deleteTask(ctx, store, target))
+			continue
+		}
+		task := transfer(obj, dstURL, nil)
+		if _, existed := synced[obj.StorageURL.Absolute()]; existed && o.Guard.BackupEnabled() {
+			upload := task
+			task = func() error {
+				if err := o.Guard.Backup(ctx, store, "sync", target); err != nil {
+					return err
+				}
+				return upload()
+			}
+		}
+		submit(i, task)
+	}
+	waiter.Wait()
+	drainDone()
+
+	next := make(watchSnapshot, len(cur))
+	for path, obj := range cur {
+		next[path] = obj
+	}
+	for i, obj := range batch {
+		if done[i] {
+			continue
+		}
+		path := obj.StorageURL.Absolute()
+		if old, ok := synced[path]; ok {
+			next[path] = old
+		} else {
+			delete(next, path)
+		}
+	}
+	return next, nil
+}
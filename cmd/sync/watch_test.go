@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+func watchObj(t *testing.T, path string, size int64, mod time.Time) *storage.Object {
+	t.Helper()
+	return &storage.Object{StorageURL: mustURL(t, path, ""), Size: size, ModTime: &mod}
+}
+
+func paths(objs []*storage.Object) []string {
+	out := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		out = append(out, obj.StorageURL.Absolute())
+	}
+	return out
+}
+
+// TestWatchSnapshotDiff verifies that only new and re-stat'ed files are
+// uploaded and that vanished files are reported as removed.
+func TestWatchSnapshotDiff(t *testing.T) {
+	t0 := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	synced := watchSnapshot{
+		"/w/same.txt":    watchObj(t, "/w/same.txt", 1, t0),
+		"/w/grown.txt":   watchObj(t, "/w/grown.txt", 1, t0),
+		"/w/touched.txt": watchObj(t, "/w/touched.txt", 1, t0),
+		"/w/gone.txt":    watchObj(t, "/w/gone.txt", 1, t0),
+	}
+	cur := watchSnapshot{
+		"/w/same.txt":    watchObj(t, "/w/same.txt", 1, t0),
+		"/w/grown.txt":   watchObj(t, "/w/grown.txt", 2, t0),
+		"/w/touched.txt": watchObj(t, "/w/touched.txt", 1, t0.Add(time.Second)),
+		"/w/new.txt":     watchObj(t, "/w/new.txt", 1, t0),
+	}
+
+	changed, removed := synced.diff(cur)
+	if want := []string{"/w/grown.txt", "/w/new.txt", "/w/touched.txt"}; !reflect.DeepEqual(paths(changed), want) {
+		t.Errorf("changed = %v, want %v", paths(changed), want)
+	}
+	if want := []string{"/w/gone.txt"}; !reflect.DeepEqual(paths(removed), want) {
+		t.Errorf("removed = %v, want %v", paths(removed), want)
+	}
+	if synced.equal(cur) || !cur.equal(cur) {
+		t.Errorf("equal should compare paths and stat info")
+	}
+}
+
+func TestWatchSnapshotDirs(t *testing.T) {
+	root := filepath.FromSlash("/w")
+	snap := watchSnapshot{
+		filepath.FromSlash("/w/a.txt"):     nil,
+		filepath.FromSlash("/w/x/y/b.txt"): nil,
+		filepath.FromSlash("/w/x/c.txt"):   nil,
+	}
+	got := snap.dirs(root)
+	sort.Strings(got)
+	want := []string{filepath.FromSlash("/w"), filepath.FromSlash("/w/x"), filepath.FromSlash("/w/x/y")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %v, want %v", got, want)
+	}
+}
+
+// TestObjectErrors verifies that the initial sync of --watch only keeps
+// watching after failures of single objects.
+func TestObjectErrors(t *testing.T) {
+	read := &errorpkg.Error{Op: "cp", Src: "/w/a.txt", Dst: "s3://b/a.txt", Err: errors.New("permission denied")}
+	backup := &errorpkg.Error{Op: "sync", Src: "s3://b/c.txt", Err: fmt.Errorf("backup: %w", errors.New("denied"))}
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{read, true},
+		{errors.Join(read, backup), true},
+		{errors.Join(read, errors.New("refusing to delete 5 objects: exceeds --max-delete 1")), false},
+		{errors.New("list s3://b/: access denied"), false},
+	} {
+		if got := objectErrors(tc.err); got != tc.want {
+			t.Errorf("objectErrors(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/LinPr/s6cmd/internal/cliutil"
 	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
 )
@@ -71,8 +72,9 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 		return err
 	}
 
+	jsonOutput := o.common.Output == "json"
 	if !url.IsRemote() {
-		return printLocalTree(url.Path, out)
+		return printLocalTree(url.Path, jsonOutput, out)
 	}
 
 	store, err := cliutil.NewStorage(ctx, o.common)
@@ -84,20 +86,26 @@ func (o *Options) run(ctx context.Context, out io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return printS3Tree(url.Path, keys, out)
+	return printS3Tree(url.Path, keys, jsonOutput, out)
 }
 
 type treeNode struct {
 	name     string
 	children map[string]*treeNode
+	// dir is set for a local directory, even an empty one; a node with
+	// children is a directory either way.
+	dir bool
 }
 
 func newTreeNode(name string) *treeNode {
 	return &treeNode{name: name, children: map[string]*treeNode{}}
 }
 
-func (n *treeNode) add(parts []string) {
+// add adds the path of parts below n; dir reports whether its last
+// element is a directory.
+func (n *treeNode) add(parts []string, dir bool) {
 	if len(parts) == 0 {
+		n.dir = n.dir || dir
 		return
 	}
 	child, ok := n.children[parts[0]]
@@ -105,16 +113,20 @@ func (n *treeNode) add(parts []string) {
 		child = newTreeNode(parts[0])
 		n.children[parts[0]] = child
 	}
-	child.add(parts[1:])
+	child.add(parts[1:], dir)
 }
 
-func (n *treeNode) print(prefix string, out io.Writer) {
+func (n *treeNode) sortedKeys() []string {
 	keys := make([]string, 0, len(n.children))
 	for k := range n.children {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
+	return keys
+}
 
+func (n *treeNode) print(prefix string, out io.Writer) {
+	keys := n.sortedKeys()
 	for i, k := range keys {
 		child := n.children[k]
 		connector := "├── "
@@ -128,7 +140,36 @@ func (n *treeNode) print(prefix string, out io.Writer) {
 	}
 }
 
-func printS3Tree(prefix string, keys []string, out io.Writer) error {
+// printJSON prints one treeMessage per node below n, depth first in name
+// order; dir is the slash path of n relative to the root.
+func (n *treeNode) printJSON(dir string, depth int, out io.Writer) {
+	for _, k := range n.sortedKeys() {
+		child := n.children[k]
+		if k == "" {
+			// The empty last element of a key ending in "/".
+			continue
+		}
+		msg := treeMessage{Path: dir + k, Type: "file", Depth: depth}
+		if child.dir || len(child.children) > 0 {
+			msg.Type = "directory"
+		}
+		fmt.Fprintln(out, msg.JSON())
+		child.printJSON(dir+k+"/", depth+1, out)
+	}
+}
+
+// treeMessage is one file or directory of the tree with --output json.
+// Path is relative to the target and Depth is 1 for its entries.
+type treeMessage struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`
+	Depth int    `json:"depth"`
+}
+
+func (m treeMessage) String() string { return m.Path }
+func (m treeMessage) JSON() string   { return strutil.SchemaJSON("tree.node/v1", m) }
+
+func printS3Tree(prefix string, keys []string, jsonOutput bool, out io.Writer) error {
 	root := newTreeNode(".")
 	cleanPrefix := strings.TrimPrefix(prefix, "/")
 	for _, key := range keys {
@@ -138,18 +179,26 @@ func printS3Tree(prefix string, keys []string, out io.Writer) error {
 			continue
 		}
 		parts := strings.Split(trimmed, "/")
-		root.add(parts)
+		root.add(parts, false)
+	}
+	if jsonOutput {
+		root.printJSON("", 1, out)
+		return nil
 	}
 	root.print("", out)
 	return nil
 }
 
-func printLocalTree(rootPath string, out io.Writer) error {
+func printLocalTree(rootPath string, jsonOutput bool, out io.Writer) error {
 	info, err := os.Stat(rootPath)
 	if err != nil {
 		return err
 	}
 	if !info.IsDir() {
+		if jsonOutput {
+			fmt.Fprintln(out, treeMessage{Path: filepath.Base(rootPath), Type: "file", Depth: 1}.JSON())
+			return nil
+		}
 		fmt.Fprintln(out, filepath.Base(rootPath))
 		return nil
 	}
@@ -167,12 +216,16 @@ func printLocalTree(rootPath string, out io.Writer) error {
 			return err
 		}
 		parts := strings.Split(filepath.ToSlash(rel), "/")
-		root.add(parts)
+		root.add(parts, d.IsDir())
 		return nil
 	}); err != nil {
 		return err
 	}
 
+	if jsonOutput {
+		root.printJSON("", 1, out)
+		return nil
+	}
 	fmt.Fprintln(out, root.name)
 	root.print("", out)
 	return nil
@@ -0,0 +1,46 @@
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/LinPr/s6cmd/internal/etag"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// remoteDigest digests the remote object with partSize parts. The
+// multipart downloader issues one ranged GET per part, up to concurrency
+// at a time, and streams each part straight into the digest.
+func remoteDigest(ctx context.Context, store *storage.Storage, url *storage.StorageURL, size, partSize int64, concurrency int) (*etag.Digest, error) {
+	d := etag.NewDigest(size, partSize)
+	if size == 0 {
+		return d, nil
+	}
+	n, err := store.Get(ctx, url, d, concurrency, d.PartSize())
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		return nil, fmt.Errorf("read %d of %d bytes", n, size)
+	}
+	return d, nil
+}
+
+// localSHA256 returns the base64 SHA-256 of the local file, the encoding
+// S3 uses for stored full-object checksums.
+func localSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,18 @@
+package verify
+
+const verify_examples = `Example 1: Verify a local tree against an S3 prefix after an upload
+
+         s6cmd verify ./local-dir/ s3://bucket/prefix/
+
+Example 2: Verify two prefixes after a server-side migration
+
+         s6cmd verify s3://old-bucket/data/ s3://new-bucket/data/
+
+Example 3: Ignore ETags and read both sides, hashing 8 parts of 64 MiB at a time
+
+         s6cmd verify --stream --concurrency 8 --part-size 64 ./backup/ s3://bucket/backup/
+
+Example 4: Verify a single object and print the report as JSON lines
+
+         s6cmd --output json verify ./video.mp4 s3://bucket/videos/
+`
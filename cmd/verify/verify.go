@@ -0,0 +1,574 @@
+// Package verify implements the `s6cmd verify` command. verify proves that
+// a destination holds the same bytes as its source after a migration: it
+// walks both sides (a local tree and an S3 prefix, or two S3 prefixes),
+// pairs objects by their path relative to the roots, and compares the
+// content of every pair on the parallel.Manager.
+//
+// Each pair is compared with the cheapest evidence that settles it:
+//
+//  1. Sizes: a size difference is a mismatch without reading anything.
+//  2. ETags: a remote ETag is reproduced from the local file with
+//     etag.Match, which auto-detects the part size of multipart "-N"
+//     ETags. Two remote ETags that are equal settle the pair outright.
+//  3. Stored checksums: a full-object SHA-256 recorded on the object is
+//     compared against the other side's SHA-256.
+//  4. Streaming: both sides are read and digested with --part-size parts.
+//     Remote objects are fetched with ranged GETs, --concurrency parts at
+//     a time, and local files are read with positioned reads in parallel.
+//
+// An ETag that disagrees is never trusted as proof of a mismatch (SSE-KMS
+// and SSE-C ETags are not MD5s), so the pair falls through to the next
+// step. --stream skips steps 2 and 3 and always reads both sides.
+//
+// The report lists every pair that did not verify: "mismatch" (content
+// differs), "missing" (in the source only), "extra" (in the destination
+// only) and "unreadable" (listing, HEAD or read failed). Any entry makes
+// the command exit non-zero.
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/etag"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+// NewVerifyCmd creates the `verify` command.
+func NewVerifyCmd() *cobra.Command {
+	o := newOptions()
+	cmd := cobra.Command{
+		Use:     "verify [flags] <source> <destination>",
+		Short:   "verify that destination content matches source",
+		Example: verify_examples,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd, args); err != nil {
+				return err
+			}
+			if err := o.validate(); err != nil {
+				return err
+			}
+			return o.run(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.Stream, "stream", false, "always compare by reading both sides, ignoring ETags and stored checksums")
+	cmd.Flags().IntVar(&o.Concurrency, "concurrency", cliutil.DefaultCopyConcurrency, "number of parts of one object hashed in parallel")
+	cmd.Flags().IntVar(&o.PartSizeMiB, "part-size", cliutil.DefaultPartSizeMiB, "size of each hashed part, in MiB; match the part size used for the upload so multipart ETags can be compared")
+	cmd.Flags().StringSliceVar(&o.Exclude, "exclude", nil, "exclude objects with given pattern (repeatable)")
+	cmd.Flags().StringSliceVar(&o.Include, "include", nil, "include objects with given pattern (repeatable)")
+	cmd.Flags().BoolVar(&o.NoFollowSymlinks, "no-follow-symlinks", false, "do not follow symbolic links")
+
+	return &cmd
+}
+
+// Args holds the positional arguments.
+type Args struct {
+	Source      string `validate:"required"`
+	Destination string `validate:"required"`
+}
+
+// Flags holds the verify-specific flags.
+type Flags struct {
+	Stream           bool
+	Concurrency      int
+	PartSizeMiB      int
+	Exclude          []string
+	Include          []string
+	NoFollowSymlinks bool
+}
+
+// Options is the closure of Args + Flags + CommonFlags.
+type Options struct {
+	Args
+	Flags
+	common cliutil.CommonFlags
+}
+
+func newOptions() *Options {
+	return &Options{}
+}
+
+func (o *Options) complete(cmd *cobra.Command, args []string) error {
+	o.Source = args[0]
+	o.Destination = args[1]
+	o.common = cliutil.LoadParentFlags(cmd)
+	return nil
+}
+
+func (o *Options) validate() error {
+	if err := validator.New().Struct(o.Args); err != nil {
+		return err
+	}
+	if o.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, got %d", o.Concurrency)
+	}
+	return nil
+}
+
+// jsonOutput reports whether --output json is in effect.
+func (o *Options) jsonOutput() bool {
+	return o.common.Output == "json"
+}
+
+func (o *Options) partSize() int64 {
+	return cliutil.PartSizeBytesFromMiB(o.PartSizeMiB)
+}
+
+func (o *Options) run(ctx context.Context, out io.Writer) error {
+	srcURL, err := storage.NewStorageURL(o.Source)
+	if err != nil {
+		return err
+	}
+	dstURL, err := storage.NewStorageURL(o.Destination)
+	if err != nil {
+		return err
+	}
+	for _, u := range []*storage.StorageURL{srcURL, dstURL} {
+		if u.IsWildcard() {
+			return fmt.Errorf("%q can not contain glob characters", u)
+		}
+	}
+
+	store, err := cliutil.NewStorage(ctx, o.common)
+	if err != nil {
+		return err
+	}
+
+	pairs, results, err := o.plan(ctx, store, srcURL, dstURL)
+	if err != nil {
+		return err
+	}
+
+	// Every task writes only its own slot, so the slice needs no lock;
+	// the results are read after waiter.Wait().
+	compared := make([]verifyResult, len(pairs))
+	waiter := parallel.NewWaiter()
+	ec := cliutil.NewErrorCollector("verify")
+	drainDone := ec.Drain(waiter)
+	for i, p := range pairs {
+		parallel.Run(func() error {
+			compared[i] = o.compare(ctx, store, p)
+			return ctx.Err()
+		}, waiter)
+	}
+	waiter.Wait()
+	drainDone()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := ec.Aggregate(); err != nil {
+		return err
+	}
+
+	results = append(results, compared...)
+	return o.report(out, results, len(pairs))
+}
+
+// verifyPair is one source object and the destination object under the
+// same relative path.
+type verifyPair struct {
+	key      string
+	src, dst *storage.Object
+}
+
+// plan lists both sides and pairs them by relative path. Keys present on
+// one side only, and listing failures, are returned as finished results.
+func (o *Options) plan(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL) ([]verifyPair, []verifyResult, error) {
+	srcTree, err := isTree(srcURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !srcTree {
+		// A single object is compared against the destination object,
+		// or against the same base name under a destination directory.
+		isDir, err := isTree(dstURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isDir {
+			dstURL = dstURL.Join(srcURL.Base())
+		}
+		src, err := store.Stat(ctx, srcURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		dst, err := store.Stat(ctx, dstURL)
+		if err != nil {
+			if errorpkg.IsWarning(err) {
+				return nil, []verifyResult{missingResult(src.StorageURL.Base(), src, dstURL)}, nil
+			}
+			return nil, []verifyResult{unreadableResult("", dstURL, err)}, nil
+		}
+		return []verifyPair{{key: srcURL.Base(), src: src, dst: dst}}, nil, nil
+	}
+
+	// A tree source makes the destination a tree too, even when it was
+	// given without a trailing slash.
+	if dstURL.IsRemote() && !dstURL.IsBucket() && !dstURL.IsPrefix() {
+		if dstURL, err = storage.NewStorageURL(o.Destination + "/"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	excludePatterns, err := cliutil.CompileExcludeIncludePatterns(o.Exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+	includePatterns, err := cliutil.CompileExcludeIncludePatterns(o.Include)
+	if err != nil {
+		return nil, nil, err
+	}
+	excluded := func(key string) bool {
+		return cliutil.IsObjectExcluded(key, excludePatterns, includePatterns)
+	}
+
+	followSymlinks := !o.NoFollowSymlinks
+	srcObjs, results := listTree(ctx, store, srcURL, followSymlinks, false, excluded)
+	dstObjs, dstResults := listTree(ctx, store, dstURL, followSymlinks, true, excluded)
+	results = append(results, dstResults...)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var pairs []verifyPair
+	for key, src := range srcObjs {
+		dst, ok := dstObjs[key]
+		if !ok {
+			results = append(results, missingResult(key, src, dstURL.Join(key)))
+			continue
+		}
+		pairs = append(pairs, verifyPair{key: key, src: src, dst: dst})
+	}
+	for key, dst := range dstObjs {
+		if _, ok := srcObjs[key]; !ok {
+			results = append(results, verifyResult{
+				Key:         key,
+				Status:      statusExtra,
+				Destination: dst.StorageURL.String(),
+			})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+	return pairs, results, nil
+}
+
+// isTree reports whether url names a directory or prefix rather than a
+// single object.
+func isTree(url *storage.StorageURL) (bool, error) {
+	if url.IsRemote() {
+		return url.IsBucket() || url.IsPrefix(), nil
+	}
+	return cliutil.IsLocalDir(url.Absolute())
+}
+
+// listTree collects every regular object under root keyed by its
+// slash-separated path relative to root. A missing destination is an
+// empty listing so every source key is reported as missing; other listing
+// errors become unreadable results.
+func listTree(ctx context.Context, store *storage.Storage, root *storage.StorageURL, followSymlinks, isDestination bool, excluded func(string) bool) (map[string]*storage.Object, []verifyResult) {
+	listURL := root
+	if root.IsRemote() {
+		listURL = root.Clone()
+		listURL.Delimiter = ""
+	}
+	objs := make(map[string]*storage.Object)
+	var results []verifyResult
+	for obj := range store.List(ctx, listURL, followSymlinks) {
+		if obj.Err != nil {
+			if errorpkg.IsCancelation(obj.Err) {
+				continue
+			}
+			if isDestination && errorpkg.IsWarning(obj.Err) {
+				// An empty prefix or a missing directory: every
+				// source key is reported as missing instead.
+				continue
+			}
+			results = append(results, unreadableResult("", root, obj.Err))
+			continue
+		}
+		if obj.Type.IsDir() {
+			continue
+		}
+		key := relativeKey(root, obj.StorageURL)
+		if key == "" || excluded(key) {
+			continue
+		}
+		objs[key] = obj
+	}
+	return objs, results
+}
+
+// relativeKey returns the path of u under root with forward slashes, the
+// form both sides are matched on.
+func relativeKey(root, u *storage.StorageURL) string {
+	if u.IsRemote() {
+		return strings.TrimPrefix(u.Path, root.Path)
+	}
+	rel, err := filepath.Rel(root.Absolute(), u.Absolute())
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// compare decides whether the two objects of a pair hold the same bytes.
+func (o *Options) compare(ctx context.Context, store *storage.Storage, p verifyPair) verifyResult {
+	res := verifyResult{
+		Key:         p.key,
+		Source:      p.src.StorageURL.String(),
+		Destination: p.dst.StorageURL.String(),
+	}
+	if p.src.Size != p.dst.Size {
+		res.Status, res.Method = statusMismatch, methodSize
+		res.Reason = fmt.Sprintf("size %d != %d", p.src.Size, p.dst.Size)
+		return res
+	}
+
+	if !o.Stream {
+		if matched, err := o.compareETags(ctx, p.src, p.dst); err != nil {
+			return res.unreadable(err)
+		} else if matched {
+			res.Status, res.Method = statusMatch, methodETag
+			return res
+		}
+		decided, matched, err := compareChecksums(ctx, store, p.src, p.dst)
+		if err != nil {
+			return res.unreadable(err)
+		}
+		if decided {
+			res.Method = methodChecksum
+			if matched {
+				res.Status = statusMatch
+			} else {
+				res.Status, res.Reason = statusMismatch, "sha256 checksums differ"
+			}
+			return res
+		}
+	}
+
+	srcSum, err := o.digest(ctx, store, p.src)
+	if err != nil {
+		return res.unreadable(err)
+	}
+	dstSum, err := o.digest(ctx, store, p.dst)
+	if err != nil {
+		return res.unreadable(err)
+	}
+	res.Method = methodStream
+	if srcSum == dstSum {
+		res.Status = statusMatch
+	} else {
+		res.Status = statusMismatch
+		res.Reason = fmt.Sprintf("content digest %s != %s", srcSum, dstSum)
+	}
+	return res
+}
+
+// compareETags reports whether the ETags prove the pair equal. It never
+// reports a mismatch: an ETag that is not an MD5 of the content cannot
+// prove the content differs.
+func (o *Options) compareETags(ctx context.Context, src, dst *storage.Object) (bool, error) {
+	srcRemote, dstRemote := src.StorageURL.IsRemote(), dst.StorageURL.IsRemote()
+	switch {
+	case srcRemote && dstRemote:
+		// Equal ETags come from identical parts, whatever part size
+		// the uploads used.
+		_, ok := etag.Parse(src.Etag)
+		return ok && src.Etag == dst.Etag, nil
+	case srcRemote != dstRemote:
+		remote, local := src, dst
+		if dstRemote {
+			remote, local = dst, src
+		}
+		matched, _, err := etag.Match(ctx, local.StorageURL.Absolute(), local.Size, remote.Etag, o.partSize())
+		if errors.Is(err, etag.ErrIncomparable) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read %s: %w", local.StorageURL, err)
+		}
+		return matched, nil
+	}
+	return false, nil
+}
+
+// compareChecksums compares stored full-object SHA-256 checksums. decided
+// is false when a remote side has no such checksum.
+func compareChecksums(ctx context.Context, store *storage.Storage, src, dst *storage.Object) (decided, matched bool, err error) {
+	objs := []*storage.Object{src, dst}
+	var sums [2]string
+	for i, obj := range objs {
+		if !obj.StorageURL.IsRemote() {
+			continue
+		}
+		_, md, err := store.HeadObject(ctx, obj.StorageURL)
+		if err != nil {
+			return false, false, err
+		}
+		if md.ChecksumSHA256 == "" || strings.Contains(md.ChecksumSHA256, "-") {
+			return false, false, nil
+		}
+		sums[i] = md.ChecksumSHA256
+	}
+	for i, obj := range objs {
+		if sums[i] != "" {
+			continue
+		}
+		if sums[i], err = localSHA256(obj.StorageURL.Absolute()); err != nil {
+			return false, false, err
+		}
+	}
+	return true, sums[0] == sums[1], nil
+}
+
+// digest returns the content digest of obj with --part-size parts.
+func (o *Options) digest(ctx context.Context, store *storage.Storage, obj *storage.Object) (string, error) {
+	var (
+		d   *etag.Digest
+		err error
+	)
+	if obj.StorageURL.IsRemote() {
+		d, err = remoteDigest(ctx, store, obj.StorageURL, obj.Size, o.partSize(), o.Concurrency)
+	} else {
+		d, err = etag.File(ctx, obj.StorageURL.Absolute(), obj.Size, o.partSize(), o.Concurrency)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", obj.StorageURL, err)
+	}
+	return d.Sum()
+}
+
+// report prints the results that did not verify, sorted by key, and fails
+// when there is at least one.
+func (o *Options) report(out io.Writer, results []verifyResult, compared int) error {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Status == statusMatch {
+			continue
+		}
+		if o.jsonOutput() {
+			fmt.Fprintln(out, verifyMessage(r))
+			continue
+		}
+		fmt.Fprintln(out, r.String())
+	}
+
+	failed := len(results) - counts[statusMatch]
+	log.Fprint(out, o.jsonOutput(), verifySummaryMessage{
+		Verified:   counts[statusMatch],
+		Total:      compared + counts[statusMissing] + counts[statusExtra],
+		Mismatched: counts[statusMismatch],
+		Missing:    counts[statusMissing],
+		Extra:      counts[statusExtra],
+		Unreadable: counts[statusUnreadable],
+	})
+	if failed > 0 {
+		return fmt.Errorf("verify: %d objects did not verify", failed)
+	}
+	return nil
+}
+
+// Result statuses.
+const (
+	statusMatch      = "match"
+	statusMismatch   = "mismatch"
+	statusMissing    = "missing"
+	statusExtra      = "extra"
+	statusUnreadable = "unreadable"
+)
+
+// Comparison methods, reported with each result.
+const (
+	methodSize     = "size"
+	methodETag     = "etag"
+	methodChecksum = "checksum"
+	methodStream   = "stream"
+)
+
+// verifyResult is the outcome for one key.
+type verifyResult struct {
+	Key         string `json:"key"`
+	Status      string `json:"status"`
+	Method      string `json:"method,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+func missingResult(key string, src *storage.Object, dst *storage.StorageURL) verifyResult {
+	return verifyResult{
+		Key:         key,
+		Status:      statusMissing,
+		Source:      src.StorageURL.String(),
+		Destination: dst.String(),
+	}
+}
+
+func unreadableResult(key string, url *storage.StorageURL, err error) verifyResult {
+	return verifyResult{
+		Key:    key,
+		Status: statusUnreadable,
+		Source: url.String(),
+		Reason: err.Error(),
+	}
+}
+
+func (r verifyResult) unreadable(err error) verifyResult {
+	r.Status, r.Method, r.Reason = statusUnreadable, "", err.Error()
+	return r
+}
+
+// String is the plain-text report line.
+func (r verifyResult) String() string {
+	line := r.Status
+	for _, s := range []string{r.Source, r.Destination} {
+		if s != "" {
+			line += " " + s
+		}
+	}
+	if r.Reason != "" {
+		line += ": " + r.Reason
+	}
+	return line
+}
+
+// verifyMessage is the per-line JSON payload when --output json is set.
+type verifyMessage verifyResult
+
+func (m verifyMessage) String() string { return m.JSON() }
+func (m verifyMessage) JSON() string   { return strutil.SchemaJSON("verify.result/v1", m) }
+
+// verifySummaryMessage is the count of objects per result, printed last.
+type verifySummaryMessage struct {
+	Verified   int `json:"verified"`
+	Total      int `json:"total"`
+	Mismatched int `json:"mismatched"`
+	Missing    int `json:"missing"`
+	Extra      int `json:"extra"`
+	Unreadable int `json:"unreadable"`
+}
+
+func (m verifySummaryMessage) String() string {
+	return fmt.Sprintf("\nverified %d of %d objects: %d mismatched, %d missing, %d extra, %d unreadable",
+		m.Verified, m.Total, m.Mismatched, m.Missing, m.Extra, m.Unreadable)
+}
+
+func (m verifySummaryMessage) JSON() string { return strutil.SchemaJSON("verify.summary/v1", m) }
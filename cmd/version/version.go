@@ -1,11 +1,13 @@
 // Package version implements the `s6cmd version` command: a flag-less
 // command that prints the build-time version string returned by
-// version.GetHumanVersion().
+// version.GetHumanVersion(), or the version and commit as JSON with
+// --output json.
 package version
 
 import (
-	"fmt"
-
+	"github.com/LinPr/s6cmd/internal/cliutil"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/strutil"
 	"github.com/LinPr/s6cmd/version"
 	"github.com/spf13/cobra"
 )
@@ -18,9 +20,19 @@ func NewVersionCmd() *cobra.Command {
 		Example: version_examples,
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Fprintln(cmd.OutOrStdout(), version.GetHumanVersion())
+			jsonOutput := cliutil.LoadParentFlags(cmd).Output == "json"
+			log.Fprint(cmd.OutOrStdout(), jsonOutput, versionMessage{Version: version.Version, GitCommit: version.GitCommit})
 			return nil
 		},
 	}
 	return cmd
 }
+
+// versionMessage is the build-time version metadata.
+type versionMessage struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+}
+
+func (m versionMessage) String() string { return version.GetHumanVersion() }
+func (m versionMessage) JSON() string   { return strutil.SchemaJSON("version/v1", m) }
@@ -0,0 +1,129 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestE2E_Bisync verifies that bisync propagates creates, updates and
+// deletes in both directions between a local directory and an S3 prefix,
+// using the state saved by the previous run.
+func TestE2E_Bisync(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	dir := filepath.Join(workdir, "dir")
+	state := filepath.Join(workdir, "bisync.json")
+	remote := "s3://" + bucket + "/p/"
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+	putObject(t, client, bucket, "p/c.txt", "c")
+
+	res := runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, dir, remote)
+	if res.ExitCode != 0 {
+		t.Fatalf("first bisync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "p/sub/b.txt"); got != "b" {
+		t.Errorf("p/sub/b.txt = %q, want %q", got, "b")
+	}
+	if got := fileContent(t, filepath.Join(dir, "c.txt")); got != "c" {
+		t.Errorf("local c.txt = %q, want %q", got, "c")
+	}
+
+	// One change of each kind on each side.
+	if err := os.Remove(filepath.Join(dir, "sub", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "d.txt"), "d")
+	putObject(t, client, bucket, "p/c.txt", "c changed remotely")
+	putObject(t, client, bucket, "p/e.txt", "e")
+
+	res = runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, dir, remote)
+	if res.ExitCode != 0 {
+		t.Fatalf("second bisync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if objectExists(t, client, bucket, "p/sub/b.txt") {
+		t.Errorf("the local deletion of sub/b.txt should have been propagated")
+	}
+	if got := objectContent(t, client, bucket, "p/d.txt"); got != "d" {
+		t.Errorf("p/d.txt = %q, want %q", got, "d")
+	}
+	if got := fileContent(t, filepath.Join(dir, "c.txt")); got != "c changed remotely" {
+		t.Errorf("local c.txt = %q, want the remote update", got)
+	}
+	if got := fileContent(t, filepath.Join(dir, "e.txt")); got != "e" {
+		t.Errorf("local e.txt = %q, want %q", got, "e")
+	}
+
+	// A third run has nothing to do.
+	res = runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, dir, remote)
+	if res.ExitCode != 0 || strings.TrimSpace(res.Stdout) != "" {
+		t.Errorf("an in-step bisync should be silent, got exit %d: %s\nstderr: %s", res.ExitCode, res.Stdout, res.Stderr)
+	}
+}
+
+// TestE2E_BisyncConflict verifies the fail and keep-both conflict
+// policies for a file changed on both sides.
+func TestE2E_BisyncConflict(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	dir := filepath.Join(workdir, "dir")
+	state := filepath.Join(workdir, "bisync.json")
+	remote := "s3://" + bucket + "/"
+	writeFile(t, filepath.Join(dir, "a.txt"), "base")
+
+	res := runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, dir, remote)
+	if res.ExitCode != 0 {
+		t.Fatalf("first bisync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+
+	// The local edit is an hour older than the remote one.
+	local := filepath.Join(dir, "a.txt")
+	writeFile(t, local, "local edit")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(local, past, past); err != nil {
+		t.Fatal(err)
+	}
+	putObject(t, client, bucket, "a.txt", "remote edit")
+
+	res = runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, "--conflict", "fail", dir, remote)
+	if res.ExitCode == 0 {
+		t.Fatalf("--conflict fail should exit non-zero on a conflict")
+	}
+	if got := fileContent(t, local); got != "local edit" {
+		t.Errorf("--conflict fail must leave the local file untouched, got %q", got)
+	}
+	if got := objectContent(t, client, bucket, "a.txt"); got != "remote edit" {
+		t.Errorf("--conflict fail must leave the object untouched, got %q", got)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "bisync", "--state-file", state, "--conflict", "keep-both", dir, remote)
+	if res.ExitCode != 0 {
+		t.Fatalf("bisync --conflict keep-both failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := fileContent(t, local); got != "remote edit" {
+		t.Errorf("local a.txt = %q, want the newer remote edit", got)
+	}
+	kept, err := filepath.Glob(filepath.Join(dir, "a.conflict-*.txt"))
+	if err != nil || len(kept) != 1 {
+		t.Fatalf("want one local conflict copy, got %v (%v)", kept, err)
+	}
+	if got := fileContent(t, kept[0]); got != "local edit" {
+		t.Errorf("local conflict copy = %q, want the older local edit", got)
+	}
+	if got := objectContent(t, client, bucket, filepath.Base(kept[0])); got != "local edit" {
+		t.Errorf("remote conflict copy = %q, want the older local edit", got)
+	}
+}
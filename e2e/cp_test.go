@@ -1,8 +1,11 @@
 package e2e
 
 import (
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -164,3 +167,214 @@ func TestE2E_CopyPrefixRequiresRecursive(t *testing.T) {
 		t.Errorf("nested/b.txt = %q, want %q", got, "b")
 	}
 }
+
+// TestE2E_CopyFanOut copies a local directory to two buckets in one run,
+// the second given with --to, then copies an object server-side to two
+// prefixes. A missing destination bucket fails only its own copies.
+func TestE2E_CopyFanOut(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	one := s3BucketFromTestName(t) + "-one"
+	two := s3BucketFromTestName(t) + "-two"
+	createBucket(t, client, one)
+	createBucket(t, client, two)
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dir", "a.txt"), "a")
+	writeFile(t, filepath.Join(workdir, "dir", "sub", "b.txt"), "bb")
+
+	res := runS6cmd(t, workdir, endpoint, "cp", "--recursive", filepath.Join(workdir, "dir")+"/", "s3://"+one+"/", "--to", "s3://"+two+"/backup/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for _, obj := range []struct{ bucket, key, content string }{
+		{one, "a.txt", "a"}, {one, "sub/b.txt", "bb"},
+		{two, "backup/a.txt", "a"}, {two, "backup/sub/b.txt", "bb"},
+	} {
+		if got := objectContent(t, client, obj.bucket, obj.key); got != obj.content {
+			t.Errorf("s3://%s/%s = %q, want %q", obj.bucket, obj.key, got, obj.content)
+		}
+	}
+
+	missing := s3BucketFromTestName(t) + "-missing"
+	res = runS6cmd(t, workdir, endpoint, "cp", "s3://"+one+"/a.txt", "s3://"+two+"/x/", "s3://"+missing+"/", "s3://"+one+"/y/")
+	if res.ExitCode == 0 {
+		t.Fatalf("cp to a missing bucket succeeded: %s", res.Stdout)
+	}
+	for _, obj := range []struct{ bucket, key string }{{two, "x/a.txt"}, {one, "y/a.txt"}} {
+		if got := objectContent(t, client, obj.bucket, obj.key); got != "a" {
+			t.Errorf("s3://%s/%s = %q, want the copied object", obj.bucket, obj.key, got)
+		}
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "cp", filepath.Join(workdir, "dir", "a.txt"), "s3://"+one+"/", filepath.Join(workdir, "out"))
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "requires s3:// destinations") {
+		t.Errorf("cp to a local and a remote destination = %d\nstderr: %s", res.ExitCode, res.Stderr)
+	}
+}
+
+// TestE2E_CopyFailedOut verifies that the objects a cp could not write are
+// listed in --failed-out and that `s6cmd run` of that file copies exactly
+// them.
+func TestE2E_CopyFailedOut(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dir", "a.txt"), "a")
+	writeFile(t, filepath.Join(workdir, "dir", "it's b.txt"), "bb")
+	failed := filepath.Join(workdir, "failed.txt")
+
+	// The bucket does not exist yet: NoSuchBucket is not transient, so
+	// every object fails without retries.
+	res := runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--failed-out", failed, filepath.Join(workdir, "dir")+"/", "s3://"+bucket+"/")
+	if res.ExitCode == 0 {
+		t.Fatalf("cp to a missing bucket succeeded: %s", res.Stdout)
+	}
+	content, err := os.ReadFile(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("failed-out has %d lines, want 2:\n%s", len(lines), content)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "cp ") {
+			t.Errorf("failed-out line %q is not a cp command", line)
+		}
+	}
+
+	createBucket(t, client, bucket)
+	res = runS6cmd(t, workdir, endpoint, "run", failed)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd run failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]string{"a.txt": "a", "it's b.txt": "bb"} {
+		if got := objectContent(t, client, bucket, key); got != want {
+			t.Errorf("s3://%s/%s = %q, want %q", bucket, key, got, want)
+		}
+	}
+}
+
+// TestE2E_CopyReport verifies that --report records one entry per object
+// with the ETag of the object written, and a --no-clobber skip as skipped.
+func TestE2E_CopyReport(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+	putObject(t, client, bucket, "a.txt", "old")
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dir", "a.txt"), "a")
+	writeFile(t, filepath.Join(workdir, "dir", "b.txt"), "bb")
+	reportFile := filepath.Join(workdir, "report.jsonl")
+
+	res := runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--no-clobber", "--report", reportFile, filepath.Join(workdir, "dir")+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	content, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		Operation   string `json:"operation"`
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Size        int64  `json:"size"`
+		ETag        string `json:"etag"`
+		Attempts    int    `json:"attempts"`
+		Status      string `json:"status"`
+	}
+	got := map[string]entry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("report line %q: %v", line, err)
+		}
+		got[e.Destination] = e
+	}
+	if len(got) != 2 {
+		t.Fatalf("report has %d entries, want 2:\n%s", len(got), content)
+	}
+	a, b := got["s3://"+bucket+"/a.txt"], got["s3://"+bucket+"/b.txt"]
+	if a.Status != "skipped" || a.Attempts != 1 {
+		t.Errorf("a.txt entry = %+v, want a skipped attempt", a)
+	}
+	if b.Status != "ok" || b.Operation != "cp" || b.Size != 2 || b.ETag == "" || b.Source != filepath.Join(workdir, "dir", "b.txt") {
+		t.Errorf("b.txt entry = %+v, want ok with size 2 and the ETag", b)
+	}
+}
+
+// TestE2E_CopyProgressJSON verifies the --progress-json events written to
+// an inherited file descriptor: each object's start and end, a skipped
+// object reported as failed, and the totals of the done event.
+func TestE2E_CopyProgressJSON(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+	putObject(t, client, bucket, "a.txt", "old")
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dir", "a.txt"), "a")
+	writeFile(t, filepath.Join(workdir, "dir", "b.txt"), "bb")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cmd := s6cmdCommand(t, workdir, []string{"--endpoint-url", endpoint, "--path-style",
+		"cp", "--recursive", "--no-clobber", "--progress-json", "3", filepath.Join(workdir, "dir") + "/", "s3://" + bucket + "/"})
+	cmd.ExtraFiles = []*os.File{w}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	events, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("s6cmd cp failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	type event struct {
+		Event            string `json:"event"`
+		Name             string `json:"name"`
+		Bytes            int64  `json:"bytes"`
+		CompletedObjects int64  `json:"completed_objects"`
+		TotalObjects     int64  `json:"total_objects"`
+		CompletedBytes   int64  `json:"completed_bytes"`
+		TotalBytes       int64  `json:"total_bytes"`
+	}
+	var got []string
+	var last event
+	for _, line := range strings.Split(strings.TrimSpace(string(events)), "\n") {
+		last = event{}
+		if err := json.Unmarshal([]byte(line), &last); err != nil {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		if last.Name != "" && last.Event != "object_progress" {
+			got = append(got, last.Event+" "+last.Name)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"object_failed a.txt", "object_finished b.txt", "object_started a.txt", "object_started b.txt"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("object events = %q, want %q\n%s", got, want, events)
+	}
+	if last.Event != "done" || last.CompletedObjects != 1 || last.TotalObjects != 2 || last.CompletedBytes != 2 || last.TotalBytes != 3 {
+		t.Errorf("last event = %+v, want done with 1/2 objects and 2/3 bytes", last)
+	}
+}
@@ -0,0 +1,122 @@
+package e2e
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestE2E_FilterListingCommands verifies that ls, du, cp and rm apply the
+// same --filter expression to the objects they list.
+func TestE2E_FilterListingCommands(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "data/big.parquet", strings.Repeat("x", 2048))
+	putObject(t, client, bucket, "data/small.parquet", "small")
+	putObject(t, client, bucket, "data/sub/big.csv", strings.Repeat("y", 4096))
+
+	const expr = `size > 1KiB && mtime > now-1h && class == "STANDARD"`
+	workdir := t.TempDir()
+
+	res := runS6cmd(t, workdir, endpoint, "ls", "--recursive", "--filter", expr, "s3://"+bucket+"/data/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd ls --filter failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "data/big.parquet") || !strings.Contains(res.Stdout, "data/sub/big.csv") ||
+		strings.Contains(res.Stdout, "small.parquet") {
+		t.Errorf("ls --filter = %q, want the two big objects only", res.Stdout)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "du", "--filter", expr+` && name =~ "\\.parquet$"`, "s3://"+bucket+"/data/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd du --filter failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "2048 bytes in 1 objects") {
+		t.Errorf("du --filter = %q, want 2048 bytes in 1 objects", res.Stdout)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--filter", `path =~ "^sub/" || size < 1K`,
+		"s3://"+bucket+"/data/", "s3://"+bucket+"/copy/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp --filter failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"copy/sub/big.csv":      true,
+		"copy/small.parquet":    true,
+		"copy/big.parquet":      false,
+		"data/small.parquet":    true,
+		"data/sub/big.csv":      true,
+		"data/big.parquet":      true,
+		"copy/data/big.parquet": false,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("after cp: %s exists = %v, want %v", key, got, want)
+		}
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "rm", "--recursive", "--filter", "!(size > 1KiB)", "s3://"+bucket+"/data/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd rm --filter failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"data/small.parquet": false,
+		"data/big.parquet":   true,
+		"data/sub/big.csv":   true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("after rm: %s exists = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// TestE2E_FilterSync verifies that sync skips the sources --filter does not
+// match and, like --exclude, keeps their destination counterparts on
+// --delete.
+func TestE2E_FilterSync(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "keep.log", "old")
+	putObject(t, client, bucket, "stale.txt", "stale")
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "report.csv"), strings.Repeat("r", 100))
+	writeFile(t, filepath.Join(srcDir, "keep.log"), "new content")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--filter", `name !~ "\\.log$"`, srcDir+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --filter failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !objectExists(t, client, bucket, "report.csv") {
+		t.Errorf("report.csv should have been uploaded")
+	}
+	if got := objectContent(t, client, bucket, "keep.log"); got != "old" {
+		t.Errorf("keep.log = %q, want the filtered-out source left alone", got)
+	}
+	if objectExists(t, client, bucket, "stale.txt") {
+		t.Errorf("stale.txt should have been deleted by --delete")
+	}
+}
+
+// TestE2E_FilterParseError verifies that an invalid expression fails the
+// command before anything is listed, pointing at the offending column.
+func TestE2E_FilterParseError(t *testing.T) {
+	t.Parallel()
+	workdir := t.TempDir()
+	res := runS6cmdRaw(t, workdir, []string{"du", "--filter", "size > 1X", "s3://bucket/"})
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, `column 8: unknown size unit "X"`) {
+		t.Errorf("du with a bad --filter: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	res = runS6cmdRaw(t, workdir, []string{"sync", "--watch", "--delete", "--yes", "--filter", "size > 1", "src", "s3://bucket/"})
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "can not be combined with --filter") {
+		t.Errorf("sync --watch --delete --filter: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
@@ -0,0 +1,131 @@
+package e2e
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestE2E_FilterRulesCopyAndRemove verifies that cp and rm follow the
+// ordered --filter-from rules: the first matching rule wins, "+ */" keeps
+// directories open, and anchored and directory-only rules drop whole
+// subtrees.
+func TestE2E_FilterRulesCopyAndRemove(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	for _, name := range []string{
+		"main.go",
+		"README.md",
+		"cmd/tool/tool.go",
+		"cmd/tool/notes.txt",
+		"vendor/lib/lib.go",
+		"docs/guide.md",
+		"docs/img/logo.png",
+		"internal/vendor/x.go",
+	} {
+		writeFile(t, filepath.Join(srcDir, filepath.FromSlash(name)), name)
+	}
+	rules := filepath.Join(workdir, "rules.txt")
+	writeFile(t, rules, `# Go sources and docs only
+- /vendor/
++ */
++ *.go
+; the whole docs tree
++ /docs/***
+- *
+`)
+
+	res := runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--filter-from", rules, srcDir+"/", "s3://"+bucket+"/src/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp --filter-from failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"src/main.go":              true,
+		"src/README.md":            false,
+		"src/cmd/tool/tool.go":     true,
+		"src/cmd/tool/notes.txt":   false,
+		"src/vendor/lib/lib.go":    false,
+		"src/docs/guide.md":        true,
+		"src/docs/img/logo.png":    true,
+		"src/internal/vendor/x.go": true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("after cp: %s exists = %v, want %v", key, got, want)
+		}
+	}
+
+	// rm removes what the rules keep: everything but the docs.
+	keep := filepath.Join(workdir, "keep.txt")
+	writeFile(t, keep, "- docs/\n")
+	res = runS6cmd(t, workdir, endpoint, "rm", "--recursive", "--filter-from", keep, "s3://"+bucket+"/src/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd rm --filter-from failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"src/main.go":           false,
+		"src/cmd/tool/tool.go":  false,
+		"src/docs/guide.md":     true,
+		"src/docs/img/logo.png": true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("after rm: %s exists = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// TestE2E_FilterRulesSync verifies that sync skips the sources the rules
+// exclude and keeps their destination counterparts on --delete.
+func TestE2E_FilterRulesSync(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "build/out.bin", "old")
+	putObject(t, client, bucket, "stale.txt", "stale")
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "app.txt"), "app")
+	writeFile(t, filepath.Join(srcDir, "build", "out.bin"), "new")
+	writeFile(t, filepath.Join(srcDir, "sub", "scratch.tmp"), "tmp")
+	rules := filepath.Join(workdir, "rules.txt")
+	writeFile(t, rules, "- /build/\n- *.tmp\n")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--filter-from", rules, srcDir+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --filter-from failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !objectExists(t, client, bucket, "app.txt") {
+		t.Errorf("app.txt should have been uploaded")
+	}
+	if objectExists(t, client, bucket, "sub/scratch.tmp") {
+		t.Errorf("sub/scratch.tmp should have been excluded")
+	}
+	if got := objectContent(t, client, bucket, "build/out.bin"); got != "old" {
+		t.Errorf("build/out.bin = %q, want the excluded directory left alone", got)
+	}
+	if objectExists(t, client, bucket, "stale.txt") {
+		t.Errorf("stale.txt should have been deleted by --delete")
+	}
+}
+
+// TestE2E_FilterRulesParseError verifies that a malformed rule file fails
+// the command with the file and line of the bad rule.
+func TestE2E_FilterRulesParseError(t *testing.T) {
+	t.Parallel()
+	workdir := t.TempDir()
+	rules := filepath.Join(workdir, "rules.txt")
+	writeFile(t, rules, "+ *.go\n*.txt\n")
+	res := runS6cmdRaw(t, workdir, []string{"du", "--filter-from", rules, "s3://bucket/"})
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "rules.txt:2:") {
+		t.Errorf("du with a bad --filter-from: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
@@ -0,0 +1,288 @@
+package e2e
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sortedLines splits output into its non-empty lines, sorted: find prints
+// matches in completion order.
+func sortedLines(out, sep string) []string {
+	var lines []string
+	for _, l := range strings.Split(out, sep) {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// TestE2E_FindPredicates verifies that find prints the objects matching a
+// compound expression, and only those.
+func TestE2E_FindPredicates(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "data/a.parquet", strings.Repeat("x", 2048))
+	putObject(t, client, bucket, "data/b.parquet", "small")
+	putObject(t, client, bucket, "data/sub/c.PARQUET", strings.Repeat("y", 4096))
+	putObject(t, client, bucket, "data/d.csv", strings.Repeat("z", 4096))
+	putObject(t, client, bucket, "other/e.parquet", strings.Repeat("w", 4096))
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "find", "s3://"+bucket+"/data/", "--",
+		"-iname", "*.parquet", "-size", "+1K", "-storage-class", "STANDARD")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	got := sortedLines(res.Stdout, "\n")
+	want := []string{"s3://" + bucket + "/data/a.parquet", "s3://" + bucket + "/data/sub/c.PARQUET"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("find = %q, want %q", got, want)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "find", "s3://"+bucket+"/data/", "--",
+		"(", "-name", "*.csv", "-or", "-path", "sub/*", ")", "-not", "-size", "-4K", "-mtime", "-1")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	got = sortedLines(res.Stdout, "\n")
+	want = []string{"s3://" + bucket + "/data/d.csv", "s3://" + bucket + "/data/sub/c.PARQUET"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("find with operators = %q, want %q", got, want)
+	}
+}
+
+// TestE2E_FindJSONAndMetadata verifies --json output and that -metadata
+// looks up the user metadata of the candidates.
+func TestE2E_FindJSONAndMetadata(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "plain.txt", "plain")
+	workdir := t.TempDir()
+	src := filepath.Join(workdir, "owned.txt")
+	writeFile(t, src, "owned")
+	res := runS6cmd(t, workdir, endpoint, "cp", "--metadata", "owner=etl", src, "s3://"+bucket+"/owned.txt")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "find", "--json", "s3://"+bucket+"/", "--", "-metadata", "owner=e*")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	lines := sortedLines(res.Stdout, "\n")
+	if len(lines) != 1 {
+		t.Fatalf("find --json printed %d lines, want 1: %q", len(lines), res.Stdout)
+	}
+	var m struct {
+		Key      string            `json:"key"`
+		Path     string            `json:"path"`
+		Size     int64             `json:"size"`
+		Etag     string            `json:"etag"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("unmarshal %q: %v", lines[0], err)
+	}
+	if m.Key != "s3://"+bucket+"/owned.txt" || m.Path != "owned.txt" || m.Size != 5 || m.Etag == "" || m.Metadata["owner"] != "etl" {
+		t.Errorf("find --json = %+v", m)
+	}
+}
+
+// TestE2E_FindCopyToAndDelete verifies that matches are copied under
+// --copy-to with their relative paths and then deleted, leaving the
+// objects that did not match.
+func TestE2E_FindCopyToAndDelete(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "logs/2024/a.log", "a")
+	putObject(t, client, bucket, "logs/2024/b.tmp", "b")
+	putObject(t, client, bucket, "logs/c.log", "c")
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "find", "--dry-run", "--delete", "--copy-to", "s3://"+bucket+"/archive/",
+		"s3://"+bucket+"/logs/", "--", "-name", "*.log")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find --dry-run failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "rm s3://"+bucket+"/logs/c.log") {
+		t.Errorf("dry run should report the deletion, got %q", res.Stdout)
+	}
+	if !objectExists(t, client, bucket, "logs/c.log") || objectExists(t, client, bucket, "archive/c.log") {
+		t.Fatalf("dry run should change nothing")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "find", "--delete", "--copy-to", "s3://"+bucket+"/archive/",
+		"s3://"+bucket+"/logs/", "--", "-name", "*.log")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find --copy-to --delete failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"archive/2024/a.log": true,
+		"archive/c.log":      true,
+		"logs/2024/a.log":    false,
+		"logs/c.log":         false,
+		"logs/2024/b.tmp":    true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("%s exists = %v, want %v", key, got, want)
+		}
+	}
+	if got := objectContent(t, client, bucket, "archive/2024/a.log"); got != "a" {
+		t.Errorf("archive/2024/a.log = %q, want %q", got, "a")
+	}
+}
+
+// TestE2E_FindDeleteGuard verifies that find --delete refuses a delete set
+// over --max-delete before deleting anything, copies the matches to
+// --backup-dir before deleting them, reports the deletions and also
+// deletes local matches.
+func TestE2E_FindDeleteGuard(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "logs/a.log", "a")
+	putObject(t, client, bucket, "logs/b.log", "b")
+	putObject(t, client, bucket, "logs/c.txt", "c")
+
+	workdir := t.TempDir()
+	src := "s3://" + bucket + "/logs/"
+	res := runS6cmd(t, workdir, endpoint, "find", "--delete", "--max-delete", "50%", src)
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "exceeds --max-delete") {
+		t.Errorf("find --delete over --max-delete: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	for _, key := range []string{"logs/a.log", "logs/b.log", "logs/c.txt"} {
+		if !objectExists(t, client, bucket, key) {
+			t.Errorf("%s was deleted despite --max-delete", key)
+		}
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "find", "--delete", "--max-delete", "2", "--backup-dir", "s3://"+bucket+"/backup/",
+		"--report", "report.jsonl", src, "--", "-name", "*.log")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find --delete --backup-dir failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"logs/a.log":                       false,
+		"logs/b.log":                       false,
+		"logs/c.txt":                       true,
+		"backup/" + bucket + "/logs/a.log": true,
+		"backup/" + bucket + "/logs/b.log": true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("%s exists = %v, want %v", key, got, want)
+		}
+	}
+	report := fileContent(t, filepath.Join(workdir, "report.jsonl"))
+	if strings.Count(report, `"operation":"rm"`) != 2 {
+		t.Errorf("report = %q, want two rm entries", report)
+	}
+
+	writeFile(t, filepath.Join(workdir, "dir", "a.tmp"), "a")
+	writeFile(t, filepath.Join(workdir, "dir", "b.txt"), "b")
+	res = runS6cmdRaw(t, workdir, []string{"find", "--delete", "dir", "--", "-name", "*.tmp"})
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find --delete of a local file failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "dir", "a.tmp")); !os.IsNotExist(err) {
+		t.Errorf("dir/a.tmp was not deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "dir", "b.txt")); err != nil {
+		t.Errorf("dir/b.txt: %v", err)
+	}
+}
+
+// TestE2E_FindLocalExecPrint0 verifies find over a local directory with
+// --exec placeholders and --print0.
+func TestE2E_FindLocalExecPrint0(t *testing.T) {
+	t.Parallel()
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "dir", "a.txt"), "aaa")
+	writeFile(t, filepath.Join(workdir, "dir", "sub", "b.txt"), "bb")
+	writeFile(t, filepath.Join(workdir, "dir", "c.bin"), "c")
+
+	res := runS6cmdRaw(t, workdir, []string{"find", "--print0", "--exec", "echo {path}={size}", "dir", "--", "-name", "*.txt"})
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	var printed, executed []string
+	for _, field := range sortedLines(strings.ReplaceAll(res.Stdout, "\n", "\x00"), "\x00") {
+		if strings.Contains(field, "=") {
+			executed = append(executed, field)
+		} else {
+			printed = append(printed, field)
+		}
+	}
+	if got, want := strings.Join(printed, ","), "dir/a.txt,dir/sub/b.txt"; got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+	if got, want := strings.Join(executed, ","), "a.txt=3,sub/b.txt=2"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+
+	// A failing command fails the run, and -etag does not apply to files.
+	res = runS6cmdRaw(t, workdir, []string{"find", "--exec", "false", "dir"})
+	if res.ExitCode == 0 {
+		t.Errorf("find --exec false should fail")
+	}
+	res = runS6cmdRaw(t, workdir, []string{"find", "dir", "--", "-etag", "x"})
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "-etag only applies to s3:// sources") {
+		t.Errorf("-etag on a local source: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
+
+// TestE2E_FindDeleteMarkers verifies that find --all-versions --delete
+// -delete-marker removes the delete markers and restores the objects.
+func TestE2E_FindDeleteMarkers(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+	enableVersioning(t, client, bucket)
+
+	putObject(t, client, bucket, "file.txt", "v1")
+	deleteObject(t, client, bucket, "file.txt")
+	putObject(t, client, bucket, "live.txt", "live")
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "find", "s3://"+bucket+"/", "--", "-delete-marker")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "-delete-marker needs --all-versions") {
+		t.Errorf("-delete-marker without --all-versions: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "find", "--all-versions", "--print", "--delete", "s3://"+bucket+"/", "--", "-delete-marker")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd find --all-versions --delete failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "s3://"+bucket+"/file.txt?versionId=") {
+		t.Errorf("find should print the marker's versioned URL, got %q", res.Stdout)
+	}
+	versions, markers := listVersionEntries(t, client, bucket, "")
+	if len(markers) != 0 {
+		t.Errorf("delete markers left: %v", markers)
+	}
+	if len(versions) != 2 {
+		t.Errorf("versions = %v, want file.txt and live.txt kept", versions)
+	}
+}
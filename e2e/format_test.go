@@ -0,0 +1,95 @@
+package e2e
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestE2E_FormatTemplates verifies that --format renders the same field
+// names in ls, stat, head and du.
+func TestE2E_FormatTemplates(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a.txt", "abc")
+	if _, err := client.PutObject(t.Context(), &s3.PutObjectInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String("dir/b.txt"),
+		Body:     strings.NewReader("hello"),
+		Metadata: map[string]string{"owner": "team-a"},
+	}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	workdir := t.TempDir()
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ls", "--format", `{{.Type}}\t{{.Key}}\t{{.Size}}`, "s3://" + bucket + "/"}, "directory\tdir/\t0\nfile\ta.txt\t3\n"},
+		{[]string{"ls", "--recursive", "--format", "{{.URL}} {{.Size}}", "s3://" + bucket + "/"}, "s3://" + bucket + "/a.txt 3\ns3://" + bucket + "/dir/b.txt 5\n"},
+		{[]string{"stat", "--format", `{{.Key}} {{.Size}} {{index .Metadata "owner"}}`, "s3://" + bucket + "/dir/b.txt"}, "dir/b.txt 5 team-a\n"},
+		{[]string{"head", "--format", "{{.Key}} {{.Size}} {{.ETag}}", "s3://" + bucket + "/dir/b.txt"}, "dir/b.txt 5 5d41402abc4b2a76b9719d911017c592\n"},
+		{[]string{"du", "--format", "{{.Count}} {{.Size}}", "s3://" + bucket + "/"}, "2 8\n"},
+	} {
+		res := runS6cmd(t, workdir, endpoint, tc.args...)
+		if res.ExitCode != 0 {
+			t.Fatalf("s6cmd %v failed: %s\nstderr: %s", tc.args, res.Stdout, res.Stderr)
+		}
+		if res.Stdout != tc.want {
+			t.Errorf("s6cmd %v = %q, want %q", tc.args, res.Stdout, tc.want)
+		}
+	}
+
+	res := runS6cmd(t, workdir, endpoint, "ls", "--format", "{{.Name}}", "s3://"+bucket+"/")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "invalid --format") {
+		t.Errorf("--format {{.Name}}: exit %d, stderr %q, want an error", res.ExitCode, res.Stderr)
+	}
+}
+
+// TestE2E_OutputCSV verifies the csv and tsv --output modes: a header line
+// with the JSON field names, then one record per row.
+func TestE2E_OutputCSV(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a,b.txt", "abc")
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "--output", "csv", "ls", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd ls --output csv failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(res.Stdout), "\n")
+	if len(lines) != 2 || lines[0] != "type,url,key,size,last_modified,etag,storage_class" {
+		t.Fatalf("ls csv = %q, want a header and one record", res.Stdout)
+	}
+	if !strings.HasPrefix(lines[1], `file,"s3://`+bucket+`/a,b.txt","a,b.txt",3,`) {
+		t.Errorf("ls csv record = %q", lines[1])
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "--output", "tsv", "du", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd du --output tsv failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if want := "url\tcount\tsize\tstorage_class\ns3://" + bucket + "/\t1\t3\t\n"; res.Stdout != want {
+		t.Errorf("du tsv = %q, want %q", res.Stdout, want)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "--output", "csv", "head", "s3://"+bucket)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd head --output csv failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.HasPrefix(res.Stdout, "url,bucket,region\ns3://"+bucket+","+bucket+",") {
+		t.Errorf("head csv = %q", res.Stdout)
+	}
+}
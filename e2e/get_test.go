@@ -3,6 +3,7 @@ package e2e
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -73,3 +74,27 @@ func mkdirAll(t *testing.T, dir string) {
 		t.Fatalf("MkdirAll(%q): %v", dir, err)
 	}
 }
+
+// TestE2E_GetShowProgress verifies the progress of a get, whose listing
+// has no sizes: the final line has the bytes downloaded, no percentage.
+func TestE2E_GetShowProgress(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a.txt", "aa")
+	putObject(t, client, bucket, "sub/b.txt", "bbb")
+
+	workdir := t.TempDir()
+	dstDir := filepath.Join(workdir, "out")
+	mkdirAll(t, dstDir)
+	res := runS6cmd(t, workdir, endpoint, "get", "--show-progress", "--recursive", "s3://"+bucket, dstDir)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd get failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stderr, "2/2 objects, 5 bytes\n") {
+		t.Errorf("stderr = %q, want the final progress line", res.Stderr)
+	}
+}
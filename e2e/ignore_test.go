@@ -0,0 +1,114 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// writeIgnoreTree lays out a source tree with a .s6cmdignore at the root,
+// a .gitignore and a nested .s6cmdignore.
+func writeIgnoreTree(t *testing.T, srcDir string) {
+	t.Helper()
+	writeFile(t, filepath.Join(srcDir, ".s6cmdignore"), "/build/\n*.tmp\n")
+	writeFile(t, filepath.Join(srcDir, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(srcDir, "main.go"), "package main")
+	writeFile(t, filepath.Join(srcDir, "debug.log"), "log")
+	writeFile(t, filepath.Join(srcDir, "scratch.tmp"), "tmp")
+	writeFile(t, filepath.Join(srcDir, "build", "out.bin"), "bin")
+	writeFile(t, filepath.Join(srcDir, "pkg", ".s6cmdignore"), "!keep.tmp\n")
+	writeFile(t, filepath.Join(srcDir, "pkg", "keep.tmp"), "keep")
+	writeFile(t, filepath.Join(srcDir, "pkg", "lib.go"), "package pkg")
+}
+
+// TestE2E_IgnoreFilesUpload verifies that put and cp skip what the
+// .s6cmdignore files list, honor .gitignore only with
+// --respect-gitignore, and upload everything with --no-s6cmdignore.
+func TestE2E_IgnoreFilesUpload(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeIgnoreTree(t, srcDir)
+
+	res := runS6cmd(t, workdir, endpoint, "put", "--recursive", srcDir+"/", "s3://"+bucket+"/put/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd put failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	res = runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--respect-gitignore", srcDir+"/", "s3://"+bucket+"/cp/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp --respect-gitignore failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	res = runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--no-s6cmdignore", srcDir+"/", "s3://"+bucket+"/all/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd cp --no-s6cmdignore failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+
+	for key, want := range map[string]bool{
+		"put/main.go":       true,
+		"put/debug.log":     true,
+		"put/scratch.tmp":   false,
+		"put/build/out.bin": false,
+		"put/pkg/keep.tmp":  true,
+		"put/.s6cmdignore":  true,
+		"cp/main.go":        true,
+		"cp/debug.log":      false,
+		"cp/scratch.tmp":    false,
+		"cp/build/out.bin":  false,
+		"cp/pkg/lib.go":     true,
+		"all/scratch.tmp":   true,
+		"all/build/out.bin": true,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("%s exists = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// TestE2E_IgnoreFilesSync verifies that sync uploads only what the ignore
+// files leave in and that --delete keeps the destination counterparts of
+// ignored files, like those of excluded ones, unless --delete-excluded.
+func TestE2E_IgnoreFilesSync(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "build/out.bin", "old")
+	putObject(t, client, bucket, "stale.txt", "stale")
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeIgnoreTree(t, srcDir)
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", srcDir+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for key, want := range map[string]bool{
+		"main.go":       true,
+		"pkg/keep.tmp":  true,
+		"scratch.tmp":   false,
+		"build/out.bin": true,
+		"stale.txt":     false,
+	} {
+		if got := objectExists(t, client, bucket, key); got != want {
+			t.Errorf("after sync: %s exists = %v, want %v", key, got, want)
+		}
+	}
+	if got := objectContent(t, client, bucket, "build/out.bin"); got != "old" {
+		t.Errorf("build/out.bin = %q, want the ignored file left alone", got)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete-excluded", "--yes", srcDir+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --delete-excluded failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if objectExists(t, client, bucket, "build/out.bin") {
+		t.Errorf("build/out.bin should have been deleted by --delete-excluded")
+	}
+}
@@ -0,0 +1,82 @@
+package e2e
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestE2E_Inventory verifies that ls, du, find, cp and rm list their source
+// from an S3 Inventory report with --inventory: a key written after the
+// report is not seen.
+func TestE2E_Inventory(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "data/a.txt", "aaa")
+	putObject(t, client, bucket, "data/sub/b.txt", "bb")
+	putObject(t, client, bucket, "data/new.txt", "new")
+
+	var report bytes.Buffer
+	gz := gzip.NewWriter(&report)
+	gz.Write([]byte(bucket + ",data/a.txt,3,2024-05-01T10:00:00.000Z\n" +
+		bucket + ",data/sub/b.txt,2,2024-05-01T10:00:00.000Z\n"))
+	gz.Close()
+	putObject(t, client, bucket, "inventory/data/1.csv.gz", report.String())
+	manifest := `{"sourceBucket":"` + bucket + `","destinationBucket":"arn:aws:s3:::` + bucket + `",` +
+		`"fileFormat":"CSV","fileSchema":"Bucket, Key, Size, LastModifiedDate",` +
+		`"files":[{"key":"inventory/data/1.csv.gz"}]}`
+	putObject(t, client, bucket, "inventory/manifest.json", manifest)
+	inventory := "s3://" + bucket + "/inventory/manifest.json"
+
+	workdir := t.TempDir()
+	src := "s3://" + bucket + "/data/"
+	run := func(args ...string) string {
+		t.Helper()
+		res := runS6cmd(t, workdir, endpoint, args...)
+		if res.ExitCode != 0 {
+			t.Fatalf("s6cmd %v failed: %s\nstderr: %s", args, res.Stdout, res.Stderr)
+		}
+		return res.Stdout
+	}
+
+	if out := run("ls", "--recursive", "--inventory", inventory, src); !strings.Contains(out, "data/sub/b.txt") || strings.Contains(out, "new.txt") {
+		t.Errorf("ls --inventory = %q, want the report's keys only", out)
+	}
+	if out := run("du", "--inventory", inventory, src); !strings.Contains(out, "5 bytes in 2 objects") {
+		t.Errorf("du --inventory = %q, want 5 bytes in 2 objects", out)
+	}
+	want := []string{src + "a.txt", src + "sub/b.txt"}
+	if got := sortedLines(run("find", "--inventory", inventory, src), "\n"); !slices.Equal(got, want) {
+		t.Errorf("find --inventory = %q, want %q", got, want)
+	}
+
+	run("cp", "--recursive", "--inventory", inventory, src+"*", "out/")
+	if got := fileContent(t, filepath.Join(workdir, "out", "sub", "b.txt")); got != "bb" {
+		t.Errorf("cp: sub/b.txt = %q, want %q", got, "bb")
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "out", "new.txt")); err == nil {
+		t.Error("cp: new.txt is not in the report but was copied")
+	}
+
+	run("rm", "--inventory", inventory, src+"*")
+	for _, k := range []string{"data/a.txt", "data/sub/b.txt"} {
+		if objectExists(t, client, bucket, k) {
+			t.Errorf("rm: %s still exists", k)
+		}
+	}
+	if !objectExists(t, client, bucket, "data/new.txt") {
+		t.Error("rm: data/new.txt is not in the report but was deleted")
+	}
+
+	if res := runS6cmd(t, workdir, endpoint, "cp", "--recursive", "--inventory", inventory, "out/", src); res.ExitCode != 1 || !strings.Contains(res.Stderr, "--inventory needs an s3:// source") {
+		t.Errorf("cp --inventory from a local source: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of TestE2E_JSONSchemas")
+
+// jsonVolatile matches the values that change from run to run: timestamps,
+// version IDs, the signature of presigned URLs and the request IDs quoted
+// in errors.
+var jsonVolatile = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`), "TIME"},
+	{regexp.MustCompile(`"version_id":"[^"]*"`), `"version_id":"VERSION"`},
+	{regexp.MustCompile(`"url":"[^"]*"`), `"url":"URL"`},
+	{regexp.MustCompile(`RequestID: [^,]*, HostID: [^,]*,`), "RequestID: ID, HostID: ID,"},
+}
+
+// TestE2E_JSONSchemas runs every command that prints results with
+// --output json and compares its output with testdata/golden/<name>.jsonl,
+// stdout first, then stderr. The bucket name, the work directory and the
+// volatile values are replaced by placeholders. Every line must be a JSON
+// object with a "schema" field. Run with -update to rewrite the files
+// after a deliberate schema change.
+func TestE2E_JSONSchemas(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a.txt", "abc")
+	putObject(t, client, bucket, "dir/b.txt", "hello")
+
+	workdir := t.TempDir()
+	writeFile(t, filepath.Join(workdir, "local", "a.txt"), "abc")
+	writeFile(t, filepath.Join(workdir, "local", "dir", "b.txt"), "hello")
+	writeFile(t, filepath.Join(workdir, "up.txt"), "up")
+
+	b := "s3://" + bucket
+	for _, tc := range []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"ls-buckets", []string{"ls"}, 0},
+		{"ls", []string{"ls", b + "/"}, 0},
+		{"ls-summarize", []string{"ls", "--recursive", "--summarize", b + "/"}, 0},
+		{"stat-object", []string{"stat", b + "/a.txt"}, 0},
+		{"stat-bucket", []string{"stat", b}, 0},
+		{"head-object", []string{"head", b + "/a.txt"}, 0},
+		{"head-bucket", []string{"head", b}, 0},
+		{"du", []string{"du", "--group", b + "/"}, 0},
+		{"find", []string{"find", b + "/", "--", "-name", "*.txt"}, 0},
+		{"tree", []string{"tree", b + "/"}, 0},
+		{"verify", []string{"verify", "./local/", b + "/"}, 0},
+		{"bucket-version", []string{"bucket-version", b}, 0},
+		{"mb", []string{"mb", b}, 0},
+		{"presign", []string{"presign", b + "/a.txt"}, 0},
+		{"version", []string{"version"}, 0},
+		{"cp", []string{"--stat", "cp", "up.txt", b + "/up.txt"}, 0},
+		{"rm", []string{"rm", b + "/up.txt"}, 0},
+		{"error", []string{"stat", b + "/missing.txt"}, 1},
+		{"usage-error", []string{"ls", "--no-such-flag", b + "/"}, 2},
+	} {
+		res := runS6cmd(t, workdir, endpoint, append([]string{"--output", "json"}, tc.args...)...)
+		if res.ExitCode != tc.code {
+			t.Errorf("%s: exit code %d, want %d\nstdout: %s\nstderr: %s", tc.name, res.ExitCode, tc.code, res.Stdout, res.Stderr)
+			continue
+		}
+		got := res.Stdout + res.Stderr
+		got = strings.ReplaceAll(got, bucket, "BUCKET")
+		got = strings.ReplaceAll(got, workdir, "WORKDIR")
+		for _, v := range jsonVolatile {
+			got = v.re.ReplaceAllString(got, v.repl)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+			var fields map[string]any
+			if err := json.Unmarshal([]byte(line), &fields); err != nil {
+				t.Errorf("%s: line %q is not a JSON object: %v", tc.name, line, err)
+				continue
+			}
+			if schema, _ := fields["schema"].(string); !strings.Contains(schema, "/v") {
+				t.Errorf("%s: line %q has no versioned schema", tc.name, line)
+			}
+		}
+
+		golden := filepath.Join("testdata", "golden", tc.name+".jsonl")
+		if *update {
+			if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %v (run with -update to create it)", tc.name, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: output differs from %s:\ngot:\n%s\nwant:\n%s", tc.name, golden, got, want)
+		}
+	}
+}
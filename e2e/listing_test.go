@@ -0,0 +1,115 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestE2E_SavedListing verifies that ls --save-listing saves a recursive
+// listing that du, find, cp and rm --from-listing use instead of listing
+// the bucket, and that --check-listing refuses it once it is stale.
+func TestE2E_SavedListing(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "data/a.txt", "aaa")
+	putObject(t, client, bucket, "data/sub/b.txt", "bb")
+
+	workdir := t.TempDir()
+	src := "s3://" + bucket + "/data/"
+	run := func(args ...string) string {
+		t.Helper()
+		res := runS6cmd(t, workdir, endpoint, args...)
+		if res.ExitCode != 0 {
+			t.Fatalf("s6cmd %v failed: %s\nstderr: %s", args, res.Stdout, res.Stderr)
+		}
+		return res.Stdout
+	}
+
+	if out := run("ls", "--recursive", "--save-listing", "listing.jsonl", src); !strings.Contains(out, "data/sub/b.txt") {
+		t.Errorf("ls --save-listing = %q, want the listing printed too", out)
+	}
+	putObject(t, client, bucket, "data/new.txt", "new")
+
+	if out := run("du", "--from-listing", "listing.jsonl", src); !strings.Contains(out, "5 bytes in 2 objects") {
+		t.Errorf("du --from-listing = %q, want 5 bytes in 2 objects", out)
+	}
+	want := []string{src + "a.txt", src + "sub/b.txt"}
+	if got := sortedLines(run("find", "--from-listing", "listing.jsonl", src), "\n"); !slices.Equal(got, want) {
+		t.Errorf("find --from-listing = %q, want %q", got, want)
+	}
+
+	run("cp", "--recursive", "--from-listing", "listing.jsonl", "--check-listing", "2", src+"*", "out/")
+	if got := fileContent(t, filepath.Join(workdir, "out", "sub", "b.txt")); got != "bb" {
+		t.Errorf("cp: sub/b.txt = %q, want %q", got, "bb")
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "out", "new.txt")); err == nil {
+		t.Error("cp: new.txt is not in the listing but was copied")
+	}
+
+	putObject(t, client, bucket, "data/a.txt", "changed")
+	res := runS6cmd(t, workdir, endpoint, "rm", "--from-listing", "listing.jsonl", "--check-listing", "2", src+"*")
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "is stale") {
+		t.Errorf("rm of a stale listing: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	if !objectExists(t, client, bucket, "data/a.txt") {
+		t.Error("rm deleted data/a.txt from a stale listing")
+	}
+
+	run("rm", "--from-listing", "listing.jsonl", src+"*")
+	for _, k := range []string{"data/a.txt", "data/sub/b.txt"} {
+		if objectExists(t, client, bucket, k) {
+			t.Errorf("rm: %s still exists", k)
+		}
+	}
+	if !objectExists(t, client, bucket, "data/new.txt") {
+		t.Error("rm: data/new.txt is not in the listing but was deleted")
+	}
+
+	if res := runS6cmd(t, workdir, endpoint, "ls", "--save-listing", "listing.jsonl", src); res.ExitCode != 1 || !strings.Contains(res.Stderr, "--save-listing needs --recursive") {
+		t.Errorf("ls --save-listing without --recursive: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+}
+
+// TestE2E_SavedListing_Partial verifies that ls refuses to save a listing
+// that leaves out objects, which sync --delete --from-listing would take
+// for deleted ones, and keeps the previous listing.
+func TestE2E_SavedListing_Partial(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+	putObject(t, client, bucket, "logs/a.gz", "a")
+
+	workdir := t.TempDir()
+	src := "s3://" + bucket + "/logs/"
+	for _, args := range [][]string{
+		{"--max-items", "1", src},
+		{"--min-size", "1KiB", src},
+		{"--newer-than", "7d", src},
+		{"--filter", "size > 0", src},
+		{"--start-after", "logs/a.gz", src},
+		{"--inventory", "manifest.json", src},
+		{src + "*.gz"},
+	} {
+		cmd := append([]string{"ls", "--recursive", "--save-listing", "listing.jsonl"}, args...)
+		res := runS6cmd(t, workdir, endpoint, cmd...)
+		if res.ExitCode != 1 || !strings.Contains(res.Stderr, "--save-listing saves complete listings only") {
+			t.Errorf("%v: exit %d, stderr %q", cmd, res.ExitCode, res.Stderr)
+		}
+	}
+	res := runS6cmd(t, workdir, endpoint, "--no-paginate", "ls", "--recursive", "--save-listing", "listing.jsonl", src)
+	if res.ExitCode != 1 || !strings.Contains(res.Stderr, "drop --no-paginate") {
+		t.Errorf("--no-paginate: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, "listing.jsonl")); err == nil {
+		t.Error("a partial listing was saved")
+	}
+}
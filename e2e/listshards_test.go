@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestE2E_ListShards verifies that --list-shards lists the same objects as
+// a sequential listing for du, find, cp and rm.
+func TestE2E_ListShards(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	keys := []string{
+		"data/2024/01/a.txt", "data/2024/02/b.txt", "data/2025/c.txt",
+		"data/readme.txt", "data/z/d.txt", "data/zz.txt",
+	}
+	for _, k := range keys {
+		putObject(t, client, bucket, k, "x")
+	}
+	putObject(t, client, bucket, "other.txt", "x")
+
+	workdir := t.TempDir()
+	src := "s3://" + bucket + "/data/"
+	run := func(args ...string) string {
+		t.Helper()
+		res := runS6cmd(t, workdir, endpoint, args...)
+		if res.ExitCode != 0 {
+			t.Fatalf("s6cmd %v failed: %s\nstderr: %s", args, res.Stdout, res.Stderr)
+		}
+		return res.Stdout
+	}
+
+	if seq, sharded := run("du", src), run("--list-shards", "4", "du", src); sharded != seq {
+		t.Errorf("sharded du = %q, want %q", sharded, seq)
+	}
+
+	seq := sortedLines(run("find", src), "\n")
+	if sharded := sortedLines(run("--list-shards", "4", "find", src), "\n"); !slices.Equal(sharded, seq) || len(seq) != len(keys) {
+		t.Errorf("sharded find = %q, want %q (%d objects)", sharded, seq, len(keys))
+	}
+
+	run("--list-shards", "4", "cp", "--recursive", src+"*", "out/")
+	for _, k := range keys {
+		if got := fileContent(t, filepath.Join(workdir, "out", filepath.FromSlash(k[len("data/"):]))); got != "x" {
+			t.Errorf("cp: %s = %q, want %q", k, got, "x")
+		}
+	}
+
+	run("--list-shards", "4", "rm", src+"*")
+	for _, k := range keys {
+		if objectExists(t, client, bucket, k) {
+			t.Errorf("rm: %s still exists", k)
+		}
+	}
+	if !objectExists(t, client, bucket, "other.txt") {
+		t.Error("rm: other.txt outside the prefix was deleted")
+	}
+}
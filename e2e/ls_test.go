@@ -170,3 +170,88 @@ func TestE2E_LsRecursive(t *testing.T) {
 		t.Errorf("stdout = %q, want dir/b.txt", res.Stdout)
 	}
 }
+
+// lsKeys returns the last column of every text ls row, or the key of every
+// JSON ls row, in output order.
+func lsKeys(t *testing.T, stdout string, jsonOutput bool) []string {
+	t.Helper()
+	var keys []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if line == "" {
+			continue
+		}
+		if !jsonOutput {
+			fields := strings.Fields(line)
+			keys = append(keys, fields[len(fields)-1])
+			continue
+		}
+		var l struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", line, err)
+		}
+		keys = append(keys, l.Key)
+	}
+	return keys
+}
+
+// TestE2E_LsSelection verifies that the size and age bounds, --sort,
+// --reverse, --max-items and --start-after pick and order the same objects
+// in text and JSON output.
+func TestE2E_LsSelection(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a.txt", strings.Repeat("a", 30))
+	putObject(t, client, bucket, "b.txt", strings.Repeat("b", 10))
+	putObject(t, client, bucket, "c.txt", strings.Repeat("c", 20))
+	putObject(t, client, bucket, "d.txt", "ddddd")
+
+	workdir := t.TempDir()
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"size bounds", []string{"--min-size", "10", "--max-size", "20"}, "b.txt c.txt"},
+		{"sort by size", []string{"--sort", "size"}, "d.txt b.txt c.txt a.txt"},
+		{"reverse", []string{"--reverse"}, "d.txt c.txt b.txt a.txt"},
+		{"sorted limit", []string{"--sort", "size", "--reverse", "--max-items", "2"}, "a.txt c.txt"},
+		{"limit", []string{"--max-items", "3"}, "a.txt b.txt c.txt"},
+		{"start after", []string{"--start-after", "b.txt"}, "c.txt d.txt"},
+		{"newer than", []string{"--newer-than", "1h", "--min-size", "15"}, "a.txt c.txt"},
+		{"older than", []string{"--older-than", "1h"}, ""},
+		{"older than a time", []string{"--older-than", "2999-01-01", "--max-size", "5"}, "d.txt"},
+	} {
+		args := append([]string{"ls"}, append(tc.args, "s3://"+bucket+"/")...)
+		res := runS6cmd(t, workdir, endpoint, args...)
+		if res.ExitCode != 0 {
+			t.Fatalf("%s: s6cmd ls failed: %s\nstderr: %s", tc.name, res.Stdout, res.Stderr)
+		}
+		if got := strings.Join(lsKeys(t, res.Stdout, false), " "); got != tc.want {
+			t.Errorf("%s: keys = %q, want %q", tc.name, got, tc.want)
+		}
+
+		res = runS6cmd(t, workdir, endpoint, append([]string{"--output", "json"}, args...)...)
+		if res.ExitCode != 0 {
+			t.Fatalf("%s: s6cmd ls --output json failed: %s\nstderr: %s", tc.name, res.Stdout, res.Stderr)
+		}
+		got := strings.ReplaceAll(strings.Join(lsKeys(t, res.Stdout, true), " "), "s3://"+bucket+"/", "")
+		if got != tc.want {
+			t.Errorf("%s: JSON keys = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	res := runS6cmd(t, workdir, endpoint, "ls", "--sort", "owner", "s3://"+bucket+"/")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "invalid --sort") {
+		t.Errorf("--sort owner: exit %d, stderr %q, want a usage error", res.ExitCode, res.Stderr)
+	}
+	res = runS6cmd(t, workdir, endpoint, "ls", "--newer-than", "yesterday", "s3://"+bucket+"/")
+	if res.ExitCode == 0 || !strings.Contains(res.Stderr, "invalid --newer-than") {
+		t.Errorf("--newer-than yesterday: exit %d, stderr %q, want a usage error", res.ExitCode, res.Stderr)
+	}
+}
@@ -220,3 +220,45 @@ func TestE2E_RmListingFailureExitCode(t *testing.T) {
 		t.Errorf("keep.txt should still exist")
 	}
 }
+
+// TestE2E_RmMaxDeleteAndBackupDir verifies that --max-delete aborts before
+// deleting anything and that --backup-dir keeps a copy of every removed
+// object under its original key.
+func TestE2E_RmMaxDeleteAndBackupDir(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "logs/a.log", "1")
+	putObject(t, client, bucket, "logs/b.log", "2")
+	putObject(t, client, bucket, "logs/c.log", "3")
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "rm", "--recursive", "--max-delete", "2", "s3://"+bucket+"/logs/")
+	if res.ExitCode == 0 {
+		t.Fatalf("rm --max-delete 2 of 3 objects should fail")
+	}
+	if !strings.Contains(res.Stderr, "--max-delete") {
+		t.Errorf("stderr should name --max-delete, got: %s", res.Stderr)
+	}
+	for _, key := range []string{"logs/a.log", "logs/b.log", "logs/c.log"} {
+		if !objectExists(t, client, bucket, key) {
+			t.Fatalf("%s must survive an exceeded --max-delete", key)
+		}
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "rm", "--recursive", "--max-delete", "100%", "--backup-dir", "s3://"+bucket+"/trash/2026-10-17/", "s3://"+bucket+"/logs/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd rm --backup-dir failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for _, key := range []string{"logs/a.log", "logs/b.log", "logs/c.log"} {
+		if objectExists(t, client, bucket, key) {
+			t.Errorf("%s should have been deleted", key)
+		}
+	}
+	if got := objectContent(t, client, bucket, "trash/2026-10-17/"+bucket+"/logs/b.log"); got != "2" {
+		t.Errorf("backup of logs/b.log = %q, want %q", got, "2")
+	}
+}
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -59,3 +60,52 @@ func TestE2E_StatBucket(t *testing.T) {
 		t.Errorf("stdout = %q, want it to contain bucket name %q", res.Stdout, bucket)
 	}
 }
+
+// TestE2E_StatCompare verifies that `stat --compare` reports whether a
+// local file reproduces the object's ETag, and exits non-zero when the
+// local content differs. gofakes3 reports plain MD5 ETags even for
+// multipart uploads, so multipart part size detection is covered by the
+// internal/etag unit tests.
+func TestE2E_StatCompare(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	putObject(t, client, bucket, "a.txt", "hello-stat")
+	workdir := t.TempDir()
+	local := filepath.Join(workdir, "a.txt")
+	writeFile(t, local, "hello-stat")
+
+	res := runS6cmd(t, workdir, endpoint, "stat", "--compare", local, "s3://"+bucket+"/a.txt")
+	if res.ExitCode != 0 {
+		t.Fatalf("stat --compare of identical content failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "Match: true") {
+		t.Errorf("stdout = %q, want it to contain %q", res.Stdout, "Match: true")
+	}
+
+	// Same size, different content.
+	writeFile(t, local, "hello-STAT")
+	res = runS6cmd(t, workdir, endpoint, "stat", "--compare", local, "s3://"+bucket+"/a.txt")
+	if res.ExitCode != 1 {
+		t.Fatalf("stat --compare exit code = %d, want 1\nstdout: %s\nstderr: %s", res.ExitCode, res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "Match: false") {
+		t.Errorf("stdout = %q, want it to contain %q", res.Stdout, "Match: false")
+	}
+
+	// The result is kept with --format and the csv and tsv outputs.
+	res = runS6cmd(t, workdir, endpoint, "stat", "--compare", local, "--format", "{{.CompareMatch}} {{.CompareReason}}", "s3://"+bucket+"/a.txt")
+	if res.ExitCode != 1 || res.Stdout != "false content differs\n" {
+		t.Errorf("stat --compare --format: exit %d, stdout %q, want %q", res.ExitCode, res.Stdout, "false content differs\n")
+	}
+	res = runS6cmd(t, workdir, endpoint, "--output", "csv", "stat", "--compare", local, "s3://"+bucket+"/a.txt")
+	lines := strings.Split(strings.TrimSpace(res.Stdout), "\n")
+	if res.ExitCode != 1 || len(lines) != 2 ||
+		!strings.HasSuffix(lines[0], ",compare_file,compare_match,compare_part_size,compare_reason") ||
+		!strings.HasSuffix(lines[1], ","+local+",false,0,content differs") {
+		t.Errorf("stat --compare --output csv: exit %d, stdout %q, want the compare columns", res.ExitCode, res.Stdout)
+	}
+}
@@ -1,10 +1,16 @@
 package e2e
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // putLocalFile writes content into <workdir>/name and returns its path.
@@ -399,3 +405,422 @@ func TestE2E_SyncExitOnErrorMidBatchTaskFailure(t *testing.T) {
 		t.Errorf("aaa-unreadable.txt should not have been uploaded")
 	}
 }
+
+// TestE2E_SyncDetectRenames verifies that --detect-renames writes a moved
+// file with a server-side copy from its old key, and that --delete removes
+// the old key only after the copy. The copy sets the metadata an upload
+// would.
+func TestE2E_SyncDetectRenames(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "moved", "a.txt"), "renamed-content")
+	writeFile(t, filepath.Join(srcDir, "b.txt"), "new-content-15b")
+	// The old location of moved/a.txt, plus an extra of the same size but
+	// different content that must not be mistaken for it.
+	putObject(t, client, bucket, "src/a.txt", "renamed-content")
+	putObject(t, client, bucket, "src/c.txt", "other-content-x")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--detect-renames", "--metadata", "origin=laptop", srcDir, "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --detect-renames failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	wantCopy := "cp s3://" + bucket + "/src/a.txt s3://" + bucket + "/src/moved/a.txt"
+	if !strings.Contains(res.Stdout, wantCopy) {
+		t.Errorf("stdout = %q, want server-side copy %q", res.Stdout, wantCopy)
+	}
+	if got := objectContent(t, client, bucket, "src/moved/a.txt"); got != "renamed-content" {
+		t.Errorf("src/moved/a.txt = %q, want %q", got, "renamed-content")
+	}
+	head, err := client.HeadObject(t.Context(), &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("src/moved/a.txt")})
+	if err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if got := head.Metadata["origin"]; got != "laptop" {
+		t.Errorf("src/moved/a.txt metadata origin = %q, want %q", got, "laptop")
+	}
+	if got := aws.ToString(head.ContentType); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("src/moved/a.txt Content-Type = %q, want text/plain", got)
+	}
+	if got := objectContent(t, client, bucket, "src/b.txt"); got != "new-content-15b" {
+		t.Errorf("src/b.txt = %q, want %q", got, "new-content-15b")
+	}
+	for _, key := range []string{"src/a.txt", "src/c.txt"} {
+		if objectExists(t, client, bucket, key) {
+			t.Errorf("%s should have been deleted by --delete", key)
+		}
+	}
+}
+
+// TestE2E_SyncExistingAndIgnoreExisting verifies that --existing only
+// updates keys already in the destination and --ignore-existing only
+// creates missing ones.
+func TestE2E_SyncExistingAndIgnoreExisting(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "old.txt"), "new-version")
+	writeFile(t, filepath.Join(srcDir, "new.txt"), "new-file")
+	putObject(t, client, bucket, "src/old.txt", "old")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--existing", srcDir, "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --existing failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "src/old.txt"); got != "new-version" {
+		t.Errorf("src/old.txt = %q, want it updated by --existing", got)
+	}
+	if objectExists(t, client, bucket, "src/new.txt") {
+		t.Errorf("src/new.txt should not be created by --existing")
+	}
+
+	putObject(t, client, bucket, "src/old.txt", "old")
+	res = runS6cmd(t, workdir, endpoint, "sync", "--ignore-existing", srcDir, "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --ignore-existing failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "src/old.txt"); got != "old" {
+		t.Errorf("src/old.txt = %q, want it untouched by --ignore-existing", got)
+	}
+	if !objectExists(t, client, bucket, "src/new.txt") {
+		t.Errorf("src/new.txt should be created by --ignore-existing")
+	}
+}
+
+// TestE2E_SyncDeleteExcluded verifies that --delete keeps the destination
+// counterpart of an excluded source while --delete-excluded removes it.
+func TestE2E_SyncDeleteExcluded(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "keep.txt"), "keep")
+	writeFile(t, filepath.Join(srcDir, "debug.log"), "log")
+	putObject(t, client, bucket, "src/debug.log", "log")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--exclude", "*.log", srcDir, "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --delete failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !objectExists(t, client, bucket, "src/debug.log") {
+		t.Fatalf("--delete must keep the counterpart of an excluded source")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete-excluded", "--delete-after", "--yes", "--exclude", "*.log", srcDir, "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --delete-excluded failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if objectExists(t, client, bucket, "src/debug.log") {
+		t.Errorf("src/debug.log should have been deleted by --delete-excluded")
+	}
+	if !objectExists(t, client, bucket, "src/keep.txt") {
+		t.Errorf("src/keep.txt should exist")
+	}
+}
+
+// TestE2E_SyncMaxDeleteAndBackupDir verifies that --max-delete is checked
+// on the full plan before anything is transferred or deleted, and that
+// --backup-dir keeps both deleted and overwritten destination objects.
+func TestE2E_SyncMaxDeleteAndBackupDir(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "changed.txt"), "new content")
+	writeFile(t, filepath.Join(srcDir, "added.txt"), "added")
+	putObject(t, client, bucket, "data/src/changed.txt", "old")
+	putObject(t, client, bucket, "data/src/gone1.txt", "gone1")
+	putObject(t, client, bucket, "data/src/gone2.txt", "gone2")
+
+	// 2 of 3 destination objects would be deleted: 66% > 50%.
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--max-delete", "50%", srcDir, "s3://"+bucket+"/data/")
+	if res.ExitCode == 0 {
+		t.Fatalf("sync --max-delete 50%% should fail when 2 of 3 objects would be deleted")
+	}
+	if objectExists(t, client, bucket, "data/src/added.txt") {
+		t.Errorf("an exceeded --max-delete must abort before any transfer")
+	}
+	if !objectExists(t, client, bucket, "data/src/gone1.txt") {
+		t.Errorf("an exceeded --max-delete must abort before any delete")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--backup-dir", "s3://"+bucket+"/data/src/trash/", srcDir, "s3://"+bucket+"/data/")
+	if res.ExitCode == 0 {
+		t.Fatalf("a --backup-dir inside the destination should be rejected")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--max-delete", "2", "--backup-dir", "s3://"+bucket+"/trash/", srcDir, "s3://"+bucket+"/data/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --backup-dir failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if objectExists(t, client, bucket, "data/src/gone1.txt") {
+		t.Errorf("data/src/gone1.txt should have been deleted")
+	}
+	if got := objectContent(t, client, bucket, "data/src/changed.txt"); got != "new content" {
+		t.Errorf("data/src/changed.txt = %q, want the new content", got)
+	}
+	if got := objectContent(t, client, bucket, "trash/"+bucket+"/data/src/gone1.txt"); got != "gone1" {
+		t.Errorf("backup of deleted object = %q, want %q", got, "gone1")
+	}
+	if got := objectContent(t, client, bucket, "trash/"+bucket+"/data/src/changed.txt"); got != "old" {
+		t.Errorf("backup of overwritten object = %q, want %q", got, "old")
+	}
+}
+
+// TestE2E_SyncPlanOutAndApply writes a plan without touching the
+// destination, then applies it after some of its objects changed: the
+// unchanged entries are applied and the stale ones refused.
+func TestE2E_SyncPlanOutAndApply(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "added.txt"), "added")
+	writeFile(t, filepath.Join(srcDir, "changed.txt"), "new content")
+	writeFile(t, filepath.Join(srcDir, "stale.txt"), "new content")
+	putObject(t, client, bucket, "data/src/changed.txt", "old")
+	putObject(t, client, bucket, "data/src/stale.txt", "old")
+	putObject(t, client, bucket, "data/src/gone.txt", "gone")
+	putObject(t, client, bucket, "data/src/rewritten.txt", "extra")
+
+	planFile := filepath.Join(workdir, "plan.json")
+	res := runS6cmd(t, workdir, endpoint, "sync", "--delete", "--plan-out", planFile, srcDir, "s3://"+bucket+"/data/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --plan-out failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if objectExists(t, client, bucket, "data/src/added.txt") || !objectExists(t, client, bucket, "data/src/gone.txt") {
+		t.Fatalf("sync --plan-out must not change the destination")
+	}
+
+	var plan struct {
+		Entries []struct {
+			Action      string `json:"action"`
+			Reason      string `json:"reason"`
+			Destination struct {
+				URL string `json:"url"`
+			} `json:"destination"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(fileContent(t, planFile)), &plan); err != nil {
+		t.Fatalf("plan is not JSON: %v", err)
+	}
+	var got []string
+	for _, e := range plan.Entries {
+		got = append(got, e.Action+" "+strings.TrimPrefix(e.Destination.URL, "s3://"+bucket+"/")+" ("+e.Reason+")")
+	}
+	sort.Strings(got)
+	want := []string{
+		"copy data/src/added.txt (not in destination)",
+		"copy data/src/changed.txt (size differs)",
+		"copy data/src/stale.txt (size differs)",
+		"delete data/src/gone.txt (not in source)",
+		"delete data/src/rewritten.txt (not in source)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("plan entries = %q, want %q", got, want)
+	}
+
+	// Change one source file and one object to delete after planning.
+	writeFile(t, filepath.Join(srcDir, "stale.txt"), "newer content")
+	putObject(t, client, bucket, "data/src/rewritten.txt", "rewritten")
+
+	res = runS6cmd(t, workdir, endpoint, "apply", planFile)
+	if res.ExitCode == 0 || objectExists(t, client, bucket, "data/src/added.txt") {
+		t.Fatalf("apply of a plan with deletes must ask for confirmation")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "apply", "--yes", planFile)
+	if res.ExitCode == 0 {
+		t.Fatalf("apply should fail when entries changed since planning\nstdout: %s", res.Stdout)
+	}
+	if !strings.Contains(res.Stderr, "changed since the plan was made") {
+		t.Errorf("stderr should name the stale entries, got: %s", res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "data/src/added.txt"); got != "added" {
+		t.Errorf("data/src/added.txt = %q, want %q", got, "added")
+	}
+	if got := objectContent(t, client, bucket, "data/src/changed.txt"); got != "new content" {
+		t.Errorf("data/src/changed.txt = %q, want the new content", got)
+	}
+	if objectExists(t, client, bucket, "data/src/gone.txt") {
+		t.Errorf("data/src/gone.txt should have been deleted")
+	}
+	if got := objectContent(t, client, bucket, "data/src/stale.txt"); got != "old" {
+		t.Errorf("a source changed since planning must not be copied, got %q", got)
+	}
+	if got := objectContent(t, client, bucket, "data/src/rewritten.txt"); got != "rewritten" {
+		t.Errorf("a destination changed since planning must not be deleted, got %q", got)
+	}
+}
+
+// TestE2E_SyncManifest verifies that sync --manifest plans against the
+// stored destination listing instead of listing the destination, so
+// out-of-band changes are only seen after a --full-rescan.
+func TestE2E_SyncManifest(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "a.txt"), "aaa")
+	writeFile(t, filepath.Join(srcDir, "b.txt"), "bbb")
+	dst := "s3://" + bucket + "/data/"
+	manifest := "s3://" + bucket + "/state/data.manifest.gz"
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--manifest", "s3://"+bucket+"/data/manifest.gz", srcDir, dst)
+	if res.ExitCode == 0 {
+		t.Fatalf("a --manifest inside the destination should be rejected")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--manifest", manifest, srcDir, dst)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --manifest failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !objectExists(t, client, bucket, "data/src/a.txt") || !objectExists(t, client, bucket, "state/data.manifest.gz") {
+		t.Fatalf("the first run should sync and write the manifest")
+	}
+
+	// Out-of-band changes the manifest does not know about. --size-only
+	// keeps the second-precision LastModified of the fake server out of
+	// the comparison.
+	deleteObject(t, client, bucket, "data/src/a.txt")
+	putObject(t, client, bucket, "data/src/oob.txt", "out of band")
+	writeFile(t, filepath.Join(srcDir, "b.txt"), "bbbb")
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--size-only", "--manifest", manifest, srcDir, dst)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --manifest failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "data/src/b.txt"); got != "bbbb" {
+		t.Errorf("data/src/b.txt = %q, want %q", got, "bbbb")
+	}
+	if objectExists(t, client, bucket, "data/src/a.txt") || !objectExists(t, client, bucket, "data/src/oob.txt") {
+		t.Errorf("a run from the manifest must not see out-of-band changes")
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--delete", "--yes", "--manifest", manifest, "--full-rescan", srcDir, dst)
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync --full-rescan failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if got := objectContent(t, client, bucket, "data/src/a.txt"); got != "aaa" {
+		t.Errorf("--full-rescan should restore data/src/a.txt, got %q", got)
+	}
+	if objectExists(t, client, bucket, "data/src/oob.txt") {
+		t.Errorf("--full-rescan should delete the out-of-band data/src/oob.txt")
+	}
+}
+
+// TestE2E_SyncFanOut syncs a local directory to two prefixes in one run:
+// each destination gets its own plan and delete set, and an object one
+// destination already has is only uploaded to the other. A remote source
+// is then fanned out server-side. The uploads carry the shared metadata
+// and the guessed Content-Type to every destination.
+func TestE2E_SyncFanOut(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "a.txt"), "a")
+	writeFile(t, filepath.Join(srcDir, "b.txt"), "bb")
+	putObject(t, client, bucket, "one/src/a.txt", "a")
+	putObject(t, client, bucket, "two/src/stale.txt", "stale")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--size-only", "--delete", "--yes", "--metadata", "origin=laptop", srcDir, "s3://"+bucket+"/one/", "--to", "s3://"+bucket+"/two/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for _, key := range []string{"one/src/a.txt", "two/src/a.txt"} {
+		if got := objectContent(t, client, bucket, key); got != "a" {
+			t.Errorf("%s = %q, want %q", key, got, "a")
+		}
+	}
+	for _, key := range []string{"one/src/b.txt", "two/src/b.txt"} {
+		if got := objectContent(t, client, bucket, key); got != "bb" {
+			t.Errorf("%s = %q, want %q", key, got, "bb")
+		}
+		head, err := client.HeadObject(t.Context(), &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			t.Fatalf("HeadObject %s: %v", key, err)
+		}
+		if head.Metadata["origin"] != "laptop" || !strings.HasPrefix(aws.ToString(head.ContentType), "text/plain") {
+			t.Errorf("%s metadata = %v, Content-Type = %q, want origin=laptop and text/plain", key, head.Metadata, aws.ToString(head.ContentType))
+		}
+	}
+	if objectExists(t, client, bucket, "two/src/stale.txt") {
+		t.Errorf("two/src/stale.txt was not deleted")
+	}
+	if strings.Contains(res.Stdout, "one/src/a.txt") {
+		t.Errorf("unchanged one/src/a.txt was copied again:\n%s", res.Stdout)
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "s3://"+bucket+"/one/", "s3://"+bucket+"/three/", "s3://"+bucket+"/four/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync s3 fan-out failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	for _, key := range []string{"three/src/b.txt", "four/src/b.txt"} {
+		if got := objectContent(t, client, bucket, key); got != "bb" {
+			t.Errorf("%s = %q, want %q", key, got, "bb")
+		}
+	}
+
+	res = runS6cmd(t, workdir, endpoint, "sync", "--plan-out", filepath.Join(workdir, "plan.json"), srcDir, "s3://"+bucket+"/one/", "--to", "s3://"+bucket+"/two/")
+	if res.ExitCode == 0 {
+		t.Errorf("sync --plan-out to several destinations succeeded")
+	}
+}
+
+// TestE2E_SyncShowProgress verifies that with stderr redirected
+// --show-progress prints plain summary lines, without the control
+// characters of the terminal display, ending with the final counts.
+func TestE2E_SyncShowProgress(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "a.txt"), "a-content")
+	writeFile(t, filepath.Join(srcDir, "sub", "b.txt"), "b-content")
+
+	res := runS6cmd(t, workdir, endpoint, "sync", "--show-transfers", srcDir+"/", "s3://"+bucket+"/")
+	if res.ExitCode != 0 {
+		t.Fatalf("s6cmd sync failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if strings.ContainsAny(res.Stderr, "\r\x1b") {
+		t.Errorf("stderr has terminal control characters: %q", res.Stderr)
+	}
+	if !strings.Contains(res.Stderr, "2/2 objects, 18/18 bytes (100%)") {
+		t.Errorf("stderr = %q, want the final progress line", res.Stderr)
+	}
+}
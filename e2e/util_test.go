@@ -94,11 +94,31 @@ func runS6cmdRaw(t *testing.T, workdir string, args []string) s6cmdResult {
 // inject config into the tests.
 func runS6cmdRawStdin(t *testing.T, workdir, stdin string, args []string) s6cmdResult {
 	t.Helper()
-	cmd := exec.Command(s6cmdPath, args...)
-	cmd.Dir = workdir
+	cmd := s6cmdCommand(t, workdir, args)
 	if stdin != "" {
 		cmd.Stdin = strings.NewReader(stdin)
 	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		// ExitError is expected when a command fails; surface its code.
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return s6cmdResult{stdout.String(), stderr.String(), exitErr.ExitCode()}
+		}
+		t.Fatalf("failed to run s6cmd: %v", err)
+	}
+	return s6cmdResult{stdout.String(), stderr.String(), 0}
+}
+
+// s6cmdCommand builds the exec.Cmd for the s6cmd binary with the isolated
+// test environment. Long-running commands (sync --watch) start it
+// themselves; everything else goes through runS6cmdRawStdin.
+func s6cmdCommand(t *testing.T, workdir string, args []string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(s6cmdPath, args...)
+	cmd.Dir = workdir
 	// Inherit the parent env so things work in CI, but override the
 	// credentials/region with our test values.
 	env := os.Environ()
@@ -126,18 +146,7 @@ func runS6cmdRawStdin(t *testing.T, workdir, stdin string, args []string) s6cmdR
 		"S6CMD_CONFIG=",
 	)
 	cmd.Env = env
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
-	if err != nil {
-		// ExitError is expected when a command fails; surface its code.
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return s6cmdResult{stdout.String(), stderr.String(), exitErr.ExitCode()}
-		}
-		t.Fatalf("failed to run s6cmd: %v", err)
-	}
-	return s6cmdResult{stdout.String(), stderr.String(), 0}
+	return cmd
 }
 
 // s3Client returns an S3 client configured against the given gofakes3
@@ -0,0 +1,77 @@
+package e2e
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestE2E_VerifyLocalToS3 verifies that `s6cmd verify` exits 0 when every
+// local file matches its object, and reports a mismatch, a missing key and
+// an extra key otherwise.
+func TestE2E_VerifyLocalToS3(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	srcDir := filepath.Join(workdir, "src")
+	writeFile(t, filepath.Join(srcDir, "a.txt"), "a-content")
+	writeFile(t, filepath.Join(srcDir, "nested", "b.txt"), "b-content")
+	putObject(t, client, bucket, "prefix/a.txt", "a-content")
+	putObject(t, client, bucket, "prefix/nested/b.txt", "b-content")
+
+	res := runS6cmd(t, workdir, endpoint, "verify", srcDir, "s3://"+bucket+"/prefix/")
+	if res.ExitCode != 0 {
+		t.Fatalf("verify of identical trees failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+
+	// Same size, different bytes: only the content comparison catches it.
+	putObject(t, client, bucket, "prefix/a.txt", "A-content")
+	putObject(t, client, bucket, "prefix/extra.txt", "extra")
+	writeFile(t, filepath.Join(srcDir, "c.txt"), "c-content")
+
+	res = runS6cmd(t, workdir, endpoint, "verify", srcDir, "s3://"+bucket+"/prefix/")
+	if res.ExitCode != 1 {
+		t.Fatalf("verify exit code = %d, want 1\nstdout: %s\nstderr: %s", res.ExitCode, res.Stdout, res.Stderr)
+	}
+	for _, want := range []string{
+		"mismatch " + filepath.Join(srcDir, "a.txt"),
+		"missing " + filepath.Join(srcDir, "c.txt"),
+		"extra s3://" + bucket + "/prefix/extra.txt",
+	} {
+		if !strings.Contains(res.Stdout, want) {
+			t.Errorf("stdout = %q, want a line containing %q", res.Stdout, want)
+		}
+	}
+	if strings.Contains(res.Stdout, "b.txt") {
+		t.Errorf("stdout = %q, matching nested/b.txt should not be reported", res.Stdout)
+	}
+}
+
+// TestE2E_VerifyStreamS3ToS3 verifies prefix-to-prefix verification with
+// --stream, which digests both objects with ranged reads instead of
+// trusting ETags.
+func TestE2E_VerifyStreamS3ToS3(t *testing.T) {
+	t.Parallel()
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	content := strings.Repeat("0123456789", 300*1024)
+	putObject(t, client, bucket, "src/big.bin", content)
+	putObject(t, client, bucket, "dst/big.bin", content)
+
+	workdir := t.TempDir()
+	res := runS6cmd(t, workdir, endpoint, "verify", "--stream", "--part-size", "1", "--concurrency", "3",
+		"s3://"+bucket+"/src/", "s3://"+bucket+"/dst/")
+	if res.ExitCode != 0 {
+		t.Fatalf("verify --stream failed: %s\nstderr: %s", res.Stdout, res.Stderr)
+	}
+	if !strings.Contains(res.Stdout, "verified 1 of 1 objects") {
+		t.Errorf("stdout = %q, want the summary line", res.Stdout)
+	}
+}
@@ -0,0 +1,166 @@
+//go:build !windows
+
+package e2e
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestE2E_SyncWatch verifies that sync --watch mirrors creates, updates
+// and (with --delete) removals after the initial sync, and that SIGINT
+// stops it with the usual interrupted exit code. It runs once with
+// inotify (on Linux) and once with --poll rescans only.
+func TestE2E_SyncWatch(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []string{"notify", "poll"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+			testSyncWatch(t, mode == "poll")
+		})
+	}
+}
+
+func testSyncWatch(t *testing.T, poll bool) {
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	dir := filepath.Join(workdir, "dir")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+
+	// On Linux the notify run rescans once an hour, so only inotify
+	// can deliver the changes in time.
+	interval := "100ms"
+	if !poll && runtime.GOOS == "linux" {
+		interval = "1h"
+	}
+	args := []string{
+		"--endpoint-url", endpoint, "--path-style",
+		"sync", "--watch", "--watch-interval", interval, "--debounce", "100ms", "--delete", "--yes",
+	}
+	if poll {
+		args = append(args, "--poll")
+	}
+	cmd := s6cmdCommand(t, workdir, append(args, dir+"/", "s3://"+bucket+"/p/"))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start s6cmd sync --watch: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(15 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s\nstdout: %s\nstderr: %s", what, stdout.String(), stderr.String())
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	hasContent := func(key, want string) func() bool {
+		return func() bool {
+			return objectExists(t, client, bucket, key) && objectContent(t, client, bucket, key) == want
+		}
+	}
+
+	eventually("the initial sync", hasContent("p/a.txt", "a"))
+
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a, changed")
+	eventually("the new file", hasContent("p/sub/b.txt", "b"))
+	eventually("the changed file", hasContent("p/a.txt", "a, changed"))
+
+	if err := os.Remove(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("the removal", func() bool { return !objectExists(t, client, bucket, "p/a.txt") })
+
+	if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+		t.Fatalf("signal: %v", err)
+	}
+	select {
+	case err := <-exited:
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 130 {
+			t.Fatalf("sync --watch should exit 130 on SIGINT, got %v\nstderr: %s", err, stderr.String())
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatalf("sync --watch did not exit after SIGINT")
+	}
+}
+
+// TestE2E_SyncWatchInitialFailure verifies that a file the initial sync of
+// --watch could not read does not end the watch, and is uploaded by the
+// first batch once it is readable.
+func TestE2E_SyncWatchInitialFailure(t *testing.T) {
+	t.Parallel()
+	if os.Geteuid() == 0 {
+		t.Skip("running as root: permission bits do not block access")
+	}
+	endpoint := s3ServerEndpoint(t)
+	client := s3Client(t, endpoint)
+	bucket := s3BucketFromTestName(t)
+	createBucket(t, client, bucket)
+
+	workdir := t.TempDir()
+	dir := filepath.Join(workdir, "dir")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	locked := filepath.Join(dir, "locked.txt")
+	writeFile(t, locked, "locked")
+	if err := os.Chmod(locked, 0o000); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := s6cmdCommand(t, workdir, []string{
+		"--endpoint-url", endpoint, "--path-style",
+		"sync", "--watch", "--poll", "--watch-interval", "100ms", "--debounce", "100ms",
+		dir + "/", "s3://" + bucket + "/p/",
+	})
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start s6cmd sync --watch: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	deadline := time.Now().Add(15 * time.Second)
+	for !objectExists(t, client, bucket, "p/a.txt") {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the initial sync\nstderr: %s", stderr.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	select {
+	case err := <-exited:
+		t.Fatalf("sync --watch exited after a failed file: %v\nstderr: %s", err, stderr.String())
+	case <-time.After(300 * time.Millisecond):
+	}
+	if err := os.Chmod(locked, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for !objectExists(t, client, bucket, "p/locked.txt") {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the retry of locked.txt\nstderr: %s", stderr.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = cmd.Process.Signal(syscall.SIGINT)
+	<-exited
+}
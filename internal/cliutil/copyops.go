@@ -11,12 +11,15 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/LinPr/s6cmd/internal/errorpkg"
 	"github.com/LinPr/s6cmd/internal/progressbar"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 )
 
 // TransferSpec bundles the flag-driven knobs a single cp/mv invocation
@@ -109,8 +112,9 @@ func (t *TransferSpec) ShouldOverride(ctx context.Context, store *storage.Storag
 //
 // A skip decided by ShouldOverride is returned as the warning sentinel so
 // the caller can tell "copied" from "skipped" (mv must not delete the
-// source of a skipped copy); errorpkg.IsWarning recognizes it.
-func (t *TransferSpec) Copy(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL) error {
+// source of a skipped copy); errorpkg.IsWarning recognizes it. On success
+// the object written is returned.
+func (t *TransferSpec) Copy(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL) (*storage.Object, error) {
 	directive := t.Shared.MetadataDirective
 	if directive == "" {
 		directive = MetadataDirectiveReplace
@@ -119,31 +123,32 @@ func (t *TransferSpec) Copy(ctx context.Context, store *storage.Storage, srcURL,
 	md.Directive = directive
 
 	if err := t.ShouldOverride(ctx, store, srcURL, dstURL); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := store.Copy(ctx, srcURL, dstURL, md); err != nil {
-		return err
+	obj, err := store.Copy(ctx, srcURL, dstURL, md)
+	if err != nil {
+		return nil, err
 	}
 
 	log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.String(), Destination: dstURL.String()})
-	return nil
+	return obj, nil
 }
 
 // Download downloads a remote object to a local file via the multipart
 // downloader. It writes to a temp file in the destination directory and
 // renames on success so a partial download never replaces a complete file.
 // Under DryRun the operation is logged and no local file is created or
-// truncated.
-func (t *TransferSpec) Download(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, pb progressbar.ProgressBar) error {
+// truncated. On success the file written is returned, with its size.
+func (t *TransferSpec) Download(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, pb progressbar.ProgressBar) (*storage.Object, error) {
 	if err := t.ShouldOverride(ctx, store, srcURL, dstURL); err != nil {
-		return err
+		return nil, err
 	}
 
 	if t.DryRun {
 		log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.String(), Destination: dstURL.Absolute()})
 		pb.IncrementCompletedObjects()
-		return nil
+		return &storage.Object{StorageURL: dstURL}, nil
 	}
 
 	local := localTempStore(store, dstURL)
@@ -151,21 +156,25 @@ func (t *TransferSpec) Download(ctx context.Context, store *storage.Storage, src
 		// Fall back to the legacy DownloadFile wrapper when the local
 		// backend does not expose CreateTemp/Rename (it always does in
 		// practice, but we do not want a panic if a mock is plugged in).
-		return store.DownloadFile(ctx, srcURL.Bucket, srcURL.Path, dstURL.Absolute(), t.Shared.Concurrency, t.Shared.PartSizeBytes())
+		if err := store.DownloadFile(ctx, srcURL.Bucket, srcURL.Path, dstURL.Absolute(), t.Shared.Concurrency, t.Shared.PartSizeBytes(), CountProgress(pb)); err != nil {
+			return nil, err
+		}
+		pb.IncrementCompletedObjects()
+		return &storage.Object{StorageURL: dstURL}, nil
 	}
 
 	dstPath := dstURL.Dir()
 	if err := local.MkdirAll(dstPath); err != nil {
-		return err
+		return nil, err
 	}
 	file, err := local.CreateTemp(dstPath, "s6cmd-")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	tempPath := file.Name()
 
 	writer := NewCountingReaderWriter(file, pb)
-	_, err = store.Get(ctx, srcURL, writer, t.Shared.Concurrency, t.Shared.PartSizeBytes())
+	n, err := store.Get(ctx, srcURL, writer, t.Shared.Concurrency, t.Shared.PartSizeBytes())
 	// A close-time write-back error (NFS, ENOSPC) means the temp file may
 	// be corrupt; it must fail the transfer instead of being renamed over
 	// the destination.
@@ -174,39 +183,42 @@ func (t *TransferSpec) Download(ctx context.Context, store *storage.Storage, src
 	}
 	if err != nil {
 		_ = os.Remove(tempPath)
-		return err
+		return nil, err
 	}
 	if err := local.Rename(tempPath, dstURL.Absolute()); err != nil {
 		_ = os.Remove(tempPath)
-		return err
+		return nil, err
 	}
 
 	log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.String(), Destination: dstURL.Absolute()})
 	pb.IncrementCompletedObjects()
-	return nil
+	return &storage.Object{StorageURL: dstURL, Size: n}, nil
 }
 
 // Upload uploads a local file to S3 via the multipart uploader. The
 // content type is guessed from the extension (and the first 512 bytes)
-// when --content-type is not set explicitly.
-func (t *TransferSpec) Upload(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, pb progressbar.ProgressBar) error {
+// when --content-type is not set explicitly. On success the object
+// written is returned.
+func (t *TransferSpec) Upload(ctx context.Context, store *storage.Storage, srcURL, dstURL *storage.StorageURL, pb progressbar.ProgressBar) (*storage.Object, error) {
 	local := localTempStore(store, srcURL)
 	if local == nil {
-		_, err := store.UploadFile(ctx, srcURL.Absolute(), dstURL.Bucket, dstURL.Path, t.Shared.Concurrency, t.Shared.PartSizeBytes())
-		if err == nil {
-			log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.Absolute(), Destination: dstURL.String()})
+		out, err := store.UploadFile(ctx, srcURL.Absolute(), dstURL.Bucket, dstURL.Path, t.Shared.Concurrency, t.Shared.PartSizeBytes(), CountProgress(pb))
+		if err != nil {
+			return nil, err
 		}
-		return err
+		log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.Absolute(), Destination: dstURL.String()})
+		pb.IncrementCompletedObjects()
+		return UploadedObject(dstURL, out), nil
 	}
 
 	file, err := local.Open(srcURL.Absolute())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	if err := t.ShouldOverride(ctx, store, srcURL, dstURL); err != nil {
-		return err
+		return nil, err
 	}
 
 	md := t.Metadata()
@@ -215,13 +227,14 @@ func (t *TransferSpec) Upload(ctx context.Context, store *storage.Storage, srcUR
 	}
 
 	reader := NewCountingReaderWriter(file, pb)
-	if err := store.Put(ctx, reader, dstURL, md, t.Shared.Concurrency, t.Shared.PartSizeBytes()); err != nil {
-		return err
+	obj, err := store.Put(ctx, reader, dstURL, md, t.Shared.Concurrency, t.Shared.PartSizeBytes())
+	if err != nil {
+		return nil, err
 	}
 
 	log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.Absolute(), Destination: dstURL.String()})
 	pb.IncrementCompletedObjects()
-	return nil
+	return obj, nil
 }
 
 // ExpandSource materializes the list of source objects. For a single
@@ -302,6 +315,34 @@ func PrepareRemoteDestination(srcURL, dstURL *storage.StorageURL, flatten, isBat
 	return dstURL.Clone()
 }
 
+// UploadedObject is the object an upload to dstURL wrote, as described by
+// the uploader's output.
+func UploadedObject(dstURL *storage.StorageURL, out *manager.UploadOutput) *storage.Object {
+	return &storage.Object{StorageURL: dstURL, Etag: aws.ToString(out.ETag), VersionID: aws.ToString(out.VersionID)}
+}
+
+// ResumeDestination is the destination the resume journal records for
+// srcURL when its transfer to dstURL never started: the resolved object
+// URL or file path, which a single-object cp or mv replays as is. It
+// returns "" for a remote key that would escape a local destination
+// directory, which the transfer itself would have refused.
+func ResumeDestination(srcURL, dstURL *storage.StorageURL, flatten, isBatch bool) string {
+	if dstURL.IsRemote() {
+		return PrepareRemoteDestination(srcURL, dstURL, flatten, isBatch).String()
+	}
+	if !isBatch {
+		return dstURL.Absolute()
+	}
+	objname := srcURL.Base()
+	if !flatten {
+		objname = srcURL.Relative()
+	}
+	if srcURL.IsRemote() && storage.EnsureLocalRelPath(srcURL.Path, objname) != nil {
+		return ""
+	}
+	return filepath.Join(dstURL.Absolute(), filepath.FromSlash(objname))
+}
+
 // PrepareLocalDestination resolves the destination URL for a local target:
 // for batch sources the dst is a directory; otherwise a single-file dst
 // may be renamed to the source's base name when it points at a directory.
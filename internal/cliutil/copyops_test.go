@@ -87,7 +87,7 @@ func TestTransferSpecDownload_CloseErrorFailsTransfer(t *testing.T) {
 	}
 
 	spec := &TransferSpec{Op: "cp", Shared: NewSharedFlags()}
-	err = spec.Download(context.Background(), store, srcURL, dstURL, &progressbar.NoOp{})
+	_, err = spec.Download(context.Background(), store, srcURL, dstURL, &progressbar.NoOp{})
 	if err == nil {
 		t.Fatal("Download must fail when the temp file's Close reports an error")
 	}
@@ -140,7 +140,7 @@ func TestTransferSpecDownload_Success(t *testing.T) {
 	}
 
 	spec := &TransferSpec{Op: "cp", Shared: NewSharedFlags()}
-	if err := spec.Download(context.Background(), store, srcURL, dstURL, &progressbar.NoOp{}); err != nil {
+	if _, err := spec.Download(context.Background(), store, srcURL, dstURL, &progressbar.NoOp{}); err != nil {
 		t.Fatalf("Download: %v", err)
 	}
 	got, err := os.ReadFile(dstPath)
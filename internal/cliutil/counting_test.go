@@ -24,6 +24,9 @@ func (f *fakeBar) IncrementCompletedObjects() {}
 func (f *fakeBar) IncrementTotalObjects()     {}
 func (f *fakeBar) AddCompletedBytes(n int64)  { f.bytes += n }
 func (f *fakeBar) AddTotalBytes(n int64)      {}
+func (f *fakeBar) Transfer(name string, size int64) progressbar.ProgressBar {
+	return f
+}
 
 // newFileWithContent creates a temp file and writes content into it, returning
 // the open *os.File (caller must Close).
@@ -0,0 +1,117 @@
+package cliutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// DeleteObjects deletes objects[i] for every i in idx and returns one
+// result per index, logging every deletion. The remote objects are
+// deleted through MultiDelete in batches, and the local ones through the
+// local store.
+func DeleteObjects(ctx context.Context, store *storage.Storage, objects []*storage.Object, idx []int) []error {
+	errs := make([]error, len(idx))
+	var remote, local []int
+	for n, i := range idx {
+		if objects[i].StorageURL.IsRemote() {
+			remote = append(remote, n)
+		} else {
+			local = append(local, n)
+		}
+	}
+	for _, group := range [][]int{remote, local} {
+		if len(group) == 0 {
+			continue
+		}
+		sub := make([]int, len(group))
+		for k, n := range group {
+			sub[k] = idx[n]
+		}
+		for k, err := range multiDelete(ctx, store, objects, sub) {
+			errs[group[k]] = err
+		}
+	}
+	return errs
+}
+
+// multiDelete deletes objects[i] for every i in idx, which are all remote
+// or all local, through MultiDelete. A failed DeleteObjects call reports
+// no keys: every key it leaves without a result gets that call's error.
+// After the first Ctrl-C no more keys are sent; they get
+// errorpkg.ErrNotStarted.
+func multiDelete(ctx context.Context, store *storage.Storage, objects []*storage.Object, idx []int) []error {
+	// Build the URL channel consumed by MultiDelete. We feed it from a
+	// goroutine so MultiDelete's batching goroutine can start draining
+	// immediately.
+	urlCh := make(chan *storage.StorageURL)
+	go func() {
+		defer close(urlCh)
+		for _, i := range idx {
+			if parallel.Stopped() {
+				return
+			}
+			urlCh <- objects[i].StorageURL
+		}
+	}()
+
+	pos := make(map[string]int, len(idx))
+	for n, i := range idx {
+		pos[deleteKey(objects[i].StorageURL)] = n
+	}
+	errs := make([]error, len(idx))
+	reported := make([]bool, len(idx))
+	var callErr error
+
+	// MultiDelete returns a per-URL result channel. Drain it on the
+	// calling goroutine so the log output is ordered.
+	for obj := range store.MultiDelete(ctx, urlCh) {
+		n, ok := -1, false
+		if obj.StorageURL != nil {
+			n, ok = pos[deleteKey(obj.StorageURL)]
+		}
+		if !ok {
+			if obj.Err != nil && callErr == nil {
+				callErr = obj.Err
+			}
+			continue
+		}
+		reported[n] = true
+		if obj.Err != nil {
+			errs[n] = obj.Err
+			continue
+		}
+		log.Info(log.InfoMessage{Operation: "rm", Source: obj.String()})
+	}
+	if callErr == nil {
+		callErr = errorpkg.ErrNotStarted
+	}
+	for n := range errs {
+		if !reported[n] {
+			errs[n] = callErr
+		}
+	}
+	return errs
+}
+
+// deleteKey identifies an object version the way MultiDelete reports it.
+func deleteKey(u *storage.StorageURL) string {
+	return u.Bucket + "/" + u.Path + "\x00" + u.VersionID
+}
+
+// DeleteFailure describes a failed deletion for --failed-out. A specific
+// version is kept as a comment: replaying it as `rm <url>` would delete
+// the current version instead.
+func DeleteFailure(u *storage.StorageURL, err error) error {
+	if u.VersionID != "" {
+		return fmt.Errorf("rm %s (version %s): %w", u, u.VersionID, err)
+	}
+	if !u.IsRemote() {
+		return &errorpkg.Error{Op: "rm", Src: u.Absolute(), Err: err}
+	}
+	return &errorpkg.Error{Op: "rm", Src: u.String(), Err: err}
+}
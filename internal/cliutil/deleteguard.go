@@ -0,0 +1,178 @@
+package cliutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+// DeleteGuard is the --max-delete / --backup-dir pair shared by the
+// commands that destroy objects (sync --delete, rm, find --delete). A typo
+// in a source path turns every destination object into an "extra", so the
+// guard caps the size of the delete set before anything is deleted, and
+// the backup directory keeps a server-side copy of every object that is
+// deleted or overwritten.
+type DeleteGuard struct {
+	// MaxDelete is an absolute count ("100") or a percentage of the
+	// objects considered for deletion ("10%"). Empty means no limit.
+	MaxDelete string
+	// BackupDir is an s3:// bucket or prefix. Objects are copied to
+	// BackupDir/<bucket>/<key> (BackupDir/<bucket>/<key>.<version ID> for
+	// a version) before they are deleted or overwritten.
+	BackupDir string
+
+	limit   int
+	percent bool
+	backup  *storage.StorageURL
+}
+
+// AddToCmd registers --max-delete and --backup-dir on cmd.
+func (g *DeleteGuard) AddToCmd(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&g.MaxDelete, "max-delete", "", "abort before deleting anything when more than N objects (or N% of the destination) would be deleted")
+	cmd.Flags().StringVar(&g.BackupDir, "backup-dir", "", "copy deleted and overwritten objects to this s3:// prefix, under <bucket>/<key>, before removing them; a version gets its version ID appended to the key. Objects over 5 GiB cannot be backed up and are kept")
+}
+
+// Validate parses --max-delete and --backup-dir. It must be called before
+// Check or Backup.
+func (g *DeleteGuard) Validate() error {
+	g.limit, g.percent = -1, false
+	if g.MaxDelete != "" {
+		value, percent := strings.CutSuffix(g.MaxDelete, "%")
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || (percent && n > 100) {
+			return fmt.Errorf("invalid --max-delete %q: want a non-negative count or a percentage such as 10%%", g.MaxDelete)
+		}
+		g.limit, g.percent = n, percent
+	}
+
+	g.backup = nil
+	if g.BackupDir != "" {
+		u, err := storage.NewStorageURL(g.BackupDir, storage.WithRaw(true))
+		if err != nil {
+			return fmt.Errorf("invalid --backup-dir: %w", err)
+		}
+		if !u.IsRemote() || u.Bucket == "" {
+			return fmt.Errorf("--backup-dir must be an s3:// bucket or prefix, got %q", g.BackupDir)
+		}
+		if !u.IsBucket() && !u.IsPrefix() {
+			u.Path += "/"
+		}
+		g.backup = u
+	}
+	return nil
+}
+
+// Check returns an error when deleting n of total objects exceeds
+// --max-delete. Percentages are taken of total, the number of objects the
+// delete set was drawn from (the destination listing for sync, the
+// expanded source for rm).
+func (g *DeleteGuard) Check(n, total int) error {
+	if g.limit < 0 || n == 0 {
+		return nil
+	}
+	if g.percent {
+		if total > 0 && n*100 <= g.limit*total {
+			return nil
+		}
+		return fmt.Errorf("refusing to delete %d of %d objects: exceeds --max-delete %s", n, total, g.MaxDelete)
+	}
+	if n <= g.limit {
+		return nil
+	}
+	return fmt.Errorf("refusing to delete %d objects: exceeds --max-delete %s", n, g.MaxDelete)
+}
+
+// BackupEnabled reports whether --backup-dir is set.
+func (g *DeleteGuard) BackupEnabled() bool {
+	return g.backup != nil
+}
+
+// Contains reports whether the backup directory lies under dst, where a
+// later sync --delete would treat the backups as extra objects.
+func (g *DeleteGuard) Contains(dst *storage.StorageURL) bool {
+	if g.backup == nil || !dst.IsRemote() || g.backup.Bucket != dst.Bucket {
+		return false
+	}
+	prefix := dst.Path
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(g.backup.Path, prefix)
+}
+
+// BackupURL returns where Backup copies obj: BackupDir/<bucket>/<key>, or
+// BackupDir/<bucket>/<key>.<version ID> for a version. The bucket keeps the
+// backups of the same key in different buckets apart, and the version ID
+// keeps the versions of one key removed by rm --all-versions apart.
+func (g *DeleteGuard) BackupURL(obj *storage.Object) *storage.StorageURL {
+	key := obj.StorageURL.Bucket + "/" + obj.StorageURL.Path
+	if v := obj.StorageURL.VersionID; v != "" {
+		key += "." + v
+	}
+	return g.backup.Join(key)
+}
+
+// maxBackupCopySize is the largest object a single CopyObject request can
+// copy.
+const maxBackupCopySize = 5 * 1024 * 1024 * 1024
+
+// Backup copies the remote object at url to BackupURL(obj) with a
+// server-side copy. A versioned url backs up that version. It is a no-op
+// without --backup-dir, for local objects and for delete markers, which
+// have no content to keep. op names the command in the returned error.
+//
+// The copy is a single CopyObject request, so an object over 5 GiB cannot
+// be backed up: Backup fails for it and the caller keeps the object.
+func (g *DeleteGuard) Backup(ctx context.Context, store *storage.Storage, op string, obj *storage.Object) error {
+	if g.backup == nil || obj.IsDeleteMarker || !obj.StorageURL.IsRemote() {
+		return nil
+	}
+	to := g.BackupURL(obj)
+	if obj.Size > maxBackupCopySize {
+		return &errorpkg.Error{Op: op, Src: obj.StorageURL.String(), Dst: to.String(),
+			Err: fmt.Errorf("backup: object is %d bytes, over the %d-byte limit of a server-side copy; not deleting it", obj.Size, int64(maxBackupCopySize))}
+	}
+	if _, err := store.Copy(ctx, obj.StorageURL, to, storage.Metadata{}); err != nil {
+		return &errorpkg.Error{Op: op, Src: obj.StorageURL.String(), Dst: to.String(), Err: fmt.Errorf("backup: %w", err)}
+	}
+	log.Info(log.InfoMessage{Operation: "backup", Source: obj.StorageURL.String(), Destination: to.String()})
+	return nil
+}
+
+// BackupEach runs Backup for every object in parallel and returns the
+// objects whose backup succeeded. An object that could not be backed up is
+// reported and left in place rather than destroyed.
+func (g *DeleteGuard) BackupEach(ctx context.Context, store *storage.Storage, op string, objects []*storage.Object) ([]*storage.Object, error) {
+	// Every task writes only its own slot; the slice is read after
+	// waiter.Wait().
+	done := make([]bool, len(objects))
+	waiter := parallel.NewWaiter()
+	ec := NewErrorCollector(op)
+	drainDone := ec.Drain(waiter)
+	for i, obj := range objects {
+		parallel.Run(func() error {
+			if err := g.Backup(ctx, store, op, obj); err != nil {
+				return err
+			}
+			done[i] = true
+			return nil
+		}, waiter)
+	}
+	waiter.Wait()
+	drainDone()
+
+	kept := objects[:0:0]
+	for i, obj := range objects {
+		if done[i] {
+			kept = append(kept, obj)
+		}
+	}
+	return kept, ec.Aggregate()
+}
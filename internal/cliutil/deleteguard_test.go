@@ -0,0 +1,135 @@
+package cliutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// TestDeleteGuardCheck covers absolute and percentage limits, including
+// the boundary values that must still pass.
+func TestDeleteGuardCheck(t *testing.T) {
+	tests := []struct {
+		maxDelete string
+		n, total  int
+		wantErr   bool
+	}{
+		{"", 1000, 1000, false},
+		{"10", 10, 1000, false},
+		{"10", 11, 1000, true},
+		{"0", 0, 5, false},
+		{"0", 1, 5, true},
+		{"10%", 10, 100, false},
+		{"10%", 11, 100, true},
+		{"50%", 1, 2, false},
+		{"50%", 2, 3, true},
+		{"100%", 7, 7, false},
+		// Nothing listed: any delete exceeds a percentage.
+		{"10%", 1, 0, true},
+	}
+	for _, tt := range tests {
+		g := &DeleteGuard{MaxDelete: tt.maxDelete}
+		if err := g.Validate(); err != nil {
+			t.Fatalf("Validate(%q): %v", tt.maxDelete, err)
+		}
+		err := g.Check(tt.n, tt.total)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("--max-delete %s: Check(%d, %d) = %v, wantErr %v", tt.maxDelete, tt.n, tt.total, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDeleteGuardValidate(t *testing.T) {
+	for _, bad := range []string{"-1", "ten", "10%%", "101%", "%"} {
+		g := &DeleteGuard{MaxDelete: bad}
+		if err := g.Validate(); err == nil {
+			t.Errorf("Validate(--max-delete %q) = nil, want error", bad)
+		}
+	}
+	for _, bad := range []string{"trash/", "s3://"} {
+		g := &DeleteGuard{BackupDir: bad}
+		if err := g.Validate(); err == nil {
+			t.Errorf("Validate(--backup-dir %q) = nil, want error", bad)
+		}
+	}
+
+	g := &DeleteGuard{BackupDir: "s3://bucket/trash/2026-10-17"}
+	if err := g.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if got := g.backup.Join("dir/a.txt").String(); got != "s3://bucket/trash/2026-10-17/dir/a.txt" {
+		t.Errorf("backup key = %q, want the prefix joined with the key", got)
+	}
+}
+
+func TestDeleteGuardContains(t *testing.T) {
+	g := &DeleteGuard{BackupDir: "s3://bucket/data/trash/"}
+	if err := g.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	tests := []struct {
+		dst  string
+		want bool
+	}{
+		{"s3://bucket/", true},
+		{"s3://bucket/data/", true},
+		{"s3://bucket/data", true},
+		{"s3://bucket/dat", false},
+		{"s3://bucket/other/", false},
+		{"s3://other/data/", false},
+	}
+	for _, tt := range tests {
+		dst, err := storage.NewStorageURL(tt.dst)
+		if err != nil {
+			t.Fatalf("NewStorageURL(%q): %v", tt.dst, err)
+		}
+		if got := g.Contains(dst); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.dst, got, tt.want)
+		}
+	}
+}
+
+// TestDeleteGuardBackupURL verifies that two versions of one key, and one
+// key in two buckets, are backed up to different keys.
+func TestDeleteGuardBackupURL(t *testing.T) {
+	g := &DeleteGuard{BackupDir: "s3://trash/2026-10-17/"}
+	if err := g.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	backup := func(rawURL string, opts ...storage.Option) string {
+		u, err := storage.NewStorageURL(rawURL, opts...)
+		if err != nil {
+			t.Fatalf("NewStorageURL(%q): %v", rawURL, err)
+		}
+		return g.BackupURL(&storage.Object{StorageURL: u}).String()
+	}
+	v1 := backup("s3://bucket/dir/a.txt", storage.WithVersion("v1"))
+	v2 := backup("s3://bucket/dir/a.txt", storage.WithVersion("v2"))
+	if v1 != "s3://trash/2026-10-17/bucket/dir/a.txt.v1" || v2 != "s3://trash/2026-10-17/bucket/dir/a.txt.v2" {
+		t.Errorf("version backups = %q, %q, want the version ID appended to the key", v1, v2)
+	}
+	if got := backup("s3://bucket/dir/a.txt"); got != "s3://trash/2026-10-17/bucket/dir/a.txt" {
+		t.Errorf("backup = %q, want the key under its bucket", got)
+	}
+	if got := backup("s3://other/dir/a.txt"); got != "s3://trash/2026-10-17/other/dir/a.txt" {
+		t.Errorf("backup from another bucket = %q, want it apart from bucket's", got)
+	}
+}
+
+// TestDeleteGuardBackupTooLarge verifies that an object over the CopyObject
+// limit fails its backup, so the caller keeps it, without a copy request.
+func TestDeleteGuardBackupTooLarge(t *testing.T) {
+	g := &DeleteGuard{BackupDir: "s3://trash/"}
+	if err := g.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	u, err := storage.NewStorageURL("s3://bucket/big.bin")
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	err = g.Backup(t.Context(), nil, "rm", &storage.Object{StorageURL: u, Size: maxBackupCopySize + 1})
+	if err == nil || !strings.Contains(err.Error(), "server-side copy") {
+		t.Errorf("Backup of an oversized object = %v, want the copy limit error", err)
+	}
+}
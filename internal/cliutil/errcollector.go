@@ -24,6 +24,8 @@ type ErrorCollector struct {
 
 	mu   sync.Mutex
 	errs []error
+	// onFailure, when set, is called with every real error collected.
+	onFailure func(error)
 }
 
 // NewErrorCollector creates a collector for the given operation name.
@@ -35,7 +37,18 @@ func NewErrorCollector(op string) *ErrorCollector {
 // the drain goroutine started by Drain and the caller's submission loop
 // may collect concurrently.
 func (c *ErrorCollector) Collect(err error) {
-	if err == nil || errorpkg.IsCancelation(err) {
+	if err == nil {
+		return
+	}
+	if errorpkg.IsCancelation(err) {
+		// Not a failure, but an operation an interrupt canceled or kept
+		// from starting is unfinished work for the resume journal.
+		c.mu.Lock()
+		onFailure := c.onFailure
+		c.mu.Unlock()
+		if onFailure != nil {
+			onFailure(err)
+		}
 		return
 	}
 	if errorpkg.IsWarning(err) {
@@ -45,7 +58,20 @@ func (c *ErrorCollector) Collect(err error) {
 	log.Error(log.ErrorMessage{Operation: c.op, Err: err.Error()})
 	c.mu.Lock()
 	c.errs = append(c.errs, err)
+	onFailure := c.onFailure
 	c.mu.Unlock()
+	if onFailure != nil {
+		onFailure(err)
+	}
+}
+
+// OnFailure registers fn to be called with every real error and every
+// cancelation collected from then on (see FailureFlags.Track). fn may be
+// called concurrently.
+func (c *ErrorCollector) OnFailure(fn func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFailure = fn
 }
 
 // HasError reports whether at least one non-warning error has been
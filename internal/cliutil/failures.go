@@ -0,0 +1,300 @@
+package cliutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/interrupt"
+	"github.com/LinPr/s6cmd/internal/parallel"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultTaskRetries      = 2
+	defaultTaskRetryBackoff = time.Second
+	// maxTaskRetryBackoff caps the doubling backoff.
+	maxTaskRetryBackoff = 30 * time.Second
+)
+
+// FailureFlags is the --task-retries / --task-retry-backoff / --failed-out
+// set shared by cp, mv, rm and sync. A task that fails with a transient
+// error (errorpkg.IsTransient) is run again after an exponential backoff;
+// this is on top of the SDK's per-request retries, which give up after a
+// few seconds. Whatever still fails is written to --failed-out as one
+// `cp`, `mv` or `rm` line per object, which `s6cmd run` replays. After an
+// interrupt the operations it canceled or kept from starting are written
+// too, to the resume journal when --failed-out is not set.
+type FailureFlags struct {
+	// Retries is how many times a task is run again after a transient
+	// failure. 0 disables task retries.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for every
+	// further retry, up to maxTaskRetryBackoff.
+	Backoff time.Duration
+	// FailedOut is the file the failed operations are written to.
+	FailedOut string
+
+	// failed is shared by the copies of the flags the commands make of
+	// their Flags.
+	failed *failedLines
+}
+
+// failedLines collects the --failed-out lines of concurrent tasks.
+type failedLines struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// AddToCmd registers --task-retries, --task-retry-backoff and --failed-out
+// on cmd.
+func (f *FailureFlags) AddToCmd(cmd *cobra.Command) {
+	f.failed = &failedLines{}
+	cmd.Flags().IntVar(&f.Retries, "task-retries", defaultTaskRetries, "run an object's transfer or delete again up to N times when it fails with a transient error (throttling, 5xx, timeout)")
+	cmd.Flags().DurationVar(&f.Backoff, "task-retry-backoff", defaultTaskRetryBackoff, "wait before the first task retry, doubled for every further retry")
+	cmd.Flags().StringVar(&f.FailedOut, "failed-out", "", "write every operation that still failed to this file, one command per line, to retry them with `s6cmd run <file>`")
+}
+
+// Validate checks the retry flags.
+func (f *FailureFlags) Validate() error {
+	if f.Retries < 0 {
+		return fmt.Errorf("--task-retries must not be negative")
+	}
+	if f.Backoff < 0 {
+		return fmt.Errorf("--task-retry-backoff must not be negative")
+	}
+	return nil
+}
+
+// Wrap returns task run again, up to Retries times, while it fails with a
+// transient error.
+func (f *FailureFlags) Wrap(ctx context.Context, task parallel.Task) parallel.Task {
+	if f.Retries == 0 {
+		return task
+	}
+	return func() error {
+		err := task()
+		for attempt := 1; attempt <= f.Retries && errorpkg.IsTransient(err); attempt++ {
+			if f.wait(ctx, attempt, err) != nil {
+				return err
+			}
+			err = task()
+		}
+		return err
+	}
+}
+
+// RetryEach is Wrap for an operation on n targets that reports one result
+// per target, such as a fan-out to several destinations: run is called
+// with the indexes to try, and again with the transiently failed ones.
+// It returns the final result of every target.
+func (f *FailureFlags) RetryEach(ctx context.Context, n int, run func(idx []int) []error) []error {
+	errs := make([]error, n)
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	for attempt := 0; ; attempt++ {
+		for i, err := range run(idx) {
+			errs[idx[i]] = err
+		}
+		var again []int
+		for _, i := range idx {
+			if errorpkg.IsTransient(errs[i]) {
+				again = append(again, i)
+			}
+		}
+		if len(again) == 0 || attempt == f.Retries || f.wait(ctx, attempt+1, errs[again[0]]) != nil {
+			return errs
+		}
+		idx = again
+	}
+}
+
+// wait sleeps before retry number attempt. It returns the context's error
+// when the run is interrupted.
+func (f *FailureFlags) wait(ctx context.Context, attempt int, err error) error {
+	d := min(f.Backoff<<(attempt-1), maxTaskRetryBackoff)
+	if d > 0 {
+		// Jitter keeps the tasks that failed together, typically on
+		// throttling, from retrying together.
+		d = d/2 + rand.N(d/2+1)
+	}
+	log.Debug(log.DebugMessage{Err: fmt.Sprintf("retry %d/%d in %s after transient error: %v", attempt, f.Retries, d.Round(time.Millisecond), err)})
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Track makes ec record every failure it collects, and every operation an
+// interrupt canceled or kept from starting, for --failed-out and the
+// resume journal.
+func (f *FailureFlags) Track(ec *ErrorCollector) {
+	ec.OnFailure(f.Record)
+}
+
+// Record adds the operation err describes to --failed-out. A cp/mv of a
+// source to a destination, or an rm, becomes a command line for `s6cmd
+// run`; any other failure, which rerunning an object operation would not
+// fix, is kept as a comment. A canceled operation is only recorded as a
+// command line: it is unfinished work, not a failure to explain.
+func (f *FailureFlags) Record(err error) {
+	if err == nil || errorpkg.IsWarning(err) {
+		return
+	}
+	line := failedLine(err)
+	if errorpkg.IsCancelation(err) && strings.HasPrefix(line, "#") {
+		return
+	}
+	f.add(line)
+}
+
+// NotStarted returns the results of the transfers of srcURL to dsts that
+// the first Ctrl-C kept from starting. Collected, they are not failures
+// but lines of the resume journal.
+func NotStarted(op string, srcURL *storage.StorageURL, dsts []*storage.StorageURL, flatten, isBatch bool) []error {
+	src := srcURL.String()
+	if !srcURL.IsRemote() {
+		src = srcURL.Absolute()
+	}
+	errs := make([]error, 0, len(dsts))
+	for _, dstURL := range dsts {
+		if dst := ResumeDestination(srcURL, dstURL, flatten, isBatch); dst != "" {
+			errs = append(errs, &errorpkg.Error{Op: op, Src: src, Dst: dst, Err: errorpkg.ErrNotStarted})
+		}
+	}
+	return errs
+}
+
+// RecordCommand records args as a command line of its own, for an
+// operation that is rerun as a whole, such as a sync.
+func (f *FailureFlags) RecordCommand(args []string) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	f.add(strings.Join(quoted, " "))
+}
+
+func (f *FailureFlags) add(line string) {
+	if f.failed == nil {
+		// Flags set up without AddToCmd, as in tests.
+		f.failed = &failedLines{}
+	}
+	f.failed.mu.Lock()
+	defer f.failed.mu.Unlock()
+	f.failed.lines = append(f.failed.lines, line)
+}
+
+// WriteFailed writes the recorded operations to --failed-out. The file is
+// written even when nothing failed, so a stale list from an earlier run
+// is never replayed. An interrupted run without --failed-out writes them
+// to the resume journal instead.
+func (f *FailureFlags) WriteFailed() error {
+	var lines []string
+	if f.failed != nil {
+		f.failed.mu.Lock()
+		lines = slices.Clone(f.failed.lines)
+		f.failed.mu.Unlock()
+	}
+	if f.FailedOut == "" {
+		return WriteResumeJournal(lines)
+	}
+	if err := writeCommands(f.FailedOut, lines); err != nil {
+		return fmt.Errorf("write --failed-out: %w", err)
+	}
+	if interrupt.Requested() {
+		fmt.Fprintf(os.Stderr, "interrupted: failed and unfinished operations written to %s, resume with `s6cmd run %s`\n", f.FailedOut, f.FailedOut)
+	}
+	return nil
+}
+
+// WriteResumeJournal writes lines, the `s6cmd run` commands of the
+// operations an interrupted run did not finish, to the resume journal
+// (interrupt.Journal). It does nothing when the run was not interrupted or
+// the journal is disabled with --resume-file "".
+func WriteResumeJournal(lines []string) error {
+	file := interrupt.Journal()
+	if !interrupt.Requested() || file == "" {
+		return nil
+	}
+	if err := writeCommands(file, lines); err != nil {
+		return fmt.Errorf("write resume journal: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "interrupted: %d unfinished operations written to %s, resume with `s6cmd run %s`\n", len(lines), file, file)
+	return nil
+}
+
+// writeCommands writes one command per line to file. The commands of an
+// interrupted run are headed by the command line that was interrupted.
+func writeCommands(file string, lines []string) error {
+	var b strings.Builder
+	if interrupt.Requested() {
+		b.WriteString("# unfinished operations of the interrupted s6cmd " + strings.ReplaceAll(strings.Join(os.Args[1:], " "), "\n", " ") + "\n")
+	}
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(file, []byte(b.String()), 0o644)
+}
+
+// failedLine renders a failure as an `s6cmd run` line.
+func failedLine(err error) string {
+	comment := "# " + strings.ReplaceAll(err.Error(), "\n", " ")
+	var e *errorpkg.Error
+	if !errors.As(err, &e) {
+		return comment
+	}
+	var urls []string
+	switch {
+	case (e.Op == "cp" || e.Op == "mv") && e.Src != "" && e.Dst != "":
+		urls = []string{e.Src, e.Dst}
+	case e.Op == "rm" && (e.Src == "") != (e.Dst == ""):
+		urls = []string{e.Src + e.Dst}
+	default:
+		return comment
+	}
+	args := []string{e.Op}
+	for _, u := range urls {
+		// The URLs name single objects: glob characters in a listed
+		// key are literal.
+		if strings.ContainsAny(u, "*?[") {
+			args = append(args, "--raw")
+			break
+		}
+	}
+	args = append(args, urls...)
+	for i, arg := range args {
+		// A line holds one command: a key with a newline in it can not
+		// be written as one.
+		if strings.ContainsAny(arg, "\n\r") {
+			return comment
+		}
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for the command splitter of `s6cmd run`, which
+// follows POSIX shell quoting.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"\\#$`*?[]{}()<>|&;~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
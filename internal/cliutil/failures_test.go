@@ -0,0 +1,158 @@
+package cliutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+)
+
+// TestFailedLine verifies that failed cp/mv/rm operations become `s6cmd
+// run` lines with their URLs quoted, and anything else a comment.
+func TestFailedLine(t *testing.T) {
+	errX := errors.New("x")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"cp", &errorpkg.Error{Op: "cp", Src: "/data/a.txt", Dst: "s3://b/a.txt", Err: errX}, "cp /data/a.txt s3://b/a.txt"},
+		{"mv quoted", &errorpkg.Error{Op: "mv", Src: "/data/it's here", Dst: "s3://b/k", Err: errX}, `mv '/data/it'\''s here' s3://b/k`},
+		{"glob chars", &errorpkg.Error{Op: "cp", Src: "s3://b/[a].txt", Dst: "s3://c/[a].txt", Err: errX}, "cp --raw 's3://b/[a].txt' 's3://c/[a].txt'"},
+		{"rm from Dst", &errorpkg.Error{Op: "rm", Dst: "s3://b/old", Err: errX}, "rm s3://b/old"},
+		{"wrapped", fmt.Errorf("attempt: %w", &errorpkg.Error{Op: "cp", Src: "s3://b/k", Dst: "s3://c/k", Err: errX}), "cp s3://b/k s3://c/k"},
+		{"rm backup", &errorpkg.Error{Op: "rm", Src: "s3://b/k", Dst: "s3://trash/k", Err: errX}, "# x"},
+		{"no destination", &errorpkg.Error{Op: "cp", Src: "s3://b/k", Err: errX}, "# x"},
+		{"newline in key", &errorpkg.Error{Op: "rm", Src: "s3://b/a\nb", Err: errX}, "# x"},
+		{"plain", errors.New("listing failed:\nAccessDenied"), "# listing failed: AccessDenied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failedLine(tt.err); got != tt.want {
+				t.Errorf("failedLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFailureFlags_Wrap verifies that a task is retried while it fails
+// transiently, up to Retries times, and not at all for a permanent error.
+func TestFailureFlags_Wrap(t *testing.T) {
+	f := &FailureFlags{Retries: 2}
+	calls := 0
+	err := f.Wrap(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	})()
+	if err != nil || calls != 3 {
+		t.Errorf("transient: err = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	calls = 0
+	err = f.Wrap(context.Background(), func() error {
+		calls++
+		return syscall.ECONNRESET
+	})()
+	if !errors.Is(err, syscall.ECONNRESET) || calls != 3 {
+		t.Errorf("always transient: err = %v after %d calls, want ECONNRESET after 3", err, calls)
+	}
+
+	calls = 0
+	errDenied := errors.New("access denied")
+	err = f.Wrap(context.Background(), func() error {
+		calls++
+		return errDenied
+	})()
+	if !errors.Is(err, errDenied) || calls != 1 {
+		t.Errorf("permanent: err = %v after %d calls, want the error after 1", err, calls)
+	}
+}
+
+// TestFailureFlags_RetryEach verifies that only the targets that failed
+// transiently are run again.
+func TestFailureFlags_RetryEach(t *testing.T) {
+	f := &FailureFlags{Retries: 1}
+	errDenied := errors.New("access denied")
+	var runs [][]int
+	errs := f.RetryEach(context.Background(), 3, func(idx []int) []error {
+		runs = append(runs, idx)
+		out := make([]error, len(idx))
+		if len(runs) == 1 {
+			out[1] = syscall.ECONNRESET
+			out[2] = errDenied
+		}
+		return out
+	})
+	if len(runs) != 2 || len(runs[1]) != 1 || runs[1][0] != 1 {
+		t.Fatalf("runs = %v, want [[0 1 2] [1]]", runs)
+	}
+	if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], errDenied) {
+		t.Errorf("errs = %v, want [<nil> <nil> %v]", errs, errDenied)
+	}
+}
+
+// TestFailureFlags_WriteFailed verifies that the failures an
+// ErrorCollector collects end up in --failed-out, and that the file is
+// rewritten empty by a run without failures.
+func TestFailureFlags_WriteFailed(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "failed.txt")
+	f := &FailureFlags{FailedOut: out}
+	ec := NewErrorCollector("cp")
+	f.Track(ec)
+	ec.Collect(&errorpkg.Error{Op: "cp", Src: "s3://b/k", Dst: "s3://c/k", Err: errors.New("denied")})
+	ec.Collect(errorpkg.ErrObjectIsNewer)
+	if err := f.WriteFailed(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "cp s3://b/k s3://c/k\n" {
+		t.Errorf("failed-out = %q", got)
+	}
+
+	if err := (&FailureFlags{FailedOut: out}).WriteFailed(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(out); len(got) != 0 {
+		t.Errorf("failed-out after a clean run = %q, want empty", got)
+	}
+}
+
+// TestFailureFlags_RecordInterrupted verifies that the operations an
+// interrupt kept from starting or finishing become lines, without being
+// reported as failures, while a cancelation that names no operation is
+// dropped.
+func TestFailureFlags_RecordInterrupted(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "failed.txt")
+	f := &FailureFlags{FailedOut: out}
+	ec := NewErrorCollector("cp")
+	f.Track(ec)
+	ec.Collect(&errorpkg.Error{Op: "cp", Src: "/data/a", Dst: "s3://b/a", Err: errorpkg.ErrNotStarted})
+	ec.Collect(&errorpkg.Error{Op: "cp", Src: "/data/b", Dst: "s3://b/b", Err: context.Canceled})
+	ec.Collect(context.Canceled)
+	if err := ec.Aggregate(); err != nil {
+		t.Errorf("Aggregate() = %v, want nil", err)
+	}
+	f.RecordCommand([]string{"sync", "/data/it's", "s3://b/"})
+	if err := f.WriteFailed(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cp /data/a s3://b/a\ncp /data/b s3://b/b\nsync '/data/it'\\''s' s3://b/\n"
+	if string(got) != want {
+		t.Errorf("failed-out = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+// fanout.go holds the multi-destination transfer primitives behind
+// `cp src dst1 dst2 ...` and sync's --to: a local file is read once and
+// streamed to every destination concurrently, and a remote object is
+// copied server-side to the first destination and from there to the rest.
+// Results are reported per destination so one failing target neither
+// stops nor fails the others.
+package cliutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/log"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+const (
+	// fanOutChunkSize is the size of the chunks the source is read in.
+	fanOutChunkSize = 1 << 20
+	// fanOutQueueChunks is how many chunks a destination may fall behind
+	// the fastest one before it holds the read of the source back.
+	fanOutQueueChunks = 16
+)
+
+var (
+	// errAllDestinationsFailed stops the read of the source once no
+	// destination is left to write to. It is never returned to callers:
+	// every destination reports its own error.
+	errAllDestinationsFailed = errors.New("every destination failed")
+	// errPutReturned closes the read end of a pipe whose Put has returned.
+	errPutReturned = errors.New("upload finished")
+)
+
+// FanOutPut uploads the size bytes of r to every destination in dsts,
+// reading r once. Each destination gets its own pipe and Put, fed from
+// its own queue of fanOutQueueChunks chunks, so a slow destination only
+// slows the others down once it is that far behind. A destination whose
+// Put fails (or returns without draining its pipe, as a dry-run store
+// does) is dropped and the others keep going. The returned slices hold
+// the object written and the result of every destination, in order.
+func FanOutPut(ctx context.Context, store *storage.Storage, r io.Reader, size int64, dsts []*storage.StorageURL, md storage.Metadata, concurrency int, partSize int64) ([]*storage.Object, []error) {
+	objs := make([]*storage.Object, len(dsts))
+	errs := make([]error, len(dsts))
+	if len(dsts) == 0 {
+		return objs, errs
+	}
+	if concurrency <= 0 {
+		concurrency = manager.DefaultUploadConcurrency
+	}
+	if partSize <= 0 {
+		partSize = manager.DefaultUploadPartSize
+	}
+	// The uploader cannot learn the size of a pipe to raise the part size
+	// itself, as it does for seekable bodies, so a large source would run
+	// out of parts halfway through.
+	partSize = max(partSize, size/int64(manager.MaxUploadParts)+1)
+
+	w := &fanOutWriter{
+		queues:  make([]chan []byte, len(dsts)),
+		stopped: make([]chan struct{}, len(dsts)),
+	}
+	var wg sync.WaitGroup
+	for i, dst := range dsts {
+		pr, pw := io.Pipe()
+		w.queues[i] = make(chan []byte, fanOutQueueChunks)
+		w.stopped[i] = make(chan struct{})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			objs[i], errs[i] = store.Put(ctx, pr, dst, md, concurrency, partSize)
+			// Unblock the pump: whatever Put did not read is not wanted.
+			pr.CloseWithError(errPutReturned)
+		}()
+		go func() {
+			defer wg.Done()
+			defer close(w.stopped[i])
+			for chunk := range w.queues[i] {
+				if _, err := pw.Write(chunk); err != nil {
+					pw.Close()
+					return
+				}
+			}
+			// A read error of the source must fail the upload instead of
+			// completing it with a truncated body.
+			pw.CloseWithError(w.err)
+		}()
+	}
+
+	// Hide any WriterTo of r, so the source is read in chunks of
+	// fanOutChunkSize rather than in one write.
+	_, copyErr := io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, fanOutChunkSize))
+	if errors.Is(copyErr, errAllDestinationsFailed) {
+		copyErr = nil
+	}
+	w.err = copyErr
+	for _, q := range w.queues {
+		if q != nil {
+			close(q)
+		}
+	}
+	wg.Wait()
+
+	if copyErr != nil {
+		for i := range errs {
+			if errs[i] == nil {
+				objs[i], errs[i] = nil, copyErr
+			}
+		}
+	}
+	return objs, errs
+}
+
+// fanOutWriter queues every chunk for all destinations that are still
+// live. A destination whose pump stopped has lost its reader and is
+// dropped; the write only fails once every destination is gone.
+type fanOutWriter struct {
+	queues []chan []byte
+	// stopped is closed when the pump of a destination returns.
+	stopped []chan struct{}
+	dead    int
+	// err is the result of reading the source, set once every chunk is
+	// queued.
+	err error
+}
+
+func (w *fanOutWriter) Write(p []byte) (int, error) {
+	// The chunk is shared by the queues, and p is reused by the caller.
+	chunk := bytes.Clone(p)
+	for i, q := range w.queues {
+		if q == nil {
+			continue
+		}
+		select {
+		case q <- chunk:
+		case <-w.stopped[i]:
+			w.queues[i] = nil
+			w.dead++
+		}
+	}
+	if w.dead == len(w.queues) {
+		return 0, errAllDestinationsFailed
+	}
+	return len(p), nil
+}
+
+// FanOutCopy copies the remote object src to every destination in dsts:
+// server-side to the first, then concurrently from the first to the rest,
+// which keeps the reads of src at one per object. When the first copy
+// fails the others copy from src directly. The returned slices hold the
+// object written and the result of every destination, in order.
+func FanOutCopy(ctx context.Context, store *storage.Storage, src *storage.StorageURL, dsts []*storage.StorageURL, md storage.Metadata) ([]*storage.Object, []error) {
+	objs := make([]*storage.Object, len(dsts))
+	errs := make([]error, len(dsts))
+	if len(dsts) == 0 {
+		return objs, errs
+	}
+	objs[0], errs[0] = store.Copy(ctx, src, dsts[0], md)
+	from := src
+	if errs[0] == nil {
+		from = dsts[0]
+	}
+	var wg sync.WaitGroup
+	for i := 1; i < len(dsts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			objs[i], errs[i] = store.Copy(ctx, from, dsts[i], md)
+		}()
+	}
+	wg.Wait()
+	return objs, errs
+}
+
+// UploadFanOut is Upload to several destinations. The file is opened and
+// read once; ShouldOverride is checked for every destination, and a
+// destination it skips gets the warning sentinel as its result. Like
+// FanOutPut it returns the object written and the result of every
+// destination.
+func (t *TransferSpec) UploadFanOut(ctx context.Context, store *storage.Storage, srcURL *storage.StorageURL, dsts []*storage.StorageURL, pb progressbar.ProgressBar) ([]*storage.Object, []error) {
+	objs := make([]*storage.Object, len(dsts))
+	errs := make([]error, len(dsts))
+	fail := func(err error) ([]*storage.Object, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return objs, errs
+	}
+	local := localTempStore(store, srcURL)
+	if local == nil {
+		return fail(errors.New("local backend does not support reading files"))
+	}
+	file, err := local.Open(srcURL.Absolute())
+	if err != nil {
+		return fail(err)
+	}
+	defer file.Close()
+
+	targets, index := t.overridden(ctx, store, srcURL, dsts, errs)
+	if len(targets) == 0 {
+		return objs, errs
+	}
+
+	md := t.Metadata()
+	if md.ContentType == "" {
+		md.ContentType = GuessContentType(file)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fail(err)
+	}
+	reader := NewCountingReaderWriter(file, pb)
+	written, results := FanOutPut(ctx, store, reader, info.Size(), targets, md, t.Shared.Concurrency, t.Shared.PartSizeBytes())
+	for i, err := range results {
+		objs[index[i]], errs[index[i]] = written[i], err
+		if err == nil {
+			log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.Absolute(), Destination: targets[i].String()})
+		}
+	}
+	return objs, errs
+}
+
+// CopyFanOut is Copy to several destinations through FanOutCopy, with the
+// same metadata-directive default and per-destination ShouldOverride.
+func (t *TransferSpec) CopyFanOut(ctx context.Context, store *storage.Storage, srcURL *storage.StorageURL, dsts []*storage.StorageURL) ([]*storage.Object, []error) {
+	objs := make([]*storage.Object, len(dsts))
+	errs := make([]error, len(dsts))
+	targets, index := t.overridden(ctx, store, srcURL, dsts, errs)
+	if len(targets) == 0 {
+		return objs, errs
+	}
+
+	directive := t.Shared.MetadataDirective
+	if directive == "" {
+		directive = MetadataDirectiveReplace
+	}
+	md := t.Metadata()
+	md.Directive = directive
+	written, results := FanOutCopy(ctx, store, srcURL, targets, md)
+	for i, err := range results {
+		objs[index[i]], errs[index[i]] = written[i], err
+		if err == nil {
+			log.Info(log.InfoMessage{Operation: t.Op, Source: srcURL.String(), Destination: targets[i].String()})
+		}
+	}
+	return objs, errs
+}
+
+// overridden returns the destinations ShouldOverride lets through and, for
+// each, its position in dsts. The result of a skipped destination is
+// stored in errs.
+func (t *TransferSpec) overridden(ctx context.Context, store *storage.Storage, srcURL *storage.StorageURL, dsts []*storage.StorageURL, errs []error) ([]*storage.StorageURL, []int) {
+	targets := make([]*storage.StorageURL, 0, len(dsts))
+	index := make([]int, 0, len(dsts))
+	for i, dst := range dsts {
+		if err := t.ShouldOverride(ctx, store, srcURL, dst); err != nil {
+			errs[i] = err
+			continue
+		}
+		targets = append(targets, dst)
+		index = append(index, i)
+	}
+	return targets, index
+}
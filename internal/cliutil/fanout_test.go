@@ -0,0 +1,220 @@
+package cliutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+var errFakePut = errors.New("put failed")
+
+// fanOutRemote records what each Put and Copy received, and the part size
+// of each Put. Puts to bucket "bad" fail after reading a few bytes; Puts to
+// bucket "lazy" return without reading, as the dry-run store does; Puts to
+// bucket "slow" wait for release before reading.
+type fanOutRemote struct {
+	storage.Store
+	storage.S3Extension
+
+	mu        sync.Mutex
+	bodies    map[string]string
+	partSizes map[string]int64
+	copies    map[string]string
+	release   chan struct{}
+}
+
+func (f *fanOutRemote) Put(ctx context.Context, reader io.Reader, to *storage.StorageURL, metadata storage.Metadata, concurrency int, partSize int64) (*storage.Object, error) {
+	switch to.Bucket {
+	case "bad":
+		_, _ = io.ReadFull(reader, make([]byte, 3))
+		return nil, errFakePut
+	case "lazy":
+		return &storage.Object{StorageURL: to}, nil
+	case "slow":
+		<-f.release
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bodies[to.String()] = string(body)
+	if f.partSizes != nil {
+		f.partSizes[to.String()] = partSize
+	}
+	return &storage.Object{StorageURL: to, Etag: "etag-" + to.Bucket}, nil
+}
+
+func (f *fanOutRemote) Copy(ctx context.Context, src, dst *storage.StorageURL, metadata storage.Metadata) (*storage.Object, error) {
+	if dst.Bucket == "bad" {
+		return nil, errFakePut
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.copies[dst.String()] = src.String()
+	return &storage.Object{StorageURL: dst, Etag: "etag-" + dst.Bucket}, nil
+}
+
+func fanOutURLs(t *testing.T, urls ...string) []*storage.StorageURL {
+	t.Helper()
+	out := make([]*storage.StorageURL, 0, len(urls))
+	for _, u := range urls {
+		parsed, err := storage.NewStorageURL(u)
+		if err != nil {
+			t.Fatalf("NewStorageURL(%q): %v", u, err)
+		}
+		out = append(out, parsed)
+	}
+	return out
+}
+
+// TestFanOutPut verifies that every healthy destination receives the whole
+// body, read once, while a failing and a non-reading destination neither
+// block nor corrupt the others.
+func TestFanOutPut(t *testing.T) {
+	remote := &fanOutRemote{bodies: map[string]string{}}
+	store := storage.NewStorage(remote, &okLocal{})
+	content := strings.Repeat("fan-out ", 64<<10)
+	dsts := fanOutURLs(t, "s3://one/k", "s3://bad/k", "s3://lazy/k", "s3://two/k")
+
+	objs, errs := FanOutPut(context.Background(), store, bytes.NewReader([]byte(content)), int64(len(content)), dsts, storage.Metadata{}, 0, 0)
+	want := []error{nil, errFakePut, nil, nil}
+	for i := range dsts {
+		if !errors.Is(errs[i], want[i]) || (want[i] == nil && errs[i] != nil) {
+			t.Errorf("%s: err = %v, want %v", dsts[i], errs[i], want[i])
+		}
+	}
+	if objs[0] == nil || objs[0].Etag != "etag-one" || objs[1] != nil {
+		t.Errorf("written objects = %v, want the ETag of s3://one/k and none for s3://bad/k", objs)
+	}
+	for _, u := range []string{"s3://one/k", "s3://two/k"} {
+		if got := remote.bodies[u]; got != content {
+			t.Errorf("%s received %d bytes, want %d", u, len(got), len(content))
+		}
+	}
+}
+
+// TestFanOutPut_ReadError verifies that a failing source read fails every
+// upload instead of completing it with a truncated body.
+func TestFanOutPut_ReadError(t *testing.T) {
+	remote := &fanOutRemote{bodies: map[string]string{}}
+	store := storage.NewStorage(remote, &okLocal{})
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("partial"), &failingReader{errRead})
+
+	_, errs := FanOutPut(context.Background(), store, r, int64(len("partial")), fanOutURLs(t, "s3://one/k", "s3://two/k"), storage.Metadata{}, 0, 0)
+	for i, err := range errs {
+		if !errors.Is(err, errRead) {
+			t.Errorf("destination %d: err = %v, want the read error", i, err)
+		}
+	}
+	if len(remote.bodies) != 0 {
+		t.Errorf("truncated uploads completed: %v", remote.bodies)
+	}
+}
+
+// TestFanOutPut_PartSize verifies that the part size is raised for a
+// source too large to upload in manager.MaxUploadParts parts of the
+// requested size, and kept otherwise.
+func TestFanOutPut_PartSize(t *testing.T) {
+	const partSize int64 = 5 << 20
+	maxParts := int64(manager.MaxUploadParts)
+	for _, size := range []int64{1 << 20, 2 * partSize * maxParts} {
+		remote := &fanOutRemote{bodies: map[string]string{}, partSizes: map[string]int64{}}
+		store := storage.NewStorage(remote, &okLocal{})
+		_, errs := FanOutPut(context.Background(), store, strings.NewReader("x"), size, fanOutURLs(t, "s3://one/k"), storage.Metadata{}, 0, partSize)
+		if errs[0] != nil {
+			t.Fatalf("size %d: %v", size, errs[0])
+		}
+		got := remote.partSizes["s3://one/k"]
+		if got < partSize || (size+got-1)/got > maxParts {
+			t.Errorf("size %d: part size = %d, want at least %d and at most %d parts", size, got, partSize, maxParts)
+		}
+		if size <= partSize && got != partSize {
+			t.Errorf("size %d: part size = %d, want %d", size, got, partSize)
+		}
+	}
+}
+
+// TestFanOutPut_SlowDestination verifies that a destination that does not
+// read yet does not hold back the others while it is less than the queue
+// behind.
+func TestFanOutPut_SlowDestination(t *testing.T) {
+	remote := &fanOutRemote{bodies: map[string]string{}, release: make(chan struct{})}
+	store := storage.NewStorage(remote, &okLocal{})
+	content := strings.Repeat("x", 4*fanOutChunkSize)
+	dsts := fanOutURLs(t, "s3://slow/k", "s3://one/k")
+
+	done := make(chan []error)
+	go func() {
+		_, errs := FanOutPut(context.Background(), store, strings.NewReader(content), int64(len(content)), dsts, storage.Metadata{}, 0, 0)
+		done <- errs
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		remote.mu.Lock()
+		_, ok := remote.bodies["s3://one/k"]
+		remote.mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			close(remote.release)
+			t.Fatal("s3://one/k did not complete while s3://slow/k was not reading")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(remote.release)
+	for i, err := range <-done {
+		if err != nil {
+			t.Errorf("%s: %v", dsts[i], err)
+		}
+	}
+	if got := remote.bodies["s3://slow/k"]; got != content {
+		t.Errorf("s3://slow/k received %d bytes, want %d", len(got), len(content))
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+// TestFanOutCopy verifies that the source is copied once and every other
+// destination copies from the first, and that a failed first copy makes
+// the others fall back to the source.
+func TestFanOutCopy(t *testing.T) {
+	remote := &fanOutRemote{copies: map[string]string{}}
+	store := storage.NewStorage(remote, &okLocal{})
+	src := fanOutURLs(t, "s3://src/k")[0]
+
+	_, errs := FanOutCopy(context.Background(), store, src, fanOutURLs(t, "s3://one/k", "s3://two/k", "s3://three/k"), storage.Metadata{})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("destination %d: %v", i, err)
+		}
+	}
+	want := map[string]string{"s3://one/k": "s3://src/k", "s3://two/k": "s3://one/k", "s3://three/k": "s3://one/k"}
+	for dst, from := range want {
+		if remote.copies[dst] != from {
+			t.Errorf("%s copied from %q, want %q", dst, remote.copies[dst], from)
+		}
+	}
+
+	remote.copies = map[string]string{}
+	_, errs = FanOutCopy(context.Background(), store, src, fanOutURLs(t, "s3://bad/k", "s3://two/k"), storage.Metadata{})
+	if !errors.Is(errs[0], errFakePut) || errs[1] != nil {
+		t.Fatalf("errs = %v, want [%v <nil>]", errs, errFakePut)
+	}
+	if remote.copies["s3://two/k"] != "s3://src/k" {
+		t.Errorf("after a failed first copy, s3://two/k copied from %q, want the source", remote.copies["s3://two/k"])
+	}
+}
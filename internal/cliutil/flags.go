@@ -1,6 +1,8 @@
 package cliutil
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -39,6 +41,10 @@ type CommonFlags struct {
 	// (S6CMD_USE_LIST_OBJECTS_V1 env). When true the legacy ListObjects API
 	// is used instead of ListObjectsV2.
 	UseListObjectsV1 bool
+	// ListShards is the --list-shards value (S6CMD_LIST_SHARDS env): the
+	// number of concurrent shards of a recursive ListObjectsV2 listing.
+	// Values <= 1 list sequentially.
+	ListShards int
 	// DryRun makes the stores built from these flags no-op every mutating
 	// operation (Put/Copy/Delete/...). Unlike the fields above it is NOT a
 	// root persistent flag: each command owns its own --dry-run flag and
@@ -94,6 +100,7 @@ func LoadParentFlags(cmd *cobra.Command) CommonFlags {
 		{Name: "use-list-objects-v1", Bool: &flags.UseListObjectsV1},
 		{Name: "retry-count", Int: &flags.RetryCount},
 		{Name: "no-such-upload-retry-count", Int: &flags.NoSuchUploadRetryCount},
+		{Name: "list-shards", Int: &flags.ListShards},
 	})
 
 	return flags
@@ -107,6 +114,8 @@ type FlagBinding struct {
 	String *string
 	Bool   *bool
 	Int    *int
+	// Duration receives duration flags such as --drain-timeout.
+	Duration *time.Duration
 	// Explicit, when non-nil, receives whether the user set the flag
 	// explicitly via any source (command line, environment variable or
 	// config file) as opposed to the cobra default applying. Callers use
@@ -125,9 +134,9 @@ type FlagBinding struct {
 //
 // For string flags an empty viper result falls through to the flag value
 // (so the cobra default — e.g. "text" for --output — is preserved when
-// nothing else applies). For bool/int flags viper.IsSet distinguishes "not
-// configured" from a configured zero value. Flags missing from fs leave
-// their destination untouched.
+// nothing else applies). For bool/int/duration flags viper.IsSet
+// distinguishes "not configured" from a configured zero value. Flags
+// missing from fs leave their destination untouched.
 func ResolveFlags(fs *pflag.FlagSet, bindings []FlagBinding) {
 	for _, b := range bindings {
 		flag := fs.Lookup(b.Name)
@@ -160,6 +169,12 @@ func ResolveFlags(fs *pflag.FlagSet, bindings []FlagBinding) {
 			} else {
 				*b.Int, _ = fs.GetInt(b.Name)
 			}
+		case b.Duration != nil:
+			if !flag.Changed && viper.IsSet(b.Name) {
+				*b.Duration = viper.GetDuration(b.Name)
+			} else {
+				*b.Duration, _ = fs.GetDuration(b.Name)
+			}
 		}
 	}
 }
@@ -0,0 +1,35 @@
+package cliutil
+
+import (
+	"github.com/LinPr/s6cmd/internal/ignore"
+	"github.com/spf13/cobra"
+)
+
+// IgnoreFlags selects the per-directory ignore files honored when a local
+// directory is walked for an upload: .s6cmdignore unless
+// --no-s6cmdignore, and .gitignore with --respect-gitignore. Ignored
+// directories are pruned from the walk instead of being listed and
+// filtered.
+type IgnoreFlags struct {
+	NoS6cmdignore    bool
+	RespectGitignore bool
+}
+
+// AddToCmd registers --no-s6cmdignore and --respect-gitignore on cmd.
+func (f *IgnoreFlags) AddToCmd(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.NoS6cmdignore, "no-s6cmdignore", false, "do not skip the local files listed in "+ignore.FileName+" files")
+	cmd.Flags().BoolVar(&f.RespectGitignore, "respect-gitignore", false, "also skip the local files listed in "+ignore.GitignoreName+" files")
+}
+
+// Files returns the names of the ignore files to read, for
+// storage.WithIgnoreFiles.
+func (f *IgnoreFlags) Files() []string {
+	var names []string
+	if !f.NoS6cmdignore {
+		names = append(names, ignore.FileName)
+	}
+	if f.RespectGitignore {
+		names = append(names, ignore.GitignoreName)
+	}
+	return names
+}
@@ -0,0 +1,33 @@
+package cliutil
+
+import (
+	"errors"
+
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+// InventoryFlags selects an S3 Inventory report to read the listing of a
+// remote source from instead of listing the bucket (see
+// storage.WithInventory).
+type InventoryFlags struct {
+	Manifest string
+}
+
+// AddToCmd registers --inventory on cmd.
+func (f *InventoryFlags) AddToCmd(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Manifest, "inventory", "", "list the source from the S3 Inventory report with this manifest.json (an s3:// URL or a local file) instead of listing the bucket")
+}
+
+// Option returns the storage.WithInventory option for the source URL.
+func (f *InventoryFlags) Option() storage.Option {
+	return storage.WithInventory(f.Manifest)
+}
+
+// Validate rejects an inventory for a local source.
+func (f *InventoryFlags) Validate(src *storage.StorageURL) error {
+	if f.Manifest != "" && !src.IsRemote() {
+		return errors.New("--inventory needs an s3:// source")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cliutil
+
+import (
+	"errors"
+
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+// ListingFlags selects a listing saved by ls --save-listing to read the
+// objects of a remote source from instead of listing the bucket (see
+// storage.WithListing).
+type ListingFlags struct {
+	Path  string
+	Check int
+}
+
+// AddToCmd registers --from-listing and --check-listing on cmd.
+func (f *ListingFlags) AddToCmd(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Path, "from-listing", "", "take the source objects from this listing saved by ls --save-listing instead of listing the bucket")
+	cmd.Flags().IntVar(&f.Check, "check-listing", 0, "with --from-listing, compare this many of the saved objects with the bucket first and refuse a stale listing")
+}
+
+// Option returns the storage.WithListing option for the source URL.
+func (f *ListingFlags) Option() storage.Option {
+	return storage.WithListing(f.Path, f.Check)
+}
+
+// Validate rejects a listing for a local source or together with
+// --inventory, and --check-listing without a listing.
+func (f *ListingFlags) Validate(src *storage.StorageURL) error {
+	if f.Check < 0 {
+		return errors.New("--check-listing must not be negative")
+	}
+	if f.Path == "" {
+		if f.Check > 0 {
+			return errors.New("--check-listing requires --from-listing")
+		}
+		return nil
+	}
+	if !src.IsRemote() {
+		return errors.New("--from-listing needs an s3:// source")
+	}
+	if src.Inventory() != "" {
+		return errors.New("--from-listing and --inventory are mutually exclusive")
+	}
+	return nil
+}
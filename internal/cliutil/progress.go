@@ -0,0 +1,99 @@
+package cliutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+// ProgressFlags is the --show-progress / --show-transfers / --progress-json
+// trio shared by the transfer commands (cp, mv, sync, put and get).
+type ProgressFlags struct {
+	// Show displays the overall progress on stderr.
+	Show bool
+	// Transfers also lists the active transfers under it; it implies Show.
+	Transfers bool
+	// JSON is a file descriptor number or a file path to write the
+	// progress to as JSON events (see progressbar.JSON).
+	JSON string
+}
+
+// AddToCmd registers --show-progress, --show-transfers and --progress-json
+// on cmd.
+func (p *ProgressFlags) AddToCmd(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&p.Show, "show-progress", false, "show object counts, bytes, throughput and ETA on stderr (repainted on a terminal, a line every 5s otherwise)")
+	cmd.Flags().BoolVar(&p.Transfers, "show-transfers", false, "with the progress, list the active transfers when stderr is a terminal (implies --show-progress)")
+	cmd.Flags().StringVar(&p.JSON, "progress-json", "", "write the progress as newline-delimited JSON events to this file descriptor number (e.g. 3) or file")
+}
+
+// New returns the progress bar the flags ask for; a NoOp without them.
+// With --progress-json its events go to the descriptor or file, which is
+// closed when the bar finishes.
+func (p *ProgressFlags) New() (progressbar.ProgressBar, error) {
+	bar := progressbar.New(p.Show || p.Transfers, p.Transfers)
+	if p.JSON == "" {
+		return bar, nil
+	}
+	w, err := openProgressJSON(p.JSON)
+	if err != nil {
+		return nil, err
+	}
+	return &closingBar{ProgressBar: progressbar.Join(bar, progressbar.NewJSON(w)), closer: w}, nil
+}
+
+// openProgressJSON opens the --progress-json target: an inherited file
+// descriptor when target is a number, a file created or truncated
+// otherwise. Standard output and error are not closed after the run.
+func openProgressJSON(target string) (io.WriteCloser, error) {
+	fd, err := strconv.ParseUint(target, 10, 31)
+	if err != nil {
+		f, err := os.Create(target)
+		if err != nil {
+			return nil, fmt.Errorf("--progress-json: %w", err)
+		}
+		return f, nil
+	}
+	switch fd {
+	case 1:
+		return nopCloser{os.Stdout}, nil
+	case 2:
+		return nopCloser{os.Stderr}, nil
+	}
+	f := os.NewFile(uintptr(fd), "fd "+target)
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("--progress-json: file descriptor %s is not open", target)
+	}
+	return f, nil
+}
+
+// closingBar closes the --progress-json target once the bar finished.
+type closingBar struct {
+	progressbar.ProgressBar
+	closer io.Closer
+}
+
+// Finish implements progressbar.ProgressBar.
+func (b *closingBar) Finish() {
+	b.ProgressBar.Finish()
+	_ = b.closer.Close()
+}
+
+// nopCloser is a WriteCloser whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// CountProgress is the storage.TransferOption that reports the bytes a
+// DownloadFile or UploadFile transfers to pb.
+func CountProgress(pb progressbar.ProgressBar) storage.TransferOption {
+	return storage.WithFileWrapper(func(f *os.File) storage.TransferFile {
+		return NewCountingReaderWriter(f, pb)
+	})
+}
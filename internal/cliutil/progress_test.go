@@ -0,0 +1,65 @@
+package cliutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LinPr/s6cmd/internal/progressbar"
+	"github.com/spf13/cobra"
+)
+
+// TestProgressFlags_New verifies that no flag shows nothing and that
+// --show-transfers implies --show-progress.
+func TestProgressFlags_New(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		args []string
+		show bool
+	}{
+		{nil, false},
+		{[]string{"--show-progress"}, true},
+		{[]string{"--show-transfers"}, true},
+	} {
+		var p ProgressFlags
+		cmd := &cobra.Command{}
+		p.AddToCmd(cmd)
+		if err := cmd.ParseFlags(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		pb, err := p.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, noop := pb.(*progressbar.NoOp); noop == tc.show {
+			t.Errorf("%v: New() = %T, want a bar: %v", tc.args, pb, tc.show)
+		}
+	}
+}
+
+// TestProgressFlags_JSON verifies that --progress-json writes the events
+// to a file and rejects a file descriptor that is not open.
+func TestProgressFlags_JSON(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "progress.jsonl")
+	p := ProgressFlags{JSON: file}
+	pb, err := p.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb.Start()
+	pb.Transfer("a", 1).Finish()
+	pb.Finish()
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 4 {
+		t.Errorf("progress file has %d events, want start, object_started, object_failed and done:\n%s", len(lines), content)
+	}
+
+	if _, err := (&ProgressFlags{JSON: "1000"}).New(); err == nil {
+		t.Error("New() with a closed file descriptor succeeded")
+	}
+}
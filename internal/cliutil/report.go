@@ -0,0 +1,99 @@
+package cliutil
+
+import (
+	"github.com/LinPr/s6cmd/internal/report"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/spf13/cobra"
+)
+
+// ReportFlags is the --report / --report-format pair shared by cp, mv, rm,
+// sync, put and get: every object operation of the run is written to the
+// report file with its outcome (see package report).
+type ReportFlags struct {
+	// File is the report file; empty disables the report.
+	File string
+	// Format is "jsonl" or "csv"; empty picks it from File's extension.
+	Format string
+
+	// writer is shared by the copies of the flags the commands make of
+	// their Flags.
+	writer *reportWriter
+}
+
+type reportWriter struct {
+	w *report.Writer
+}
+
+// AddToCmd registers --report and --report-format on cmd.
+func (r *ReportFlags) AddToCmd(cmd *cobra.Command) {
+	r.writer = &reportWriter{}
+	cmd.Flags().StringVar(&r.File, "report", "", "write one line per object with its source, destination, size, ETag, version ID, duration, attempts and status to this file")
+	cmd.Flags().StringVar(&r.Format, "report-format", "", `format of --report: "jsonl" or "csv" (default: csv for a .csv file, jsonl otherwise)`)
+}
+
+// Validate checks --report-format.
+func (r *ReportFlags) Validate() error {
+	_, err := report.ParseFormat(r.Format, r.File)
+	return err
+}
+
+// Open creates the report file, when --report is set. With dryRun the
+// operations are reported as dry-run instead of ok.
+func (r *ReportFlags) Open(dryRun bool) error {
+	if r.File == "" {
+		return nil
+	}
+	format, err := report.ParseFormat(r.Format, r.File)
+	if err != nil {
+		return err
+	}
+	w, err := report.Open(r.File, format, dryRun)
+	if err != nil {
+		return err
+	}
+	if r.writer == nil {
+		// Flags set up without AddToCmd, as in tests.
+		r.writer = &reportWriter{}
+	}
+	r.writer.w = w
+	return nil
+}
+
+// Start begins the report entry of an operation; it returns nil, which
+// reports nothing, without --report.
+func (r *ReportFlags) Start(op, src, dst string, size int64) *report.Operation {
+	if r.writer == nil {
+		return nil
+	}
+	return r.writer.w.Start(op, src, dst, size)
+}
+
+// Close writes the pending entries and closes the report. It must be
+// called once every task finished.
+func (r *ReportFlags) Close() error {
+	if r.writer == nil {
+		return nil
+	}
+	return r.writer.w.Close()
+}
+
+// StartEach begins the report entries of copying object to each of dsts,
+// which resolve as in the resume journal (see ResumeDestination).
+func (r *ReportFlags) StartEach(op string, object *storage.Object, dsts []*storage.StorageURL, flatten, isBatch bool) []*report.Operation {
+	rops := make([]*report.Operation, len(dsts))
+	if r.writer == nil || r.writer.w == nil {
+		return rops
+	}
+	src := object.StorageURL.String()
+	if !object.StorageURL.IsRemote() {
+		src = object.StorageURL.Absolute()
+	}
+	for i, dstURL := range dsts {
+		dst := ResumeDestination(object.StorageURL, dstURL, flatten, isBatch)
+		if dst == "" {
+			dst = dstURL.String()
+		}
+		rops[i] = r.writer.w.Start(op, src, dst, object.Size)
+	}
+	return rops
+}
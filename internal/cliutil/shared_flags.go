@@ -69,6 +69,15 @@ type SharedFlags struct {
 	// are present, only matching objects are included.
 	Exclude []string
 	Include []string
+	// Filter is a --filter expression (see package filter); objects it
+	// does not match are skipped like excluded ones.
+	Filter string
+	// FilterFrom is a file of ordered rsync-style "+ PATTERN" and
+	// "- PATTERN" rules (see filter.Rules), applied with Exclude/Include.
+	FilterFrom string
+	// Ignore selects the .s6cmdignore/.gitignore files honored by local
+	// walks (see IgnoreFlags).
+	Ignore IgnoreFlags
 	// Raw disables wildcard expansion on the source URL.
 	Raw bool
 }
@@ -139,6 +148,9 @@ func (sf *SharedFlags) AddToCmd(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&sf.DestinationRegion, "destination-region", "", "set the region of destination bucket")
 	cmd.Flags().StringSliceVar(&sf.Exclude, "exclude", nil, "exclude objects with given pattern (repeatable)")
 	cmd.Flags().StringSliceVar(&sf.Include, "include", nil, "include objects with given pattern (repeatable)")
+	cmd.Flags().StringVar(&sf.FilterFrom, "filter-from", "", "read ordered rsync-style '+ PATTERN' / '- PATTERN' rules from a file; the first matching rule wins")
+	cmd.Flags().StringVar(&sf.Filter, "filter", "", `only process objects matching the expression, e.g. 'size > 100MiB && mtime < now-30d'`)
+	sf.Ignore.AddToCmd(cmd)
 	cmd.Flags().BoolVar(&sf.Raw, "raw", false, "disable wildcard operations, useful with filenames that contains glob characters")
 }
 
@@ -0,0 +1,89 @@
+package cliutil
+
+import "fmt"
+
+// SplitCommandLine splits a command line into fields with POSIX-like
+// quoting rules, so `rm "s3://bucket/file with spaces"` targets one key
+// instead of three:
+//
+//   - unquoted whitespace separates fields;
+//   - single quotes preserve everything literally up to the closing quote;
+//   - double quotes preserve everything except `\"` and `\\`, which escape
+//     the quote and the backslash respectively;
+//   - an unquoted backslash escapes the next character.
+//
+// An unterminated quote (or a trailing bare backslash) returns an error so
+// a malformed line is rejected instead of silently targeting the wrong
+// key. Empty quoted strings (” / "") produce empty fields.
+func SplitCommandLine(s string) ([]string, error) {
+	const (
+		stateUnquoted = iota
+		stateSingle
+		stateDouble
+	)
+	var (
+		fields  []string
+		cur     []byte
+		inField bool // distinguishes "" (empty field) from no field at all
+	)
+	state := stateUnquoted
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch state {
+		case stateSingle:
+			if c == '\'' {
+				state = stateUnquoted
+			} else {
+				cur = append(cur, c)
+			}
+		case stateDouble:
+			switch c {
+			case '"':
+				state = stateUnquoted
+			case '\\':
+				// Inside double quotes a backslash only escapes the
+				// closing quote and itself; otherwise it is literal.
+				if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+					cur = append(cur, s[i])
+				} else {
+					cur = append(cur, c)
+				}
+			default:
+				cur = append(cur, c)
+			}
+		default: // stateUnquoted
+			switch c {
+			case '\'':
+				state = stateSingle
+				inField = true
+			case '"':
+				state = stateDouble
+				inField = true
+			case '\\':
+				if i+1 >= len(s) {
+					return nil, fmt.Errorf("trailing backslash")
+				}
+				i++
+				cur = append(cur, s[i])
+				inField = true
+			case ' ', '\t', '\n', '\r':
+				if inField {
+					fields = append(fields, string(cur))
+					cur = cur[:0]
+					inField = false
+				}
+			default:
+				cur = append(cur, c)
+				inField = true
+			}
+		}
+	}
+	if state == stateSingle || state == stateDouble {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, string(cur))
+	}
+	return fields, nil
+}
@@ -1,16 +1,16 @@
-package run
+package cliutil
 
 import (
 	"reflect"
 	"testing"
 )
 
-// TestShellquoteSplit exercises the POSIX-ish tokenizer: plain fields,
+// TestSplitCommandLine exercises the POSIX-ish tokenizer: plain fields,
 // single/double quotes, backslash escapes and the error paths. The old
 // strings.Fields implementation broke every quoted argument (a key with a
 // space became three tokens, so rm targeted the wrong keys) and its
 // unterminated-quote error path was dead code.
-func TestShellquoteSplit(t *testing.T) {
+func TestSplitCommandLine(t *testing.T) {
 	cases := []struct {
 		name string
 		in   string
@@ -33,27 +33,27 @@ func TestShellquoteSplit(t *testing.T) {
 		{"whitespace only", "   \t ", nil},
 	}
 	for _, c := range cases {
-		got, err := shellquoteSplit(c.in)
+		got, err := SplitCommandLine(c.in)
 		if err != nil {
-			t.Errorf("%s: shellquoteSplit(%q) error: %v", c.name, c.in, err)
+			t.Errorf("%s: SplitCommandLine(%q) error: %v", c.name, c.in, err)
 			continue
 		}
 		if !reflect.DeepEqual(got, c.want) {
-			t.Errorf("%s: shellquoteSplit(%q) = %#v, want %#v", c.name, c.in, got, c.want)
+			t.Errorf("%s: SplitCommandLine(%q) = %#v, want %#v", c.name, c.in, got, c.want)
 		}
 	}
 }
 
-// TestShellquoteSplitErrors verifies the malformed-line error paths.
-func TestShellquoteSplitErrors(t *testing.T) {
+// TestSplitCommandLineErrors verifies the malformed-line error paths.
+func TestSplitCommandLineErrors(t *testing.T) {
 	for _, in := range []string{
 		`rm "s3://b/unterminated`,
 		`rm 's3://b/unterminated`,
 		`rm "closed" "open`,
 		`rm trailing\`,
 	} {
-		if _, err := shellquoteSplit(in); err == nil {
-			t.Errorf("shellquoteSplit(%q) = nil error, want unterminated-quote/backslash error", in)
+		if _, err := SplitCommandLine(in); err == nil {
+			t.Errorf("SplitCommandLine(%q) = nil error, want unterminated-quote/backslash error", in)
 		}
 	}
 }
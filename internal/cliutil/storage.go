@@ -51,5 +51,6 @@ func s3OptFromFlags(flags CommonFlags) s3store.S3Option {
 		CredentialFile:         flags.CredentialsFile,
 		NoSignRequest:          flags.NoSignRequest,
 		UseListObjectsV1:       flags.UseListObjectsV1,
+		ListShards:             flags.ListShards,
 	}
 }
@@ -74,10 +74,10 @@ func TestNewStorageDryRun(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 
-	if err := store.Put(ctx, strings.NewReader("data"), dst, storage.Metadata{}, 1, 5*1024*1024); err != nil {
+	if _, err := store.Put(ctx, strings.NewReader("data"), dst, storage.Metadata{}, 1, 5*1024*1024); err != nil {
 		t.Errorf("dry-run Put: %v", err)
 	}
-	if err := store.Copy(ctx, src, dst, storage.Metadata{}); err != nil {
+	if _, err := store.Copy(ctx, src, dst, storage.Metadata{}); err != nil {
 		t.Errorf("dry-run Copy: %v", err)
 	}
 	if err := store.Delete(ctx, dst); err != nil {
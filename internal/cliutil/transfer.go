@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/LinPr/s6cmd/internal/ignore"
 )
 
 func WildcardBasePath(pattern string) string {
@@ -20,6 +22,21 @@ func WildcardBasePath(pattern string) string {
 }
 
 func ListLocalFiles(src string, recursive bool) ([]string, error) {
+	return listLocalFiles(src, recursive, nil)
+}
+
+// ListLocalFilesIgnoring is ListLocalFiles honoring the ignore files
+// called ignoreFiles (see package ignore) in src, or in the directory a
+// glob starts in, and below. Ignored directories are not walked.
+func ListLocalFilesIgnoring(src string, recursive bool, ignoreFiles []string) ([]string, error) {
+	root := src
+	if strings.ContainsAny(src, "?*") {
+		root = WildcardBasePath(src)
+	}
+	return listLocalFiles(src, recursive, ignore.NewTree(root, ignoreFiles))
+}
+
+func listLocalFiles(src string, recursive bool, tree *ignore.Tree) ([]string, error) {
 	if strings.ContainsAny(src, "?*") {
 		matches, err := filepath.Glob(src)
 		if err != nil {
@@ -34,11 +51,18 @@ func ListLocalFiles(src string, recursive bool) ([]string, error) {
 			if err != nil {
 				return nil, err
 			}
+			ignored, err := tree.IgnoredPath(m, info.IsDir())
+			if err != nil {
+				return nil, err
+			}
+			if ignored {
+				continue
+			}
 			if info.IsDir() {
 				if !recursive {
 					return nil, fmt.Errorf("%s is a directory (use --recursive)", m)
 				}
-				sub, err := ListLocalFiles(filepath.Join(m, "*"), recursive)
+				sub, err := listLocalFiles(filepath.Join(m, "*"), recursive, tree)
 				if err != nil {
 					return nil, err
 				}
@@ -66,6 +90,18 @@ func ListLocalFiles(src string, recursive bool) ([]string, error) {
 		if err != nil {
 			return err
 		}
+		if path != src {
+			ignored, err := tree.IgnoredPath(path, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if ignored && d.IsDir() {
+				return filepath.SkipDir
+			}
+			if ignored {
+				return nil
+			}
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -556,3 +556,50 @@ func contains(s []string, v string) bool {
 	}
 	return false
 }
+
+// TestListLocalFilesIgnoring verifies that the ignore files prune the walk
+// of a directory and of a glob, and that an explicit file is kept.
+func TestListLocalFilesIgnoring(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, []string{
+		".gitignore",
+		"keep.txt",
+		"drop.log",
+		"node_modules/pkg/index.js",
+		"src/app.txt",
+	})
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\nnode_modules/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{".gitignore"}
+	rel := func(files []string) []string {
+		out := make([]string, 0, len(files))
+		for _, f := range files {
+			r, _ := filepath.Rel(root, f)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+	want := []string{".gitignore", "keep.txt", "src/app.txt"}
+
+	got, err := ListLocalFilesIgnoring(root, true, names)
+	if err != nil {
+		t.Fatalf("ListLocalFilesIgnoring: %v", err)
+	}
+	if !sliceEqual(rel(got), want) {
+		t.Errorf("directory: got %v, want %v", rel(got), want)
+	}
+
+	got, err = ListLocalFilesIgnoring(filepath.Join(root, "*"), true, names)
+	if err != nil {
+		t.Fatalf("ListLocalFilesIgnoring(glob): %v", err)
+	}
+	if !sliceEqual(rel(got), want) {
+		t.Errorf("glob: got %v, want %v", rel(got), want)
+	}
+
+	got, err = ListLocalFilesIgnoring(filepath.Join(root, "drop.log"), false, names)
+	if err != nil || len(got) != 1 {
+		t.Errorf("explicit file: got %v, %v, want it listed", got, err)
+	}
+}
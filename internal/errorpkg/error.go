@@ -11,7 +11,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"syscall"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/smithy-go"
 )
 
@@ -78,6 +83,11 @@ func IsCancelation(err error) bool {
 	return false
 }
 
+// ErrNotStarted is the result of an operation the first Ctrl-C kept from
+// starting. It is a cancelation, so it is not reported as a failure, but
+// it still reaches the resume journal.
+var ErrNotStarted = fmt.Errorf("not started: %w", context.Canceled)
+
 // Sentinel errors used by commands to signal non-fatal conditions that
 // should be logged as warnings rather than failures.
 var (
@@ -130,3 +140,39 @@ func IsWarning(err error) bool {
 
 	return false
 }
+
+// transientCodes are the S3 error codes that IsTransient treats as
+// retryable on top of the SDK's default retryables.
+var transientCodes = map[string]bool{
+	"InternalError":        true,
+	"RequestTimeTooSkewed": true,
+	"RequestTimeout":       true,
+	"ServiceUnavailable":   true,
+	"SlowDown":             true,
+}
+
+// IsTransient reports whether err is worth retrying the whole operation
+// for: throttling, 5xx responses, timeouts and dropped connections, as
+// classified by the SDK's default retryables plus a few S3 error codes.
+// Cancelations, warnings and errors such as AccessDenied or NoSuchBucket
+// are not transient. The SDK has usually retried the request already by
+// the time such an error reaches a command, which is why commands retry
+// the operation as a whole with a longer backoff.
+func IsTransient(err error) bool {
+	if err == nil || IsCancelation(err) || IsWarning(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && transientCodes[apiErr.ErrorCode()] {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
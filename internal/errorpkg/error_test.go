@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/LinPr/s6cmd/internal/errorpkg"
@@ -211,3 +213,33 @@ func TestErrObjectIsNewerAndSizesMatch_Message(t *testing.T) {
 		t.Errorf("message %q does not contain %q", msg, errorpkg.ErrObjectSizesMatch.Error())
 	}
 }
+
+// TestIsTransient verifies that throttling, server errors and dropped
+// connections are transient, and that permanent errors, warnings and
+// cancelations are not.
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("boom"), false},
+		{"slow down", &smithy.GenericAPIError{Code: "SlowDown"}, true},
+		{"throttling", &smithy.GenericAPIError{Code: "Throttling"}, true},
+		{"internal error", &errorpkg.Error{Op: "cp", Err: &smithy.GenericAPIError{Code: "InternalError"}}, true},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, false},
+		{"no such bucket", &smithy.GenericAPIError{Code: "NoSuchBucket"}, false},
+		{"unexpected eof", fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), true},
+		{"connection reset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+		{"warning", errorpkg.ErrObjectExists, false},
+	}
+	for _, tt := range tests {
+		if got := errorpkg.IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package etag reproduces S3 ETags for local content so a local file can be
+// compared against an object without downloading it.
+//
+// A single PutObject gets the hex MD5 of the body as its ETag. A multipart
+// upload gets the MD5 of the concatenated binary MD5s of its parts,
+// followed by "-N" where N is the part count. The part size itself is not
+// recorded anywhere, so Match derives the candidate part sizes from N and
+// the object size and tries each of them. ETags of SSE-KMS and SSE-C
+// objects are not MD5s at all; a mismatch is therefore never proof that the
+// content differs.
+package etag
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrIncomparable is returned by Match when no part size reproduces the
+// ETag's part count, or the ETag is not MD5 based.
+var ErrIncomparable = errors.New("etag is not comparable with a local digest")
+
+const mib = 1024 * 1024
+
+// maxUploadParts mirrors manager.MaxUploadParts: uploaders that would
+// exceed it grow the part size to size/maxUploadParts+1.
+const maxUploadParts = 10000
+
+// commonPartSizes are the part sizes of popular uploaders, tried after the
+// caller's preferred size: 8 MiB (aws-cli, boto3), 5 MiB (SDK transfer
+// managers), 50 MiB (s6cmd), then other round values.
+var commonPartSizes = []int64{
+	8 * mib, 5 * mib, 50 * mib, 16 * mib, 10 * mib, 15 * mib, 25 * mib, 32 * mib,
+	64 * mib, 100 * mib, 128 * mib, 256 * mib, 512 * mib, 1024 * mib,
+}
+
+// Parse splits an ETag into its part count. parts is 0 for a plain MD5
+// ETag. ok is false when etag is not MD5 based.
+func Parse(etag string) (parts int, ok bool) {
+	hexPart, suffix, multipart := strings.Cut(etag, "-")
+	if len(hexPart) != 2*md5.Size {
+		return 0, false
+	}
+	if _, err := hex.DecodeString(hexPart); err != nil {
+		return 0, false
+	}
+	if !multipart {
+		return 0, true
+	}
+	n, err := strconv.Atoi(suffix)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// PartCount returns the number of parts an object of the given size is
+// split into. An empty object still has one (empty) part.
+func PartCount(size, partSize int64) int {
+	if size <= 0 || partSize <= 0 {
+		return 1
+	}
+	return int((size + partSize - 1) / partSize)
+}
+
+// PartSizes returns the part sizes that may have produced etag for an
+// object of the given size, most likely first. preferred (e.g. the
+// configured --part-size) is tried first when it fits. A plain ETag has a
+// single candidate covering the whole object; an ETag that is not MD5
+// based has none.
+func PartSizes(etag string, size, preferred int64) []int64 {
+	parts, ok := Parse(etag)
+	if !ok {
+		return nil
+	}
+	if parts <= 1 {
+		return []int64{max(size, 1)}
+	}
+
+	// The smallest part size giving N parts, exact and rounded up to a
+	// whole MiB, covers uploaders that derive the part size from the
+	// object size.
+	lower := (size + int64(parts) - 1) / int64(parts)
+	candidates := append([]int64{preferred}, commonPartSizes...)
+	candidates = append(candidates, (lower+mib-1)/mib*mib, lower, size/maxUploadParts+1)
+
+	var out []int64
+	seen := make(map[int64]bool)
+	for _, p := range candidates {
+		if p <= 0 || seen[p] || PartCount(size, p) != parts {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+// Digest computes the ETag of content split into partSize-byte parts. It
+// is an io.WriterAt so the multipart downloader can feed it directly:
+// every part is fetched on its own goroutine and written in offset order
+// within the part, so parts are hashed in parallel without buffering.
+type Digest struct {
+	partSize int64
+	size     int64
+	parts    []*partHash
+}
+
+// partHash is the running MD5 of one part. next is the next offset the
+// part expects; a write at the part's start resets the hash so a part
+// body the downloader retries from the beginning is not hashed twice.
+type partHash struct {
+	mu    sync.Mutex
+	start int64
+	end   int64
+	next  int64
+	h     hash.Hash
+}
+
+// NewDigest returns a Digest for content of the given size. A
+// non-positive partSize makes the whole content a single part.
+func NewDigest(size, partSize int64) *Digest {
+	if partSize <= 0 {
+		partSize = max(size, 1)
+	}
+	d := &Digest{partSize: partSize, size: size, parts: make([]*partHash, PartCount(size, partSize))}
+	for i := range d.parts {
+		start := int64(i) * partSize
+		end := min(start+partSize, size)
+		d.parts[i] = &partHash{start: start, end: end, next: start, h: md5.New()}
+	}
+	return d
+}
+
+// PartSize returns the part size the digest splits content into.
+func (d *Digest) PartSize() int64 {
+	return d.partSize
+}
+
+// WriteAt implements io.WriterAt. A write may not span a part boundary
+// and must continue where the previous write to the same part stopped.
+func (d *Digest) WriteAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	idx := int(off / d.partSize)
+	if off < 0 || idx >= len(d.parts) {
+		return 0, fmt.Errorf("write at offset %d is outside the content (size %d)", off, d.size)
+	}
+	part := d.parts[idx]
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	if off == part.start {
+		part.h.Reset()
+		part.next = part.start
+	}
+	if off != part.next || off+int64(len(p)) > part.end {
+		return 0, fmt.Errorf("out of order write at offset %d (part %d expects %d)", off, idx+1, part.next)
+	}
+	part.h.Write(p)
+	part.next += int64(len(p))
+	return len(p), nil
+}
+
+// Sum returns the ETag S3 reports for an upload with this part size: the
+// plain MD5 when the content fits in one part, the "-N" form otherwise. It
+// fails when a part was not written in full.
+func (d *Digest) Sum() (string, error) {
+	if len(d.parts) == 1 {
+		if err := d.complete(); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(d.parts[0].h.Sum(nil)), nil
+	}
+	return d.MultipartSum()
+}
+
+// MultipartSum returns the "-N" form even for a single part, which is
+// what a multipart upload of exactly one part reports.
+func (d *Digest) MultipartSum() (string, error) {
+	if err := d.complete(); err != nil {
+		return "", err
+	}
+	outer := md5.New()
+	for _, part := range d.parts {
+		outer.Write(part.h.Sum(nil))
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(outer.Sum(nil)), len(d.parts)), nil
+}
+
+// Matches reports whether the digest reproduces etag.
+func (d *Digest) Matches(etag string) (bool, error) {
+	parts, ok := Parse(etag)
+	if !ok {
+		return false, ErrIncomparable
+	}
+	sum, err := d.Sum()
+	if parts > 0 {
+		sum, err = d.MultipartSum()
+	}
+	if err != nil {
+		return false, err
+	}
+	return sum == etag, nil
+}
+
+func (d *Digest) complete() error {
+	for i, part := range d.parts {
+		if part.next != part.end {
+			return fmt.Errorf("part %d is incomplete: read %d of %d bytes", i+1, part.next-part.start, part.end-part.start)
+		}
+	}
+	return nil
+}
+
+// File digests the local file at path with partSize parts, reading up to
+// concurrency parts at once with positioned reads.
+func File(ctx context.Context, path string, size, partSize int64, concurrency int) (*Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := NewDigest(size, partSize)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(d.parts))
+	var wg sync.WaitGroup
+	for i, part := range d.parts {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			r := io.NewSectionReader(f, part.start, part.end-part.start)
+			_, errs[i] = io.Copy(io.NewOffsetWriter(d, part.start), r)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Match reports whether the local file at path reproduces etag, the ETag
+// of an object of the given size. Every candidate part size from PartSizes
+// is hashed in a single sequential pass over the file, so auto-detection
+// costs one read no matter how many candidates there are. partSize is the
+// candidate that matched, or 0. ErrIncomparable is returned when etag
+// cannot be reproduced from local content.
+func Match(ctx context.Context, path string, size int64, etag string, preferred int64) (matched bool, partSize int64, err error) {
+	candidates := PartSizes(etag, size, preferred)
+	if len(candidates) == 0 {
+		return false, 0, ErrIncomparable
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer f.Close()
+
+	digests := make([]*Digest, len(candidates))
+	for i, p := range candidates {
+		digests[i] = NewDigest(size, p)
+	}
+	w := &fanoutWriter{digests: digests}
+	if _, err := io.Copy(w, &ctxReader{ctx: ctx, r: io.LimitReader(f, size)}); err != nil {
+		return false, 0, err
+	}
+	for _, d := range digests {
+		ok, err := d.Matches(etag)
+		if err != nil {
+			return false, 0, err
+		}
+		if ok {
+			return true, d.PartSize(), nil
+		}
+	}
+	return false, 0, nil
+}
+
+// fanoutWriter feeds a sequential stream to several digests, splitting
+// each write at every digest's part boundaries.
+type fanoutWriter struct {
+	digests []*Digest
+	off     int64
+}
+
+func (w *fanoutWriter) Write(p []byte) (int, error) {
+	for _, d := range w.digests {
+		off, rest := w.off, p
+		for len(rest) > 0 {
+			n := min(int64(len(rest)), d.partSize-off%d.partSize)
+			if _, err := d.WriteAt(rest[:n], off); err != nil {
+				return 0, err
+			}
+			off += n
+			rest = rest[n:]
+		}
+	}
+	w.off += int64(len(p))
+	return len(p), nil
+}
+
+// ctxReader stops a long sequential read when ctx is canceled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,193 @@
+package etag
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// multipartETag is the reference S3 multipart ETag formula, computed
+// sequentially.
+func multipartETag(data []byte, partSize int) string {
+	var concat []byte
+	n := 0
+	for off := 0; off < len(data) || n == 0; off += partSize {
+		sum := md5.Sum(data[off:min(off+partSize, len(data))])
+		concat = append(concat, sum[:]...)
+		n++
+	}
+	sum := md5.Sum(concat)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), n)
+}
+
+func plainETag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeTemp writes data to a temp file and returns its path.
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestFile_MatchesMultipartETag verifies that hashing parts in parallel
+// produces the same digest as the sequential ETag formula, for single-part,
+// exact-multiple and ragged-tail sizes.
+func TestFile_MatchesMultipartETag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		size     int
+		partSize int
+	}{
+		{"empty", 0, 4},
+		{"single part", 3, 4},
+		{"exact multiple", 12, 4},
+		{"ragged tail", 13, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := bytes.Repeat([]byte("abcdefg"), tt.size/7+1)[:tt.size]
+			d, err := File(context.Background(), writeTemp(t, data), int64(tt.size), int64(tt.partSize), 3)
+			if err != nil {
+				t.Fatalf("File: %v", err)
+			}
+			got, err := d.Sum()
+			if err != nil {
+				t.Fatalf("Sum: %v", err)
+			}
+			want := multipartETag(data, tt.partSize)
+			if tt.size <= tt.partSize {
+				want = plainETag(data)
+			}
+			if got != want {
+				t.Errorf("Sum = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// TestDigest_RetriedPart verifies that a part the downloader rewrites from
+// its start is hashed once, and that a gap is rejected.
+func TestDigest_RetriedPart(t *testing.T) {
+	t.Parallel()
+	data := []byte("0123456789")
+	d := NewDigest(int64(len(data)), 4)
+	writes := []struct {
+		off int64
+		p   string
+	}{
+		{4, "45"},
+		{4, "4567"}, // retry of part 2 from its start
+		{0, "0123"},
+		{8, "89"},
+	}
+	for _, w := range writes {
+		if _, err := d.WriteAt([]byte(w.p), w.off); err != nil {
+			t.Fatalf("WriteAt(%q, %d): %v", w.p, w.off, err)
+		}
+	}
+	got, err := d.Sum()
+	if err != nil {
+		t.Fatalf("Sum: %v", err)
+	}
+	if want := multipartETag(data, 4); got != want {
+		t.Errorf("Sum = %q, want %q", got, want)
+	}
+
+	gap := NewDigest(int64(len(data)), 4)
+	if _, err := gap.WriteAt([]byte("56"), 5); err == nil {
+		t.Errorf("WriteAt into the middle of an unstarted part should fail")
+	}
+}
+
+// TestPartSizes covers part size auto-detection from the "-N" suffix.
+func TestPartSizes(t *testing.T) {
+	t.Parallel()
+	md5hex := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name      string
+		etag      string
+		size      int64
+		preferred int64
+		wantFirst int64
+		wantNone  bool
+	}{
+		{"plain", md5hex, 100, 10, 100, false},
+		{"plain empty object", md5hex, 0, 10, 1, false},
+		{"preferred fits", md5hex + "-3", 20 * mib, 8 * mib, 8 * mib, false},
+		{"preferred does not fit", md5hex + "-2", 12 * mib, 50 * mib, 8 * mib, false},
+		{"only the exact lower bound fits", md5hex + "-4", 100, 0, 25, false},
+		{"not md5", "not-an-etag", 100, 10, 0, true},
+		{"bad suffix", md5hex + "-x", 100, 10, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := PartSizes(tt.etag, tt.size, tt.preferred)
+			if tt.wantNone {
+				if len(got) != 0 {
+					t.Errorf("PartSizes = %v, want none", got)
+				}
+				return
+			}
+			if len(got) == 0 || got[0] != tt.wantFirst {
+				t.Fatalf("PartSizes = %v, want first %d", got, tt.wantFirst)
+			}
+			parts, _ := Parse(tt.etag)
+			for _, p := range got {
+				if parts > 1 && PartCount(tt.size, p) != parts {
+					t.Errorf("candidate %d gives %d parts, want %d", p, PartCount(tt.size, p), parts)
+				}
+			}
+		})
+	}
+}
+
+// TestMatch verifies that Match reproduces plain and multipart ETags
+// without being told the part size, and rejects other content.
+func TestMatch(t *testing.T) {
+	t.Parallel()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	path := writeTemp(t, data)
+	size := int64(len(data))
+
+	tests := []struct {
+		name         string
+		etag         string
+		wantMatch    bool
+		wantPartSize int64
+	}{
+		{"plain", plainETag(data), true, size},
+		{"multipart, part size derived from the size", multipartETag(data, 4000), true, 4000},
+		{"single part multipart upload", multipartETag(data, len(data)), true, size},
+		{"other content", plainETag(data[1:]), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			matched, partSize, err := Match(context.Background(), path, size, tt.etag, 0)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if matched != tt.wantMatch || partSize != tt.wantPartSize {
+				t.Errorf("Match = (%v, %d), want (%v, %d)", matched, partSize, tt.wantMatch, tt.wantPartSize)
+			}
+		})
+	}
+
+	if _, _, err := Match(context.Background(), path, size, "kms-style-etag", 0); err != ErrIncomparable {
+		t.Errorf("Match(non-MD5 ETag) err = %v, want ErrIncomparable", err)
+	}
+}
@@ -0,0 +1,525 @@
+// Package filter implements the --filter expression language shared by the
+// listing commands: a boolean expression over the fields of a
+// storage.Object, such as
+//
+//	size > 100MiB && mtime < now-30d && class == "STANDARD"
+//
+// An expression is parsed and type-checked once into a Filter, which is
+// then evaluated against every listed object.
+//
+// Fields are name (the last path element), path (the path relative to
+// the listed prefix or directory, the form --exclude matches), key (the
+// full key or file path), size, mtime, class, etag, version and
+// delete_marker. Values are numbers with an optional size unit (100MiB,
+// 1.5G; units are powers of 1024), durations (30d, 1w, 1h30m; a lowercase
+// m is minutes), quoted strings and the time now. A quoted RFC 3339 time
+// or YYYY-MM-DD date compares with mtime, and durations add to and
+// subtract from times.
+//
+// Operators are == != < <= > >= on values of the same type, =~ and !~
+// against a quoted regular expression, + and -, and &&, || and ! (also
+// spelled and, or and not) with parentheses. An object without a
+// modification time matches no comparison involving mtime.
+//
+// The package also implements the ordered, rsync-style path rules read by
+// --filter-from (see Rules).
+package filter
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/LinPr/s6cmd/strutil"
+)
+
+// Fields lists the object fields an expression can refer to.
+const Fields = "name, path, key, size, mtime, class, etag, version, delete_marker"
+
+// Filter is a parsed filter expression. A nil *Filter matches every
+// object, so commands can call Match without checking whether --filter
+// was given.
+type Filter struct {
+	expr  string
+	match func(*storage.Object) bool
+}
+
+// Parse parses expr, resolving now to the current time. An empty or blank
+// expression yields a nil Filter and no error.
+func Parse(expr string) (*Filter, error) {
+	return parse(expr, time.Now())
+}
+
+func parse(expr string, now time.Time) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: expr, toks: toks, now: now}
+	v, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(expr, t.pos, "unexpected %s", t)
+	}
+	if err := p.needCondition(v); err != nil {
+		return nil, err
+	}
+	return &Filter{expr: expr, match: v.cond}, nil
+}
+
+// Match reports whether obj satisfies the filter.
+func (f *Filter) Match(obj *storage.Object) bool {
+	if f == nil {
+		return true
+	}
+	return f.match(obj)
+}
+
+// String returns the expression the filter was parsed from.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Error is a parse error, located at a byte offset of the expression.
+type Error struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	col := utf8.RuneCountInString(e.Expr[:e.Pos]) + 1
+	return fmt.Sprintf("filter %q: column %d: %s", e.Expr, col, e.Msg)
+}
+
+func errorAt(src string, pos int, format string, args ...any) error {
+	return &Error{Expr: src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// kind is the type of an expression.
+type kind int
+
+const (
+	kindBool kind = iota
+	kindNumber
+	kindDuration
+	kindTime
+	kindString
+)
+
+var kindNames = [...]string{
+	kindBool:     "a condition",
+	kindNumber:   "a number",
+	kindDuration: "a duration",
+	kindTime:     "a time",
+	kindString:   "a string",
+}
+
+// value is a type-checked expression, compiled to the evaluation function
+// of its kind: cond for conditions, str for strings and num for numbers,
+// durations and times (as Unix nanoseconds). num reports false when the
+// object lacks the field, which makes every comparison with it false.
+type value struct {
+	kind kind
+	pos  int
+	text string
+	cond func(*storage.Object) bool
+	num  func(*storage.Object) (int64, bool)
+	str  func(*storage.Object) string
+	// literal is the content of a quoted string, from which regular
+	// expressions and times are compiled at parse time.
+	literal *string
+}
+
+// parser is a recursive-descent parser over the tokens of an expression.
+// Precedence, loosest first: ||, &&, !, comparisons, + and -.
+type parser struct {
+	src  string
+	toks []token
+	i    int
+	now  time.Time
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of ops. The word operators
+// (and, or, not) are identifier tokens.
+func (p *parser) accept(ops ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+// span sets the position and source text of v to run from start to the
+// last consumed token.
+func (p *parser) span(v value, start int) value {
+	end := start
+	if p.i > 0 {
+		last := p.toks[p.i-1]
+		end = last.pos + len(last.text)
+	}
+	v.pos, v.text = start, p.src[start:end]
+	return v
+}
+
+func (p *parser) needCondition(v value) error {
+	if v.kind != kindBool {
+		return errorAt(p.src, v.pos, "%q is %s, not a condition", v.text, kindNames[v.kind])
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (value, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return x, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return x, nil
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return y, err
+		}
+		if err := p.needCondition(x); err != nil {
+			return x, err
+		}
+		if err := p.needCondition(y); err != nil {
+			return y, err
+		}
+		xf, yf := x.cond, y.cond
+		x = p.span(value{kind: kindBool, cond: func(o *storage.Object) bool { return xf(o) || yf(o) }}, x.pos)
+	}
+}
+
+func (p *parser) parseAnd() (value, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return x, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return y, err
+		}
+		if err := p.needCondition(x); err != nil {
+			return x, err
+		}
+		if err := p.needCondition(y); err != nil {
+			return y, err
+		}
+		xf, yf := x.cond, y.cond
+		x = p.span(value{kind: kindBool, cond: func(o *storage.Object) bool { return xf(o) && yf(o) }}, x.pos)
+	}
+}
+
+func (p *parser) parseUnary() (value, error) {
+	op, ok := p.accept("!", "not")
+	if !ok {
+		return p.parseComparison()
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return x, err
+	}
+	if err := p.needCondition(x); err != nil {
+		return x, err
+	}
+	xf := x.cond
+	return p.span(value{kind: kindBool, cond: func(o *storage.Object) bool { return !xf(o) }}, op.pos), nil
+}
+
+func (p *parser) parseComparison() (value, error) {
+	x, err := p.parseSum()
+	if err != nil {
+		return x, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "=~", "!~")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.parseSum()
+	if err != nil {
+		return y, err
+	}
+	return p.compare(op, x, y)
+}
+
+func (p *parser) compare(op token, x, y value) (value, error) {
+	if op.text == "=~" || op.text == "!~" {
+		if x.kind != kindString {
+			return x, errorAt(p.src, x.pos, "%s needs a string on its left, %q is %s", op.text, x.text, kindNames[x.kind])
+		}
+		if y.literal == nil {
+			return y, errorAt(p.src, y.pos, "%s needs a quoted regular expression on its right", op.text)
+		}
+		re, err := regexp.Compile(*y.literal)
+		if err != nil {
+			return y, errorAt(p.src, y.pos, "invalid regular expression: %v", err)
+		}
+		xf, negate := x.str, op.text == "!~"
+		return p.span(value{kind: kindBool, cond: func(o *storage.Object) bool { return re.MatchString(xf(o)) != negate }}, x.pos), nil
+	}
+
+	// A quoted time compares with a time.
+	var err error
+	if x.kind == kindTime && y.literal != nil {
+		y, err = p.timeLiteral(y)
+	} else if y.kind == kindTime && x.literal != nil {
+		x, err = p.timeLiteral(x)
+	}
+	if err != nil {
+		return x, err
+	}
+	if x.kind != y.kind {
+		return x, errorAt(p.src, op.pos, "cannot compare %q (%s) with %q (%s)", x.text, kindNames[x.kind], y.text, kindNames[y.kind])
+	}
+
+	var cond func(*storage.Object) bool
+	switch x.kind {
+	case kindBool:
+		if op.text != "==" && op.text != "!=" {
+			return x, errorAt(p.src, op.pos, "conditions can only be compared with == or !=")
+		}
+		xf, yf, negate := x.cond, y.cond, op.text == "!="
+		cond = func(o *storage.Object) bool { return (xf(o) == yf(o)) != negate }
+	case kindString:
+		xf, yf := x.str, y.str
+		cond = func(o *storage.Object) bool { return holds(op.text, strings.Compare(xf(o), yf(o))) }
+	default:
+		xf, yf := x.num, y.num
+		cond = func(o *storage.Object) bool {
+			a, ok := xf(o)
+			if !ok {
+				return false
+			}
+			b, ok := yf(o)
+			return ok && holds(op.text, cmp.Compare(a, b))
+		}
+	}
+	return p.span(value{kind: kindBool, cond: cond}, x.pos), nil
+}
+
+// holds reports whether the comparison op is true of a three-way
+// comparison result c.
+func holds(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// timeLiteral converts a quoted string compared with a time to a time.
+func (p *parser) timeLiteral(v value) (value, error) {
+	t, err := ParseTime(*v.literal)
+	if err != nil {
+		return v, errorAt(p.src, v.pos, "%v", err)
+	}
+	return value{kind: kindTime, pos: v.pos, text: v.text, num: constant(t.UnixNano())}, nil
+}
+
+func (p *parser) parseSum() (value, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return x, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parsePrimary()
+		if err != nil {
+			return y, err
+		}
+		if x, err = p.arith(op, x, y); err != nil {
+			return x, err
+		}
+	}
+}
+
+// arith adds or subtracts y to x: numbers to numbers, durations to
+// durations or times, and times from times, which yields a duration.
+func (p *parser) arith(op token, x, y value) (value, error) {
+	var k kind
+	switch {
+	case x.kind == y.kind && (x.kind == kindNumber || x.kind == kindDuration):
+		k = x.kind
+	case x.kind == kindTime && y.kind == kindDuration:
+		k = kindTime
+	case x.kind == kindDuration && y.kind == kindTime && op.text == "+":
+		k = kindTime
+	case x.kind == kindTime && y.kind == kindTime && op.text == "-":
+		k = kindDuration
+	default:
+		hint := ""
+		if x.kind == kindTime && y.kind == kindNumber {
+			hint = "; give the number a unit, as in 30d"
+		}
+		return x, errorAt(p.src, op.pos, "cannot apply %s to %q (%s) and %q (%s)%s",
+			op.text, x.text, kindNames[x.kind], y.text, kindNames[y.kind], hint)
+	}
+	sign := int64(1)
+	if op.text == "-" {
+		sign = -1
+	}
+	xf, yf := x.num, y.num
+	num := func(o *storage.Object) (int64, bool) {
+		a, ok := xf(o)
+		if !ok {
+			return 0, false
+		}
+		b, ok := yf(o)
+		return a + sign*b, ok
+	}
+	return p.span(value{kind: k, num: num}, x.pos), nil
+}
+
+func (p *parser) parsePrimary() (value, error) {
+	t := p.next()
+	switch t.kind {
+	case tokOp:
+		if t.text != "(" {
+			break
+		}
+		v, err := p.parseOr()
+		if err != nil {
+			return v, err
+		}
+		if closing := p.next(); closing.kind != tokOp || closing.text != ")" {
+			return v, errorAt(p.src, closing.pos, "expected \")\", found %s", closing)
+		}
+		return p.span(v, t.pos), nil
+	case tokNumber:
+		return p.number(t)
+	case tokString:
+		s := t.value
+		return value{kind: kindString, pos: t.pos, text: t.text, str: func(*storage.Object) string { return s }, literal: &s}, nil
+	case tokIdent:
+		switch t.text {
+		case "and", "or", "not":
+			return value{}, errorAt(p.src, t.pos, "expected a value, found %s", t)
+		case "now":
+			return value{kind: kindTime, pos: t.pos, text: t.text, num: constant(p.now.UnixNano())}, nil
+		case "true", "false":
+			b := t.text == "true"
+			return value{kind: kindBool, pos: t.pos, text: t.text, cond: func(*storage.Object) bool { return b }}, nil
+		}
+		v, ok := field(t.text)
+		if !ok {
+			return v, errorAt(p.src, t.pos, "unknown field %q, want one of %s", t.text, Fields)
+		}
+		v.pos, v.text = t.pos, t.text
+		return v, nil
+	}
+	return value{}, errorAt(p.src, t.pos, "expected a value, found %s", t)
+}
+
+// number parses a number token: a duration when every letter in it is a
+// duration unit (ns, us, ms, s, m, h, d, w), a size otherwise.
+func (p *parser) number(t token) (value, error) {
+	letters, duration := false, true
+	for i := 0; i < len(t.text); i++ {
+		if c := t.text[i]; isLetter(c) {
+			letters = true
+			duration = duration && strings.IndexByte("nusmhdw", c) >= 0
+		}
+	}
+	if letters && duration {
+		d, err := ParseDuration(t.text)
+		if err != nil {
+			return value{}, errorAt(p.src, t.pos, "%v", err)
+		}
+		return value{kind: kindDuration, pos: t.pos, text: t.text, num: constant(int64(d))}, nil
+	}
+	n, err := ParseSize(t.text)
+	if err != nil {
+		return value{}, errorAt(p.src, t.pos, "%v", err)
+	}
+	return value{kind: kindNumber, pos: t.pos, text: t.text, num: constant(n)}, nil
+}
+
+func constant(n int64) func(*storage.Object) (int64, bool) {
+	return func(*storage.Object) (int64, bool) { return n, true }
+}
+
+// field returns the value of the object field name.
+func field(name string) (value, bool) {
+	str := func(f func(*storage.Object) string) (value, bool) {
+		return value{kind: kindString, str: f}, true
+	}
+	switch name {
+	case "name":
+		return str(func(o *storage.Object) string { return o.StorageURL.Base() })
+	case "path":
+		return str(func(o *storage.Object) string { return o.StorageURL.Relative() })
+	case "key":
+		return str(func(o *storage.Object) string { return o.StorageURL.Path })
+	case "class":
+		// An object listed without a storage class is STANDARD.
+		return str(func(o *storage.Object) string {
+			if o.StorageClass == "" {
+				return "STANDARD"
+			}
+			return string(o.StorageClass)
+		})
+	case "etag":
+		return str(func(o *storage.Object) string { return strutil.TrimQuotes(o.Etag) })
+	case "version":
+		return str(func(o *storage.Object) string { return o.VersionID })
+	case "size":
+		return value{kind: kindNumber, num: func(o *storage.Object) (int64, bool) { return o.Size, true }}, true
+	case "mtime":
+		return value{kind: kindTime, num: func(o *storage.Object) (int64, bool) {
+			if o.ModTime == nil {
+				return 0, false
+			}
+			return o.ModTime.UnixNano(), true
+		}}, true
+	case "delete_marker":
+		return value{kind: kindBool, cond: func(o *storage.Object) bool { return o.IsDeleteMarker }}, true
+	}
+	return value{}, false
+}
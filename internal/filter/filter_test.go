@@ -0,0 +1,176 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newObject returns s3://bucket/<key>, listed under s3://bucket/data/.
+func newObject(t *testing.T, key string, size int64, age time.Duration, class string) *storage.Object {
+	t.Helper()
+	u, err := storage.NewStorageURL("s3://bucket/" + key)
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	u.SetRelativePath(strings.TrimPrefix(key, "data/"))
+	mod := testNow.Add(-age)
+	return &storage.Object{
+		StorageURL:   u,
+		Etag:         `"0cc175b9c0f1b6a831c399e269772661"`,
+		ModTime:      &mod,
+		Size:         size,
+		StorageClass: storage.StorageClass(class),
+		VersionID:    "v1",
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	const day = 24 * time.Hour
+	obj := newObject(t, "data/2024/part-0001.parquet", 200<<20, 45*day, "")
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{`size > 100MiB && mtime < now-30d && class == "STANDARD"`, true},
+		{"size > 100MiB and mtime < now - 60d", false},
+		{"size >= 200M", true},
+		{"size > 200M", false},
+		{"size <= 209715200", true},
+		{"size < 0.1G", false},
+		{"size == 200MB || size == 1", true},
+		{"size != 200MiB", false},
+		{"mtime > now - 7w", true},
+		{"mtime < now - 1w - 40d", false},
+		{"now - mtime > 1000h", true},
+		{"now - mtime < 45d + 1m", true},
+		{`mtime < "2025-05-01"`, true},
+		{`mtime >= "2025-04-17T12:00:00Z"`, true},
+		{`"2025-04-18" < mtime`, false},
+		{`name == "part-0001.parquet"`, true},
+		{`path == "2024/part-0001.parquet"`, true},
+		{`key == "data/2024/part-0001.parquet"`, true},
+		{`name =~ "\\.parquet$"`, true},
+		{`path =~ '^2024/'`, true},
+		{`key !~ "^data/"`, false},
+		{`name =~ "(?i)PART"`, true},
+		{`etag == "0cc175b9c0f1b6a831c399e269772661"`, true},
+		{`version == "v1" && !delete_marker`, true},
+		{"delete_marker == false", true},
+		{`class == "GLACIER" || class != "STANDARD"`, false},
+		{`class > "GLACIER"`, true},
+		{`!(size > 1G || name =~ "csv$")`, true},
+		{`not (size > 1G) and (name =~ "csv$" or true)`, true},
+		{"!!delete_marker", false},
+	}
+	for _, tc := range cases {
+		f, err := parse(tc.expr, testNow)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := f.Match(obj); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestFilterMissingModTime(t *testing.T) {
+	obj := newObject(t, "data/a", 1, 0, "GLACIER")
+	obj.ModTime = nil
+	for _, expr := range []string{"mtime < now", "mtime >= now", "mtime != now", "now - mtime > 1s"} {
+		f, err := parse(expr, testNow)
+		if err != nil {
+			t.Fatalf("parse(%q): %v", expr, err)
+		}
+		if f.Match(obj) {
+			t.Errorf("%q matched an object without a modification time", expr)
+		}
+	}
+}
+
+func TestFilterNil(t *testing.T) {
+	f, err := Parse("  ")
+	if err != nil || f != nil {
+		t.Fatalf("Parse(blank) = %v, %v; want nil, nil", f, err)
+	}
+	if !f.Match(&storage.Object{}) {
+		t.Errorf("a nil filter should match every object")
+	}
+}
+
+func TestFilterParseErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		col  int
+		msg  string
+	}{
+		{"size > ", 8, "expected a value, found end of expression"},
+		{"size > && 1", 8, `expected a value, found "&&"`},
+		{"size", 1, `"size" is a number, not a condition`},
+		{"size > 1 && name", 13, `"name" is a string, not a condition`},
+		{"sise > 1", 1, `unknown field "sise"`},
+		{"size > 1X", 8, `unknown size unit "X"`},
+		{"size = 1", 6, "use == to compare"},
+		{"size > 1 & name", 10, "use &&"},
+		{`name == "abc`, 9, "unterminated string"},
+		{"size > 1 )", 10, `unexpected ")"`},
+		{"(size > 1", 10, `expected ")", found end of expression`},
+		{`size > "1"`, 6, `cannot compare "size" (a number) with "\"1\"" (a string)`},
+		{"mtime < now - 30", 13, "give the number a unit"},
+		{"size > 1 + now", 10, "cannot apply +"},
+		{`mtime < "yesterday"`, 9, `invalid time "yesterday"`},
+		{`name =~ "("`, 9, "invalid regular expression"},
+		{`name =~ key`, 9, "needs a quoted regular expression"},
+		{`size =~ "1"`, 1, "needs a string on its left"},
+		{"delete_marker < true", 15, "only be compared with == or !="},
+		{"size > 1 size", 10, `unexpected "size"`},
+		{"size > 1 or", 12, "expected a value"},
+		{"ñame > 1", 1, `unexpected character`},
+	}
+	for _, tc := range cases {
+		_, err := parse(tc.expr, testNow)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("parse(%q) = %v, want an *Error", tc.expr, err)
+			continue
+		}
+		if col := len([]rune(perr.Expr[:perr.Pos])) + 1; col != tc.col || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("parse(%q) = %q at column %d, want %q at column %d", tc.expr, err, col, tc.msg, tc.col)
+		}
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	sizes := map[string]int64{"0": 0, "42": 42, "1K": 1 << 10, "1kb": 1 << 10, "1.5GiB": 3 << 29, "2P": 2 << 50, "3b": 3}
+	for in, want := range sizes {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "1.5", "-1", "1X", "1KBB", "1BB", "K"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) = nil error", in)
+		}
+	}
+	durations := map[string]time.Duration{"90d": 90 * 24 * time.Hour, "1w2d": 9 * 24 * time.Hour, "1d12h": 36 * time.Hour, "90m": 90 * time.Minute}
+	for in, want := range durations {
+		if got, err := ParseDuration(in); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "1.5d", "d", "-1h", "soon"} {
+		if _, err := ParseDuration(in); err == nil {
+			t.Errorf("ParseDuration(%q) = nil error", in)
+		}
+	}
+	if got, err := ParseTime("2024-02-29"); err != nil || !got.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTime(date) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,127 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+// token is a lexeme of a filter expression. pos is its byte offset in the
+// expression, for error messages.
+type token struct {
+	kind tokenKind
+	text string
+	// value is the unquoted content of a string token.
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators lists the operator tokens, longest first so "<=" is not lexed
+// as "<" followed by "=".
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "+", "-", "(", ")"}
+
+// lex splits src into tokens, ending with a tokEOF.
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isLetter(c):
+			j := i + 1
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		case isDigit(c):
+			// A number runs up to the next operator or space, taking its
+			// unit with it: 100MiB, 1.5G, 30d, 1h30m.
+			j := i + 1
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			tok, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i += len(tok.text)
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			switch {
+			case op != "":
+			case c == '=':
+				return nil, errorAt(src, i, `unexpected "=", use == to compare`)
+			case c == '&' || c == '|':
+				return nil, errorAt(src, i, "unexpected %q, use %s", string(c), strings.Repeat(string(c), 2))
+			default:
+				return nil, errorAt(src, i, "unexpected character %q", src[i:i+1])
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString lexes the quoted string starting at src[start]. Double-quoted
+// strings take Go escapes; single-quoted strings are taken literally.
+func lexString(src string, start int) (token, error) {
+	quote := src[start]
+	for j := start + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			if quote == '"' {
+				j++
+			}
+		case quote:
+			text := src[start : j+1]
+			value := text[1 : len(text)-1]
+			if quote == '"' {
+				v, err := strconv.Unquote(text)
+				if err != nil {
+					return token{}, errorAt(src, start, "invalid string %s", text)
+				}
+				value = v
+			}
+			return token{kind: tokString, text: text, value: value, pos: start}, nil
+		}
+	}
+	return token{}, errorAt(src, start, "unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
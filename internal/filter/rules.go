@@ -0,0 +1,214 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rules is an ordered list of rsync-style include and exclude rules, read
+// from a --filter-from file. A path is decided by the first rule matching
+// it; a path no rule matches is included. As in rsync, the directories
+// leading to an object are decided first, and an excluded directory
+// excludes everything under it: "+ */" keeps every directory open so that
+// later rules decide the objects inside.
+//
+// A nil *Rules includes every path.
+type Rules struct {
+	rules []rule
+
+	// dirs caches the decision of every directory seen: objects share
+	// their leading directories, and a rule file can be long.
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+// rule is one parsed "+ pattern" or "- pattern" line.
+type rule struct {
+	include bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// LoadRules reads the rules in the file at path. An empty path yields nil
+// Rules and no error.
+func LoadRules(path string) (*Rules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRules(f, path)
+}
+
+// ParseRules reads rules from r, one per line. name identifies r in error
+// messages.
+//
+// A rule is "+ PATTERN" (or "include PATTERN") or "- PATTERN" (or
+// "exclude PATTERN"). Blank lines and lines starting with # or ; are
+// ignored. In a pattern, * matches within a path element, ** matches
+// across elements, ? matches one character and [...] a character class;
+// a trailing /*** matches a directory and everything under it. A pattern
+// starting with / is anchored at the root of the listing, otherwise it
+// matches the end of the path at an element boundary. A pattern ending in
+// / only matches directories.
+func ParseRules(r io.Reader, name string) (*Rules, error) {
+	rs := &Rules{dirs: make(map[string]bool)}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+			continue
+		}
+		var include bool
+		var pattern string
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			include, pattern = true, line[2:]
+		case strings.HasPrefix(line, "- "):
+			pattern = line[2:]
+		case strings.HasPrefix(line, "include "):
+			include, pattern = true, line[len("include "):]
+		case strings.HasPrefix(line, "exclude "):
+			pattern = line[len("exclude "):]
+		default:
+			return nil, fmt.Errorf("%s:%d: want \"+ PATTERN\" or \"- PATTERN\", got %q", name, n, line)
+		}
+		ru, err := compileRule(pattern, include)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		rs.rules = append(rs.rules, ru)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return rs, nil
+}
+
+func compileRule(pattern string, include bool) (rule, error) {
+	ru := rule{include: include}
+	p := pattern
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if strings.HasSuffix(p, "/") {
+		ru.dirOnly = true
+		p = strings.TrimSuffix(p, "/")
+	}
+	// dir/*** is dir itself and everything under it.
+	suffix := ""
+	if strings.HasSuffix(p, "/***") {
+		p = strings.TrimSuffix(p, "/***")
+		suffix = "(?:/.*)?"
+	}
+	if p == "" {
+		return ru, fmt.Errorf("empty pattern %q", pattern)
+	}
+	body, err := globToRegexp(p)
+	if err != nil {
+		return ru, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	start := "(?:^|/)"
+	if anchored {
+		start = "^"
+	}
+	ru.re, err = regexp.Compile(start + body + suffix + "$")
+	if err != nil {
+		return ru, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	return ru, nil
+}
+
+// globToRegexp translates a rule pattern to a regular expression.
+func globToRegexp(p string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				b.WriteString(".*")
+				for i+1 < len(p) && p[i+1] == '*' {
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			// A ] right after [ or [! is part of the class.
+			if end == 0 || (end == 1 && p[i+1] == '!') {
+				next := strings.IndexByte(p[i+end+2:], ']')
+				if next < 0 {
+					return "", fmt.Errorf("unterminated character class")
+				}
+				end += next + 1
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			// A backslash escapes the next character.
+			if i+1 < len(p) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
+
+// Excluded reports whether the rules exclude the object at path, a
+// slash-separated path relative to the root of the listing.
+func (rs *Rules) Excluded(path string) bool {
+	if rs == nil || len(rs.rules) == 0 {
+		return false
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && i > 0 && !rs.dirIncluded(path[:i]) {
+			return true
+		}
+	}
+	return !rs.decide(path, false)
+}
+
+func (rs *Rules) dirIncluded(dir string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	included, ok := rs.dirs[dir]
+	if !ok {
+		included = rs.decide(dir, true)
+		rs.dirs[dir] = included
+	}
+	return included
+}
+
+// decide returns whether the first rule matching path includes it.
+func (rs *Rules) decide(path string, isDir bool) bool {
+	for _, ru := range rs.rules {
+		if ru.dirOnly && !isDir {
+			continue
+		}
+		if ru.re.MatchString(path) {
+			return ru.include
+		}
+	}
+	return true
+}
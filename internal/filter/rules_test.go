@@ -0,0 +1,156 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustRules(t *testing.T, text string) *Rules {
+	t.Helper()
+	rs, err := ParseRules(strings.NewReader(text), "rules")
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	return rs
+}
+
+func TestRulesFirstMatchWins(t *testing.T) {
+	rs := mustRules(t, `
+# keep the important logs, drop the rest
++ important.log
+- *.log
+; comments may also start with a semicolon
++ *.txt
+exclude *.txt
+`)
+	cases := map[string]bool{
+		"important.log":       false,
+		"a/b/important.log":   false,
+		"debug.log":           true,
+		"a/debug.log":         true,
+		"notes.txt":           false,
+		"data.csv":            false,
+		"important.log.bak":   false,
+		"dir.log/readme.md":   true,
+		"dir.txt/debug.log":   true,
+		"dir.txt/readme.txt2": false,
+	}
+	for path, want := range cases {
+		if got := rs.Excluded(path); got != want {
+			t.Errorf("Excluded(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRulesPatterns(t *testing.T) {
+	cases := []struct {
+		rule     string
+		path     string
+		excluded bool
+	}{
+		// A pattern without a slash matches the last element anywhere.
+		{"- *.tmp", "a/b/c.tmp", true},
+		{"- *.tmp", "a/b/c.tmp.gz", false},
+		// A leading slash anchors the pattern at the root.
+		{"- /build", "build", true},
+		{"- /build", "src/build", false},
+		{"- /src/*.o", "src/main.o", true},
+		{"- /src/*.o", "src/sub/main.o", false},
+		// An inner slash matches the end of the path at an element
+		// boundary.
+		{"- cache/*.bin", "x/cache/a.bin", true},
+		{"- cache/*.bin", "x/mycache/a.bin", false},
+		{"- cache/*.bin", "cache/sub/a.bin", false},
+		// ** crosses elements, * and ? do not.
+		{"- /logs/**.gz", "logs/2024/01/a.gz", true},
+		{"- /logs/*.gz", "logs/2024/a.gz", false},
+		{"- /a?c", "abc", true},
+		{"- /a?c", "a/c", false},
+		// Character classes, negated with !.
+		{"- /[ab]*.csv", "b1.csv", true},
+		{"- /[!ab]*.csv", "b1.csv", false},
+		{"- /[]x]", "]", true},
+		// A backslash escapes a wildcard.
+		{`- /literal\*`, "literal*", true},
+		{`- /literal\*`, "literalX", false},
+		// A trailing slash only matches directories, so it excludes
+		// what is under them but not a file of that name.
+		{"- tmp/", "a/tmp/x.txt", true},
+		{"- tmp/", "a/tmp", false},
+		// dir/*** matches the directory and everything under it.
+		{"- /assets/***", "assets/img/logo.png", true},
+		{"- /assets/***", "assetsx/logo.png", false},
+		// An excluded directory excludes its contents.
+		{"- node_modules", "web/node_modules/pkg/index.js", true},
+	}
+	for _, tc := range cases {
+		if got := mustRules(t, tc.rule).Excluded(tc.path); got != tc.excluded {
+			t.Errorf("%q on %q: excluded = %v, want %v", tc.rule, tc.path, got, tc.excluded)
+		}
+	}
+}
+
+// TestRulesDirectories checks the rsync idiom for keeping only some files:
+// without "+ */" the final "- *" excludes every directory, and with it
+// the contents are decided file by file.
+func TestRulesDirectories(t *testing.T) {
+	closed := mustRules(t, "+ *.go\n- *\n")
+	if !closed.Excluded("cmd/main.go") || closed.Excluded("main.go") {
+		t.Errorf("without + */, files in subdirectories should be excluded")
+	}
+	open := mustRules(t, "+ */\n+ *.go\n- *\n")
+	for path, want := range map[string]bool{"cmd/main.go": false, "cmd/README.md": true, "main.go": false} {
+		if got := open.Excluded(path); got != want {
+			t.Errorf("with + */: Excluded(%q) = %v, want %v", path, got, want)
+		}
+	}
+	// The directory decisions are cached; asking again gives the same
+	// answers.
+	if open.Excluded("cmd/README.md") != true || open.Excluded("cmd/main.go") != false {
+		t.Errorf("cached directory decisions changed the result")
+	}
+}
+
+func TestRulesNil(t *testing.T) {
+	var rs *Rules
+	if rs.Excluded("anything") {
+		t.Errorf("nil rules should include every path")
+	}
+	if mustRules(t, "# only comments\n\n").Excluded("x") {
+		t.Errorf("an empty rule list should include every path")
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for text, want := range map[string]string{
+		"+ *.go\nfoo\n":  `rules:2: want "+ PATTERN" or "- PATTERN", got "foo"`,
+		"-*.go\n":        `rules:1: want "+ PATTERN" or "- PATTERN"`,
+		"- /\n":          `rules:1: empty pattern "/"`,
+		"\n\n+ [abc\n":   "rules:3: pattern \"[abc\": unterminated character class",
+		"- a\n- [z-a]\n": "rules:2: pattern \"[z-a]\"",
+	} {
+		_, err := ParseRules(strings.NewReader(text), "rules")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseRules(%q) = %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.txt")
+	if err := os.WriteFile(path, []byte("- *.bak\r\n+ keep.bak\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	if !rs.Excluded("keep.bak") {
+		t.Errorf("the first matching rule should win")
+	}
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("LoadRules of a missing file should fail")
+	}
+}
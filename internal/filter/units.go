@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits are the binary multipliers of a size, by their first letter.
+var sizeUnits = map[byte]int64{
+	'B': 1,
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+}
+
+// ParseSize parses a byte count: a number and an optional unit (B, K, M, G,
+// T or P, which may be spelled KB or KiB, in any case). Units are powers of
+// 1024 and a fraction is allowed with one, as in 1.5G.
+func ParseSize(v string) (int64, error) {
+	num := v
+	end := strings.IndexFunc(num, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	unit := ""
+	if end >= 0 {
+		num, unit = num[:end], num[end:]
+	}
+	mult := int64(1)
+	if unit != "" {
+		upper := strings.ToUpper(unit)
+		m, ok := sizeUnits[upper[0]]
+		if !ok || len(upper) > 3 || (len(upper) > 1 && upper[0] == 'B') ||
+			(len(upper) == 2 && upper[1] != 'B') || (len(upper) == 3 && upper[1:] != "IB") {
+			return 0, fmt.Errorf("unknown size unit %q", unit)
+		}
+		mult = m
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	if mult == 1 && f != float64(int64(f)) {
+		return 0, fmt.Errorf("invalid size %q: a byte count must be whole", v)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// ParseDuration is time.ParseDuration with the d (24h) and w (7d) units
+// added, as in 90d or 1w2d. Negative durations are rejected.
+func ParseDuration(v string) (time.Duration, error) {
+	var total time.Duration
+	rest := v
+	for rest != "" {
+		i := strings.IndexAny(rest, "dw")
+		if i < 0 {
+			break
+		}
+		// A "d" or "w" ends the number before it; anything between the
+		// previous unit and it must be a whole number.
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		unit := 24 * time.Hour
+		if rest[i] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		total += d
+	}
+	if v == "" || total < 0 {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return total, nil
+}
+
+// ParseTime parses an RFC 3339 time or a date, which is midnight UTC.
+func ParseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339 or YYYY-MM-DD", v)
+	}
+	return t, nil
+}
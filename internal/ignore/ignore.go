@@ -0,0 +1,296 @@
+// Package ignore implements the per-directory, .gitignore-syntax ignore
+// files (.s6cmdignore, and .gitignore on request) honored when a local
+// directory is walked for an upload.
+//
+// The rules follow git: a file applies to its directory and everything
+// below it, a deeper file overrides a shallower one, and within a file the
+// last matching pattern wins. A "!" pattern re-includes a path, except
+// under an ignored directory: ignored directories are pruned, so nothing
+// inside them is read or listed.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FileName is the s6cmd ignore file, read in every walked directory
+// unless --no-s6cmdignore is set.
+const FileName = ".s6cmdignore"
+
+// GitignoreName is the git ignore file, read with --respect-gitignore.
+const GitignoreName = ".gitignore"
+
+// Tree answers whether paths under a local root are ignored. The ignore
+// files of a directory are read the first time a path in it is asked
+// about, and the decision for every directory is cached, so asking about
+// each entry of a walk costs a few map lookups.
+//
+// A nil *Tree ignores nothing.
+type Tree struct {
+	root  string
+	names []string
+
+	mu       sync.Mutex
+	patterns map[string][]pattern
+	dirs     map[string]bool
+}
+
+// pattern is one parsed line of an ignore file.
+type pattern struct {
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// NewTree returns the Tree for the directory root, reading the ignore
+// files called names. It returns nil when names is empty.
+func NewTree(root string, names []string) *Tree {
+	if len(names) == 0 {
+		return nil
+	}
+	return &Tree{
+		root:     root,
+		names:    names,
+		patterns: make(map[string][]pattern),
+		dirs:     make(map[string]bool),
+	}
+}
+
+// Ignored reports whether the slash-separated path rel, relative to the
+// root, is ignored. A path under an ignored directory is ignored. The
+// error reports an ignore file that could not be read or parsed.
+func (t *Tree) Ignored(rel string, isDir bool) (bool, error) {
+	if t == nil || rel == "" || rel == "." {
+		return false, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := 0; i < len(rel); i++ {
+		if rel[i] != '/' || i == 0 {
+			continue
+		}
+		ignored, err := t.dirIgnored(rel[:i])
+		if err != nil || ignored {
+			return ignored, err
+		}
+	}
+	if isDir {
+		return t.dirIgnored(rel)
+	}
+	return t.decide(rel, false)
+}
+
+// IgnoredPath is Ignored for a path in the OS form, under the root.
+func (t *Tree) IgnoredPath(p string, isDir bool) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+	rel, err := filepath.Rel(t.root, p)
+	if err != nil {
+		return false, err
+	}
+	return t.Ignored(filepath.ToSlash(rel), isDir)
+}
+
+func (t *Tree) dirIgnored(dir string) (bool, error) {
+	if ignored, ok := t.dirs[dir]; ok {
+		return ignored, nil
+	}
+	ignored, err := t.decide(dir, true)
+	if err != nil {
+		return false, err
+	}
+	t.dirs[dir] = ignored
+	return ignored, nil
+}
+
+// decide applies the ignore files from the directory holding rel up to
+// the root; the first pattern matching, from the deepest file and the
+// last line up, decides.
+func (t *Tree) decide(rel string, isDir bool) (bool, error) {
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	for {
+		patterns, err := t.load(dir)
+		if err != nil {
+			return false, err
+		}
+		sub := rel
+		if dir != "" {
+			sub = rel[len(dir)+1:]
+		}
+		for i := len(patterns) - 1; i >= 0; i-- {
+			p := patterns[i]
+			if p.dirOnly && !isDir {
+				continue
+			}
+			if p.re.MatchString(sub) {
+				return !p.negate, nil
+			}
+		}
+		if dir == "" {
+			return false, nil
+		}
+		if dir = path.Dir(dir); dir == "." {
+			dir = ""
+		}
+	}
+}
+
+// load returns the patterns of the ignore files in dir, reading them on
+// first use. A missing file has no patterns.
+func (t *Tree) load(dir string) ([]pattern, error) {
+	if patterns, ok := t.patterns[dir]; ok {
+		return patterns, nil
+	}
+	var patterns []pattern
+	for _, name := range t.names {
+		file := filepath.Join(t.root, filepath.FromSlash(dir), name)
+		f, err := os.Open(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ps, err := parse(f, file)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, ps...)
+	}
+	t.patterns[dir] = patterns
+	return patterns, nil
+}
+
+// parse reads the patterns of one ignore file. name identifies r in error
+// messages.
+func parse(r io.Reader, name string) ([]pattern, error) {
+	var patterns []pattern
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := trimTrailingSpace(strings.TrimSuffix(sc.Text(), "\r"))
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		patterns = append(patterns, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return patterns, nil
+}
+
+// trimTrailingSpace drops trailing spaces that are not escaped with a
+// backslash.
+func trimTrailingSpace(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func compile(line string) (pattern, error) {
+	var p pattern
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, fmt.Errorf("empty pattern")
+	}
+	// A slash at the start or in the middle anchors the pattern at the
+	// directory of the ignore file; otherwise it matches at any depth.
+	start := "^(?:.*/)?"
+	if strings.Contains(line, "/") {
+		start = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+	body, err := globToRegexp(line)
+	if err != nil {
+		return p, fmt.Errorf("pattern %q: %w", line, err)
+	}
+	if p.re, err = regexp.Compile(start + body + "$"); err != nil {
+		return p, fmt.Errorf("pattern %q: %w", line, err)
+	}
+	return p, nil
+}
+
+// globToRegexp translates a gitignore pattern to a regular expression. A
+// "**" element matches any number of elements, including none; any other
+// "*" and "?" stay within an element.
+func globToRegexp(p string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if strings.HasPrefix(p[i:], "**") && (i == 0 || p[i-1] == '/') && (i+2 == len(p) || p[i+2] == '/') {
+				if i+2 == len(p) {
+					b.WriteString(".*")
+					return b.String(), nil
+				}
+				b.WriteString("(?:.*/)?")
+				i += 2
+				continue
+			}
+			for i+1 < len(p) && p[i+1] == '*' {
+				i++
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			// A ] right after [ or [! is part of the class.
+			if end == 0 || (end == 1 && (p[i+1] == '!' || p[i+1] == '^')) {
+				next := strings.IndexByte(p[i+end+2:], ']')
+				if next < 0 {
+					end = -1
+				} else {
+					end += next + 1
+				}
+			}
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(p) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,159 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree writes files (slash paths to contents) under a new root.
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func checkIgnored(t *testing.T, tree *Tree, cases map[string]bool) {
+	t.Helper()
+	for rel, want := range cases {
+		isDir := strings.HasSuffix(rel, "/")
+		got, err := tree.Ignored(strings.TrimSuffix(rel, "/"), isDir)
+		if err != nil {
+			t.Fatalf("Ignored(%q): %v", rel, err)
+		}
+		if got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	root := makeTree(t, map[string]string{FileName: `
+# build output
+*.o
+/build/
+node_modules/
+docs/*.html
+**/cache/**
+a/**/z
+\#hash
+trailing   
+[Tt]emp?
+`})
+	checkIgnored(t, NewTree(root, []string{FileName}), map[string]bool{
+		// No slash: matches at any depth.
+		"main.o":      true,
+		"src/x/y.o":   true,
+		"main.go":     false,
+		"src/main.o2": false,
+		// Leading slash and trailing slash: anchored, directories only.
+		"build/":     true,
+		"build/a.go": true,
+		"src/build/": false,
+		"build":      false,
+		// A directory-only pattern at any depth.
+		"web/node_modules/":         true,
+		"web/node_modules/pkg/x.js": true,
+		"web/node_modules":          false,
+		// An inner slash anchors the pattern.
+		"docs/index.html":     true,
+		"sub/docs/index.html": false,
+		"docs/api/index.html": false,
+		// ** matches any number of elements.
+		"cache/x":       true,
+		"a/b/cache/c/d": true,
+		"a/z":           true,
+		"a/b/c/z":       true,
+		"b/a/z":         false,
+		// Escapes, trailing spaces and character classes.
+		"#hash":    true,
+		"trailing": true,
+		"Temp1":    true,
+		"temp12":   false,
+	})
+}
+
+// TestNegationAndPrecedence checks that the last matching line wins, that
+// a deeper file overrides a shallower one, and that nothing under an
+// ignored directory can be re-included.
+func TestNegationAndPrecedence(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		FileName:                 "*.log\n!keep.log\nlogs/\n!logs/important.log\n",
+		"sub/" + FileName:        "!*.log\n",
+		"sub/deeper/" + FileName: "debug.log\n",
+	})
+	checkIgnored(t, NewTree(root, []string{FileName}), map[string]bool{
+		"a.log":                true,
+		"keep.log":             false,
+		"x/keep.log":           false,
+		"sub/a.log":            false,
+		"sub/deeper/debug.log": true,
+		"sub/deeper/info.log":  false,
+		"logs/important.log":   true,
+		"logs/":                true,
+	})
+}
+
+// TestNames checks that only the requested file names are read, in the
+// order given, and that an ignored directory's own files are never read.
+func TestNames(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		FileName:                "*.tmp\n",
+		GitignoreName:           "*.bak\n!x.tmp\n",
+		"skip/" + FileName:      "[unterminated\n",
+		"skip/" + GitignoreName: "",
+	})
+	checkIgnored(t, NewTree(root, []string{FileName}), map[string]bool{
+		"a.tmp": true,
+		"a.bak": false,
+		"x.tmp": true,
+	})
+	checkIgnored(t, NewTree(root, []string{FileName, GitignoreName}), map[string]bool{
+		"a.tmp": true,
+		"a.bak": true,
+		"x.tmp": false,
+	})
+	if NewTree(root, nil) != nil {
+		t.Errorf("NewTree without names should return nil")
+	}
+	var nilTree *Tree
+	if ignored, err := nilTree.Ignored("a.tmp", false); ignored || err != nil {
+		t.Errorf("nil Tree: Ignored = %v, %v", ignored, err)
+	}
+
+	// skip/ holds a broken file: reading it fails, unless skip/ itself is
+	// ignored and never read.
+	tree := NewTree(root, []string{FileName})
+	if _, err := tree.Ignored("skip/a", false); err == nil || !strings.Contains(err.Error(), FileName+":1: pattern \"[unterminated\"") {
+		t.Errorf("broken ignore file: err = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte("skip/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checkIgnored(t, NewTree(root, []string{FileName}), map[string]bool{"skip/a": true})
+}
+
+func TestIgnoredPath(t *testing.T) {
+	root := makeTree(t, map[string]string{FileName: "*.tmp\n"})
+	tree := NewTree(root, []string{FileName})
+	for path, want := range map[string]bool{
+		filepath.Join(root, "x", "a.tmp"): true,
+		filepath.Join(root, "a.txt"):      false,
+		root:                              false,
+	} {
+		got, err := tree.IgnoredPath(path, false)
+		if err != nil || got != want {
+			t.Errorf("IgnoredPath(%q) = %v, %v, want %v", path, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package interrupt implements the two-stage Ctrl-C of s6cmd.
+//
+// The first SIGINT (or SIGTERM) does not cancel anything: it calls the
+// drain callback, which stops the parallel.Manager from starting new
+// tasks, and gives the tasks already running DrainTimeout to finish. The
+// command then returns on its own, records what it did not do in the
+// resume journal (see Journal) and prints the --stat summary. The context
+// is canceled when the drain times out, when there is nothing to drain,
+// or on a second signal; canceling aborts the transfers still running
+// (multipart uploads included). Once canceled, the default signal
+// disposition is restored, so one more Ctrl-C kills the process.
+package interrupt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultDrainTimeout is how long in-flight work may take to finish
+	// after the first interrupt.
+	DefaultDrainTimeout = 30 * time.Second
+	// DefaultJournal is the resume journal written by an interrupted run
+	// without --failed-out.
+	DefaultJournal = "s6cmd-resume.txt"
+)
+
+var (
+	drainTimeout atomic.Int64
+	journal      atomic.Value
+	requested    = make(chan struct{})
+	requestOnce  atomic.Bool
+)
+
+func init() {
+	drainTimeout.Store(int64(DefaultDrainTimeout))
+	journal.Store(DefaultJournal)
+}
+
+// SetDrainTimeout sets how long the first interrupt waits for in-flight
+// work before canceling it (--drain-timeout).
+func SetDrainTimeout(d time.Duration) {
+	drainTimeout.Store(int64(d))
+}
+
+// SetJournal sets the resume journal file (--resume-file).
+func SetJournal(file string) {
+	journal.Store(file)
+}
+
+// Journal returns the resume journal file.
+func Journal() string {
+	return journal.Load().(string)
+}
+
+// Requested reports whether the run was interrupted.
+func Requested() bool {
+	return requestOnce.Load()
+}
+
+// Done returns a channel closed by the first interrupt. Long-running
+// loops that wait outside the parallel.Manager, such as sync --watch,
+// select on it to stop at the first Ctrl-C.
+func Done() <-chan struct{} {
+	return requested
+}
+
+// request marks the run interrupted.
+func request() {
+	if requestOnce.CompareAndSwap(false, true) {
+		close(requested)
+	}
+}
+
+// Notify returns a copy of parent that is canceled as described in the
+// package documentation. drain is called on the first signal and returns
+// the number of tasks still running; 0 cancels at once. stop releases the
+// signal handler and cancels the context.
+func Notify(parent context.Context, drain func() int) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigch := make(chan os.Signal, 2)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		// Restore the default disposition once canceled: any further
+		// signal kills the process, whatever is blocked outside ctx.
+		defer signal.Stop(sigch)
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigch:
+		}
+		request()
+		running := drain()
+		if running == 0 {
+			return
+		}
+		d := time.Duration(drainTimeout.Load())
+		fmt.Fprintf(os.Stderr, "interrupted: waiting up to %s for %d running operations, press Ctrl-C again to abort them\n", d, running)
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-sigch:
+		case <-t.C:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigch)
+		cancel()
+	}
+}
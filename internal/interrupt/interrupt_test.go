@@ -0,0 +1,49 @@
+package interrupt
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestNotify_Drain verifies that the first signal stops the work through
+// the drain callback without canceling the context, and that the context
+// is canceled once the drain timeout expires.
+func TestNotify_Drain(t *testing.T) {
+	SetDrainTimeout(200 * time.Millisecond)
+	defer SetDrainTimeout(DefaultDrainTimeout)
+
+	drained := make(chan struct{})
+	ctx, stop := Notify(context.Background(), func() int {
+		close(drained)
+		return 1
+	})
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-drained:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the first signal did not call drain")
+	}
+	select {
+	case <-Done():
+	default:
+		t.Error("Done() not closed after the first signal")
+	}
+	if !Requested() {
+		t.Error("Requested() = false after the first signal")
+	}
+	if ctx.Err() != nil {
+		t.Error("context canceled while tasks were draining")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not canceled after the drain timeout")
+	}
+}
@@ -0,0 +1,199 @@
+// Package outfmt renders the rows of ls, stat, head and du for --format
+// templates and the csv and tsv --output modes.
+//
+// Every command describes its results as Rows, so a template field or a
+// csv column has the same name whichever command printed it: {{.Key}} is
+// the object key and the "size" column the size in bytes in ls, stat,
+// head and du alike.
+package outfmt
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/LinPr/s6cmd/strutil"
+)
+
+// The --output values rendered by a Printer. "text" and "json" are left
+// to the commands.
+const (
+	OutputCSV = "csv"
+	OutputTSV = "tsv"
+)
+
+// Row types.
+const (
+	TypeFile      = "file"
+	TypeDirectory = "directory"
+	TypeBucket    = "bucket"
+)
+
+// Row is one result of ls, stat, head or du. A command fills in the
+// fields it knows and leaves the others zero.
+type Row struct {
+	// Type is TypeFile, TypeDirectory or TypeBucket.
+	Type string
+	// URL is the s3:// URL of the object, prefix or bucket; du sets it to
+	// the target as given.
+	URL    string
+	Bucket string
+	// Key is the object key, or the prefix of a directory row or of the
+	// total of du.
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string
+	// StorageClass is the object's storage class; du sets it with --group.
+	StorageClass   string
+	VersionID      string
+	IsDeleteMarker bool
+	// Count is the number of objects du summed up.
+	Count int64
+	// CreationDate is the creation date of a bucket row of ls.
+	CreationDate         time.Time
+	Region               string
+	ContentType          string
+	CacheControl         string
+	ContentEncoding      string
+	ContentDisposition   string
+	ServerSideEncryption string
+	// Metadata is the user-defined metadata of stat and head.
+	Metadata map[string]string
+	// CompareFile, CompareMatch, ComparePartSize and CompareReason are the
+	// result of stat --compare: the local file, whether it matches the
+	// object, the detected part size of a multipart ETag and why it does
+	// not match.
+	CompareFile     string
+	CompareMatch    bool
+	ComparePartSize int64
+	CompareReason   string
+}
+
+// columns maps the csv and tsv column names, which are the JSON field
+// names of --output json, to the Row fields. The fields of the nested
+// compare object of stat get a compare_ prefix.
+var columns = map[string]func(Row) string{
+	"type":                   func(r Row) string { return r.Type },
+	"url":                    func(r Row) string { return r.URL },
+	"bucket":                 func(r Row) string { return r.Bucket },
+	"key":                    func(r Row) string { return r.Key },
+	"size":                   func(r Row) string { return strconv.FormatInt(r.Size, 10) },
+	"last_modified":          func(r Row) string { return formatTime(r.ModTime) },
+	"etag":                   func(r Row) string { return r.ETag },
+	"storage_class":          func(r Row) string { return r.StorageClass },
+	"version_id":             func(r Row) string { return r.VersionID },
+	"is_delete_marker":       func(r Row) string { return strconv.FormatBool(r.IsDeleteMarker) },
+	"count":                  func(r Row) string { return strconv.FormatInt(r.Count, 10) },
+	"created_at":             func(r Row) string { return formatTime(r.CreationDate) },
+	"region":                 func(r Row) string { return r.Region },
+	"content_type":           func(r Row) string { return r.ContentType },
+	"cache_control":          func(r Row) string { return r.CacheControl },
+	"content_encoding":       func(r Row) string { return r.ContentEncoding },
+	"content_disposition":    func(r Row) string { return r.ContentDisposition },
+	"server_side_encryption": func(r Row) string { return r.ServerSideEncryption },
+	"compare_file":           func(r Row) string { return r.CompareFile },
+	"compare_match":          func(r Row) string { return strconv.FormatBool(r.CompareMatch) },
+	"compare_part_size":      func(r Row) string { return strconv.FormatInt(r.ComparePartSize, 10) },
+	"compare_reason":         func(r Row) string { return r.CompareReason },
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// funcs are the functions a --format template can call besides the
+// text/template builtins.
+var funcs = template.FuncMap{
+	"humanize": strutil.HumanizeBytes,
+	"json":     strutil.JSON,
+}
+
+// Printer writes Rows as a --format template or as csv or tsv records.
+// Like a bufio.Writer it keeps the first error, which Flush returns.
+type Printer struct {
+	w       io.Writer
+	tmpl    *template.Template
+	records *csv.Writer
+	columns []string
+	header  bool
+	err     error
+}
+
+// New returns the Printer for the --output and --format values, or nil
+// when the command prints its own text or JSON: format is empty and
+// output is neither csv nor tsv. A non-empty format wins over output; "\t"
+// and "\n" in it stand for a tab and a newline, and a newline ends every
+// row. columns names the csv and tsv columns, in order.
+func New(w io.Writer, output, format string, columns ...string) (*Printer, error) {
+	p := &Printer{w: w, columns: columns}
+	switch {
+	case format != "":
+		text := strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+		tmpl, err := template.New("format").Funcs(funcs).Parse(text + "\n")
+		if err != nil {
+			return nil, fmt.Errorf("invalid --format: %w", err)
+		}
+		// text/template resolves fields only when it runs: try the
+		// template on an empty row so a misspelled field fails before
+		// anything is listed.
+		if err := tmpl.Execute(io.Discard, Row{}); err != nil {
+			return nil, fmt.Errorf("invalid --format: %w", err)
+		}
+		p.tmpl = tmpl
+	case output == OutputCSV || output == OutputTSV:
+		p.records = csv.NewWriter(w)
+		if output == OutputTSV {
+			p.records.Comma = '\t'
+		}
+	default:
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Print writes r.
+func (p *Printer) Print(r Row) {
+	if p.err != nil {
+		return
+	}
+	if p.tmpl != nil {
+		p.err = p.tmpl.Execute(p.w, r)
+		return
+	}
+	p.writeHeader()
+	record := make([]string, len(p.columns))
+	for i, name := range p.columns {
+		record[i] = columns[name](r)
+	}
+	p.err = p.records.Write(record)
+}
+
+// writeHeader writes the csv or tsv header once.
+func (p *Printer) writeHeader() {
+	if p.header || p.err != nil {
+		return
+	}
+	p.header = true
+	p.err = p.records.Write(p.columns)
+}
+
+// Flush writes the buffered records, and the csv or tsv header of an
+// empty result, and returns the first error met.
+func (p *Printer) Flush() error {
+	if p.records != nil {
+		p.writeHeader()
+		p.records.Flush()
+		if p.err == nil {
+			p.err = p.records.Error()
+		}
+	}
+	return p.err
+}
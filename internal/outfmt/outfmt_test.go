@@ -0,0 +1,75 @@
+package outfmt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := New(&buf, "json", `{{.Key}}\t{{.Size}}\t{{.ModTime.Unix}}\t{{humanize .Size}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Print(Row{Key: "a.txt", Size: 2048, ModTime: time.Unix(1700000000, 0)})
+	p.Print(Row{Key: "b.txt", Size: 1})
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.txt\t2048\t1700000000\t2.0K\nb.txt\t1\t-62135596800\t1\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for format, want := range map[string]string{
+		"{{.Key":          "invalid --format",
+		"{{.Name}}":       `can't evaluate field Name`,
+		"{{nosuch .Key}}": `function "nosuch" not defined`,
+	} {
+		_, err := New(&bytes.Buffer{}, "text", format)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("New(%q): err = %v, want it to contain %q", format, err, want)
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for output, want := range map[string]string{
+		OutputCSV: "key,size,last_modified,is_delete_marker\n\"a,b.txt\",3,2024-01-02T03:04:05Z,false\nc.txt,0,,true\n",
+		OutputTSV: "key\tsize\tlast_modified\tis_delete_marker\na,b.txt\t3\t2024-01-02T03:04:05Z\tfalse\nc.txt\t0\t\ttrue\n",
+	} {
+		var buf bytes.Buffer
+		p, err := New(&buf, output, "", "key", "size", "last_modified", "is_delete_marker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Print(Row{Key: "a,b.txt", Size: 3, ModTime: mod})
+		p.Print(Row{Key: "c.txt", IsDeleteMarker: true})
+		if err := p.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s output = %q, want %q", output, buf.String(), want)
+		}
+	}
+
+	// An empty result still gets its header.
+	var buf bytes.Buffer
+	p, _ := New(&buf, OutputCSV, "", "url", "count")
+	if err := p.Flush(); err != nil || buf.String() != "url,count\n" {
+		t.Errorf("empty csv = %q, %v", buf.String(), err)
+	}
+}
+
+func TestTextAndJSON(t *testing.T) {
+	for _, output := range []string{"text", "json"} {
+		if p, err := New(&bytes.Buffer{}, output, ""); p != nil || err != nil {
+			t.Errorf("New(%q) = %v, %v, want nil", output, p, err)
+		}
+	}
+}
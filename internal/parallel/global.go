@@ -45,12 +45,30 @@ func Close() {
 	}
 }
 
-// Run schedules task on the global Manager. It panics if Init has not
-// been called, since silently defaulting would hide a startup-ordering
-// bug.
-func Run(task Task, waiter *Waiter) {
+// Run schedules task on the global Manager and reports whether it did
+// (see Manager.Run). It panics if Init has not been called, since
+// silently defaulting would hide a startup-ordering bug.
+func Run(task Task, waiter *Waiter) bool {
 	if global == nil {
 		panic("parallel: Init must be called before Run")
 	}
-	global.Run(task, waiter)
+	return global.Run(task, waiter)
+}
+
+// Stop stops every Manager not yet closed, the global one included, from
+// starting tasks and returns the number of tasks still running on them
+// (see Manager.Stop).
+func Stop() int {
+	live.Lock()
+	defer live.Unlock()
+	running := 0
+	for p := range live.managers {
+		running += p.Stop()
+	}
+	return running
+}
+
+// Stopped reports whether the global Manager was stopped.
+func Stopped() bool {
+	return global != nil && global.Stopped()
 }
@@ -5,11 +5,17 @@
 // number of in-flight tasks. The Waiter exposes an unbuffered error channel
 // that callers must drain concurrently with Wait, because task goroutines
 // block on sending errors until a reader is ready.
+//
+// Stop ends the scheduling of new tasks, for the first Ctrl-C: Run returns
+// false instead of starting a task, while the tasks already running finish.
+// The package-level Stop stops every Manager not yet closed, the global one
+// and those of commands with a pool of their own such as run.
 package parallel
 
 import (
 	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -23,6 +29,12 @@ type Task func() error
 type Manager struct {
 	wg        *sync.WaitGroup
 	semaphore chan struct{}
+
+	// stop is closed by Stop; running counts the tasks started and not
+	// yet finished.
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  atomic.Int64
 }
 
 // New creates a Manager whose concurrency is workercount. A negative
@@ -37,15 +49,37 @@ func New(workercount int) *Manager {
 		workercount = minNumWorkers
 	}
 
-	return &Manager{
+	p := &Manager{
 		wg:        &sync.WaitGroup{},
 		semaphore: make(chan struct{}, workercount),
+		stop:      make(chan struct{}),
 	}
+	live.Lock()
+	live.managers[p] = struct{}{}
+	live.Unlock()
+	return p
 }
 
-// acquire blocks until a semaphore slot is available.
-func (p *Manager) acquire() {
-	p.semaphore <- struct{}{}
+// live holds the Managers created and not yet closed, for Stop.
+var live = struct {
+	sync.Mutex
+	managers map[*Manager]struct{}
+}{managers: make(map[*Manager]struct{})}
+
+// acquire blocks until a semaphore slot is available. It reports false,
+// without a slot, once the Manager is stopped.
+func (p *Manager) acquire() bool {
+	select {
+	case <-p.stop:
+		return false
+	case p.semaphore <- struct{}{}:
+	}
+	// Both cases may have been ready: a stopped Manager starts nothing.
+	if p.Stopped() {
+		p.release()
+		return false
+	}
+	return true
 }
 
 // release frees a semaphore slot.
@@ -57,19 +91,45 @@ func (p *Manager) release() {
 // caller must be draining waiter.Err() before (or concurrently with)
 // calling Wait, since the error channel is unbuffered and task goroutines
 // block until a reader is ready.
-func (p *Manager) Run(task Task, waiter *Waiter) {
+//
+// Run reports whether task was scheduled: after Stop it returns false
+// without running task, also when it was waiting for a free slot.
+func (p *Manager) Run(task Task, waiter *Waiter) bool {
+	if !p.acquire() {
+		return false
+	}
 	waiter.wg.Add(1)
-	p.acquire()
 	p.wg.Add(1)
+	p.running.Add(1)
 	go func() {
 		defer waiter.wg.Done()
 		defer p.release()
 		defer p.wg.Done()
+		defer p.running.Add(-1)
 
 		if err := task(); err != nil {
 			waiter.errch <- err
 		}
 	}()
+	return true
+}
+
+// Stop makes every later Run, and every Run waiting for a slot, return
+// without starting its task. Tasks already running are not affected.
+// Stop returns the number of them; it may be called more than once.
+func (p *Manager) Stop() int {
+	p.stopOnce.Do(func() { close(p.stop) })
+	return int(p.running.Load())
+}
+
+// Stopped reports whether Stop was called.
+func (p *Manager) Stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
 }
 
 // Close blocks until all in-flight tasks have completed and then closes
@@ -78,6 +138,9 @@ func (p *Manager) Run(task Task, waiter *Waiter) {
 func (p *Manager) Close() {
 	p.wg.Wait()
 	close(p.semaphore)
+	live.Lock()
+	delete(live.managers, p)
+	live.Unlock()
 }
 
 // Waiter collects errors from tasks scheduled via Manager.Run.
@@ -250,3 +250,56 @@ func TestWaiter_DrainBeforeWaitContract(t *testing.T) {
 	}
 	m.Close()
 }
+
+// TestManager_Stop verifies that Stop lets running tasks finish, wakes a
+// Run waiting for a slot without starting its task, and makes later Runs
+// return false.
+func TestManager_Stop(t *testing.T) {
+	t.Parallel()
+	m := parallel.New(2)
+	w := parallel.NewWaiter()
+
+	release := make(chan struct{})
+	var finished atomic.Int32
+	for i := 0; i < 2; i++ {
+		if !m.Run(func() error {
+			<-release
+			finished.Add(1)
+			return nil
+		}, w) {
+			t.Fatal("Run returned false before Stop")
+		}
+	}
+
+	var started atomic.Bool
+	blocked := make(chan bool)
+	go func() {
+		blocked <- m.Run(func() error {
+			started.Store(true)
+			return nil
+		}, w)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if n := m.Stop(); n != 2 {
+		t.Errorf("Stop() = %d running, want 2", n)
+	}
+	select {
+	case ok := <-blocked:
+		if ok {
+			t.Error("the Run waiting for a slot scheduled its task after Stop")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not wake the Run waiting for a slot")
+	}
+	if m.Run(func() error { return nil }, w) {
+		t.Error("Run after Stop returned true")
+	}
+
+	close(release)
+	w.Wait()
+	m.Close()
+	if finished.Load() != 2 || started.Load() {
+		t.Errorf("finished %d running tasks (want 2), skipped task started: %v", finished.Load(), started.Load())
+	}
+}
@@ -0,0 +1,74 @@
+package progressbar
+
+// Join returns a ProgressBar that forwards every update to each of bars,
+// so one set of counters drives, say, the terminal bar and a JSON stream.
+// NoOps are left out; Join of none is a NoOp and Join of one is that bar.
+func Join(bars ...ProgressBar) ProgressBar {
+	var joined multi
+	for _, b := range bars {
+		if _, ok := b.(*NoOp); !ok && b != nil {
+			joined = append(joined, b)
+		}
+	}
+	switch len(joined) {
+	case 0:
+		return &NoOp{}
+	case 1:
+		return joined[0]
+	}
+	return joined
+}
+
+// multi is the ProgressBar Join returns.
+type multi []ProgressBar
+
+// Start implements ProgressBar.
+func (m multi) Start() {
+	for _, b := range m {
+		b.Start()
+	}
+}
+
+// Finish implements ProgressBar.
+func (m multi) Finish() {
+	for _, b := range m {
+		b.Finish()
+	}
+}
+
+// IncrementCompletedObjects implements ProgressBar.
+func (m multi) IncrementCompletedObjects() {
+	for _, b := range m {
+		b.IncrementCompletedObjects()
+	}
+}
+
+// IncrementTotalObjects implements ProgressBar.
+func (m multi) IncrementTotalObjects() {
+	for _, b := range m {
+		b.IncrementTotalObjects()
+	}
+}
+
+// AddCompletedBytes implements ProgressBar.
+func (m multi) AddCompletedBytes(n int64) {
+	for _, b := range m {
+		b.AddCompletedBytes(n)
+	}
+}
+
+// AddTotalBytes implements ProgressBar.
+func (m multi) AddTotalBytes(n int64) {
+	for _, b := range m {
+		b.AddTotalBytes(n)
+	}
+}
+
+// Transfer implements ProgressBar.
+func (m multi) Transfer(name string, size int64) ProgressBar {
+	transfers := make(multi, len(m))
+	for i, b := range m {
+		transfers[i] = b.Transfer(name, size)
+	}
+	return transfers
+}
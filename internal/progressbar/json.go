@@ -0,0 +1,268 @@
+package progressbar
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JSONVersion is the version of the event schema, sent in the start
+// event. It is bumped when a field changes meaning or goes away; new
+// fields and events do not bump it, so consumers must ignore what they do
+// not know.
+const JSONVersion = 1
+
+// jsonInterval is how often a JSON progress stream reports the bytes of
+// the active transfers and the totals.
+const jsonInterval = time.Second
+
+// The event names of a JSON progress stream.
+const (
+	EventStart          = "start"
+	EventObjectStarted  = "object_started"
+	EventObjectProgress = "object_progress"
+	EventObjectFinished = "object_finished"
+	EventObjectFailed   = "object_failed"
+	EventProgress       = "progress"
+	EventDone           = "done"
+)
+
+// StartEvent is the first event of a stream.
+type StartEvent struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Version int       `json:"version"`
+}
+
+// ObjectEvent reports one object transfer: object_started when it
+// begins, object_progress every interval its byte count changed, and
+// object_finished or object_failed when it ends. ID tells the transfers
+// apart; a retried transfer starts again under a new ID. object_failed
+// means the transfer ended without completing: it failed, was skipped (as
+// by --no-clobber) or was canceled; the reason is in the command's errors
+// and --report. Size is 0 when not known up front.
+type ObjectEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	ID    uint64    `json:"id"`
+	Name  string    `json:"name"`
+	Size  int64     `json:"size"`
+	Bytes int64     `json:"bytes"`
+}
+
+// TotalsEvent reports the run's totals: progress every interval, and done
+// once as the last event. The totals grow while the command is still
+// finding objects to transfer. BytesPerSecond is the average since the
+// start.
+type TotalsEvent struct {
+	Event            string    `json:"event"`
+	Time             time.Time `json:"time"`
+	CompletedObjects int64     `json:"completed_objects"`
+	TotalObjects     int64     `json:"total_objects"`
+	CompletedBytes   int64     `json:"completed_bytes"`
+	TotalBytes       int64     `json:"total_bytes"`
+	BytesPerSecond   int64     `json:"bytes_per_second"`
+}
+
+// JSON is a ProgressBar that writes its updates as newline-delimited JSON
+// events (see StartEvent, ObjectEvent and TotalsEvent) for other tools to
+// render. Every event is written under mu, so the events of a transfer
+// are in order.
+type JSON struct {
+	interval time.Duration
+
+	totalObjects     atomic.Int64
+	completedObjects atomic.Int64
+	totalBytes       atomic.Int64
+	completedBytes   atomic.Int64
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	started time.Time
+	seq     uint64
+	active  map[*jsonTransfer]struct{}
+
+	startOnce  sync.Once
+	finishOnce sync.Once
+	done       chan struct{}
+	tickDone   chan struct{}
+}
+
+// NewJSON returns a JSON progress stream writing to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{
+		interval: jsonInterval,
+		enc:      json.NewEncoder(w),
+		started:  time.Now(),
+		active:   make(map[*jsonTransfer]struct{}),
+		done:     make(chan struct{}),
+		tickDone: make(chan struct{}),
+	}
+}
+
+// Start writes the start event and launches the interval goroutine. It is
+// idempotent.
+func (j *JSON) Start() {
+	j.startOnce.Do(func() {
+		j.mu.Lock()
+		j.started = time.Now()
+		j.emit(StartEvent{Event: EventStart, Time: j.started.UTC(), Version: JSONVersion})
+		j.mu.Unlock()
+		go func() {
+			defer close(j.tickDone)
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-j.done:
+					return
+				case now := <-ticker.C:
+					j.tick(now)
+				}
+			}
+		}()
+	})
+}
+
+// Finish stops the interval goroutine and writes the done event.
+// Idempotent.
+func (j *JSON) Finish() {
+	j.finishOnce.Do(func() {
+		close(j.done)
+		j.startOnce.Do(func() { close(j.tickDone) })
+		<-j.tickDone
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.emit(j.totals(EventDone, time.Now()))
+	})
+}
+
+// IncrementCompletedObjects implements ProgressBar.
+func (j *JSON) IncrementCompletedObjects() { j.completedObjects.Add(1) }
+
+// IncrementTotalObjects implements ProgressBar.
+func (j *JSON) IncrementTotalObjects() { j.totalObjects.Add(1) }
+
+// AddCompletedBytes implements ProgressBar.
+func (j *JSON) AddCompletedBytes(n int64) { j.completedBytes.Add(n) }
+
+// AddTotalBytes implements ProgressBar.
+func (j *JSON) AddTotalBytes(n int64) { j.totalBytes.Add(n) }
+
+// Transfer writes the object_started event of the transfer.
+func (j *JSON) Transfer(name string, size int64) ProgressBar {
+	t := &jsonTransfer{j: j, name: name, size: size}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	t.id = j.seq
+	j.active[t] = struct{}{}
+	j.emit(t.event(EventObjectStarted, time.Now()))
+	return t
+}
+
+// tick writes an object_progress event for every active transfer whose
+// bytes changed since the last tick, oldest first, then the totals.
+func (j *JSON) tick(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	active := make([]*jsonTransfer, 0, len(j.active))
+	for t := range j.active {
+		active = append(active, t)
+	}
+	slices.SortFunc(active, func(x, y *jsonTransfer) int { return cmp.Compare(x.id, y.id) })
+	for _, t := range active {
+		if n := t.bytes.Load(); n != t.reported {
+			t.reported = n
+			j.emit(t.event(EventObjectProgress, now))
+		}
+	}
+	j.emit(j.totals(EventProgress, now))
+}
+
+// totals is the totals event at now.
+func (j *JSON) totals(event string, now time.Time) TotalsEvent {
+	e := TotalsEvent{
+		Event:            event,
+		Time:             now.UTC(),
+		CompletedObjects: j.completedObjects.Load(),
+		TotalObjects:     j.totalObjects.Load(),
+		CompletedBytes:   j.completedBytes.Load(),
+		TotalBytes:       j.totalBytes.Load(),
+	}
+	if elapsed := now.Sub(j.started); elapsed > 0 {
+		e.BytesPerSecond = int64(float64(e.CompletedBytes) / elapsed.Seconds())
+	}
+	return e
+}
+
+// emit writes one event; j.mu must be held. A write error is dropped: the
+// progress stream must not fail the transfers it reports on.
+func (j *JSON) emit(event any) {
+	_ = j.enc.Encode(event)
+}
+
+// jsonTransfer is the ProgressBar of one object transfer of a JSON
+// stream.
+type jsonTransfer struct {
+	j        *JSON
+	id       uint64
+	name     string
+	size     int64
+	bytes    atomic.Int64
+	complete atomic.Bool
+	// reported is the byte count of the last object_progress event,
+	// guarded by j.mu.
+	reported   int64
+	finishOnce sync.Once
+}
+
+// Start implements ProgressBar.
+func (t *jsonTransfer) Start() {}
+
+// Finish writes the object_finished event of the transfer, or
+// object_failed when it did not complete. Idempotent.
+func (t *jsonTransfer) Finish() {
+	t.finishOnce.Do(func() {
+		event := EventObjectFailed
+		if t.complete.Load() {
+			event = EventObjectFinished
+		}
+		t.j.mu.Lock()
+		defer t.j.mu.Unlock()
+		delete(t.j.active, t)
+		t.j.emit(t.event(event, time.Now()))
+	})
+}
+
+// IncrementCompletedObjects marks the transfer completed.
+func (t *jsonTransfer) IncrementCompletedObjects() {
+	t.complete.Store(true)
+	t.j.IncrementCompletedObjects()
+}
+
+// IncrementTotalObjects implements ProgressBar.
+func (t *jsonTransfer) IncrementTotalObjects() { t.j.IncrementTotalObjects() }
+
+// AddCompletedBytes implements ProgressBar.
+func (t *jsonTransfer) AddCompletedBytes(n int64) {
+	t.bytes.Add(n)
+	t.j.AddCompletedBytes(n)
+}
+
+// AddTotalBytes implements ProgressBar.
+func (t *jsonTransfer) AddTotalBytes(n int64) { t.j.AddTotalBytes(n) }
+
+// Transfer implements ProgressBar.
+func (t *jsonTransfer) Transfer(name string, size int64) ProgressBar {
+	return t.j.Transfer(name, size)
+}
+
+// event is the ObjectEvent of the transfer at now.
+func (t *jsonTransfer) event(event string, now time.Time) ObjectEvent {
+	return ObjectEvent{Event: event, Time: now.UTC(), ID: t.id, Name: t.name, Size: t.size, Bytes: t.bytes.Load()}
+}
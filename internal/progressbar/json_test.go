@@ -0,0 +1,98 @@
+package progressbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJSONEvents pins the event stream: the start event, the events of a
+// transfer that completes and of one that does not, the progress events
+// of a tick and the closing done event.
+func TestJSONEvents(t *testing.T) {
+	var buf bytes.Buffer
+	j := NewJSON(&buf)
+	j.interval = time.Hour // ticks are driven by hand below
+	j.Start()
+	j.AddTotalBytes(30)
+	j.IncrementTotalObjects()
+	j.IncrementTotalObjects()
+
+	a := j.Transfer("a.txt", 10)
+	b := j.Transfer("b.txt", 20)
+	a.AddCompletedBytes(4)
+	j.tick(time.Now())
+	j.tick(time.Now()) // nothing changed: totals only
+	a.AddCompletedBytes(6)
+	a.IncrementCompletedObjects()
+	a.Finish()
+	a.Finish()
+	b.Finish()
+	j.Finish()
+
+	var got []string
+	var totals TotalsEvent
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		switch e["event"] {
+		case EventStart:
+			got = append(got, "start")
+			if e["version"] != float64(JSONVersion) {
+				t.Errorf("start event = %s, want version %d", line, JSONVersion)
+			}
+		case EventProgress, EventDone:
+			got = append(got, e["event"].(string))
+			if err := json.Unmarshal([]byte(line), &totals); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			var o ObjectEvent
+			if err := json.Unmarshal([]byte(line), &o); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fmt.Sprintf("%s %s %d %d", o.Event, o.Name, o.ID, o.Bytes))
+		}
+	}
+	want := []string{
+		"start",
+		"object_started a.txt 1 0",
+		"object_started b.txt 2 0",
+		"object_progress a.txt 1 4",
+		"progress",
+		"progress",
+		"object_finished a.txt 1 10",
+		"object_failed b.txt 2 0",
+		"done",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if totals.CompletedObjects != 1 || totals.TotalObjects != 2 || totals.CompletedBytes != 10 || totals.TotalBytes != 30 {
+		t.Errorf("done event = %+v", totals)
+	}
+}
+
+// TestJoin verifies that Join drops NoOps and forwards every update,
+// transfers included, to each bar.
+func TestJoin(t *testing.T) {
+	if _, ok := Join(&NoOp{}, nil).(*NoOp); !ok {
+		t.Error("Join of NoOps is not a NoOp")
+	}
+	bar := NewBar(&bytes.Buffer{})
+	if Join(&NoOp{}, bar) != ProgressBar(bar) {
+		t.Error("Join of one bar is not that bar")
+	}
+	j := NewJSON(&bytes.Buffer{})
+	joined := Join(bar, j)
+	joined.IncrementTotalObjects()
+	joined.Transfer("a", 5).AddCompletedBytes(5)
+	if bar.completedBytes.Load() != 5 || j.completedBytes.Load() != 5 || j.totalObjects.Load() != 1 {
+		t.Errorf("updates not forwarded: bar %d bytes, json %d bytes, %d objects", bar.completedBytes.Load(), j.completedBytes.Load(), j.totalObjects.Load())
+	}
+}
@@ -1,14 +1,19 @@
 // Package progressbar defines the progress-reporting interface used by
 // commands and a minimal terminal implementation with no external
-// dependencies. New returns the real bar only when progress was requested
-// AND stderr is a terminal; otherwise it returns a NoOp so redirected or
-// scripted runs never see control characters.
+// dependencies. New returns a NoOp unless progress was requested; on a
+// terminal the bar repaints in place, and when stderr is redirected it
+// prints a plain summary line every few seconds instead, so logs and pipes
+// never see control characters. JSON writes the same updates as events for
+// other tools, and Join drives several bars from one set of updates.
 package progressbar
 
 import (
+	"cmp"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,10 +41,15 @@ type ProgressBar interface {
 	AddCompletedBytes(n int64)
 	// AddTotalBytes adds n to the count of total bytes.
 	AddTotalBytes(n int64)
+	// Transfer returns the progress of one object transfer of size bytes
+	// (0 when not known). The bytes and objects added to it count toward
+	// the bar, which lists it as active until its Finish is called; its
+	// Start does nothing.
+	Transfer(name string, size int64) ProgressBar
 }
 
-// NoOp is a ProgressBar that does nothing. It is returned by New when the
-// bar would not be visible (flag not set, or stderr is not a terminal).
+// NoOp is a ProgressBar that does nothing. It is returned by New when no
+// progress was requested.
 type NoOp struct{}
 
 // Start implements ProgressBar.
@@ -60,37 +70,77 @@ func (pb *NoOp) AddCompletedBytes(n int64) {}
 // AddTotalBytes implements ProgressBar.
 func (pb *NoOp) AddTotalBytes(n int64) {}
 
-// renderInterval is how often the terminal bar repaints. 5 Hz is smooth
-// enough for a transfer display and cheap enough to never matter.
-const renderInterval = 200 * time.Millisecond
+// Transfer implements ProgressBar.
+func (pb *NoOp) Transfer(name string, size int64) ProgressBar { return pb }
+
+const (
+	// renderInterval is how often the terminal bar repaints. 5 Hz is
+	// smooth enough for a transfer display and cheap enough to never
+	// matter.
+	renderInterval = 200 * time.Millisecond
+	// plainInterval is how often a bar that is not on a terminal prints
+	// its summary line.
+	plainInterval = 5 * time.Second
+	// rateWindow is the span the throughput is averaged over, and
+	// minRateSpan the shortest span it is computed from.
+	rateWindow  = 10 * time.Second
+	minRateSpan = time.Second
+	// maxTransferLines caps the active transfers listed under the
+	// summary line.
+	maxTransferLines = 10
+)
 
 // Bar is a minimal terminal progress bar. Counter updates are atomic (they
 // arrive from many transfer goroutines); a single render goroutine repaints
-// the current line on stderr with \r at a fixed interval.
+// the summary line, and the active transfers under it, on stderr at a
+// fixed interval.
 type Bar struct {
 	out io.Writer
+	// plain prints the summary line on a line of its own every
+	// plainInterval instead of repainting it, for output that is not a
+	// terminal.
+	plain bool
+	// transfers lists the active transfers under the summary line.
+	transfers bool
+	// width is the terminal width lines are cut to; 0 does not cut them.
+	width int
 
 	totalObjects     atomic.Int64
 	completedObjects atomic.Int64
 	totalBytes       atomic.Int64
 	completedBytes   atomic.Int64
 
+	// active holds the transfers in progress; seq orders them by start.
+	mu     sync.Mutex
+	seq    uint64
+	active map[*transfer]struct{}
+
 	startOnce  sync.Once
 	finishOnce sync.Once
 	done       chan struct{}
 	renderDone chan struct{}
 
-	// lastLen is the rune length of the last rendered line, used to blank
-	// leftovers when a new line is shorter. Only the render goroutine and
-	// Finish (after the render goroutine exited) touch it.
-	lastLen int
+	// samples are the completed byte counts the throughput is computed
+	// from, and drawn is the number of lines the last repaint left on the
+	// terminal. Only the render goroutine and Finish (after the render
+	// goroutine exited) touch them.
+	samples []sample
+	drawn   int
+}
+
+// sample is the completed byte count at a point in time.
+type sample struct {
+	at    time.Time
+	bytes int64
 }
 
-// NewBar returns a Bar writing to out. Callers normally use New, which
-// picks between Bar and NoOp; NewBar is exported for tests.
+// NewBar returns a Bar repainting a single summary line on out. Callers
+// normally use New, which picks the kind of bar; NewBar is exported for
+// tests.
 func NewBar(out io.Writer) *Bar {
 	return &Bar{
 		out:        out,
+		active:     make(map[*transfer]struct{}),
 		done:       make(chan struct{}),
 		renderDone: make(chan struct{}),
 	}
@@ -99,30 +149,39 @@ func NewBar(out io.Writer) *Bar {
 // Start launches the render goroutine. It is idempotent.
 func (b *Bar) Start() {
 	b.startOnce.Do(func() {
+		b.sample(time.Now())
+		interval := renderInterval
+		if b.plain {
+			interval = plainInterval
+		}
 		go func() {
 			defer close(b.renderDone)
-			ticker := time.NewTicker(renderInterval)
+			ticker := time.NewTicker(interval)
 			defer ticker.Stop()
 			for {
 				select {
 				case <-b.done:
 					return
-				case <-ticker.C:
-					b.render()
+				case now := <-ticker.C:
+					b.render(now, b.transfers)
 				}
 			}
 		}()
 	})
 }
 
-// Finish stops the render goroutine, paints the final state and moves to a
-// fresh line so subsequent output does not overwrite the bar. Idempotent.
+// Finish stops the render goroutine, paints the final state without the
+// transfers and moves to a fresh line so subsequent output does not
+// overwrite the bar. Idempotent.
 func (b *Bar) Finish() {
 	b.finishOnce.Do(func() {
 		close(b.done)
+		b.startOnce.Do(func() { close(b.renderDone) })
 		<-b.renderDone
-		b.render()
-		fmt.Fprintln(b.out)
+		b.render(time.Now(), false)
+		if !b.plain {
+			fmt.Fprintln(b.out)
+		}
 	})
 }
 
@@ -138,49 +197,219 @@ func (b *Bar) AddCompletedBytes(n int64) { b.completedBytes.Add(n) }
 // AddTotalBytes implements ProgressBar.
 func (b *Bar) AddTotalBytes(n int64) { b.totalBytes.Add(n) }
 
-// render repaints the progress line in place with \r. It never emits a
-// newline; Finish adds the terminating one.
-func (b *Bar) render() {
-	line := b.line()
-	// Blank the tail when the previous line was longer.
-	pad := ""
-	if d := b.lastLen - len(line); d > 0 {
-		pad = fmt.Sprintf("%*s", d, "")
+// Transfer implements ProgressBar.
+func (b *Bar) Transfer(name string, size int64) ProgressBar {
+	t := &transfer{bar: b, name: name, size: size}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	t.seq = b.seq
+	b.active[t] = struct{}{}
+	return t
+}
+
+// render paints the current state: a line of its own for a plain bar,
+// otherwise a repaint in place of the lines the last render drew. It never
+// ends a repaint with a newline; Finish adds the terminating one.
+func (b *Bar) render(now time.Time, withTransfers bool) {
+	b.sample(now)
+	if b.plain {
+		fmt.Fprintln(b.out, b.line())
+		return
+	}
+	lines := []string{b.line()}
+	if withTransfers {
+		lines = append(lines, b.transferLines()...)
 	}
-	b.lastLen = len(line)
-	fmt.Fprintf(b.out, "\r%s%s", line, pad)
+	var buf strings.Builder
+	if b.drawn > 1 {
+		fmt.Fprintf(&buf, "\x1b[%dA", b.drawn-1)
+	}
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("\r\x1b[2K")
+		buf.WriteString(b.fit(line))
+	}
+	// Clear the lines a longer previous repaint left below.
+	buf.WriteString("\x1b[J")
+	b.drawn = len(lines)
+	io.WriteString(b.out, buf.String())
 }
 
-// line formats the current progress state, e.g.
+// line formats the summary, e.g.
+//
+//	12/40 objects, 128.0M/512.3M bytes (25%), 42.1M/s, ETA 9s
 //
-//	12/40 objects, 128.0M/512.3M bytes (25%)
+// The throughput shows once the bar has run for minRateSpan, and the ETA
+// when the total bytes are known. Without them, as for a get that lists
+// keys only, the line has the bytes completed and no percentage.
 func (b *Bar) line() string {
 	completedObj := b.completedObjects.Load()
 	totalObj := b.totalObjects.Load()
 	completedBytes := b.completedBytes.Load()
 	totalBytes := b.totalBytes.Load()
 
-	percent := int64(0)
-	if totalBytes > 0 {
-		percent = completedBytes * 100 / totalBytes
-		if percent > 100 {
-			percent = 100
+	var line string
+	if totalBytes == 0 && completedBytes > 0 {
+		line = fmt.Sprintf("%d/%d objects, %s bytes",
+			completedObj, totalObj, strutil.HumanizeBytes(completedBytes))
+	} else {
+		percent := int64(0)
+		if totalBytes > 0 {
+			percent = min(completedBytes*100/totalBytes, 100)
+		}
+		line = fmt.Sprintf("%d/%d objects, %s/%s bytes (%d%%)",
+			completedObj, totalObj,
+			strutil.HumanizeBytes(completedBytes), strutil.HumanizeBytes(totalBytes),
+			percent)
+	}
+	if rate := b.rate(); rate > 0 {
+		line += fmt.Sprintf(", %s/s", strutil.HumanizeBytes(int64(rate)))
+		if totalBytes > completedBytes {
+			eta := time.Duration(float64(totalBytes-completedBytes) / rate * float64(time.Second))
+			line += ", ETA " + max(eta.Round(time.Second), time.Second).String()
+		}
+	}
+	return line
+}
+
+// sample records the completed bytes at now and drops the samples that
+// fell out of rateWindow, keeping one older sample so the window stays
+// covered.
+func (b *Bar) sample(now time.Time) {
+	b.samples = append(b.samples, sample{at: now, bytes: b.completedBytes.Load()})
+	for len(b.samples) > 2 && now.Sub(b.samples[1].at) >= rateWindow {
+		b.samples = b.samples[1:]
+	}
+}
+
+// rate is the throughput over the samples, in bytes per second; 0 until
+// they span minRateSpan.
+func (b *Bar) rate() float64 {
+	if len(b.samples) < 2 {
+		return 0
+	}
+	first, last := b.samples[0], b.samples[len(b.samples)-1]
+	span := last.at.Sub(first.at)
+	if span < minRateSpan {
+		return 0
+	}
+	return float64(last.bytes-first.bytes) / span.Seconds()
+}
+
+// transferLines formats the active transfers, oldest first, up to
+// maxTransferLines.
+func (b *Bar) transferLines() []string {
+	b.mu.Lock()
+	active := make([]*transfer, 0, len(b.active))
+	for t := range b.active {
+		active = append(active, t)
+	}
+	b.mu.Unlock()
+	slices.SortFunc(active, func(x, y *transfer) int { return cmp.Compare(x.seq, y.seq) })
+
+	lines := make([]string, 0, min(len(active), maxTransferLines+1))
+	for i, t := range active {
+		if i == maxTransferLines {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(active)-i))
+			break
 		}
+		lines = append(lines, t.line(b.width))
 	}
-	return fmt.Sprintf("%d/%d objects, %s/%s bytes (%d%%)",
-		completedObj, totalObj,
-		strutil.HumanizeBytes(completedBytes), strutil.HumanizeBytes(totalBytes),
-		percent)
+	return lines
 }
 
-// New returns a ProgressBar. The real terminal bar is returned only when
-// isVisible is true (the user passed --show-progress on an applicable
-// transfer) and stderr is a terminal; every other combination gets a NoOp
-// so logs and pipes stay clean. The bar renders to STDERR because stdout
-// carries the command's real output (per-object log lines, JSON).
-func New(isVisible bool) ProgressBar {
-	if !isVisible || !term.IsTerminal(int(os.Stderr.Fd())) {
+// fit cuts line to the terminal width: a line that wraps would throw off
+// the cursor movement of the next repaint.
+func (b *Bar) fit(line string) string {
+	if b.width <= 0 {
+		return line
+	}
+	runes := []rune(line)
+	if len(runes) < b.width {
+		return line
+	}
+	return string(runes[:b.width-1])
+}
+
+// transfer is the ProgressBar of one object transfer. It forwards every
+// update to its bar and counts its own completed bytes for its line.
+type transfer struct {
+	bar        *Bar
+	seq        uint64
+	name       string
+	size       int64
+	done       atomic.Int64
+	finishOnce sync.Once
+}
+
+// Start implements ProgressBar.
+func (t *transfer) Start() {}
+
+// Finish ends the transfer: the bar no longer lists it. Idempotent.
+func (t *transfer) Finish() {
+	t.finishOnce.Do(func() {
+		t.bar.mu.Lock()
+		defer t.bar.mu.Unlock()
+		delete(t.bar.active, t)
+	})
+}
+
+// IncrementCompletedObjects implements ProgressBar.
+func (t *transfer) IncrementCompletedObjects() { t.bar.IncrementCompletedObjects() }
+
+// IncrementTotalObjects implements ProgressBar.
+func (t *transfer) IncrementTotalObjects() { t.bar.IncrementTotalObjects() }
+
+// AddCompletedBytes implements ProgressBar.
+func (t *transfer) AddCompletedBytes(n int64) {
+	t.done.Add(n)
+	t.bar.AddCompletedBytes(n)
+}
+
+// AddTotalBytes implements ProgressBar.
+func (t *transfer) AddTotalBytes(n int64) { t.bar.AddTotalBytes(n) }
+
+// Transfer implements ProgressBar.
+func (t *transfer) Transfer(name string, size int64) ProgressBar { return t.bar.Transfer(name, size) }
+
+// line formats the transfer, e.g. "  dir/a.bin  12.0M/40.0M (30%)". The
+// start of a name too long for width is cut so the counts stay visible.
+func (t *transfer) line(width int) string {
+	done := t.done.Load()
+	counts := strutil.HumanizeBytes(done)
+	if t.size > 0 {
+		counts = fmt.Sprintf("%s/%s (%d%%)", counts, strutil.HumanizeBytes(t.size), min(done*100/t.size, 100))
+	}
+	name := t.name
+	if room := width - len(counts) - 5; width > 0 {
+		if runes := []rune(name); len(runes) > room {
+			name = "..." + string(runes[max(len(runes)-room+3, 0):])
+		}
+	}
+	return "  " + name + "  " + counts
+}
+
+// New returns a ProgressBar. Without show it is a NoOp. On a terminal the
+// bar repaints in place, listing the active transfers under the summary
+// line when transfers is set; otherwise it prints the summary line every
+// plainInterval. The bar renders to STDERR because stdout carries the
+// command's real output (per-object log lines, JSON).
+func New(show, transfers bool) ProgressBar {
+	if !show {
 		return &NoOp{}
 	}
-	return NewBar(os.Stderr)
+	b := NewBar(os.Stderr)
+	fd := int(os.Stderr.Fd())
+	if !term.IsTerminal(fd) {
+		b.plain = true
+		return b
+	}
+	b.transfers = transfers
+	if width, _, err := term.GetSize(fd); err == nil {
+		b.width = width
+	}
+	return b
 }
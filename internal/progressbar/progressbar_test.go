@@ -2,9 +2,11 @@ package progressbar
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // TestBarLineFormat pins the rendered progress line: object counts, byte
@@ -93,15 +95,104 @@ func TestBarConcurrentUpdates(t *testing.T) {
 	}
 }
 
-// TestNewInvisibleReturnsNoOp verifies New's gating: with isVisible false a
-// NoOp is returned. (The TTY branch cannot be exercised here because the
-// test process's stderr is not a terminal, which also means New(true) must
-// return a NoOp under `go test`.)
-func TestNewInvisibleReturnsNoOp(t *testing.T) {
-	if _, ok := New(false).(*NoOp); !ok {
-		t.Error("New(false) did not return a NoOp")
-	}
-	if _, ok := New(true).(*NoOp); !ok {
-		t.Error("New(true) with non-terminal stderr did not return a NoOp")
+// TestBarRateAndETA verifies the throughput and ETA appended to the
+// summary line once the samples span minRateSpan, and the byte-only line
+// when the total is not known.
+func TestBarRateAndETA(t *testing.T) {
+	b := NewBar(&bytes.Buffer{})
+	b.AddTotalBytes(4000)
+	start := time.Now()
+	b.sample(start)
+	b.AddCompletedBytes(500)
+	b.sample(start.Add(500 * time.Millisecond))
+	if got := b.line(); got != "0/0 objects, 500/3.9K bytes (12%)" {
+		t.Errorf("line() before minRateSpan = %q", got)
+	}
+	b.AddCompletedBytes(500)
+	b.sample(start.Add(time.Second))
+	if got, want := b.line(), "0/0 objects, 1000/3.9K bytes (25%), 1000/s, ETA 3s"; got != want {
+		t.Errorf("line() = %q, want %q", got, want)
+	}
+
+	unknown := NewBar(&bytes.Buffer{})
+	unknown.AddCompletedBytes(2048)
+	if got, want := unknown.line(), "0/0 objects, 2.0K bytes"; got != want {
+		t.Errorf("line() without a total = %q, want %q", got, want)
+	}
+}
+
+// TestBarTransfers verifies the active transfer lines: listed in start
+// order, capped at maxTransferLines, gone once finished, and with long
+// names cut from the start to fit the width.
+func TestBarTransfers(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBar(&buf)
+	b.transfers = true
+	first := b.Transfer("a.bin", 400)
+	first.AddCompletedBytes(100)
+	for i := range maxTransferLines + 2 {
+		b.Transfer(fmt.Sprintf("obj%02d", i), 0)
+	}
+	lines := b.transferLines()
+	if len(lines) != maxTransferLines+1 {
+		t.Fatalf("got %d transfer lines, want %d: %q", len(lines), maxTransferLines+1, lines)
+	}
+	if lines[0] != "  a.bin  100/400 (25%)" || lines[1] != "  obj00  0" {
+		t.Errorf("transfer lines = %q", lines[:2])
+	}
+	if last := lines[len(lines)-1]; last != "  ... and 3 more" {
+		t.Errorf("last line = %q, want the count of the unlisted transfers", last)
+	}
+	if b.completedBytes.Load() != 100 {
+		t.Errorf("bar completed bytes = %d, want the transfer's 100", b.completedBytes.Load())
+	}
+	first.Finish()
+	if lines := b.transferLines(); lines[0] != "  obj00  0" {
+		t.Errorf("finished transfer still listed: %q", lines[0])
+	}
+
+	b.width = 24
+	if got, want := (&transfer{name: "some/long/prefix/name.bin"}).line(b.width), "  ...prefix/name.bin  0"; got != want {
+		t.Errorf("cut line = %q, want %q", got, want)
+	}
+
+	b.render(time.Now(), true)
+	b.render(time.Now(), true)
+	if !strings.Contains(buf.String(), fmt.Sprintf("\x1b[%dA", maxTransferLines+1)) {
+		t.Errorf("repaint does not move the cursor back over the transfer lines: %q", buf.String())
+	}
+}
+
+// TestBarPlain verifies that a plain bar prints whole lines without control
+// characters and ends with the final summary.
+func TestBarPlain(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBar(&buf)
+	b.plain = true
+	b.Start()
+	b.AddTotalBytes(2)
+	b.AddCompletedBytes(2)
+	b.IncrementTotalObjects()
+	b.IncrementCompletedObjects()
+	b.Finish()
+
+	out := buf.String()
+	if strings.ContainsAny(out, "\r\x1b") {
+		t.Errorf("plain output has control characters: %q", out)
+	}
+	if !strings.HasPrefix(out, "1/1 objects, 2/2 bytes (100%)") || strings.Count(out, "\n") != 1 {
+		t.Errorf("plain output = %q, want the final summary line", out)
+	}
+}
+
+// TestNew verifies New's gating: without show a NoOp is returned, and with
+// show on a stderr that is not a terminal (as under `go test`) a plain Bar.
+func TestNew(t *testing.T) {
+	if _, ok := New(false, true).(*NoOp); !ok {
+		t.Error("New(false, true) did not return a NoOp")
+	}
+	b, ok := New(true, true).(*Bar)
+	if !ok || !b.plain || b.transfers {
+		t.Errorf("New(true, true) with non-terminal stderr = %#v, want a plain Bar", b)
 	}
 }
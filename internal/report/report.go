@@ -0,0 +1,387 @@
+// Package report writes the transfer report of --report: one entry per
+// object operation with its source, destination, size, the ETag and
+// version ID of the object written, duration, attempts and final status.
+//
+// Tasks feed entries as they complete; a single writer goroutine encodes
+// them, so concurrent tasks never interleave lines and a slow disk never
+// holds a transfer slot. The report is independent of the console output
+// of package log: --output, --log and --stat do not change it.
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// Format is the encoding of the report file.
+type Format string
+
+const (
+	// FormatJSONL writes one JSON object per line.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes a header line and one record per entry.
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat returns the Format named s. An empty s picks the format
+// from the extension of file: CSV for ".csv", JSON lines otherwise.
+func ParseFormat(s, file string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "":
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			return FormatCSV, nil
+		}
+		return FormatJSONL, nil
+	case "jsonl", "json":
+		return FormatJSONL, nil
+	case "csv":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf(`report format must be "jsonl" or "csv", got %q`, s)
+}
+
+// Status is the final outcome of an operation.
+type Status string
+
+// The statuses of an Entry. A skip is a copy --no-clobber, --if-size-differ
+// or --if-source-newer decided against; canceled and not-started are the
+// operations an interrupt stopped or kept from starting.
+const (
+	StatusOK         Status = "ok"
+	StatusDryRun     Status = "dry-run"
+	StatusSkipped    Status = "skipped"
+	StatusFailed     Status = "failed"
+	StatusCanceled   Status = "canceled"
+	StatusNotStarted Status = "not-started"
+)
+
+// Entry is one line of the report.
+type Entry struct {
+	// Time is when the first attempt started, or when the operation was
+	// given up for one that never started.
+	Time        time.Time
+	Operation   string
+	Source      string
+	Destination string
+	Size        int64
+	ETag        string
+	VersionID   string
+	Duration    time.Duration
+	Attempts    int
+	Status      Status
+	Error       string
+}
+
+// jsonEntry is the JSON lines encoding of Entry.
+type jsonEntry struct {
+	Time        string  `json:"time"`
+	Operation   string  `json:"operation"`
+	Source      string  `json:"source,omitempty"`
+	Destination string  `json:"destination,omitempty"`
+	Size        int64   `json:"size"`
+	ETag        string  `json:"etag,omitempty"`
+	VersionID   string  `json:"version_id,omitempty"`
+	DurationMS  float64 `json:"duration_ms"`
+	Attempts    int     `json:"attempts"`
+	Status      Status  `json:"status"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// csvHeader names the CSV columns, in the order of Entry.
+var csvHeader = []string{"time", "operation", "source", "destination", "size", "etag", "version_id", "duration_ms", "attempts", "status", "error"}
+
+// Writer writes entries to a report file. Its methods, and those of the
+// Operations it starts, may be called on a nil *Writer, which reports
+// nothing; commands call them whether --report is set or not.
+type Writer struct {
+	file    *os.File
+	buf     *bufio.Writer
+	encode  func(Entry) error
+	dryRun  bool
+	entries chan Entry
+	done    chan struct{}
+	// err is the first write error; it is returned by Close.
+	err error
+
+	// open holds the operations started and not finished yet, by start
+	// order; Close reports them as never started.
+	mu   sync.Mutex
+	seq  uint64
+	open map[*Operation]struct{}
+}
+
+// Open creates file and starts the writer goroutine. With dryRun, the
+// operations that succeed are reported as StatusDryRun.
+func Open(file string, format Format, dryRun bool) (*Writer, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	w := &Writer{
+		file:    f,
+		buf:     bufio.NewWriter(f),
+		dryRun:  dryRun,
+		entries: make(chan Entry, 64),
+		done:    make(chan struct{}),
+		open:    make(map[*Operation]struct{}),
+	}
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w.buf)
+		if err := cw.Write(csvHeader); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("report: %w", err)
+		}
+		w.encode = func(e Entry) error {
+			cw.Write(csvRecord(e))
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		enc := json.NewEncoder(w.buf)
+		w.encode = func(e Entry) error { return enc.Encode(jsonRecord(e)) }
+	}
+	go w.write()
+	return w, nil
+}
+
+// write is the writer goroutine. After a write error it keeps draining
+// entries, so tasks never block on a failed report.
+func (w *Writer) write() {
+	defer close(w.done)
+	for e := range w.entries {
+		if w.err == nil {
+			w.err = w.encode(e)
+		}
+	}
+}
+
+// Close reports the operations that were started and never finished as
+// not started, such as the tasks an interrupt kept from running, waits
+// for the entries to be written and closes the file. It must be called
+// once no task runs anymore; it returns the first error writing the
+// report.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	open := make([]*Operation, 0, len(w.open))
+	for o := range w.open {
+		open = append(open, o)
+	}
+	w.mu.Unlock()
+	slices.SortFunc(open, func(a, b *Operation) int { return cmp.Compare(a.seq, b.seq) })
+	for _, o := range open {
+		o.Finish(errorpkg.ErrNotStarted)
+	}
+	close(w.entries)
+	<-w.done
+	err := errors.Join(w.err, w.buf.Flush(), w.file.Close())
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	return nil
+}
+
+// Start begins the entry of an operation on one object. size is the size
+// of the source, when known.
+func (w *Writer) Start(op, src, dst string, size int64) *Operation {
+	if w == nil {
+		return nil
+	}
+	o := &Operation{w: w, entry: Entry{Operation: op, Source: src, Destination: dst, Size: size}}
+	w.mu.Lock()
+	w.seq++
+	o.seq = w.seq
+	w.open[o] = struct{}{}
+	w.mu.Unlock()
+	return o
+}
+
+// Operation is the entry of an operation in progress. Its methods are
+// safe for concurrent use, and do nothing on a nil *Operation.
+type Operation struct {
+	w   *Writer
+	seq uint64
+	mu  sync.Mutex
+	// entry is sent once, by the first Finish.
+	entry    Entry
+	finished bool
+}
+
+// Attempt returns task, counted as one attempt of the operation when run.
+// Retries wrap the returned task, so each retry is counted.
+func (o *Operation) Attempt(task func() error) func() error {
+	if o == nil {
+		return task
+	}
+	return func() error {
+		o.Attempted()
+		return task()
+	}
+}
+
+// Attempted counts one attempt of the operation. A fan-out, which tries
+// its destinations within one task, calls it for each destination tried.
+func (o *Operation) Attempted() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.entry.Attempts == 0 {
+		o.entry.Time = time.Now()
+	}
+	o.entry.Attempts++
+}
+
+// Resolved replaces the destination given to Start with the one the task
+// resolved, such as the file a download into a directory writes.
+func (o *Operation) Resolved(dst string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entry.Destination = dst
+}
+
+// Finishes returns task, finishing the operation with its result.
+func (o *Operation) Finishes(task func() error) func() error {
+	if o == nil {
+		return task
+	}
+	return func() error {
+		err := task()
+		o.Finish(err)
+		return err
+	}
+}
+
+// Wrote records the object the operation wrote: its ETag and version ID,
+// and its size when the source's was not known.
+func (o *Operation) Wrote(obj *storage.Object) {
+	if o == nil || obj == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entry.ETag = obj.Etag
+	o.entry.VersionID = obj.VersionID
+	if o.entry.Size == 0 {
+		o.entry.Size = obj.Size
+	}
+}
+
+// Discard drops the operation without an entry, for an operation that
+// another one takes over, such as a copy that falls back to an upload.
+func (o *Operation) Discard() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	o.finished = true
+	o.mu.Unlock()
+	o.w.mu.Lock()
+	delete(o.w.open, o)
+	o.w.mu.Unlock()
+}
+
+// Finish sends the entry with the status err describes. Only the first
+// call counts.
+func (o *Operation) Finish(err error) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	if o.finished {
+		o.mu.Unlock()
+		return
+	}
+	o.finished = true
+	e := o.entry
+	o.mu.Unlock()
+	o.w.mu.Lock()
+	delete(o.w.open, o)
+	o.w.mu.Unlock()
+
+	if e.Attempts == 0 {
+		e.Time = time.Now()
+	} else {
+		e.Duration = time.Since(e.Time)
+	}
+	e.Status = o.w.status(err)
+	if err != nil {
+		e.Error = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+	o.w.entries <- e
+}
+
+// status maps the final error of an operation to its Status.
+func (w *Writer) status(err error) Status {
+	switch {
+	case err == nil && w.dryRun:
+		return StatusDryRun
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, errorpkg.ErrNotStarted):
+		return StatusNotStarted
+	case errorpkg.IsCancelation(err):
+		return StatusCanceled
+	case errorpkg.IsWarning(err):
+		return StatusSkipped
+	}
+	return StatusFailed
+}
+
+func jsonRecord(e Entry) jsonEntry {
+	return jsonEntry{
+		Time:        e.Time.UTC().Format(time.RFC3339Nano),
+		Operation:   e.Operation,
+		Source:      e.Source,
+		Destination: e.Destination,
+		Size:        e.Size,
+		ETag:        e.ETag,
+		VersionID:   e.VersionID,
+		DurationMS:  durationMS(e.Duration),
+		Attempts:    e.Attempts,
+		Status:      e.Status,
+		Error:       e.Error,
+	}
+}
+
+func csvRecord(e Entry) []string {
+	return []string{
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.Operation,
+		e.Source,
+		e.Destination,
+		strconv.FormatInt(e.Size, 10),
+		e.ETag,
+		e.VersionID,
+		strconv.FormatFloat(durationMS(e.Duration), 'f', -1, 64),
+		strconv.Itoa(e.Attempts),
+		string(e.Status),
+		e.Error,
+	}
+}
+
+// durationMS is d in milliseconds, to the microsecond.
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
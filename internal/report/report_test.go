@@ -0,0 +1,148 @@
+package report
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// TestWriter_Statuses verifies the status, attempts and written object of
+// each entry, that Close reports the operations that never finished as
+// not started, in start order, and that a discarded operation is not
+// reported.
+func TestWriter_Statuses(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.jsonl")
+	w, err := Open(file, FormatJSONL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := w.Start("cp", "a", "s3://b/a", 1)
+	tries := 0
+	task := ok.Attempt(func() error {
+		tries++
+		if tries < 2 {
+			return errors.New("slow down")
+		}
+		ok.Wrote(&storage.Object{Etag: "e1", VersionID: "v1"})
+		return nil
+	})
+	err = task()
+	if err != nil {
+		err = task()
+	}
+	ok.Finish(err)
+
+	skipped := w.Start("cp", "b", "s3://b/b", 2)
+	skipped.Finishes(skipped.Attempt(func() error { return errorpkg.ErrObjectExists }))()
+	failed := w.Start("rm", "s3://b/c", "", 3)
+	failed.Finishes(failed.Attempt(func() error { return fmt.Errorf("delete:\n%w", errors.New("denied")) }))()
+	canceled := w.Start("cp", "d", "s3://b/d", 4)
+	canceled.Finishes(canceled.Attempt(func() error { return context.Canceled }))()
+	discarded := w.Start("cp", "g", "s3://b/g", 7)
+	discarded.Attempt(func() error { return errors.New("copy failed") })()
+	discarded.Discard()
+	w.Start("cp", "e", "s3://b/e", 5)
+	w.Start("cp", "f", "s3://b/f", 6)
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	want := []struct {
+		source   string
+		status   Status
+		attempts int
+	}{
+		{"a", StatusOK, 2},
+		{"b", StatusSkipped, 1},
+		{"s3://b/c", StatusFailed, 1},
+		{"d", StatusCanceled, 1},
+		{"e", StatusNotStarted, 0},
+		{"f", StatusNotStarted, 0},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("report has %d lines, want %d:\n%s", len(lines), len(want), content)
+	}
+	for i, line := range lines {
+		var e jsonEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if e.Source != want[i].source || e.Status != want[i].status || e.Attempts != want[i].attempts {
+			t.Errorf("line %d = %s, want source %q, status %q, %d attempts", i, line, want[i].source, want[i].status, want[i].attempts)
+		}
+		if i == 0 && (e.ETag != "e1" || e.VersionID != "v1" || e.Size != 1) {
+			t.Errorf("ok entry = %s, want the written object's ETag and version ID", line)
+		}
+		if i == 2 && e.Error != "delete: denied" {
+			t.Errorf("failed entry error = %q, want it on one line", e.Error)
+		}
+	}
+}
+
+// TestWriter_CSV verifies the header and records of the CSV format, and
+// that a dry run reports successes as dry-run.
+func TestWriter_CSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.csv")
+	format, err := ParseFormat("", file)
+	if err != nil || format != FormatCSV {
+		t.Fatalf("ParseFormat(%q) = %q, %v, want csv", file, format, err)
+	}
+	w, err := Open(file, format, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := w.Start("put", "a, b.txt", "s3://b/a, b.txt", 7)
+	op.Finishes(op.Attempt(func() error { return nil }))()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		t.Fatalf("records = %q, want the header and one record", records)
+	}
+	r := records[1]
+	if r[1] != "put" || r[2] != "a, b.txt" || r[4] != "7" || r[8] != "1" || r[9] != string(StatusDryRun) {
+		t.Errorf("record = %q", r)
+	}
+}
+
+// TestWriter_Nil verifies that a nil Writer and its Operations report
+// nothing and run the tasks unchanged.
+func TestWriter_Nil(t *testing.T) {
+	var w *Writer
+	op := w.Start("cp", "a", "b", 1)
+	ran := false
+	if err := op.Finishes(op.Attempt(func() error { ran = true; return nil }))(); err != nil || !ran {
+		t.Errorf("task through a nil Operation: ran = %v, err = %v", ran, err)
+	}
+	op.Wrote(&storage.Object{})
+	op.Finish(nil)
+	op.Discard()
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
@@ -92,6 +92,12 @@ func Close() {
 	}
 }
 
+// JSON reports whether the global logger writes JSON: whether --output
+// json is in effect, once the root command initialized the logger.
+func JSON() bool {
+	return logger().json
+}
+
 // Trace queues a trace-level message to stdout.
 func Trace(msg Message) {
 	logger().printf(LevelTrace, msg, os.Stdout)
@@ -2,17 +2,42 @@ package log
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/LinPr/s6cmd/strutil"
 )
 
 // Message is the interface that all loggable values implement. String is
 // used for plain-text output, JSON for the JSON output mode.
+//
+// JSON renders a single line with strutil.SchemaJSON: its "schema" field
+// names the message type and the version of its fields, such as
+// "info/v1". The version changes only when a field is removed or changes
+// meaning; new fields may be added to any version.
 type Message interface {
 	fmt.Stringer
 	JSON() string
 }
 
+// Schemas of the messages of this package.
+const (
+	InfoSchema  = "info/v1"
+	ErrorSchema = "error/v1"
+	DebugSchema = "debug/v1"
+	TraceSchema = "trace/v1"
+)
+
+// Fprint writes msg to w on a line of its own, as JSON when json is set.
+// Commands print their results to their output with it, while progress
+// and per-object errors go through the logger.
+func Fprint(w io.Writer, json bool, msg Message) {
+	if json {
+		fmt.Fprintln(w, msg.JSON())
+		return
+	}
+	fmt.Fprintln(w, msg.String())
+}
+
 // InfoMessage is a generic message for successful operations.
 type InfoMessage struct {
 	Operation   string  `json:"operation"`
@@ -43,14 +68,16 @@ func (i InfoMessage) JSON() string {
 		i.VersionID = ""
 	}
 	i.Success = true
-	return strutil.JSON(i)
+	return strutil.SchemaJSON(InfoSchema, i)
 }
 
-// ErrorMessage is a generic message for unsuccessful operations.
+// ErrorMessage is a generic message for unsuccessful operations. The
+// error that ends a command is reported with its ExitCode.
 type ErrorMessage struct {
 	Operation string `json:"operation,omitempty"`
 	Command   string `json:"command,omitempty"`
 	Err       string `json:"error"`
+	ExitCode  int    `json:"exit_code,omitempty"`
 }
 
 // String is the plain-text representation of ErrorMessage.
@@ -63,7 +90,7 @@ func (e ErrorMessage) String() string {
 
 // JSON is the JSON representation of ErrorMessage.
 func (e ErrorMessage) JSON() string {
-	return strutil.JSON(e)
+	return strutil.SchemaJSON(ErrorSchema, e)
 }
 
 // DebugMessage is a generic message for debug-level log entries.
@@ -83,7 +110,7 @@ func (d DebugMessage) String() string {
 
 // JSON is the JSON representation of DebugMessage.
 func (d DebugMessage) JSON() string {
-	return strutil.JSON(d)
+	return strutil.SchemaJSON(DebugSchema, d)
 }
 
 // TraceMessage carries an opaque trace string, typically from the AWS SDK.
@@ -98,5 +125,5 @@ func (t TraceMessage) String() string {
 
 // JSON is the JSON representation of TraceMessage.
 func (t TraceMessage) JSON() string {
-	return strutil.JSON(t)
+	return strutil.SchemaJSON(TraceSchema, t)
 }
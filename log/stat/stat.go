@@ -119,13 +119,16 @@ func (s Stats) String() string {
 	return buf.String()
 }
 
+// Schema is the schema of the JSON lines of Stats.
+const Schema = "stat/v1"
+
 // JSON renders Stats as one JSON object per line.
 func (s Stats) JSON() string {
-	var builder strings.Builder
-	for _, stat := range s {
-		builder.WriteString(strutil.JSON(stat) + "\n")
+	lines := make([]string, len(s))
+	for i, stat := range s {
+		lines[i] = strutil.SchemaJSON(Schema, stat)
 	}
-	return builder.String()
+	return strings.Join(lines, "\n")
 }
 
 // Statistics returns the stats collected so far, sorted by operation name
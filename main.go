@@ -4,33 +4,24 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 
 	"github.com/LinPr/s6cmd/cmd"
+	"github.com/LinPr/s6cmd/internal/interrupt"
 	"github.com/LinPr/s6cmd/internal/parallel"
 	"github.com/LinPr/s6cmd/log"
 )
 
 func main() {
-	// Cancel the root context on SIGINT/SIGTERM so in-flight transfers
-	// stop cooperatively (temp files cleaned up, multipart uploads
-	// aborted) instead of the process being hard-killed mid-write. The
-	// context flows to every subcommand via cmd.Context().
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-
-	// Two-Ctrl-C contract: the FIRST signal cancels the context for a
-	// graceful shutdown; once that happened, stop() unregisters the
-	// handler and restores the default signal disposition, so a SECOND
-	// Ctrl-C hard-kills the process. Without this, signal.NotifyContext
-	// keeps swallowing every subsequent SIGINT for the whole process
-	// lifetime, and any code path blocked outside the context (a bufio
-	// read on stdin/fifo, a terminal confirmation prompt) could only be
-	// stopped with SIGKILL.
-	go func() {
-		<-ctx.Done()
-		stop()
-	}()
+	// Two-stage interrupt: the first SIGINT/SIGTERM stops the global
+	// parallel.Manager from starting new tasks and lets the running ones
+	// finish within --drain-timeout; the root context is canceled after
+	// that, or by a second signal, so in-flight transfers stop
+	// cooperatively (temp files cleaned up, multipart uploads aborted).
+	// Once canceled the default disposition is restored, so one more
+	// Ctrl-C hard-kills a process blocked outside the context (a read on
+	// stdin, a confirmation prompt). The context flows to every
+	// subcommand via cmd.Context().
+	ctx, stop := interrupt.Notify(context.Background(), parallel.Stop)
 
 	// Initialize process-wide infrastructure before any command runs.
 	// parallel.Init raises the soft RLIMIT_NOFILE and constructs the
@@ -8,8 +8,10 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/internal/ignore"
 	"github.com/LinPr/s6cmd/log"
 	"github.com/LinPr/s6cmd/storage"
 )
@@ -88,6 +90,14 @@ func (f *FileStore) expandGlob(ctx context.Context, src *storage.StorageURL, fol
 			return
 		}
 
+		// The ignore files apply from the directory the pattern starts
+		// in, so "dir/*" honors dir/.s6cmdignore.
+		base := src.Absolute()
+		if i := strings.IndexAny(base, "*?["); i >= 0 {
+			base = base[:i]
+		}
+		tree := ignore.NewTree(filepath.Dir(base), src.IgnoreFiles())
+
 		for _, filename := range matched {
 			fileURL, err := storage.NewStorageURL(filename)
 			if err != nil {
@@ -113,11 +123,19 @@ func (f *FileStore) expandGlob(ctx context.Context, src *storage.StorageURL, fol
 				})
 				continue
 			}
+			ignored, err := tree.IgnoredPath(filename, obj.Type.IsDir())
+			if err != nil {
+				sendError(ctx, err, ch)
+				return
+			}
+			if ignored {
+				continue
+			}
 			if !obj.Type.IsDir() {
 				sendObject(ctx, obj, ch)
 				continue
 			}
-			walkDir(ctx, f, fileURL, followSymlinks, func(o *storage.Object) {
+			walkDir(ctx, f, fileURL, followSymlinks, tree, func(o *storage.Object) {
 				sendObject(ctx, o, ch)
 			})
 		}
@@ -128,14 +146,29 @@ func (f *FileStore) expandGlob(ctx context.Context, src *storage.StorageURL, fol
 // walkDir walks the directory rooted at src and calls fn for every file
 // (symlinks are skipped when followSymlinks is false). It uses
 // filepath.WalkDir to avoid pulling in github.com/karrick/godirwalk.
-func walkDir(ctx context.Context, f *FileStore, src *storage.StorageURL, followSymlinks bool, fn func(*storage.Object)) {
+// Directories tree ignores are skipped without being read; a nil tree
+// ignores nothing.
+func walkDir(ctx context.Context, f *FileStore, src *storage.StorageURL, followSymlinks bool, tree *ignore.Tree, fn func(*storage.Object)) {
 	if !ShouldProcessURL(src, followSymlinks) {
 		return
 	}
-	err := filepath.WalkDir(src.Absolute(), func(pathname string, d fs.DirEntry, err error) error {
+	root := src.Absolute()
+	err := filepath.WalkDir(root, func(pathname string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if pathname != root {
+			ignored, err := tree.IgnoredPath(pathname, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if ignored && d.IsDir() {
+				return filepath.SkipDir
+			}
+			if ignored {
+				return nil
+			}
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -163,7 +196,8 @@ func (f *FileStore) walkDir(ctx context.Context, src *storage.StorageURL, follow
 	ch := make(chan *storage.Object)
 	go func() {
 		defer close(ch)
-		walkDir(ctx, f, src, followSymlinks, func(obj *storage.Object) {
+		tree := ignore.NewTree(src.Absolute(), src.IgnoreFiles())
+		walkDir(ctx, f, src, followSymlinks, tree, func(obj *storage.Object) {
 			sendObject(ctx, obj, ch)
 		})
 	}()
@@ -175,26 +209,26 @@ func (f *FileStore) walkDir(ctx context.Context, src *storage.StorageURL, follow
 // is written to a temporary file in the destination directory and renamed
 // into place only after a successful write+close, so a failed copy (e.g.
 // ENOSPC surfacing at Close) never truncates or replaces an existing file.
-func (f *FileStore) Copy(ctx context.Context, src, dst *storage.StorageURL, _ storage.Metadata) error {
+func (f *FileStore) Copy(ctx context.Context, src, dst *storage.StorageURL, _ storage.Metadata) (*storage.Object, error) {
 	_ = ctx
 	if f.dryRun {
-		return nil
+		return &storage.Object{StorageURL: dst}, nil
 	}
 	dstDir := dst.Dir()
 	if err := os.MkdirAll(dstDir, 0o755); err != nil {
-		return err
+		return nil, err
 	}
 	in, err := os.Open(src.Absolute())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer in.Close()
 	out, err := f.CreateTemp(dstDir, "s6cmd-")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	tempPath := out.Name()
-	_, err = io.Copy(out, in)
+	n, err := io.Copy(out, in)
 	if closeErr := out.Close(); err == nil {
 		err = closeErr
 	}
@@ -203,9 +237,9 @@ func (f *FileStore) Copy(ctx context.Context, src, dst *storage.StorageURL, _ st
 	}
 	if err != nil {
 		_ = os.Remove(tempPath)
-		return err
+		return nil, err
 	}
-	return nil
+	return &storage.Object{StorageURL: dst, Size: n}, nil
 }
 
 // Delete removes the file at url.Absolute().
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/LinPr/s6cmd/storage"
@@ -53,7 +54,7 @@ func TestCopyBasic(t *testing.T) {
 	writeFile(t, srcPath, "payload")
 
 	dstPath := filepath.Join(dir, "sub", "dst.txt")
-	if err := f.Copy(context.Background(), mustURL(t, srcPath), mustURL(t, dstPath), storage.Metadata{}); err != nil {
+	if _, err := f.Copy(context.Background(), mustURL(t, srcPath), mustURL(t, dstPath), storage.Metadata{}); err != nil {
 		t.Fatalf("Copy: %v", err)
 	}
 	got, err := os.ReadFile(dstPath)
@@ -66,7 +67,7 @@ func TestCopyBasic(t *testing.T) {
 
 	// Overwrite with new content.
 	writeFile(t, srcPath, "updated")
-	if err := f.Copy(context.Background(), mustURL(t, srcPath), mustURL(t, dstPath), storage.Metadata{}); err != nil {
+	if _, err := f.Copy(context.Background(), mustURL(t, srcPath), mustURL(t, dstPath), storage.Metadata{}); err != nil {
 		t.Fatalf("Copy (overwrite): %v", err)
 	}
 	got, err = os.ReadFile(dstPath)
@@ -100,7 +101,7 @@ func TestCopyMissingSourceLeavesDestination(t *testing.T) {
 	dstPath := filepath.Join(dir, "dst.txt")
 	writeFile(t, dstPath, "keep me")
 
-	err := f.Copy(context.Background(), mustURL(t, filepath.Join(dir, "missing.txt")), mustURL(t, dstPath), storage.Metadata{})
+	_, err := f.Copy(context.Background(), mustURL(t, filepath.Join(dir, "missing.txt")), mustURL(t, dstPath), storage.Metadata{})
 	if err == nil {
 		t.Fatal("Copy succeeded, want error for missing source")
 	}
@@ -165,3 +166,48 @@ func TestExpandGlobSymlinkedFile(t *testing.T) {
 		t.Errorf("followSymlinks=true: List = %v, want [link.txt real.txt]", got)
 	}
 }
+
+// TestWalkDirIgnoreFiles verifies that a directory or glob listing of a
+// URL with ignore files skips the ignored files and does not descend into
+// ignored directories.
+func TestWalkDirIgnoreFiles(t *testing.T) {
+	t.Parallel()
+	f := NewFileStore(context.Background(), LocalOption{})
+	dir := t.TempDir()
+	for _, sub := range []string{"build", "src"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(dir, ".s6cmdignore"), "/build/\n*.tmp\n")
+	writeFile(t, filepath.Join(dir, "build", "out.bin"), "x")
+	writeFile(t, filepath.Join(dir, "src", "main.go"), "x")
+	writeFile(t, filepath.Join(dir, "src", "scratch.tmp"), "x")
+	writeFile(t, filepath.Join(dir, "src", ".s6cmdignore"), "!scratch.tmp\n")
+	writeFile(t, filepath.Join(dir, "a.tmp"), "x")
+	// Unreadable patterns in an ignored directory are never read.
+	writeFile(t, filepath.Join(dir, "build", ".s6cmdignore"), "[broken\n")
+
+	names := []string{".s6cmdignore"}
+	src, err := storage.NewStorageURL(dir, storage.WithIgnoreFiles(names))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectList(t, f, src, false)
+	want := []string{".s6cmdignore", ".s6cmdignore", "main.go", "scratch.tmp"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+
+	glob, err := storage.NewStorageURL(filepath.Join(dir, "*"), storage.WithIgnoreFiles(names))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collectList(t, f, glob, false); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("List of a glob = %v, want %v", got, want)
+	}
+
+	if got := collectList(t, f, mustURL(t, dir), false); len(got) != 7 {
+		t.Errorf("List without ignore files = %v, want all 7 files", got)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// listingVersion is bumped when the layout of a saved listing changes. A
+// listing of another version is rejected rather than misread.
+const listingVersion = 1
+
+// ListingHeader is the first line of a saved listing: the URL that was
+// listed and when.
+type ListingHeader struct {
+	Version  int       `json:"version"`
+	URL      string    `json:"url"`
+	ListedAt time.Time `json:"listed_at"`
+}
+
+// ListingWriter saves a listing as JSON lines: a ListingHeader followed by
+// one Object per line, in their JSON form. It writes to a temporary file
+// next to the listing and replaces the listing on Commit only, so an
+// interrupted listing never leaves a truncated file behind.
+type ListingWriter struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// CreateListing starts a listing of url at path, listed at listedAt.
+func CreateListing(path string, url *StorageURL, listedAt time.Time) (*ListingWriter, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("save listing: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	lw := &ListingWriter{path: path, f: f, w: w, enc: json.NewEncoder(w)}
+	if err := lw.enc.Encode(ListingHeader{Version: listingVersion, URL: url.Absolute(), ListedAt: listedAt}); err != nil {
+		lw.Close()
+		return nil, fmt.Errorf("save listing: %w", err)
+	}
+	return lw, nil
+}
+
+// Write appends obj to the listing.
+func (lw *ListingWriter) Write(obj *Object) error {
+	if err := lw.enc.Encode(obj); err != nil {
+		return fmt.Errorf("save listing: %w", err)
+	}
+	return nil
+}
+
+// Commit flushes the listing and moves it to its path.
+func (lw *ListingWriter) Commit() error {
+	f := lw.f
+	lw.f = nil
+	err := lw.w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), lw.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("save listing: %w", err)
+	}
+	return nil
+}
+
+// Close discards the listing unless it was committed.
+func (lw *ListingWriter) Close() error {
+	if lw.f == nil {
+		return nil
+	}
+	lw.f.Close()
+	err := os.Remove(lw.f.Name())
+	lw.f = nil
+	return err
+}
+
+// ListingReader reads a listing saved by a ListingWriter.
+type ListingReader struct {
+	// Header is the first line of the listing.
+	Header ListingHeader
+
+	path string
+	f    *os.File
+	dec  *json.Decoder
+	line int
+}
+
+// OpenListing opens the listing saved at path and reads its header.
+func OpenListing(path string) (*ListingReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read listing: %w", err)
+	}
+	lr := &ListingReader{path: path, f: f, dec: json.NewDecoder(bufio.NewReader(f)), line: 1}
+	if err := lr.dec.Decode(&lr.Header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read listing %s: %w", path, err)
+	}
+	if lr.Header.Version != listingVersion {
+		f.Close()
+		return nil, fmt.Errorf("read listing %s: version %d is not supported, want %d", path, lr.Header.Version, listingVersion)
+	}
+	return lr, nil
+}
+
+// Next returns the next object of the listing, or io.EOF after the last.
+func (lr *ListingReader) Next() (*Object, error) {
+	lr.line++
+	var obj Object
+	if err := lr.dec.Decode(&obj); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read listing %s, line %d: %w", lr.path, lr.line, err)
+	}
+	if obj.StorageURL == nil {
+		return nil, fmt.Errorf("read listing %s, line %d: no key", lr.path, lr.line)
+	}
+	return &obj, nil
+}
+
+// Close closes the listing.
+func (lr *ListingReader) Close() error {
+	return lr.f.Close()
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestListing_RoundTrip verifies that a saved listing reads back the URL,
+// the header and the objects with their attributes, glob characters in
+// keys included.
+func TestListing_RoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "listing.jsonl")
+	listed, err := NewStorageURL("s3://bucket/data/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listedAt := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+	lw, err := CreateListing(path, listed, listedAt)
+	if err != nil {
+		t.Fatalf("CreateListing: %v", err)
+	}
+	defer lw.Close()
+	mod := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, key := range []string{"data/a.txt", "data/b*[1].txt"} {
+		u, _ := NewStorageURL("s3://bucket/"+key, WithRaw(true))
+		obj := &Object{StorageURL: u, Etag: "etag", ModTime: &mod, Type: NewObjectType(0), Size: 12, StorageClass: "GLACIER"}
+		if err := lw.Write(obj); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the listing exists before Commit: %v", err)
+	}
+	if err := lw.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	lr, err := OpenListing(path)
+	if err != nil {
+		t.Fatalf("OpenListing: %v", err)
+	}
+	defer lr.Close()
+	if lr.Header.URL != "s3://bucket/data/" || !lr.Header.ListedAt.Equal(listedAt) {
+		t.Errorf("header = %+v", lr.Header)
+	}
+	var keys []string
+	for {
+		obj, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		keys = append(keys, obj.StorageURL.Path)
+		if obj.StorageURL.Bucket != "bucket" || obj.Etag != "etag" || obj.Size != 12 || obj.StorageClass != "GLACIER" ||
+			!obj.Type.IsRegular() || obj.ModTime == nil || !obj.ModTime.Equal(mod) {
+			t.Errorf("object = %+v", obj)
+		}
+	}
+	if got := strings.Join(keys, ","); got != "data/a.txt,data/b*[1].txt" {
+		t.Errorf("keys = %s", got)
+	}
+}
+
+// TestListing_Discarded verifies that a listing closed without Commit
+// leaves nothing behind.
+func TestListing_Discarded(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	listed, _ := NewStorageURL("s3://bucket/")
+	lw, err := CreateListing(filepath.Join(dir, "listing.jsonl"), listed, time.Now())
+	if err != nil {
+		t.Fatalf("CreateListing: %v", err)
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if entries := listDir(t, dir); len(entries) != 0 {
+		t.Errorf("left behind %v", entries)
+	}
+}
+
+// TestOpenListing_Version verifies that a listing of another version is
+// rejected.
+func TestOpenListing_Version(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "listing.jsonl")
+	if err := os.WriteFile(path, []byte(`{"version":2,"url":"s3://bucket/"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenListing(path); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("OpenListing = %v, want a version error", err)
+	}
+}
@@ -0,0 +1,237 @@
+package s3store
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// inventoryManifest is the manifest.json of an S3 Inventory report. Only
+// the fields needed to read the CSV data files are decoded.
+type inventoryManifest struct {
+	SourceBucket string `json:"sourceBucket"`
+	// DestinationBucket is the ARN of the bucket holding the data files,
+	// e.g. "arn:aws:s3:::inventory-bucket".
+	DestinationBucket string `json:"destinationBucket"`
+	FileFormat        string `json:"fileFormat"`
+	// FileSchema names the CSV columns, e.g. "Bucket, Key, Size,
+	// LastModifiedDate, ETag, StorageClass".
+	FileSchema string `json:"fileSchema"`
+	Files      []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// listInventory sends the objects of an S3 Inventory report instead of
+// listing the bucket: url.Inventory() names the report's manifest.json,
+// either an s3:// URL or a local file, and the gzipped CSV data files it
+// lists are read from the report's destination bucket in turn.
+//
+// The rows are filtered like a live listing (see replay), and only the
+// latest versions are sent unless url asks for versions. Rows arrive in
+// report order, not in key order.
+func (s *S3Store) listInventory(ctx context.Context, url *storage.StorageURL) <-chan *storage.Object {
+	objCh := make(chan *storage.Object)
+
+	go func() {
+		defer close(objCh)
+
+		manifest, err := s.readInventoryManifest(ctx, url.Inventory())
+		if err != nil {
+			sendObject(ctx, &storage.Object{Err: err}, objCh)
+			return
+		}
+		if manifest.SourceBucket != url.Bucket {
+			sendObject(ctx, &storage.Object{Err: fmt.Errorf("inventory %s lists bucket %q, not %q", url.Inventory(), manifest.SourceBucket, url.Bucket)}, objCh)
+			return
+		}
+		if !strings.EqualFold(manifest.FileFormat, "CSV") {
+			sendObject(ctx, &storage.Object{Err: fmt.Errorf("inventory %s: file format %q is not supported, only CSV", url.Inventory(), manifest.FileFormat)}, objCh)
+			return
+		}
+		columns, err := inventoryColumns(manifest.FileSchema)
+		if err != nil {
+			sendObject(ctx, &storage.Object{Err: fmt.Errorf("inventory %s: %w", url.Inventory(), err)}, objCh)
+			return
+		}
+		bucket := manifest.DestinationBucket[strings.LastIndex(manifest.DestinationBucket, ":")+1:]
+
+		versions := url.AllVersions || url.VersionID != ""
+		r := newReplay(ctx, url, objCh)
+		send := func(row inventoryRow) bool {
+			if !versions && (!row.isLatest || row.isDeleteMarker) {
+				return true
+			}
+			obj := &storage.Object{
+				Etag:           row.etag,
+				Size:           row.size,
+				StorageClass:   storage.StorageClass(row.storageClass),
+				IsDeleteMarker: row.isDeleteMarker,
+			}
+			if !row.modTime.IsZero() {
+				obj.ModTime = &row.modTime
+			}
+			if versions {
+				obj.VersionID = row.versionID
+			}
+			return r.send(row.key, obj)
+		}
+
+		for _, file := range manifest.Files {
+			if err := s.readInventoryFile(ctx, bucket, file.Key, columns, send); err != nil {
+				sendObject(ctx, &storage.Object{Err: err}, objCh)
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		r.finish()
+	}()
+
+	return objCh
+}
+
+// readInventoryManifest reads and decodes the manifest at location, an
+// s3:// URL or a local path.
+func (s *S3Store) readInventoryManifest(ctx context.Context, location string) (*inventoryManifest, error) {
+	var data []byte
+	if strings.HasPrefix(location, "s3://") {
+		u, err := storage.NewStorageURL(location, storage.WithRaw(true))
+		if err != nil {
+			return nil, err
+		}
+		body, err := s.Read(ctx, u)
+		if err != nil {
+			return nil, fmt.Errorf("read inventory manifest %s: %w", location, err)
+		}
+		defer body.Close()
+		if data, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("read inventory manifest %s: %w", location, err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("read inventory manifest: %w", err)
+		}
+	}
+	var m inventoryManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse inventory manifest %s: %w", location, err)
+	}
+	if m.SourceBucket == "" || m.DestinationBucket == "" {
+		return nil, fmt.Errorf("parse inventory manifest %s: sourceBucket and destinationBucket are required", location)
+	}
+	return &m, nil
+}
+
+// inventoryRow is one object version of an inventory data file.
+type inventoryRow struct {
+	key            string
+	versionID      string
+	isLatest       bool
+	isDeleteMarker bool
+	size           int64
+	modTime        time.Time
+	etag           string
+	storageClass   string
+}
+
+// inventoryColumns maps the column names of fileSchema to their index. Key
+// is the only required column.
+func inventoryColumns(fileSchema string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range strings.Split(fileSchema, ",") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["Key"]; !ok {
+		return nil, fmt.Errorf("fileSchema %q has no Key column", fileSchema)
+	}
+	return columns, nil
+}
+
+// readInventoryFile reads the gzipped CSV data file key of bucket and
+// calls send with each row until send returns false.
+func (s *S3Store) readInventoryFile(ctx context.Context, bucket, key string, columns map[string]int, send func(inventoryRow) bool) error {
+	u, err := storage.NewStorageURL("s3://"+bucket+"/"+key, storage.WithRaw(true))
+	if err != nil {
+		return err
+	}
+	body, err := s.Read(ctx, u)
+	if err != nil {
+		return fmt.Errorf("read inventory file %s: %w", u, err)
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("read inventory file %s: %w", u, err)
+	}
+	defer gz.Close()
+
+	r := csv.NewReader(gz)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read inventory file %s: %w", u, err)
+		}
+		row, err := parseInventoryRow(record, columns)
+		if err != nil {
+			return fmt.Errorf("inventory file %s, line %d: %w", u, line, err)
+		}
+		if !send(row) {
+			return nil
+		}
+	}
+}
+
+// parseInventoryRow decodes record. Keys are URL-encoded in inventory
+// reports; missing optional columns keep their zero value, except
+// IsLatest, which defaults to true for reports without versions.
+func parseInventoryRow(record []string, columns map[string]int) (inventoryRow, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	key, err := neturl.QueryUnescape(field("Key"))
+	if err != nil {
+		return inventoryRow{}, fmt.Errorf("invalid key %q: %w", field("Key"), err)
+	}
+	row := inventoryRow{
+		key:            key,
+		versionID:      field("VersionId"),
+		isLatest:       field("IsLatest") != "false",
+		isDeleteMarker: field("IsDeleteMarker") == "true",
+		etag:           trimEtag(field("ETag")),
+		storageClass:   field("StorageClass"),
+	}
+	if v := field("Size"); v != "" {
+		if row.size, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return inventoryRow{}, fmt.Errorf("invalid size %q", v)
+		}
+	}
+	if v := field("LastModifiedDate"); v != "" {
+		if row.modTime, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return inventoryRow{}, fmt.Errorf("invalid last modified date %q", v)
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,161 @@
+package s3store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// inventorySchema is the fileSchema of the test reports.
+const inventorySchema = "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate, ETag, StorageClass"
+
+// inventoryRows are the rows of the test report of bucket "src", in report
+// order: two versions of old.txt, a deleted key and a URL-encoded one.
+var inventoryRows = [][]string{
+	{"src", "data/a.txt", "v1", "true", "false", "3", "2024-05-01T10:00:00.000Z", `"etag-a"`, "STANDARD"},
+	{"src", "data/old.txt", "v2", "true", "false", "5", "2024-05-02T10:00:00.000Z", "etag-old2", "STANDARD"},
+	{"src", "data/old.txt", "v1", "false", "false", "4", "2024-04-02T10:00:00.000Z", "etag-old1", "STANDARD"},
+	{"src", "data/gone.txt", "v3", "true", "true", "", "2024-05-03T10:00:00.000Z", "", ""},
+	{"src", "data/sub/b.txt", "v1", "true", "false", "7", "2024-05-04T10:00:00.000Z", "etag-b", "GLACIER"},
+	{"src", "data/with%20space.txt", "v1", "true", "false", "1", "2024-05-05T10:00:00.000Z", "etag-s", "STANDARD"},
+	{"src", "other/x.txt", "v1", "true", "false", "1", "2024-05-06T10:00:00.000Z", "etag-x", "STANDARD"},
+}
+
+// putInventory stores a report of inventoryRows for sourceBucket in bucket
+// "inv" and returns the manifest.
+func putInventory(t *testing.T, backend *mockS3, sourceBucket string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := csv.NewWriter(gz)
+	if err := w.WriteAll(inventoryRows); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	manifest := []byte(`{
+		"sourceBucket": "` + sourceBucket + `",
+		"destinationBucket": "arn:aws:s3:::inv",
+		"fileFormat": "CSV",
+		"fileSchema": "` + inventorySchema + `",
+		"files": [{"key": "inventory/src/data/1.csv.gz"}]
+	}`)
+	backend.makeBucket(t, "inv")
+	backend.putTestObject(t, "inv", "inventory/src/data/1.csv.gz", buf.Bytes(), nil)
+	backend.putTestObject(t, "inv", "inventory/src/manifest.json", manifest, nil)
+	return manifest
+}
+
+// TestListInventory verifies that a listing from an inventory report sends
+// the latest, not deleted, versions of the keys that match the URL, with
+// the attributes of the report, and never lists the bucket.
+func TestListInventory(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "src")
+	backend.putTestObject(t, "src", "data/live-only.txt", []byte("x"), nil)
+	putInventory(t, backend, "src")
+	store := newS3Store(t, srv)
+	inventory := storage.WithInventory("s3://inv/inventory/src/manifest.json")
+
+	u, err := storage.NewStorageURL("s3://src/data/*", inventory)
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	var keys []string
+	for _, obj := range drainObjects(t, store.List(context.Background(), u, false)) {
+		if obj.Err != nil {
+			t.Fatalf("List: %v", obj.Err)
+		}
+		keys = append(keys, obj.StorageURL.Path)
+		if obj.StorageURL.Path != "data/sub/b.txt" {
+			continue
+		}
+		if obj.Size != 7 || obj.Etag != "etag-b" || obj.StorageClass != "GLACIER" || obj.VersionID != "" {
+			t.Errorf("data/sub/b.txt = %+v", obj)
+		}
+		if obj.ModTime == nil || obj.ModTime.Format("2006-01-02") != "2024-05-04" {
+			t.Errorf("data/sub/b.txt modified %v, want 2024-05-04", obj.ModTime)
+		}
+	}
+	want := []string{"data/a.txt", "data/old.txt", "data/sub/b.txt", "data/with space.txt"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("listing = %v, want %v", keys, want)
+	}
+
+	if got := listKeys(t, store, "s3://src/data/*.txt", inventory, storage.WithAllVersions(true)); len(got) != 6 {
+		t.Errorf("all-versions listing = %v, want 6 versions", got)
+	}
+	if got, want := listKeys(t, store, "s3://src/data/", inventory), []string{"data/a.txt", "data/old.txt", "data/sub/", "data/with space.txt"}; !slices.Equal(got, want) {
+		t.Errorf("delimited listing = %v, want %v", got, want)
+	}
+}
+
+// TestListInventory_LocalManifest verifies that the manifest may be a local
+// file while the data files stay in the report's bucket.
+func TestListInventory_LocalManifest(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(manifest, putInventory(t, backend, "src"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := listKeys(t, newS3Store(t, srv), "s3://src/other/*", storage.WithInventory(manifest))
+	if want := []string{"other/x.txt"}; !slices.Equal(got, want) {
+		t.Errorf("listing = %v, want %v", got, want)
+	}
+}
+
+// TestListInventory_OtherBucket verifies that a report of another bucket is
+// rejected.
+func TestListInventory_OtherBucket(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	putInventory(t, backend, "elsewhere")
+	u, err := storage.NewStorageURL("s3://src/data/*", storage.WithInventory("s3://inv/inventory/src/manifest.json"))
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	got := drainObjects(t, newS3Store(t, srv).List(context.Background(), u, false))
+	if len(got) != 1 || got[0].Err == nil || !strings.Contains(got[0].Err.Error(), `lists bucket "elsewhere"`) {
+		t.Fatalf("want a single bucket mismatch error, got %v", got)
+	}
+}
+
+// TestParseInventoryRow verifies the optional columns and the errors of
+// malformed rows.
+func TestParseInventoryRow(t *testing.T) {
+	t.Parallel()
+	columns, err := inventoryColumns("Bucket, Key, Size, LastModifiedDate")
+	if err != nil {
+		t.Fatalf("inventoryColumns: %v", err)
+	}
+	row, err := parseInventoryRow([]string{"b", "a%2Bb/c+d", "12", "2024-01-02T03:04:05.000Z"}, columns)
+	if err != nil {
+		t.Fatalf("parseInventoryRow: %v", err)
+	}
+	if row.key != "a+b/c d" || row.size != 12 || !row.isLatest || row.modTime.Year() != 2024 {
+		t.Errorf("row = %+v", row)
+	}
+	for _, record := range [][]string{
+		{"b", "k", "twelve", ""},
+		{"b", "k", "1", "yesterday"},
+		{"b", "%zz", "1", ""},
+	} {
+		if _, err := parseInventoryRow(record, columns); err == nil {
+			t.Errorf("parseInventoryRow(%q) succeeded, want an error", record)
+		}
+	}
+	if _, err := inventoryColumns("Bucket, Size"); err == nil {
+		t.Error("inventoryColumns without Key succeeded, want an error")
+	}
+}
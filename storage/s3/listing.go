@@ -0,0 +1,203 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// replay sends the objects of a listing that was not read from the bucket,
+// an S3 Inventory report or a saved listing, the way a live listing of url
+// would: keys must match url, and a delimiter collapses the keys below the
+// prefix into directories.
+type replay struct {
+	ctx   context.Context
+	url   *storage.StorageURL
+	objCh chan<- *storage.Object
+	dirs  map[string]bool
+	found bool
+}
+
+func newReplay(ctx context.Context, url *storage.StorageURL, objCh chan<- *storage.Object) *replay {
+	return &replay{ctx: ctx, url: url, objCh: objCh, dirs: map[string]bool{}}
+}
+
+// send sends obj as the object at key, or the directory holding key once.
+// obj gets its own copy of the URL, with obj.VersionID set on it. send
+// returns false once ctx is done.
+func (r *replay) send(key string, obj *storage.Object) bool {
+	url := r.url
+	if url.Delimiter != "" {
+		rest := strings.TrimPrefix(key, url.Prefix)
+		if i := strings.Index(rest, url.Delimiter); i >= 0 && strings.HasPrefix(key, url.Prefix) {
+			dir := url.Prefix + rest[:i+len(url.Delimiter)]
+			if r.dirs[dir] || !url.Match(dir) {
+				return true
+			}
+			r.dirs[dir] = true
+			newURL := url.Clone()
+			newURL.Path = dir
+			r.found = true
+			sendObject(r.ctx, &storage.Object{
+				StorageURL: newURL,
+				Type:       storage.NewObjectType(os.ModeDir | 0o755),
+			}, r.objCh)
+			return r.ctx.Err() == nil
+		}
+	}
+	if !url.Match(key) {
+		return true
+	}
+	r.found = true
+	newURL := url.Clone()
+	newURL.Path = key
+	newURL.VersionID = obj.VersionID
+	obj.StorageURL = newURL
+	sendObject(r.ctx, obj, r.objCh)
+	return r.ctx.Err() == nil
+}
+
+// finish sends ErrNoObjectFound when nothing was sent.
+func (r *replay) finish() {
+	if !r.found {
+		sendObject(r.ctx, &storage.Object{Err: errorpkg.ErrNoObjectFound}, r.objCh)
+	}
+}
+
+// listSaved sends the objects of the listing ls --save-listing saved at the
+// path url.Listing() names instead of listing the bucket. The listing must
+// be of url's bucket and of a prefix that holds url's.
+//
+// When url asks for a check, checkListing compares a sample of the listing
+// with the bucket before anything is sent.
+func (s *S3Store) listSaved(ctx context.Context, url *storage.StorageURL) <-chan *storage.Object {
+	objCh := make(chan *storage.Object)
+
+	go func() {
+		defer close(objCh)
+
+		path, check := url.Listing()
+		if url.AllVersions || url.VersionID != "" {
+			sendObject(ctx, &storage.Object{Err: fmt.Errorf("listing %s holds no versions", path)}, objCh)
+			return
+		}
+		lr, err := openListing(path, url)
+		if err != nil {
+			sendObject(ctx, &storage.Object{Err: err}, objCh)
+			return
+		}
+		defer lr.Close()
+		if check > 0 {
+			if err := s.checkListing(ctx, url, path, check); err != nil {
+				sendObject(ctx, &storage.Object{Err: err}, objCh)
+				return
+			}
+		}
+
+		r := newReplay(ctx, url, objCh)
+		for {
+			obj, err := lr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				sendObject(ctx, &storage.Object{Err: err}, objCh)
+				return
+			}
+			if !r.send(obj.StorageURL.Path, obj) {
+				return
+			}
+		}
+		r.finish()
+	}()
+
+	return objCh
+}
+
+// openListing opens the listing at path and checks that it covers url: url
+// must be below the listed prefix, or be the listed wildcard itself.
+func openListing(path string, url *storage.StorageURL) (*storage.ListingReader, error) {
+	lr, err := storage.OpenListing(path)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := storage.NewStorageURL(lr.Header.URL)
+	switch {
+	case err != nil:
+	case listed.IsWildcard() && lr.Header.URL != url.Absolute():
+		// A wildcard listing holds the matches of its pattern only.
+		err = fmt.Errorf("listing %s is of %s, which only holds that pattern, not %s", path, lr.Header.URL, url)
+	case listed.Bucket != url.Bucket || !strings.HasPrefix(url.Prefix, listed.Prefix):
+		err = fmt.Errorf("listing %s is of %s, which does not hold %s", path, lr.Header.URL, url)
+	}
+	if err != nil {
+		lr.Close()
+		return nil, err
+	}
+	return lr, nil
+}
+
+// checkListing compares n of the saved objects that match url, spread
+// evenly over the listing, with the bucket. It reports the first one that
+// is gone or whose size or ETag changed since the listing was saved; keys
+// written since are not detected.
+func (s *S3Store) checkListing(ctx context.Context, url *storage.StorageURL, path string, n int) error {
+	// each calls fn with every saved object that matches url.
+	each := func(fn func(*storage.Object) (bool, error)) error {
+		lr, err := openListing(path, url)
+		if err != nil {
+			return err
+		}
+		defer lr.Close()
+		match := url.Clone()
+		for {
+			obj, err := lr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !match.Match(obj.StorageURL.Path) {
+				continue
+			}
+			if more, err := fn(obj); !more || err != nil {
+				return err
+			}
+		}
+	}
+
+	count := 0
+	if err := each(func(*storage.Object) (bool, error) { count++; return true, nil }); err != nil {
+		return err
+	}
+	n = min(n, count)
+	i, checked := 0, 0
+	return each(func(obj *storage.Object) (bool, error) {
+		if checked == n {
+			return false, nil
+		}
+		i++
+		if i-1 != checked*count/n {
+			return true, nil
+		}
+		checked++
+		live, err := s.Stat(ctx, obj.StorageURL)
+		if errors.Is(err, errorpkg.ErrGivenObjectNotFound) {
+			return false, fmt.Errorf("listing %s is stale: %s no longer exists", path, obj.StorageURL)
+		}
+		if err != nil {
+			return false, err
+		}
+		if live.Size != obj.Size || live.Etag != obj.Etag {
+			return false, fmt.Errorf("listing %s is stale: %s changed since it was saved", path, obj.StorageURL)
+		}
+		return true, nil
+	})
+}
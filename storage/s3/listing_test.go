@@ -0,0 +1,152 @@
+package s3store
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// saveListing lists rawURL live and saves the objects to a listing of
+// rawURL, as ls --save-listing does, returning its path.
+func saveListing(t *testing.T, store *S3Store, rawURL string) string {
+	t.Helper()
+	u, err := storage.NewStorageURL(rawURL)
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "listing.jsonl")
+	lw, err := storage.CreateListing(path, u, time.Now())
+	if err != nil {
+		t.Fatalf("CreateListing: %v", err)
+	}
+	defer lw.Close()
+	listURL := u.Clone()
+	listURL.Delimiter = ""
+	for _, obj := range drainObjects(t, store.List(context.Background(), listURL, false)) {
+		if obj.Err != nil {
+			t.Fatalf("List: %v", obj.Err)
+		}
+		if err := lw.Write(obj); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := lw.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return path
+}
+
+// listSavedErr lists rawURL from the listing at path and returns the error
+// the listing ended with, if any.
+func listSavedErr(t *testing.T, store *S3Store, rawURL, path string, check int) error {
+	t.Helper()
+	u, err := storage.NewStorageURL(rawURL, storage.WithListing(path, check))
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	for _, obj := range drainObjects(t, store.List(context.Background(), u, false)) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+	}
+	return nil
+}
+
+// TestListSaved verifies that a saved listing stands in for the live one:
+// keys written since are not listed, and the URL's wildcard and delimiter
+// apply to the saved keys.
+func TestListSaved(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "src")
+	for _, k := range []string{"data/a.txt", "data/b.log", "data/sub/c.txt", "other/d.txt"} {
+		backend.putTestObject(t, "src", k, []byte("x"), nil)
+	}
+	store := newS3Store(t, srv)
+	path := saveListing(t, store, "s3://src/data/")
+	backend.putTestObject(t, "src", "data/new.txt", []byte("x"), nil)
+
+	for rawURL, want := range map[string][]string{
+		"s3://src/data/*":        {"data/a.txt", "data/b.log", "data/sub/c.txt"},
+		"s3://src/data/*.txt":    {"data/a.txt", "data/sub/c.txt"},
+		"s3://src/data/":         {"data/a.txt", "data/b.log", "data/sub/"},
+		"s3://src/data/sub/*":    {"data/sub/c.txt"},
+		"s3://src/data/sub/*.gz": nil,
+	} {
+		if want == nil {
+			if err := listSavedErr(t, store, rawURL, path, 0); err == nil {
+				t.Errorf("%s: want ErrNoObjectFound", rawURL)
+			}
+			continue
+		}
+		if got := listKeys(t, store, rawURL, storage.WithListing(path, 0)); !slices.Equal(got, want) {
+			t.Errorf("%s: listing = %v, want %v", rawURL, got, want)
+		}
+	}
+
+	for _, rawURL := range []string{"s3://src/other/*", "s3://elsewhere/data/*"} {
+		if err := listSavedErr(t, store, rawURL, path, 0); err == nil || !strings.Contains(err.Error(), "does not hold") {
+			t.Errorf("%s: error = %v, want the listing not to hold it", rawURL, err)
+		}
+	}
+}
+
+// TestListSaved_Wildcard verifies that a listing of a wildcard only stands
+// in for that same wildcard, not for the prefix it is below.
+func TestListSaved_Wildcard(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "src")
+	for _, k := range []string{"logs/a.gz", "logs/b.txt"} {
+		backend.putTestObject(t, "src", k, []byte("x"), nil)
+	}
+	store := newS3Store(t, srv)
+	path := saveListing(t, store, "s3://src/logs/*.gz")
+
+	if got, want := listKeys(t, store, "s3://src/logs/*.gz", storage.WithListing(path, 0)), []string{"logs/a.gz"}; !slices.Equal(got, want) {
+		t.Errorf("listing = %v, want %v", got, want)
+	}
+	for _, rawURL := range []string{"s3://src/logs/", "s3://src/logs/*"} {
+		if err := listSavedErr(t, store, rawURL, path, 0); err == nil || !strings.Contains(err.Error(), "only holds that pattern") {
+			t.Errorf("%s: error = %v, want the wildcard listing refused", rawURL, err)
+		}
+	}
+}
+
+// TestListSaved_Check verifies that --check-listing accepts a listing that
+// matches the bucket and refuses one whose objects changed or are gone.
+func TestListSaved_Check(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "src")
+	for _, k := range []string{"data/a.txt", "data/b.txt", "data/c.txt"} {
+		backend.putTestObject(t, "src", k, []byte("x"), nil)
+	}
+	store := newS3Store(t, srv)
+	path := saveListing(t, store, "s3://src/data/")
+
+	if err := listSavedErr(t, store, "s3://src/data/*", path, 10); err != nil {
+		t.Fatalf("fresh listing refused: %v", err)
+	}
+	// A check of one object looks at the first one only.
+	backend.putTestObject(t, "src", "data/c.txt", []byte("changed"), nil)
+	if err := listSavedErr(t, store, "s3://src/data/*", path, 1); err != nil {
+		t.Errorf("check of data/a.txt refused the listing: %v", err)
+	}
+	if err := listSavedErr(t, store, "s3://src/data/*", path, 3); err == nil || !strings.Contains(err.Error(), "data/c.txt changed") {
+		t.Errorf("error = %v, want data/c.txt changed", err)
+	}
+
+	u, _ := storage.NewStorageURL("s3://src/data/a.txt")
+	if err := store.Delete(context.Background(), u); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := listSavedErr(t, store, "s3://src/data/a.txt", path, 1); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("error = %v, want data/a.txt no longer exists", err)
+	}
+}
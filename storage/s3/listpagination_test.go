@@ -7,24 +7,29 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // pagingListServer is a minimal ListObjectsV2 endpoint that always serves
 // two pages ("a.txt", then "b.txt") via NextContinuationToken. It records
-// the max-keys query parameter of the first request and counts list
-// requests, so tests can pin the --page-size and --no-paginate wiring of
+// the max-keys and start-after query parameters of the first request and
+// counts list requests, so tests can pin the --page-size and --no-paginate wiring of
 // ListObjectsWithPagination. The mockS3 backend cannot be used here: its V2
 // handler never paginates.
 func pagingListServer(t *testing.T) (*httptest.Server, *struct {
-	mu       sync.Mutex
-	requests int
-	maxKeys  string
+	mu         sync.Mutex
+	requests   int
+	maxKeys    string
+	startAfter string
 }) {
 	t.Helper()
 	state := &struct {
-		mu       sync.Mutex
-		requests int
-		maxKeys  string
+		mu         sync.Mutex
+		requests   int
+		maxKeys    string
+		startAfter string
 	}{}
 
 	type contents struct {
@@ -44,6 +49,7 @@ func pagingListServer(t *testing.T) (*httptest.Server, *struct {
 		state.requests++
 		if state.requests == 1 {
 			state.maxKeys = r.URL.Query().Get("max-keys")
+			state.startAfter = r.URL.Query().Get("start-after")
 		}
 		token := r.URL.Query().Get("continuation-token")
 		state.mu.Unlock()
@@ -117,3 +123,30 @@ func TestListObjectsWithPagination_PageSizeAndNoPaginate(t *testing.T) {
 		}
 	})
 }
+
+// TestListObjectPages verifies that startAfter is sent as start-after and
+// that the listing stops as soon as fn returns false.
+func TestListObjectPages(t *testing.T) {
+	t.Parallel()
+	srv, state := pagingListServer(t)
+	store := newS3Store(t, srv)
+	var keys []string
+	err := store.ListObjectPages(context.Background(), "paging-bucket", "", "", "0.txt", 0, func(objects []types.Object, _ []types.CommonPrefix) bool {
+		for _, obj := range objects {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+		return false
+	})
+	if err != nil {
+		t.Fatalf("ListObjectPages: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "a.txt" {
+		t.Errorf("keys: want only the first page [a.txt], got %v", keys)
+	}
+	if state.requests != 1 {
+		t.Errorf("requests: want 1, got %d", state.requests)
+	}
+	if state.startAfter != "0.txt" {
+		t.Errorf("start-after: want %q, got %q", "0.txt", state.startAfter)
+	}
+}
@@ -0,0 +1,280 @@
+package s3store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// shardBuffer is the number of objects an ordered shard lists ahead of the
+// merge, one ListObjectsV2 page. At most listShards shards are open at a
+// time, which bounds the memory of an ordered sharded listing to about
+// listShards pages.
+const shardBuffer = 1000
+
+// shardAlphabet is the characters a key range is split at when the key
+// space below a prefix is flat, in byte order.
+const shardAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// listShard is one unit of a sharded listing: the keys below prefix that
+// sort after startAfter and, when end is set, not after end. A shard with
+// listed set was already listed while the shards were planned and only
+// replays objects.
+type listShard struct {
+	prefix     string
+	startAfter string
+	end        string
+	listed     bool
+	objects    []types.Object
+}
+
+// listSharded is the parallel variant of listObjectsV2 for recursive
+// listings of very large prefixes. planShards splits the key space below
+// url.Prefix into shards, which s.listShards workers list concurrently.
+//
+// Shards cover consecutive key ranges, so the objects are sent in key
+// order by draining the shards one after the other while up to
+// s.listShards shards, the one drained included, list ahead; a URL
+// created WithUnordered sends them as they arrive instead. The first error cancels the remaining shards and is sent
+// last.
+func (s *S3Store) listSharded(ctx context.Context, url *storage.StorageURL) <-chan *storage.Object {
+	objCh := make(chan *storage.Object)
+
+	go func() {
+		defer close(objCh)
+
+		listCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		shards, err := s.planShards(listCtx, url)
+		if err != nil {
+			sendObject(ctx, &storage.Object{Err: err}, objCh)
+			return
+		}
+
+		ordered := !url.Unordered()
+		type shardTask struct {
+			i   int
+			out chan *storage.Object
+		}
+		next := make(chan shardTask)
+		// order receives the channel of every open shard, in key order.
+		// A shard is open from the time the feeder hands it out until the
+		// merge drained it; open holds a slot for each.
+		order := make(chan chan *storage.Object, s.listShards)
+		open := make(chan struct{}, s.listShards)
+
+		// The feeder hands out the shards in key order, so the lowest
+		// shard the merge waits for always has a worker.
+		go func() {
+			defer close(next)
+			defer close(order)
+			for i := range shards {
+				out := objCh
+				if ordered {
+					select {
+					case open <- struct{}{}:
+					case <-listCtx.Done():
+						return
+					}
+					out = make(chan *storage.Object, shardBuffer)
+					order <- out
+				}
+				select {
+				case next <- shardTask{i: i, out: out}:
+				case <-listCtx.Done():
+					if ordered {
+						close(out)
+					}
+					return
+				}
+			}
+		}()
+
+		var (
+			wg       sync.WaitGroup
+			found    atomic.Bool
+			errOnce  sync.Once
+			firstErr error
+		)
+		for range min(s.listShards, len(shards)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for task := range next {
+					err := s.listShard(listCtx, url, shards[task.i], func(obj *storage.Object) {
+						found.Store(true)
+						sendObject(listCtx, obj, task.out)
+					})
+					if ordered {
+						close(task.out)
+					}
+					if err != nil {
+						errOnce.Do(func() { firstErr = err })
+						cancel()
+					}
+				}
+			}()
+		}
+
+		if ordered {
+			for ch := range order {
+				for obj := range ch {
+					sendObject(listCtx, obj, objCh)
+				}
+				<-open
+			}
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			sendObject(ctx, &storage.Object{Err: firstErr}, objCh)
+			return
+		}
+		if !found.Load() {
+			sendObject(ctx, &storage.Object{Err: errorpkg.ErrNoObjectFound}, objCh)
+		}
+	}()
+
+	return objCh
+}
+
+// planShards splits the keys below url.Prefix into shards in key order.
+//
+// It lists the prefix with the "/" delimiter: every common prefix becomes
+// a shard and the objects next to them are kept as they were listed. A
+// prefix holding a single common prefix and nothing else is descended
+// into. When a page of the delimiter listing comes back without common
+// prefixes, the key space is flat and listing it with the delimiter would
+// list it sequentially, so the rest of it is split into key ranges at
+// shardAlphabet, listed with StartAfter, instead.
+func (s *S3Store) planShards(ctx context.Context, url *storage.StorageURL) ([]listShard, error) {
+	prefix := url.Prefix
+	for {
+		var (
+			shards   []listShard
+			objects  []types.Object
+			prefixes int
+			last     string
+		)
+		flushObjects := func() {
+			if len(objects) > 0 {
+				shards = append(shards, listShard{listed: true, objects: objects})
+				objects = nil
+			}
+		}
+
+		paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+			Bucket:       aws.String(url.Bucket),
+			Prefix:       aws.String(prefix),
+			Delimiter:    aws.String("/"),
+			RequestPayer: s.requestPayer(),
+		})
+		flat := false
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// Contents and CommonPrefixes are each sorted; merge them so
+			// the shards stay in key order.
+			contents, common := page.Contents, page.CommonPrefixes
+			for len(contents) > 0 || len(common) > 0 {
+				if len(common) == 0 || (len(contents) > 0 && aws.ToString(contents[0].Key) < aws.ToString(common[0].Prefix)) {
+					objects = append(objects, contents[0])
+					last = aws.ToString(contents[0].Key)
+					contents = contents[1:]
+					continue
+				}
+				flushObjects()
+				cp := aws.ToString(common[0].Prefix)
+				shards = append(shards, listShard{prefix: cp})
+				prefixes++
+				common = common[1:]
+			}
+			if len(page.CommonPrefixes) == 0 && paginator.HasMorePages() {
+				flat = true
+				break
+			}
+		}
+		flushObjects()
+
+		if flat {
+			return append(shards, splitKeyRange(prefix, last, s.listShards)...), nil
+		}
+		if prefixes == 1 && len(shards) == 1 {
+			prefix = shards[0].prefix
+			continue
+		}
+		return shards, nil
+	}
+}
+
+// splitKeyRange splits the keys below prefix that sort after startAfter
+// into consecutive ranges at the characters of shardAlphabet, about four
+// per worker so that uneven ranges still keep every worker busy.
+func splitKeyRange(prefix, startAfter string, workers int) []listShard {
+	n := min(4*workers, len(shardAlphabet))
+	var bounds []string
+	for i := 1; i < n; i++ {
+		b := prefix + string(shardAlphabet[i*len(shardAlphabet)/n])
+		if b > startAfter {
+			bounds = append(bounds, b)
+		}
+	}
+	shards := make([]listShard, 0, len(bounds)+1)
+	lo := startAfter
+	for _, b := range bounds {
+		shards = append(shards, listShard{prefix: prefix, startAfter: lo, end: b})
+		lo = b
+	}
+	return append(shards, listShard{prefix: prefix, startAfter: lo})
+}
+
+// listShard lists sh recursively and calls emit with every object that
+// matches url.
+func (s *S3Store) listShard(ctx context.Context, url *storage.StorageURL, sh listShard, emit func(*storage.Object)) error {
+	// Match records the relative path on the URL it is called on, so each
+	// shard matches against its own copy.
+	url = url.Clone()
+	send := func(obj types.Object) {
+		if key := aws.ToString(obj.Key); url.Match(key) {
+			emit(objectFromListing(url, obj))
+		}
+	}
+	if sh.listed {
+		for _, obj := range sh.objects {
+			send(obj)
+		}
+		return nil
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(url.Bucket),
+		Prefix:       aws.String(sh.prefix),
+		RequestPayer: s.requestPayer(),
+	}
+	if sh.startAfter != "" {
+		input.StartAfter = aws.String(sh.startAfter)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			if sh.end != "" && aws.ToString(obj.Key) > sh.end {
+				return nil
+			}
+			send(obj)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/s6cmd/internal/errorpkg"
+	"github.com/LinPr/s6cmd/storage"
+)
+
+// listKeys lists rawURL and returns the listed keys in the order they were
+// sent, failing the test on any error.
+func listKeys(t *testing.T, store *S3Store, rawURL string, opts ...storage.Option) []string {
+	t.Helper()
+	u, err := storage.NewStorageURL(rawURL, opts...)
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	var keys []string
+	for _, obj := range drainObjects(t, store.List(context.Background(), u, false)) {
+		if obj.Err != nil {
+			t.Fatalf("List(%s): %v", rawURL, obj.Err)
+		}
+		keys = append(keys, obj.StorageURL.Path)
+	}
+	return keys
+}
+
+// TestListSharded verifies that a sharded listing sends the same objects as
+// the sequential one, in key order unless the URL is unordered, for a key
+// space with prefixes, a flat one that is split into key ranges and one
+// with a single prefix that is descended into.
+func TestListSharded(t *testing.T) {
+	t.Parallel()
+
+	layouts := map[string][]string{
+		"prefixes": {
+			"data/2024/a.txt", "data/2024/b.txt", "data/2025/c.txt",
+			"data/2025/d/e.txt", "data/readme", "data/z/f.txt", "data/zz",
+		},
+		"nested": {"data/deep/x/1", "data/deep/x/2", "data/deep/y/3"},
+		"flat": {
+			"data/0001", "data/0002", "data/1abc", "data/Apple", "data/K",
+			"data/Zulu", "data/a", "data/m/n.txt", "data/q", "data/zz",
+		},
+	}
+	for name, keys := range layouts {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			srv, backend := newMockS3Server(t)
+			backend.makeBucket(t, "shards")
+			for _, k := range append(keys, "other/x.txt") {
+				backend.putTestObject(t, "shards", k, []byte("x"), nil)
+			}
+			// Two entries per page: the flat layout's first delimiter
+			// page holds no common prefix, which switches to key ranges.
+			backend.mu.Lock()
+			backend.listMaxKeys = 2
+			backend.mu.Unlock()
+
+			sequential := listKeys(t, newS3Store(t, srv), "s3://shards/data/*")
+			if !slices.Equal(sequential, keys) {
+				t.Fatalf("sequential listing = %v, want %v", sequential, keys)
+			}
+			sharded := newS3Store(t, srv, func(o *S3Option) { o.ListShards = 3 })
+			if got := listKeys(t, sharded, "s3://shards/data/*"); !slices.Equal(got, keys) {
+				t.Errorf("ordered sharded listing = %v, want %v", got, keys)
+			}
+			got := listKeys(t, sharded, "s3://shards/data/*", storage.WithUnordered(true))
+			slices.Sort(got)
+			if !slices.Equal(got, keys) {
+				t.Errorf("unordered sharded listing = %v, want %v in any order", got, keys)
+			}
+		})
+	}
+}
+
+// TestListSharded_Bounded verifies that an ordered sharded listing lists
+// no more than about ListShards shards ahead of a consumer that stopped
+// reading, and still sends every key in order.
+func TestListSharded_Bounded(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "shards")
+	var keys []string
+	for i := range 40 {
+		k := fmt.Sprintf("data/p%02d/x", i)
+		keys = append(keys, k)
+		backend.putTestObject(t, "shards", k, []byte("x"), nil)
+	}
+	store := newS3Store(t, srv, func(o *S3Option) { o.ListShards = 2 })
+	u, err := storage.NewStorageURL("s3://shards/data/*")
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	objCh := store.List(context.Background(), u, false)
+	first := <-objCh
+	time.Sleep(200 * time.Millisecond)
+
+	backend.mu.Lock()
+	listed := 0
+	for _, req := range backend.requests {
+		if strings.Contains(req, "list-type=2") && !strings.Contains(req, "delimiter=") {
+			listed++
+		}
+	}
+	backend.mu.Unlock()
+	if listed > 4 {
+		t.Errorf("%d shards listed ahead of the consumer, want at most 4", listed)
+	}
+
+	got := []string{first.StorageURL.Path}
+	for _, obj := range drainObjects(t, objCh) {
+		if obj.Err != nil {
+			t.Fatalf("List: %v", obj.Err)
+		}
+		got = append(got, obj.StorageURL.Path)
+	}
+	if !slices.Equal(got, keys) {
+		t.Errorf("listing = %v, want %v", got, keys)
+	}
+}
+
+// TestListSharded_Filter verifies that the wildcard of the URL still
+// filters the keys of every shard.
+func TestListSharded_Filter(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "shards")
+	for _, k := range []string{"logs/a/1.gz", "logs/a/2.txt", "logs/b/3.gz", "logs/4.gz"} {
+		backend.putTestObject(t, "shards", k, []byte("x"), nil)
+	}
+	store := newS3Store(t, srv, func(o *S3Option) { o.ListShards = 4 })
+	want := []string{"logs/4.gz", "logs/a/1.gz", "logs/b/3.gz"}
+	if got := listKeys(t, store, "s3://shards/logs/*.gz"); !slices.Equal(got, want) {
+		t.Errorf("listing = %v, want %v", got, want)
+	}
+}
+
+// TestListSharded_NoObject verifies that an empty prefix reports
+// ErrNoObjectFound like the sequential listing.
+func TestListSharded_NoObject(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	backend.makeBucket(t, "shards")
+	store := newS3Store(t, srv, func(o *S3Option) { o.ListShards = 4 })
+	u, err := storage.NewStorageURL("s3://shards/missing/*")
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	got := drainObjects(t, store.List(context.Background(), u, false))
+	if len(got) != 1 || !errors.Is(got[0].Err, errorpkg.ErrNoObjectFound) {
+		t.Fatalf("want a single ErrNoObjectFound, got %v", got)
+	}
+}
+
+// TestSplitKeyRange verifies that the key ranges start after startAfter,
+// follow each other without gaps and leave the last one open.
+func TestSplitKeyRange(t *testing.T) {
+	t.Parallel()
+	shards := splitKeyRange("p/", "p/K", 2)
+	if len(shards) == 0 || shards[0].startAfter != "p/K" {
+		t.Fatalf("first range must start after p/K, got %+v", shards)
+	}
+	for i := 1; i < len(shards); i++ {
+		if shards[i].startAfter != shards[i-1].end || shards[i].startAfter <= shards[i-1].startAfter {
+			t.Errorf("range %d %+v does not follow %+v", i, shards[i], shards[i-1])
+		}
+	}
+	if last := shards[len(shards)-1]; last.end != "" {
+		t.Errorf("last range must be open, ends at %q", last.end)
+	}
+}
@@ -42,6 +42,8 @@ type mockS3 struct {
 	contentType map[string]map[string]string
 	// modTime maps bucket/key → last modification time.
 	modTime map[string]map[string]time.Time
+	// tags maps bucket/key → the tag set sent in x-amz-tagging.
+	tags map[string]map[string]url.Values
 	// buckets records bucket existence and creation time.
 	buckets map[string]time.Time
 	// multipart uploads indexed by upload id.
@@ -50,6 +52,9 @@ type mockS3 struct {
 	// requests records every request (method + path + host) seen by the
 	// handler. Tests assert addressing style by inspecting this slice.
 	requests []string
+	// checksumModes records the x-amz-checksum-mode header of every
+	// HeadObject request, "" when it was not sent.
+	checksumModes []string
 
 	// srvURL is the base URL of the httptest server; set by
 	// newMockS3Server so putTestObject can reach the mock without the
@@ -57,7 +62,8 @@ type mockS3 struct {
 	srvURL string
 
 	// listMaxKeys, when > 0, caps the number of Contents entries per
-	// ListObjects (V1) page so tests can force pagination. V1 responses
+	// ListObjects (V1) page, and of Contents and CommonPrefixes entries
+	// per ListObjectsV2 page, so tests can force pagination. V1 responses
 	// mimic real S3: NextMarker is only emitted when a delimiter was
 	// supplied, so clients must fall back to the last Contents key.
 	listMaxKeys int
@@ -86,6 +92,7 @@ func newMockS3() *mockS3 {
 		metadata:    map[string]map[string]map[string]string{},
 		contentType: map[string]map[string]string{},
 		modTime:     map[string]map[string]time.Time{},
+		tags:        map[string]map[string]url.Values{},
 		buckets:     map[string]time.Time{},
 		multipart:   map[string]*mockMultipart{},
 	}
@@ -177,6 +184,8 @@ func (m *mockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// The SDK's V2 ListObjects always sends list-type=2, so a bare
 			// GET /bucket is the legacy ListObjects (V1) shape.
 			m.handleListObjectsV1(w, r, bucket)
+		case q.Has("tagging"):
+			m.handleGetObjectTagging(w, r, bucket, key)
 		default:
 			m.handleGetObject(w, r, bucket, key)
 		}
@@ -295,6 +304,14 @@ func (m *mockS3) handleListObjectsV2(w http.ResponseWriter, r *http.Request, buc
 
 	prefix := r.URL.Query().Get("prefix")
 	delimiter := r.URL.Query().Get("delimiter")
+	// Entries at or before start-after, or before the continuation token
+	// (the last entry of the previous page), are skipped; a common prefix
+	// as the token skips every key below it.
+	after := r.URL.Query().Get("start-after")
+	token := r.URL.Query().Get("continuation-token")
+	if token > after {
+		after = token
+	}
 
 	type contentXML struct {
 		Key          string `xml:"Key"`
@@ -307,14 +324,15 @@ func (m *mockS3) handleListObjectsV2(w http.ResponseWriter, r *http.Request, buc
 		Prefix string `xml:"Prefix"`
 	}
 	type result struct {
-		XMLName        xml.Name          `xml:"ListBucketResult"`
-		Name           string            `xml:"Name"`
-		Prefix         string            `xml:"Prefix"`
-		Delimiter      string            `xml:"Delimiter,omitempty"`
-		IsTruncated    bool              `xml:"IsTruncated"`
-		KeyCount       int               `xml:"KeyCount"`
-		Contents       []contentXML      `xml:"Contents"`
-		CommonPrefixes []commonPrefixXML `xml:"CommonPrefixes"`
+		XMLName               xml.Name          `xml:"ListBucketResult"`
+		Name                  string            `xml:"Name"`
+		Prefix                string            `xml:"Prefix"`
+		Delimiter             string            `xml:"Delimiter,omitempty"`
+		IsTruncated           bool              `xml:"IsTruncated"`
+		NextContinuationToken string            `xml:"NextContinuationToken,omitempty"`
+		KeyCount              int               `xml:"KeyCount"`
+		Contents              []contentXML      `xml:"Contents"`
+		CommonPrefixes        []commonPrefixXML `xml:"CommonPrefixes"`
 	}
 
 	res := result{Name: bucket, Prefix: prefix, Delimiter: delimiter}
@@ -324,12 +342,24 @@ func (m *mockS3) handleListObjectsV2(w http.ResponseWriter, r *http.Request, buc
 		if prefix != "" && !strings.HasPrefix(k, prefix) {
 			continue
 		}
+		if k <= after {
+			continue
+		}
+		if delimiter != "" && strings.HasSuffix(token, delimiter) && strings.HasPrefix(k, token) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
 	common := map[string]struct{}{}
+	last := ""
 	for _, k := range keys {
+		if m.listMaxKeys > 0 && len(res.Contents)+len(common) == m.listMaxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = last
+			break
+		}
 		if delimiter != "" {
 			// Compute the common prefix: everything after the prefix up to
 			// and including the next delimiter.
@@ -337,9 +367,11 @@ func (m *mockS3) handleListObjectsV2(w http.ResponseWriter, r *http.Request, buc
 			if idx := strings.Index(rest, delimiter); idx >= 0 {
 				cp := prefix + rest[:idx+1]
 				common[cp] = struct{}{}
+				last = cp
 				continue
 			}
 		}
+		last = k
 		res.Contents = append(res.Contents, contentXML{
 			Key:          k,
 			LastModified: m.modTime[bucket][k].UTC().Format(time.RFC3339),
@@ -459,6 +491,7 @@ func (m *mockS3) handleListObjectsV1(w http.ResponseWriter, r *http.Request, buc
 func (m *mockS3) handleHeadObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.checksumModes = append(m.checksumModes, r.Header.Get("x-amz-checksum-mode"))
 	objs, ok := m.objects[bucket]
 	if !ok {
 		writeS3Error(w, http.StatusNotFound, "NotFound", "bucket not found")
@@ -482,6 +515,36 @@ func (m *mockS3) handleHeadObject(w http.ResponseWriter, r *http.Request, bucket
 	w.WriteHeader(http.StatusOK)
 }
 
+// --- GetObjectTagging ---
+
+func (m *mockS3) handleGetObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[bucket][key]; !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "key not found")
+		return
+	}
+	type tag struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	}
+	type result struct {
+		XMLName xml.Name `xml:"Tagging"`
+		TagSet  []tag    `xml:"TagSet>Tag"`
+	}
+	var out result
+	tags := m.tags[bucket][key]
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out.TagSet = append(out.TagSet, tag{Key: k, Value: tags.Get(k)})
+	}
+	writeXML(w, http.StatusOK, out)
+}
+
 // --- GetObject ---
 
 func (m *mockS3) handleGetObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
@@ -621,6 +684,9 @@ func (m *mockS3) handlePutObject(w http.ResponseWriter, r *http.Request, bucket,
 		m.contentType[bucket] = map[string]string{}
 		m.modTime[bucket] = map[string]time.Time{}
 	}
+	if m.tags[bucket] == nil {
+		m.tags[bucket] = map[string]url.Values{}
+	}
 	m.objects[bucket][key] = body
 	m.contentType[bucket][key] = r.Header.Get("Content-Type")
 	m.modTime[bucket][key] = time.Now().UTC()
@@ -634,6 +700,8 @@ func (m *mockS3) handlePutObject(w http.ResponseWriter, r *http.Request, bucket,
 		}
 	}
 	m.metadata[bucket][key] = md
+	tags, _ := url.ParseQuery(r.Header.Get("x-amz-tagging"))
+	m.tags[bucket][key] = tags
 	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(body)))
 	w.WriteHeader(http.StatusOK)
 }
@@ -726,6 +794,7 @@ func (m *mockS3) handleDeleteObject(w http.ResponseWriter, r *http.Request, buck
 	delete(m.metadata[bucket], key)
 	delete(m.contentType[bucket], key)
 	delete(m.modTime[bucket], key)
+	delete(m.tags[bucket], key)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -63,14 +63,26 @@ func (s *S3Store) Stat(ctx context.Context, url *storage.StorageURL) (*storage.O
 
 // List is a non-blocking S3 list operation which paginates and filters S3
 // keys. If no object is found or an error is encountered during listing, it
-// is sent to the returned channel as an Object with Err set.
+// is sent to the returned channel as an Object with Err set. Recursive
+// listings (no delimiter) are sharded when the store was created with
+// ListShards > 1, and a URL created WithInventory or WithListing is listed
+// from the S3 Inventory report or the saved listing instead.
 func (s *S3Store) List(ctx context.Context, url *storage.StorageURL, _ bool) <-chan *storage.Object {
+	if url.Inventory() != "" {
+		return s.listInventory(ctx, url)
+	}
+	if path, _ := url.Listing(); path != "" {
+		return s.listSaved(ctx, url)
+	}
 	if url.VersionID != "" || url.AllVersions {
 		return s.listObjectVersions(ctx, url)
 	}
 	if s.useListObjectsV1 {
 		return s.listObjects(ctx, url)
 	}
+	if s.listShards > 1 && url.Delimiter == "" && !url.IsRaw() {
+		return s.listSharded(ctx, url)
+	}
 	return s.listObjectsV2(ctx, url)
 }
 
@@ -115,21 +127,11 @@ func (s *S3Store) listObjectsV2(ctx context.Context, url *storage.StorageURL) <-
 			}
 
 			for _, obj := range page.Contents {
-				key := aws.ToString(obj.Key)
-				if !url.Match(key) {
+				if !url.Match(aws.ToString(obj.Key)) {
 					continue
 				}
 				objectFound = true
-				mod := aws.ToTime(obj.LastModified)
-				newURL := url.Clone()
-				newURL.Path = key
-				sendObject(ctx, &storage.Object{
-					StorageURL:   newURL,
-					Etag:         trimEtag(aws.ToString(obj.ETag)),
-					ModTime:      &mod,
-					Size:         aws.ToInt64(obj.Size),
-					StorageClass: storage.StorageClass(string(obj.StorageClass)),
-				}, objCh)
+				sendObject(ctx, objectFromListing(url, obj), objCh)
 			}
 		}
 
@@ -141,6 +143,21 @@ func (s *S3Store) listObjectsV2(ctx context.Context, url *storage.StorageURL) <-
 	return objCh
 }
 
+// objectFromListing converts an entry of a ListObjectsV2 page that
+// url.Match just accepted to the Object List sends.
+func objectFromListing(url *storage.StorageURL, obj types.Object) *storage.Object {
+	mod := aws.ToTime(obj.LastModified)
+	newURL := url.Clone()
+	newURL.Path = aws.ToString(obj.Key)
+	return &storage.Object{
+		StorageURL:   newURL,
+		Etag:         trimEtag(aws.ToString(obj.ETag)),
+		ModTime:      &mod,
+		Size:         aws.ToInt64(obj.Size),
+		StorageClass: storage.StorageClass(string(obj.StorageClass)),
+	}
+}
+
 // listObjects is the legacy ListObjects (V1) variant, used for
 // S3-compatible services that do not implement V2. Unlike V2 there is no
 // handwritten paginator in the SDK, so we paginate manually via the
@@ -337,9 +354,9 @@ func (s *S3Store) listObjectVersions(ctx context.Context, url *storage.StorageUR
 
 // Copy performs a server-side CopyObject from src to dst, applying the given
 // metadata. The metadata directive defaults to COPY when unset.
-func (s *S3Store) Copy(ctx context.Context, src, dst *storage.StorageURL, metadata storage.Metadata) error {
+func (s *S3Store) Copy(ctx context.Context, src, dst *storage.StorageURL, metadata storage.Metadata) (*storage.Object, error) {
 	if s.dryRun {
-		return nil
+		return &storage.Object{StorageURL: dst}, nil
 	}
 
 	input := &s3.CopyObjectInput{
@@ -370,7 +387,7 @@ func (s *S3Store) Copy(ctx context.Context, src, dst *storage.StorageURL, metada
 	if metadata.Expires != "" {
 		t, err := time.Parse(time.RFC3339, metadata.Expires)
 		if err != nil {
-			return fmt.Errorf("parse expires: %w", err)
+			return nil, fmt.Errorf("parse expires: %w", err)
 		}
 		input.Expires = aws.Time(t)
 	}
@@ -387,8 +404,15 @@ func (s *S3Store) Copy(ctx context.Context, src, dst *storage.StorageURL, metada
 		input.Metadata = metadata.UserDefined
 	}
 
-	_, err := s.client.CopyObject(ctx, input)
-	return err
+	out, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	obj := &storage.Object{StorageURL: dst, VersionID: aws.ToString(out.VersionId)}
+	if out.CopyObjectResult != nil {
+		obj.Etag = trimEtag(aws.ToString(out.CopyObjectResult.ETag))
+	}
+	return obj, nil
 }
 
 // Delete deletes a single S3 object. When the URL carries a VersionID the
@@ -492,9 +516,11 @@ func (s *S3Store) MultiDelete(ctx context.Context, urlch <-chan *storage.Storage
 				if u != nil {
 					u.VersionID = aws.ToString(derr.VersionId)
 				}
+				// Keep the error code visible to errors.As, so callers
+				// can tell a throttled key from a denied one.
 				resultch <- &storage.Object{
 					StorageURL: u,
-					Err:        fmt.Errorf("%s: %s", aws.ToString(derr.Code), aws.ToString(derr.Message)),
+					Err:        &smithy.GenericAPIError{Code: aws.ToString(derr.Code), Message: aws.ToString(derr.Message)},
 				}
 			}
 			for _, k := range c.Keys {
@@ -541,9 +567,9 @@ func (s *S3Store) Get(ctx context.Context, from *storage.StorageURL, to io.Write
 
 // Put uploads reader to the URL using the multipart uploader with the
 // requested concurrency and part size, applying the given metadata.
-func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.StorageURL, metadata storage.Metadata, concurrency int, partSize int64) error {
+func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.StorageURL, metadata storage.Metadata, concurrency int, partSize int64) (*storage.Object, error) {
 	if s.dryRun {
-		return nil
+		return &storage.Object{StorageURL: to}, nil
 	}
 
 	contentType := metadata.ContentType
@@ -573,7 +599,7 @@ func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.Storage
 	if metadata.Expires != "" {
 		t, err := time.Parse(time.RFC3339, metadata.Expires)
 		if err != nil {
-			return fmt.Errorf("parse expires: %w", err)
+			return nil, fmt.Errorf("parse expires: %w", err)
 		}
 		input.Expires = aws.Time(t)
 	}
@@ -601,7 +627,7 @@ func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.Storage
 		input.Metadata[metadataKeyRetryID] = generateRetryID()
 	}
 
-	_, err := s.uploader.Upload(ctx, input, func(u *manager.Uploader) {
+	out, err := s.uploader.Upload(ctx, input, func(u *manager.Uploader) {
 		if partSize > 0 {
 			u.PartSize = partSize
 		}
@@ -619,7 +645,19 @@ func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.Storage
 			}
 		})
 	}
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return uploaded(to, out), nil
+}
+
+// uploaded returns the object the uploader wrote to.
+func uploaded(to *storage.StorageURL, out *manager.UploadOutput) *storage.Object {
+	return &storage.Object{
+		StorageURL: to,
+		Etag:       trimEtag(aws.ToString(out.ETag)),
+		VersionID:  aws.ToString(out.VersionID),
+	}
 }
 
 // retryOnNoSuchUpload handles NoSuchUpload by checking whether a previous
@@ -637,30 +675,34 @@ func (s *S3Store) Put(ctx context.Context, reader io.Reader, to *storage.Storage
 // start before re-uploading. When the body is not seekable (stdin/pipe) a
 // retry would upload truncated data; in that case the original error is
 // returned with a message explaining why no retry happened.
-func (s *S3Store) retryOnNoSuchUpload(ctx context.Context, to *storage.StorageURL, input *s3.PutObjectInput, err error, opts ...func(*manager.Uploader)) error {
+func (s *S3Store) retryOnNoSuchUpload(ctx context.Context, to *storage.StorageURL, input *s3.PutObjectInput, err error, opts ...func(*manager.Uploader)) (*storage.Object, error) {
 	expectedRetryID := input.Metadata[metadataKeyRetryID]
 	seeker, seekable := input.Body.(io.Seeker)
 
+	var out *manager.UploadOutput
 	attempts := 0
 	for errHasCode(err, "NoSuchUpload") && attempts < s.noSuchUploadRetryCount {
 		attempts++
 		obj, sErr := s.Stat(ctx, to)
 		if sErr == nil && obj != nil && obj.RetryID() == expectedRetryID && expectedRetryID != "" {
-			return nil
+			return &storage.Object{StorageURL: to, Etag: obj.Etag}, nil
 		}
 		if !seekable {
-			return fmt.Errorf("RetryOnNoSuchUpload: cannot retry upload of %q: request body is not seekable (e.g. stdin/pipe): %w", to.String(), err)
+			return nil, fmt.Errorf("RetryOnNoSuchUpload: cannot retry upload of %q: request body is not seekable (e.g. stdin/pipe): %w", to.String(), err)
 		}
 		if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
-			return fmt.Errorf("RetryOnNoSuchUpload: rewind request body for retry: %v: %w", seekErr, err)
+			return nil, fmt.Errorf("RetryOnNoSuchUpload: rewind request body for retry: %v: %w", seekErr, err)
 		}
-		_, err = s.uploader.Upload(ctx, input, opts...)
+		out, err = s.uploader.Upload(ctx, input, opts...)
 	}
 
 	if err != nil && errHasCode(err, "NoSuchUpload") && s.noSuchUploadRetryCount > 0 {
-		return fmt.Errorf("RetryOnNoSuchUpload: %d attempts to retry resulted in NoSuchUpload: %w", attempts, err)
+		return nil, fmt.Errorf("RetryOnNoSuchUpload: %d attempts to retry resulted in NoSuchUpload: %w", attempts, err)
 	}
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return uploaded(to, out), nil
 }
 
 // errHasCode reports whether err is (or wraps) an S3 error with the given
@@ -985,6 +1027,9 @@ func (s *S3Store) HeadObject(ctx context.Context, url *storage.StorageURL) (*sto
 		Bucket:       aws.String(url.Bucket),
 		Key:          aws.String(url.Path),
 		RequestPayer: s.requestPayer(),
+		// Ask for stored additional checksums so callers (verify) can
+		// compare content without downloading the object.
+		ChecksumMode: types.ChecksumModeEnabled,
 	}
 	if url.VersionID != "" {
 		input.VersionId = aws.String(url.VersionID)
@@ -1013,12 +1058,37 @@ func (s *S3Store) HeadObject(ctx context.Context, url *storage.StorageURL) (*sto
 		EncryptionMethod:   string(output.ServerSideEncryption),
 		EncryptionKeyID:    aws.ToString(output.SSEKMSKeyId),
 	}
+	if output.ChecksumType == types.ChecksumTypeFullObject || output.ChecksumType == "" {
+		md.ChecksumSHA256 = aws.ToString(output.ChecksumSHA256)
+	}
 	if len(output.Metadata) > 0 {
 		md.UserDefined = output.Metadata
 	}
 	return obj, md, nil
 }
 
+// ObjectTags returns the tag set of the object at url, or of the version
+// url.VersionID names. An object without tags returns an empty map.
+func (s *S3Store) ObjectTags(ctx context.Context, url *storage.StorageURL) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket:       aws.String(url.Bucket),
+		Key:          aws.String(url.Path),
+		RequestPayer: s.requestPayer(),
+	}
+	if url.VersionID != "" {
+		input.VersionId = aws.String(url.VersionID)
+	}
+	output, err := s.client.GetObjectTagging(ctx, input)
+	if err != nil {
+		return nil, statObjectNotFound(url, err)
+	}
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
 // HeadObjectOutput returns the raw SDK HeadObjectOutput for the given
 // bucket/key. It is kept for backwards compatibility with cmd/stat and
 // cmd/du which still read the raw v2 struct.
@@ -1065,6 +1135,24 @@ func (s *S3Store) ListObjectKeysRecursive(ctx context.Context, bucket, prefix st
 // limit); noPaginate stops after the first page so --no-paginate returns at
 // most one page of results.
 func (s *S3Store) ListObjectsWithPagination(ctx context.Context, bucket, key, delimiter string, pageSize int32, noPaginate bool) ([]types.Object, []types.CommonPrefix, error) {
+	var objects []types.Object
+	var prefixes []types.CommonPrefix
+	err := s.ListObjectPages(ctx, bucket, key, delimiter, "", pageSize, func(objs []types.Object, prefs []types.CommonPrefix) bool {
+		objects = append(objects, objs...)
+		prefixes = append(prefixes, prefs...)
+		return !noPaginate
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return objects, prefixes, nil
+}
+
+// ListObjectPages is the streaming form of ListObjectsWithPagination: fn
+// receives each ListObjectsV2 page as it arrives and returns false to stop
+// listing. A non-empty startAfter is forwarded as StartAfter, so the
+// listing begins after that key.
+func (s *S3Store) ListObjectPages(ctx context.Context, bucket, key, delimiter, startAfter string, pageSize int32, fn func([]types.Object, []types.CommonPrefix) bool) error {
 	input := &s3.ListObjectsV2Input{
 		Bucket:       aws.String(bucket),
 		Prefix:       aws.String(key),
@@ -1073,25 +1161,43 @@ func (s *S3Store) ListObjectsWithPagination(ctx context.Context, bucket, key, de
 	if delimiter != "" {
 		input.Delimiter = aws.String(delimiter)
 	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
 	if pageSize > 0 {
 		input.MaxKeys = aws.Int32(pageSize)
 	}
 	paginator := s3.NewListObjectsV2Paginator(s.client, input)
-
-	var objects []types.Object
-	var prefixes []types.CommonPrefix
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		objects = append(objects, page.Contents...)
-		prefixes = append(prefixes, page.CommonPrefixes...)
-		if noPaginate {
-			break
+		if !fn(page.Contents, page.CommonPrefixes) {
+			return nil
 		}
 	}
-	return objects, prefixes, nil
+	return nil
+}
+
+// ListedObject converts an entry of a raw ListObjectsV2 page listed under
+// listURL to a storage.Object, as List would have sent it, so callers of
+// ListObjectsWithPagination can apply the shared object filters. Its
+// relative path is the key below the last "/" of the listed prefix.
+func ListedObject(listURL *storage.StorageURL, obj types.Object) *storage.Object {
+	key := aws.ToString(obj.Key)
+	newURL := listURL.Clone()
+	newURL.Path = key
+	dir := listURL.Path[:strings.LastIndex(listURL.Path, "/")+1]
+	newURL.SetRelativePath(strings.TrimPrefix(key, dir))
+	mod := aws.ToTime(obj.LastModified)
+	return &storage.Object{
+		StorageURL:   newURL,
+		Etag:         trimEtag(aws.ToString(obj.ETag)),
+		ModTime:      &mod,
+		Size:         aws.ToInt64(obj.Size),
+		StorageClass: storage.StorageClass(string(obj.StorageClass)),
+	}
 }
 
 // DeleteObjects deletes the given keys from the bucket in batched
@@ -115,4 +115,11 @@ type S3Option struct {
 	//
 	// Status: implemented.
 	UseAccelerate bool
+
+	// ListShards, when greater than 1, lists recursive ListObjectsV2
+	// listings as that many concurrent shards (see listSharded). It does
+	// not apply to ListObjects (V1) or to version listings.
+	//
+	// Status: implemented.
+	ListShards int
 }
@@ -154,7 +154,7 @@ func TestPut_RetryOnNoSuchUpload_SuccessOnRetry(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 	body := bytes.NewReader([]byte("hello"))
-	err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
 	if err != nil {
 		t.Fatalf("Put: %v", err)
 	}
@@ -192,7 +192,7 @@ func TestPut_NoSuchUpload_RetryGivesUp(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 	body := bytes.NewReader([]byte("hello"))
-	err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
 	if err == nil {
 		t.Fatalf("expected Put to fail with NoSuchUpload after retries")
 	}
@@ -247,7 +247,7 @@ func TestPut_RetryOnNoSuchUpload_RewindsBody(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
-	err = store.Put(context.Background(), bytes.NewReader(content), to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), bytes.NewReader(content), to, storage.Metadata{}, 1, 5*1024*1024)
 	if err != nil {
 		t.Fatalf("Put: %v", err)
 	}
@@ -294,7 +294,7 @@ func TestPut_NoSuchUpload_NonSeekableBody_NoRetry(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 	body := &nonSeekableReader{r: bytes.NewReader([]byte("hello"))}
-	err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
 	if err == nil {
 		t.Fatalf("expected Put to fail for a non-seekable body")
 	}
@@ -365,7 +365,7 @@ func TestPut_NoSuchUpload_StatDetectsSuccess(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 	body := bytes.NewReader([]byte("hello"))
-	err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
 	if err != nil {
 		t.Fatalf("Put should succeed via Stat retry-id match, got %v", err)
 	}
@@ -408,7 +408,7 @@ func TestPut_NoSuchUpload_Disabled(t *testing.T) {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
 	body := bytes.NewReader([]byte("hello"))
-	err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
+	_, err = store.Put(context.Background(), body, to, storage.Metadata{}, 1, 5*1024*1024)
 	if err == nil {
 		t.Fatalf("expected Put to fail with NoSuchUpload")
 	}
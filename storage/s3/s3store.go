@@ -86,6 +86,9 @@ type S3Store struct {
 	// noSuchUploadRetryCount caps the number of times Put retries an upload
 	// that failed with NoSuchUpload. See Put/retryOnNoSuchUpload.
 	noSuchUploadRetryCount int
+	// listShards is the number of concurrent shards of a recursive
+	// ListObjectsV2 listing; 0 and 1 list sequentially.
+	listShards int
 }
 
 // metadataKeyRetryID is the object metadata key that carries the per-upload
@@ -169,6 +172,24 @@ func (r *extendedRetryer) IsErrorRetryable(err error) bool {
 	return r.Retryer.IsErrorRetryable(err)
 }
 
+// abortTimeout bounds the AbortMultipartUpload of a failed upload.
+const abortTimeout = 30 * time.Second
+
+// abortDetachedClient is the uploader's client. The manager aborts a
+// failed multipart upload with the upload's own context, so an upload
+// canceled by Ctrl-C could not abort and left its parts behind (billed
+// until a lifecycle rule removes them). The abort here runs on a context
+// that outlives the cancelation.
+type abortDetachedClient struct {
+	*s3.Client
+}
+
+func (c *abortDetachedClient) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, opts ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	return c.Client.AbortMultipartUpload(ctx, in, opts...)
+}
+
 // resolveUsePathStyle implements the addressing policy:
 //
 //   - An explicit --path-style (true or false, from flag/env/config —
@@ -307,7 +328,7 @@ func NewS3Client(ctx context.Context, option S3Option) (*S3Store, error) {
 		}
 	})
 
-	uploader := manager.NewUploader(client)
+	uploader := manager.NewUploader(&abortDetachedClient{Client: client})
 	downloader := manager.NewDownloader(client)
 	presigner := s3.NewPresignClient(client)
 
@@ -320,6 +341,7 @@ func NewS3Client(ctx context.Context, option S3Option) (*S3Store, error) {
 		useListObjectsV1:       option.UseListObjectsV1,
 		requestPayerFlag:       option.RequestPayer,
 		noSuchUploadRetryCount: option.NoSuchUploadRetryCount,
+		listShards:             option.ListShards,
 	}, nil
 }
 
@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"sync"
@@ -280,7 +282,7 @@ func TestPut_ThenGet(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
-	if err := store.Put(context.Background(), bytes.NewReader(content), u, storage.Metadata{}, 1, 5*1024*1024); err != nil {
+	if _, err := store.Put(context.Background(), bytes.NewReader(content), u, storage.Metadata{}, 1, 5*1024*1024); err != nil {
 		t.Fatalf("Put: %v", err)
 	}
 
@@ -329,7 +331,7 @@ func TestPut_Metadata(t *testing.T) {
 		ContentType: "application/json",
 		UserDefined: map[string]string{"foo": "bar", "baz": "qux"},
 	}
-	if err := store.Put(context.Background(), bytes.NewReader(content), u, md, 1, 5*1024*1024); err != nil {
+	if _, err := store.Put(context.Background(), bytes.NewReader(content), u, md, 1, 5*1024*1024); err != nil {
 		t.Fatalf("Put: %v", err)
 	}
 
@@ -354,6 +356,34 @@ func TestPut_Metadata(t *testing.T) {
 	}
 }
 
+// TestHeadObject_ChecksumMode verifies that HeadObject asks for the stored
+// checksums verify compares, and that a plain Stat does not, since that
+// needs kms:Decrypt on SSE-KMS objects.
+func TestHeadObject_ChecksumMode(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	store := newS3Store(t, srv)
+	backend.makeBucket(t, "head")
+	backend.putTestObject(t, "head", "a.txt", []byte("x"), nil)
+	u, err := storage.NewStorageURL("s3://head/a.txt")
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+
+	if _, _, err := store.HeadObject(context.Background(), u); err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if _, err := store.Stat(context.Background(), u); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	backend.mu.Lock()
+	modes := backend.checksumModes
+	backend.mu.Unlock()
+	if len(modes) != 2 || modes[0] != "ENABLED" || modes[1] != "" {
+		t.Errorf("x-amz-checksum-mode of HeadObject and Stat = %q, want [ENABLED \"\"]", modes)
+	}
+}
+
 // TestPut_LargeObject exercises the multipart upload path: a body larger
 // than PartSize forces the v2 manager.Uploader to use CreateMultipartUpload
 // + UploadPart + CompleteMultipartUpload. The mock implements all three.
@@ -374,7 +404,7 @@ func TestPut_LargeObject(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
-	if err := store.Put(context.Background(), bytes.NewReader(body), u, storage.Metadata{}, 2, 5*1024*1024); err != nil {
+	if _, err := store.Put(context.Background(), bytes.NewReader(body), u, storage.Metadata{}, 2, 5*1024*1024); err != nil {
 		t.Fatalf("Put large: %v", err)
 	}
 
@@ -431,6 +461,48 @@ func TestGet_Range(t *testing.T) {
 	}
 }
 
+// TestPut_CanceledMultipartIsAborted verifies that a multipart upload
+// canceled mid-way (Ctrl-C past the drain timeout) is still aborted: the
+// manager aborts with the upload's context, which is canceled by then.
+func TestPut_CanceledMultipartIsAborted(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var aborted atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && q.Get("uploadId") != "":
+			// The first part is in flight when the run is canceled.
+			cancel()
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == http.MethodDelete && q.Get("uploadId") == "up-1":
+			aborted.Store(true)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+	store := newS3StoreForTest(t, server.URL, 0)
+
+	to, err := storage.NewStorageURL("s3://bucket/key")
+	if err != nil {
+		t.Fatalf("NewStorageURL: %v", err)
+	}
+	const partSize = 5 * 1024 * 1024
+	_, err = store.Put(ctx, bytes.NewReader(make([]byte, partSize+1)), to, storage.Metadata{}, 1, partSize)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Put: err = %v, want context.Canceled", err)
+	}
+	if !aborted.Load() {
+		t.Fatal("the canceled multipart upload was not aborted")
+	}
+}
+
 // =========================================================================
 // Copy tests
 // =========================================================================
@@ -454,7 +526,7 @@ func TestCopy_Object(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
-	if err := store.Copy(context.Background(), src, dst, storage.Metadata{}); err != nil {
+	if _, err := store.Copy(context.Background(), src, dst, storage.Metadata{}); err != nil {
 		t.Fatalf("Copy: %v", err)
 	}
 
@@ -475,6 +547,53 @@ func TestCopy_Object(t *testing.T) {
 	}
 }
 
+// TestObjectTags verifies ObjectTags returns the tag set stored with the
+// object, an empty map for an untagged object and ErrGivenObjectNotFound
+// for a missing key.
+func TestObjectTags(t *testing.T) {
+	t.Parallel()
+	srv, backend := newMockS3Server(t)
+	store := newS3Store(t, srv)
+
+	const bucket = "tags-bucket"
+	backend.makeBucket(t, bucket)
+	backend.putTestObject(t, bucket, "plain.txt", []byte("x"), nil)
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/"+bucket+"/tagged.txt", strings.NewReader("y"))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("x-amz-tagging", "team=data&tier=cold")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("put tagged object: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx := context.Background()
+	tagged, _ := storage.NewStorageURL("s3://" + bucket + "/tagged.txt")
+	tags, err := store.ObjectTags(ctx, tagged)
+	if err != nil {
+		t.Fatalf("ObjectTags: %v", err)
+	}
+	if len(tags) != 2 || tags["team"] != "data" || tags["tier"] != "cold" {
+		t.Errorf("ObjectTags = %v, want team=data tier=cold", tags)
+	}
+
+	plain, _ := storage.NewStorageURL("s3://" + bucket + "/plain.txt")
+	tags, err = store.ObjectTags(ctx, plain)
+	if err != nil {
+		t.Fatalf("ObjectTags untagged: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("ObjectTags untagged = %v, want empty", tags)
+	}
+
+	missing, _ := storage.NewStorageURL("s3://" + bucket + "/missing.txt")
+	if _, err := store.ObjectTags(ctx, missing); !errors.Is(err, errorpkg.ErrGivenObjectNotFound) {
+		t.Errorf("ObjectTags missing: want ErrGivenObjectNotFound, got %v", err)
+	}
+}
+
 // =========================================================================
 // Delete / MultiDelete tests
 // =========================================================================
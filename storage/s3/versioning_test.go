@@ -629,7 +629,7 @@ func TestCopy_KeyWithSpaces(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewStorageURL: %v", err)
 	}
-	if err := store.Copy(context.Background(), src, dst, storage.Metadata{}); err != nil {
+	if _, err := store.Copy(context.Background(), src, dst, storage.Metadata{}); err != nil {
 		t.Fatalf("Copy: %v", err)
 	}
 	got, ok := backend.objects[bucket]["dst.txt"]
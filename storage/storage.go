@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -39,8 +40,9 @@ type Store interface {
 
 	// Copy src to dst, optionally setting the given metadata. Src and dst
 	// arguments are of the same type. If src is a remote type, server side
-	// copying will be used.
-	Copy(ctx context.Context, src, dst *StorageURL, metadata Metadata) error
+	// copying will be used. The returned object is dst as written, with
+	// its ETag and version ID when the backend reports them.
+	Copy(ctx context.Context, src, dst *StorageURL, metadata Metadata) (*Object, error)
 }
 
 // S3Extension is the optional interface that S3-only stores implement. The
@@ -54,8 +56,9 @@ type S3Extension interface {
 	RemoveBucket(ctx context.Context, bucket string) error
 	HeadBucket(ctx context.Context, bucket string) (*Bucket, error)
 	HeadObject(ctx context.Context, url *StorageURL) (*Object, *Metadata, error)
+	ObjectTags(ctx context.Context, url *StorageURL) (map[string]string, error)
 	Get(ctx context.Context, from *StorageURL, to io.WriterAt, concurrency int, partSize int64) (int64, error)
-	Put(ctx context.Context, reader io.Reader, to *StorageURL, metadata Metadata, concurrency int, partSize int64) error
+	Put(ctx context.Context, reader io.Reader, to *StorageURL, metadata Metadata, concurrency int, partSize int64) (*Object, error)
 	Presign(ctx context.Context, url *StorageURL, expire time.Duration) (string, error)
 	Read(ctx context.Context, src *StorageURL) (io.ReadCloser, error)
 	Select(ctx context.Context, url *StorageURL, query *SelectQuery, resultCh chan<- json.RawMessage) error
@@ -115,6 +118,11 @@ type Metadata struct {
 	EncryptionMethod   string
 	EncryptionKeyID    string
 
+	// ChecksumSHA256 is the stored base64 full-object SHA-256 checksum,
+	// when the object has one. It is populated by HeadObject only and is
+	// ignored by Copy/Put.
+	ChecksumSHA256 string
+
 	UserDefined map[string]string
 
 	// MetadataDirective is used to specify whether the metadata is copied from
@@ -153,6 +161,28 @@ func (o ObjectType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(o.String())
 }
 
+// UnmarshalJSON parses the stringer of ObjectType back, for saved
+// listings.
+func (o *ObjectType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	switch s {
+	case "file":
+		o.mode = 0
+	case "directory":
+		o.mode = os.ModeDir
+	case "symlink":
+		o.mode = os.ModeSymlink
+	case "":
+		*o = ObjectType{}
+	default:
+		return fmt.Errorf("unknown object type %q", s)
+	}
+	return nil
+}
+
 // IsDir checks if the object is a directory.
 func (o ObjectType) IsDir() bool {
 	return o.mode.IsDir()
@@ -329,7 +359,7 @@ func (s *Storage) MultiDelete(ctx context.Context, urls <-chan *StorageURL) <-ch
 }
 
 // Copy copies src to dst, optionally applying metadata.
-func (s *Storage) Copy(ctx context.Context, src, dst *StorageURL, metadata Metadata) error {
+func (s *Storage) Copy(ctx context.Context, src, dst *StorageURL, metadata Metadata) (*Object, error) {
 	if src.IsRemote() {
 		return s.remote.Copy(ctx, src, dst, metadata)
 	}
@@ -396,6 +426,15 @@ func (s *Storage) HeadObject(ctx context.Context, url *StorageURL) (*Object, *Me
 	return ext.HeadObject(ctx, url)
 }
 
+// ObjectTags fetches the tag set of the object at the given URL.
+func (s *Storage) ObjectTags(ctx context.Context, url *StorageURL) (map[string]string, error) {
+	ext, err := s.s3ext()
+	if err != nil {
+		return nil, err
+	}
+	return ext.ObjectTags(ctx, url)
+}
+
 // Get downloads the object at the given URL into w using the multipart
 // downloader with the requested concurrency and part size.
 func (s *Storage) Get(ctx context.Context, from *StorageURL, to io.WriterAt, concurrency int, partSize int64) (int64, error) {
@@ -407,10 +446,10 @@ func (s *Storage) Get(ctx context.Context, from *StorageURL, to io.WriterAt, con
 }
 
 // Put uploads the given reader to the URL using the multipart uploader.
-func (s *Storage) Put(ctx context.Context, reader io.Reader, to *StorageURL, metadata Metadata, concurrency int, partSize int64) error {
+func (s *Storage) Put(ctx context.Context, reader io.Reader, to *StorageURL, metadata Metadata, concurrency int, partSize int64) (*Object, error) {
 	ext, err := s.s3ext()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	return ext.Put(ctx, reader, to, metadata, concurrency, partSize)
 }
@@ -443,13 +482,49 @@ func (s *Storage) Select(ctx context.Context, url *StorageURL, query *SelectQuer
 // strings) keep compiling. New code should prefer the StorageURL-based
 // methods above.
 
+// TransferOption configures a DownloadFile or UploadFile.
+type TransferOption func(*transferOptions)
+
+type transferOptions struct {
+	wrap func(*os.File) TransferFile
+}
+
+// TransferFile is the view of the local file a transfer goes through: the
+// uploader reads and seeks it, the downloader writes it at offsets.
+type TransferFile interface {
+	io.Reader
+	io.ReaderAt
+	io.WriterAt
+	io.Seeker
+}
+
+// WithFileWrapper makes the transfer read or write wrap(file) instead of
+// the local file itself, e.g. to count the bytes transferred.
+func WithFileWrapper(wrap func(*os.File) TransferFile) TransferOption {
+	return func(o *transferOptions) {
+		o.wrap = wrap
+	}
+}
+
+// transferFile applies opts to f.
+func transferFile(f *os.File, opts []TransferOption) TransferFile {
+	var o transferOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.wrap == nil {
+		return f
+	}
+	return o.wrap(f)
+}
+
 // DownloadFile downloads an S3 object to a local path (or "-" for stdout).
 // The object is streamed into a temporary file in the destination directory
 // and renamed into place only after a fully successful transfer, so a
 // mid-transfer failure never truncates or replaces an existing file.
 // concurrency and partSize tune the multipart download; values <= 0 fall
 // back to the defaults (manager.DefaultDownloadConcurrency, 10 MiB).
-func (s *Storage) DownloadFile(ctx context.Context, bucketName, objectKey, localFile string, concurrency int, partSize int64) error {
+func (s *Storage) DownloadFile(ctx context.Context, bucketName, objectKey, localFile string, concurrency int, partSize int64, opts ...TransferOption) error {
 	// Dry-run: the remote Get would be a no-op anyway, but the temp-file
 	// + rename dance below would still create (or truncate) localFile, so
 	// bail out before touching the filesystem.
@@ -495,7 +570,7 @@ func (s *Storage) DownloadFile(ctx context.Context, bucketName, objectKey, local
 	if partSize <= 0 {
 		partSize = 10 * 1024 * 1024
 	}
-	_, err = ext.Get(ctx, url, f, concurrency, partSize)
+	_, err = ext.Get(ctx, url, transferFile(f, opts), concurrency, partSize)
 	if closeErr := f.Close(); err == nil {
 		err = closeErr
 	}
@@ -511,7 +586,7 @@ func (s *Storage) DownloadFile(ctx context.Context, bucketName, objectKey, local
 
 // UploadFile uploads a local file to S3. concurrency and partSize tune the
 // multipart upload; values <= 0 fall back to the manager defaults.
-func (s *Storage) UploadFile(ctx context.Context, fileName, bucketName, objectKey string, concurrency int, partSize int64) (*manager.UploadOutput, error) {
+func (s *Storage) UploadFile(ctx context.Context, fileName, bucketName, objectKey string, concurrency int, partSize int64, opts ...TransferOption) (*manager.UploadOutput, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
@@ -531,10 +606,11 @@ func (s *Storage) UploadFile(ctx context.Context, fileName, bucketName, objectKe
 	if partSize <= 0 {
 		partSize = manager.DefaultUploadPartSize
 	}
-	if err := ext.Put(ctx, f, url, Metadata{}, concurrency, partSize); err != nil {
+	obj, err := ext.Put(ctx, transferFile(f, opts), url, Metadata{}, concurrency, partSize)
+	if err != nil {
 		return nil, err
 	}
-	return &manager.UploadOutput{Location: url.String()}, nil
+	return uploadOutput(url, obj), nil
 }
 
 // CopyS3Object performs a server-side CopyObject.
@@ -547,7 +623,8 @@ func (s *Storage) CopyS3Object(ctx context.Context, sourceBucket, sourceKey, des
 	if err != nil {
 		return err
 	}
-	return s.remote.Copy(ctx, src, dst, Metadata{})
+	_, err = s.remote.Copy(ctx, src, dst, Metadata{})
+	return err
 }
 
 // ListS3Keys returns all object keys under (bucket, prefix) recursively.
@@ -628,10 +705,24 @@ func (s *Storage) UploadFromStdin(ctx context.Context, bucketName, objectKey str
 		partSize = manager.DefaultUploadPartSize
 	}
 	stdinReader := &stdin{file: os.Stdin}
-	if err := ext.Put(ctx, stdinReader, url, Metadata{}, concurrency, partSize); err != nil {
+	obj, err := ext.Put(ctx, stdinReader, url, Metadata{}, concurrency, partSize)
+	if err != nil {
 		return nil, err
 	}
-	return &manager.UploadOutput{Location: url.String()}, nil
+	return uploadOutput(url, obj), nil
+}
+
+// uploadOutput converts the object written by Put for the legacy
+// UploadFile/UploadFromStdin return value.
+func uploadOutput(url *StorageURL, obj *Object) *manager.UploadOutput {
+	out := &manager.UploadOutput{Location: url.String()}
+	if obj.Etag != "" {
+		out.ETag = &obj.Etag
+	}
+	if obj.VersionID != "" {
+		out.VersionID = &obj.VersionID
+	}
+	return out
 }
 
 // stdin adapts os.File to io.Reader so the SDK does not attempt to Seek on
@@ -107,3 +107,36 @@ func TestDownloadFileFailurePreservesExisting(t *testing.T) {
 		t.Errorf("destination dir entries = %v, want only [out.txt] (temp file must be removed)", names)
 	}
 }
+
+// countingFile is a TransferFile that counts the bytes written through it.
+type countingFile struct {
+	*os.File
+	written int
+}
+
+func (c *countingFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := c.File.WriteAt(p, off)
+	c.written += n
+	return n, err
+}
+
+// TestDownloadFileWithFileWrapper verifies that the download writes through
+// the file wrapper.
+func TestDownloadFileWithFileWrapper(t *testing.T) {
+	t.Parallel()
+	content := []byte("counted content")
+	store := NewStorage(&fakeRemote{data: content}, nil)
+
+	var wrapped *countingFile
+	wrap := WithFileWrapper(func(f *os.File) TransferFile {
+		wrapped = &countingFile{File: f}
+		return wrapped
+	})
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	if err := store.DownloadFile(context.Background(), "bucket", "key.txt", dst, 0, 0, wrap); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if wrapped == nil || wrapped.written != len(content) {
+		t.Errorf("wrapper saw %+v, want %d bytes written", wrapped, len(content))
+	}
+}
@@ -52,6 +52,11 @@ type StorageURL struct {
 	filter       string
 	filterRegex  *regexp.Regexp
 	raw          bool
+	ignoreFiles  []string
+	unordered    bool
+	inventory    string
+	listing      string
+	listingCheck int
 }
 
 type Option func(u *StorageURL)
@@ -74,6 +79,43 @@ func WithAllVersions(isAllVersions bool) Option {
 	}
 }
 
+// WithIgnoreFiles makes a local directory walk of the URL honor the
+// per-directory ignore files with the given names (see package ignore).
+func WithIgnoreFiles(names []string) Option {
+	return func(u *StorageURL) {
+		u.ignoreFiles = names
+	}
+}
+
+// WithUnordered lets a sharded listing of the URL send objects as the
+// shards find them instead of in key order, for callers that do not
+// depend on the order.
+func WithUnordered(unordered bool) Option {
+	return func(u *StorageURL) {
+		u.unordered = unordered
+	}
+}
+
+// WithInventory makes a listing of the URL read the S3 Inventory report
+// whose manifest.json is at manifest, an s3:// URL or a local path,
+// instead of listing the bucket.
+func WithInventory(manifest string) Option {
+	return func(u *StorageURL) {
+		u.inventory = manifest
+	}
+}
+
+// WithListing makes a listing of the URL read the listing ls
+// --save-listing saved at path instead of listing the bucket. When check is
+// positive, check of the saved objects are compared with the live bucket
+// first and the listing is refused if any of them changed.
+func WithListing(path string, check int) Option {
+	return func(u *StorageURL) {
+		u.listing = path
+		u.listingCheck = check
+	}
+}
+
 // New creates a new StorageURL from given path string.
 func NewStorageURL(s string, opts ...Option) (*StorageURL, error) {
 	scheme, rest, isFound := strings.Cut(s, "://")
@@ -319,6 +361,11 @@ func (u *StorageURL) Clone() *StorageURL {
 		filter:       u.filter,
 		filterRegex:  u.filterRegex,
 		raw:          u.raw,
+		ignoreFiles:  u.ignoreFiles,
+		unordered:    u.unordered,
+		inventory:    u.inventory,
+		listing:      u.listing,
+		listingCheck: u.listingCheck,
 	}
 }
 
@@ -400,6 +447,21 @@ func (u StorageURL) MarshalJSON() ([]byte, error) {
 	return json.Marshal(u.String())
 }
 
+// UnmarshalJSON parses the URL MarshalJSON wrote. Its glob characters are
+// taken literally.
+func (u *StorageURL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := NewStorageURL(s, WithRaw(true))
+	if err != nil {
+		return err
+	}
+	*u = *parsed
+	return nil
+}
+
 func (u StorageURL) ToBytes() []byte {
 	buf := bytes.NewBuffer(make([]byte, 0))
 	enc := gob.NewEncoder(buf)
@@ -430,6 +492,31 @@ func (u *StorageURL) IsWildcard() bool {
 	return !u.raw && hasGlobCharacter(u.Path)
 }
 
+// IgnoreFiles returns the names of the ignore files a walk of the URL
+// honors.
+func (u *StorageURL) IgnoreFiles() []string {
+	return u.ignoreFiles
+}
+
+// Unordered reports whether a listing of the URL may send objects out of
+// key order.
+func (u *StorageURL) Unordered() bool {
+	return u.unordered
+}
+
+// Inventory returns the manifest of the S3 Inventory report a listing of
+// the URL reads, or "" to list the bucket.
+func (u *StorageURL) Inventory() string {
+	return u.inventory
+}
+
+// Listing returns the path of the saved listing a listing of the URL reads,
+// or "" to list the bucket, and how many of its objects are checked
+// against the bucket first.
+func (u *StorageURL) Listing() (path string, check int) {
+	return u.listing, u.listingCheck
+}
+
 func (u *StorageURL) IsRaw() bool {
 	return u.raw
 }
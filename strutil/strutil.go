@@ -53,6 +53,22 @@ func JSON(v interface{}) string {
 	return string(bytes)
 }
 
+// SchemaJSON encodes the struct v like JSON, with a leading "schema" field
+// set to schema, e.g. {"schema":"ls.object/v1","key":...}. Every JSON line
+// of --output json is built this way, so scripts can tell the message
+// types apart and see a breaking change in the version suffix.
+func SchemaJSON(schema string, v interface{}) string {
+	head := `{"schema":` + JSON(schema)
+	body := JSON(v)
+	if len(body) < 2 || body[0] != '{' {
+		return body
+	}
+	if body == "{}" {
+		return head + "}"
+	}
+	return head + "," + body[1:]
+}
+
 // CapitalizeFirstRune converts first rune to uppercase, and converts rest of
 // the string to lower case.
 func CapitalizeFirstRune(str string) string {
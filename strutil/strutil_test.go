@@ -179,6 +179,26 @@ func TestJSON(t *testing.T) {
 	}
 }
 
+func TestSchemaJSON(t *testing.T) {
+	t.Parallel()
+	type msg struct {
+		Key  string `json:"key"`
+		Size int64  `json:"size,omitempty"`
+	}
+	for _, tc := range []struct {
+		in   interface{}
+		want string
+	}{
+		{msg{Key: "a", Size: 1}, `{"schema":"x/v1","key":"a","size":1}`},
+		{struct{}{}, `{"schema":"x/v1"}`},
+		{[]int{1}, `[1]`},
+	} {
+		if got := SchemaJSON("x/v1", tc.in); got != tc.want {
+			t.Errorf("SchemaJSON(%#v) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
 func TestTrimQuotes(t *testing.T) {
 	testCases := []struct {
 		in   string